@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/backup"
+	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+	"github.com/morzhanov/binance-orders-watcher/internal/checker"
+	"github.com/morzhanov/binance-orders-watcher/internal/client"
+	"github.com/morzhanov/binance-orders-watcher/internal/config"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/klines"
+)
+
+type command func(conf *config.Config, dbClient db.Client, args []string) error
+
+var commands = map[string]command{
+	"backup":   backupCommand,
+	"restore":  restoreCommand,
+	"export":   exportCommand,
+	"import":   importCommand,
+	"backtest": backtestCommand,
+}
+
+func runCommand(name string, conf *config.Config, dbClient db.Client, args []string) {
+	cmd, ok := commands[name]
+	if !ok {
+		log.Fatalf("unknown command %s", name)
+	}
+	if err := cmd(conf, dbClient, args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func pathArg(args []string, usage string) (string, error) {
+	if len(args) != 1 || args[0] == "" {
+		return "", fmt.Errorf("usage: app %s", usage)
+	}
+	return args[0], nil
+}
+
+func backupCommand(_ *config.Config, dbClient db.Client, args []string) error {
+	path, err := pathArg(args, "backup <file>")
+	if err != nil {
+		return err
+	}
+	backuper, ok := dbClient.(db.Backuper)
+	if !ok {
+		return db.ErrBackupNotSupported
+	}
+	if err = backuper.Backup(path); err != nil {
+		return err
+	}
+	log.Printf("database backed up to %s", path)
+	return nil
+}
+
+func restoreCommand(_ *config.Config, dbClient db.Client, args []string) error {
+	path, err := pathArg(args, "restore <file>")
+	if err != nil {
+		return err
+	}
+	if _, err = os.Stat(path); err != nil {
+		return err
+	}
+	backuper, ok := dbClient.(db.Backuper)
+	if !ok {
+		return db.ErrBackupNotSupported
+	}
+	if err = backuper.Restore(path); err != nil {
+		return err
+	}
+	log.Printf("database restored from %s", path)
+	return nil
+}
+
+func exportCommand(_ *config.Config, dbClient db.Client, args []string) error {
+	path, err := pathArg(args, "export <file.json>")
+	if err != nil {
+		return err
+	}
+	snapshot, err := backup.Export(dbClient)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = backup.WriteSnapshot(file, snapshot); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	log.Printf("exported %d alerts and %d users to %s", len(snapshot.Alerts), len(snapshot.Users), path)
+	return nil
+}
+
+func importCommand(_ *config.Config, dbClient db.Client, args []string) error {
+	path, err := pathArg(args, "import <file.json>")
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	snapshot, err := backup.ReadSnapshot(file)
+	if err != nil {
+		return errors.New("failed to read snapshot: " + err.Error())
+	}
+	if err = backup.Import(dbClient, snapshot, client.ValidateAlert); err != nil {
+		return err
+	}
+	log.Printf("imported data from %s", path)
+	return nil
+}
+
+// backtestCommand prints every time a price alert would have fired, klines are downloaded once and cached in the db.
+func backtestCommand(conf *config.Config, dbClient db.Client, args []string) error {
+	flags := flag.NewFlagSet("backtest", flag.ContinueOnError)
+	down := flags.Bool("down", false, "alert when the price falls to the limit")
+	interval := flags.String("interval", "1h", "candle interval")
+	days := flags.Int("days", 30, "number of days to replay")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return errors.New("usage: app backtest [-down] [-interval 1h] [-days 30] <symbol> <price>")
+	}
+	if _, ok := klines.Intervals[*interval]; !ok {
+		return fmt.Errorf("unsupported interval %q", *interval)
+	}
+	alert := &db.Alert{Type: db.AlertTypePrice, Symbol: strings.ToUpper(flags.Arg(0)), Price: flags.Arg(1), DirectionDown: *down}
+
+	cache := klines.New(binance.New("", "", conf.BinProdURI), dbClient)
+	to := time.Now()
+	items, err := cache.Get(alert.Symbol, *interval, to.Add(-time.Duration(*days)*24*time.Hour), to)
+	if err != nil {
+		return err
+	}
+	triggers, err := checker.Backtest(alert, items)
+	if err != nil {
+		return err
+	}
+	for _, t := range triggers {
+		fmt.Printf("%s\t%s\n", time.UnixMilli(t.Time).Format(time.RFC3339), t.Price)
+	}
+	log.Printf("alert %s %s would have fired %d times in %d %s candles", alert.Symbol, alert.Price, len(triggers), len(items), *interval)
+	return nil
+}
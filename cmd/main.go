@@ -2,18 +2,36 @@ package main
 
 import (
 	"log"
+	"net/http"
+	"os"
+	"time"
 
 	"github.com/morzhanov/binance-orders-watcher/internal/alertmanager"
+	"github.com/morzhanov/binance-orders-watcher/internal/backup"
 	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+	"github.com/morzhanov/binance-orders-watcher/internal/bybit"
+	"github.com/morzhanov/binance-orders-watcher/internal/cassette"
+	"github.com/morzhanov/binance-orders-watcher/internal/certs"
 	"github.com/morzhanov/binance-orders-watcher/internal/checker"
 	"github.com/morzhanov/binance-orders-watcher/internal/client"
 	"github.com/morzhanov/binance-orders-watcher/internal/config"
 	"github.com/morzhanov/binance-orders-watcher/internal/cron"
 	"github.com/morzhanov/binance-orders-watcher/internal/db"
 	"github.com/morzhanov/binance-orders-watcher/internal/debug"
+	"github.com/morzhanov/binance-orders-watcher/internal/events"
+	"github.com/morzhanov/binance-orders-watcher/internal/exchange"
 	"github.com/morzhanov/binance-orders-watcher/internal/fetcher"
+	"github.com/morzhanov/binance-orders-watcher/internal/klines"
+	"github.com/morzhanov/binance-orders-watcher/internal/simulator"
+	"github.com/morzhanov/binance-orders-watcher/internal/trader"
 )
 
+// defaultBinanceURI is used to replay cassettes when BINANCE_PRODUCTION_URI is not set.
+const defaultBinanceURI = "https://api.binance.com"
+
+// defaultACMEHTTPPort is the port HTTP-01 challenges are validated on.
+const defaultACMEHTTPPort = "80"
+
 func main() {
 	if debug.IsDebug() {
 		log.Println("app started in debug mode: database will not be cleared and cron will not be run")
@@ -23,17 +41,76 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	alertManager := alertmanager.New(conf.MailjetApiKey, conf.MailjetApiSecret, conf.MailjetSenderName, conf.MailjetSenderEmail)
-	dbClient, err := db.NewClient()
+	dbClient, err := db.NewClient(conf.DBDriver, conf.DBDSN)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(os.Args) > 1 && !debug.IsDebug() {
+		runCommand(os.Args[1], conf, dbClient, os.Args[2:])
+		return
+	}
+
+	accountsConf, err := conf.Accounts()
+	if err != nil {
+		log.Fatal(err)
+	}
+	binHTTPClient, binURI, err := binanceHTTPClient(conf)
 	if err != nil {
 		log.Fatal(err)
 	}
-	binClient := binance.New(conf.BinApiKey, conf.BinApiSecret, conf.BinProdURI)
-	fetcherClient := fetcher.New(binClient, dbClient)
-	checkerClient := checker.New(dbClient, alertManager)
+	bybitHTTPClient := &http.Client{Timeout: bybit.DefaultTimeout}
+	var accounts []*exchange.Account
+	var binanceAccounts []*binance.Account
+	var accountIDs []string
+	intervals := make(map[string]time.Duration, len(accountsConf))
+	for _, a := range accountsConf {
+		switch a.Exchange {
+		case exchange.Bybit:
+			accounts = append(accounts, &exchange.Account{ID: a.ID, Driver: bybit.New(a.ApiKey, a.ApiSecret, conf.BybitURI, bybitHTTPClient)})
+		default:
+			accountClient := binance.NewWithHTTPClient(a.ApiKey, a.ApiSecret, binURI, binHTTPClient)
+			accounts = append(accounts, &exchange.Account{ID: a.ID, Driver: binance.NewDriver(accountClient)})
+			binanceAccount := &binance.Account{ID: a.ID, Client: accountClient}
+			if a.Futures {
+				binanceAccount.Futures = binance.NewFutures(a.ApiKey, a.ApiSecret, conf.BinFuturesURI, binHTTPClient)
+			}
+			if a.Margin {
+				binanceAccount.Margin = binance.NewMargin(a.ApiKey, a.ApiSecret, binURI, binHTTPClient)
+			}
+			binanceAccounts = append(binanceAccounts, binanceAccount)
+		}
+		accountIDs = append(accountIDs, a.ID)
+		intervals[a.ID] = a.FetchInterval
+	}
+	// market data endpoints are public and do not need account keys
+	binClient := binance.NewWithHTTPClient("", "", binURI, binHTTPClient)
+
+	alertManager := alertmanager.New(conf.MailjetApiKey, conf.MailjetApiSecret, conf.MailjetSenderName, conf.MailjetSenderEmail)
+	exchangeInfo := binance.NewExchangeInfoCache(binClient)
+	go exchangeInfo.Run(binance.DefaultExchangeInfoRefreshInterval)
+	bus := events.NewBus()
+	eventRetention := fetcher.DefaultEventRetention
+	if conf.EventRetention != "" {
+		if eventRetention, err = time.ParseDuration(conf.EventRetention); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fetcherClient := fetcher.New(accounts, binanceAccounts, dbClient, bus, eventRetention)
+	checkerClient := checker.New(dbClient, alertManager, bus)
 
-	cronClient := cron.New(fetcherClient, checkerClient)
-	cl := client.New(conf.BaseAuthUsername, conf.BaseAuthPassword, conf.BaseAuthSecret, conf.AppURI, conf.AppSchema, conf.AppPort, conf.MailjetSenderName, conf.MailjetSenderEmail, dbClient, fetcherClient, checkerClient, alertManager)
+	cronClient := cron.New(fetcherClient, checkerClient, intervals)
+	sessionIdleTimeout := client.DefaultSessionIdleTimeout
+	if conf.SessionIdleTimeout != "" {
+		sessionIdleTimeout, err = time.ParseDuration(conf.SessionIdleTimeout)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	trustedProxies, err := client.ParseNetworks(conf.TrustedProxies)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cl := client.New(conf.BaseAuthUsername, conf.BaseAuthPassword, conf.BaseAuthSecret, sessionIdleTimeout, trustedProxies, conf.AppURI, conf.AppSchema, conf.AppPort, conf.MailjetSenderName, conf.MailjetSenderEmail, dbClient, fetcherClient, checkerClient, alertManager, bus, klines.New(binClient, dbClient), trader.New(binanceAccounts, exchangeInfo, dbClient), simulator.New(dbClient, exchangeInfo), exchangeInfo, accountIDs)
 
 	go func() {
 		if debug.IsDebug() {
@@ -45,7 +122,90 @@ func main() {
 			log.Fatal(err)
 		}
 	}()
-	if err = cl.Run(conf.AppTlsCertPath, conf.AppTlsKeyPath); err != nil {
+	if conf.BackupDir != "" {
+		startBackupScheduler(conf, dbClient)
+	}
+	certSource, httpPort, err := tlsCertSource(conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = cl.Run(certSource, httpPort); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// tlsCertSource returns the certificates of the HTTPS server and the port of the HTTP server. With ACME_ENABLED
+// the certificates of APP_URI are obtained from the ACME CA, which validates the domain over HTTP on port 80.
+func tlsCertSource(conf *config.Config) (certs.Source, string, error) {
+	if conf.AppSchema != client.AppSchemaHTTPS {
+		return nil, "", nil
+	}
+	switch {
+	case conf.ACMEEnabled:
+		source, err := certs.NewACME(&certs.ACMEConfig{
+			Domains:      []string{conf.AppURI},
+			Email:        conf.ACMEEmail,
+			DirectoryURL: conf.ACMEDirectoryURL,
+			CACertPath:   conf.ACMECACertPath,
+			CacheDir:     conf.ACMECacheDir,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		httpPort := conf.AppHTTPPort
+		if httpPort == "" {
+			httpPort = defaultACMEHTTPPort
+		}
+		log.Printf("obtaining TLS certificates of %s with ACME", conf.AppURI)
+		return source, httpPort, nil
+	case conf.AppTlsCertPath != "" && conf.AppTlsKeyPath != "":
+		source, err := certs.NewFiles(conf.AppTlsCertPath, conf.AppTlsKeyPath, certs.DefaultCheckInterval)
+		return source, conf.AppHTTPPort, err
+	default:
+		return nil, "", nil
+	}
+}
+
+func startBackupScheduler(conf *config.Config, dbClient db.Client) {
+	backuper, ok := dbClient.(db.Backuper)
+	if !ok {
+		log.Println("scheduled backups are disabled: ", db.ErrBackupNotSupported)
+		return
+	}
+	interval := backup.DefaultInterval
+	if conf.BackupInterval != "" {
+		parsed, err := time.ParseDuration(conf.BackupInterval)
+		if err != nil {
+			log.Fatal(err)
+		}
+		interval = parsed
+	}
+	scheduler := backup.NewScheduler(backuper, conf.BackupDir, interval, conf.BackupKeep)
+	go func() {
+		log.Printf("starting backup scheduler, interval %s...", interval)
+		scheduler.Run()
+	}()
+}
+
+// binanceHTTPClient returns the http client of the spot Binance clients and their base URI. With BINANCE_REPLAY
+// the watcher runs offline from a recorded cassette, with BINANCE_RECORD the traffic is saved to a cassette.
+func binanceHTTPClient(conf *config.Config) (*http.Client, string, error) {
+	switch {
+	case conf.BinReplay != "":
+		c, err := cassette.Load(conf.BinReplay)
+		if err != nil {
+			return nil, "", err
+		}
+		log.Printf("replaying Binance responses from %s", conf.BinReplay)
+		uri := conf.BinProdURI
+		if uri == "" {
+			uri = defaultBinanceURI
+		}
+		return &http.Client{Transport: cassette.NewReplayer(c)}, uri, nil
+	case conf.BinRecord != "":
+		log.Printf("recording Binance responses to %s", conf.BinRecord)
+		return &http.Client{Transport: cassette.NewRecorder(conf.BinRecord, nil)}, conf.BinProdURI, nil
+	default:
+		return http.DefaultClient, conf.BinProdURI, nil
+	}
+}
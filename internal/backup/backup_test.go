@@ -0,0 +1,184 @@
+package backup
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/decimal"
+)
+
+// validatePrice stands in for the alert validation of the client package.
+func validatePrice(alert *db.Alert) error {
+	_, err := decimal.Parse(alert.Price)
+	return err
+}
+
+func TestExportImport(t *testing.T) {
+	src := db.NewMemoryClient()
+	users := []*db.User{
+		{ID: "src-admin", Username: "admin", Role: db.RoleAdmin, PasswordHash: "admin-hash"},
+		{ID: "alice", Username: "alice", Role: db.RoleTrader, Email: "alice@example.com", PasswordHash: "alice-hash", TOTPSecret: "secret", TOTPEnabled: true, RecoveryCodes: []string{"code"}},
+	}
+	for _, u := range users {
+		if err := src.AddUser(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, a := range []*db.Alert{
+		{ID: "1", Symbol: "BTCUSDT", Price: "40000", Email: "john@example.com"},
+		{ID: "2", UserID: "alice", Type: db.AlertTypePrice, Symbol: "ETHUSDT", Price: "3000"},
+	} {
+		if err := src.AddAlert(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := src.AddAPIToken(&db.APIToken{ID: "token", UserID: "src-admin", Name: "script", TokenHash: "token-hash", Scopes: []string{"alerts:read"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.AddIPBan(&db.IPBan{ID: "ban", Network: "10.0.0.0/8", CreatedBy: "admin"}); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := Export(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = WriteSnapshot(&buf, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the new host created its first admin on start
+	dest := db.NewMemoryClient()
+	if err = dest.AddUser(&db.User{ID: "dest-admin", Username: "admin", Role: db.RoleAdmin, PasswordHash: "new-hash"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = dest.AddAlert(&db.Alert{ID: "1", Symbol: "BTCUSDT", Price: "1", Email: "john@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = Import(dest, read, validatePrice); err != nil {
+		t.Fatal(err)
+	}
+	// importing twice must not duplicate anything
+	if err = Import(dest, read, validatePrice); err != nil {
+		t.Fatal(err)
+	}
+
+	alerts, err := dest.GetAlerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 2 || alerts[0].Price != "1" || alerts[1].UserID != "alice" {
+		t.Fatalf("existing alert should be kept and the new one added, got %+v", alerts)
+	}
+	alice, err := dest.GetUser("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice == nil || alice.PasswordHash != "alice-hash" || alice.TOTPSecret != "secret" || !alice.TOTPEnabled || len(alice.RecoveryCodes) != 1 {
+		t.Fatalf("user credentials were not imported: %+v", alice)
+	}
+	if admin, _ := dest.GetUserByUsername("admin"); admin.ID != "dest-admin" || admin.PasswordHash != "new-hash" {
+		t.Fatalf("the existing admin should be kept, got %+v", admin)
+	}
+	if tokens, _ := dest.GetAPITokens(); len(tokens) != 1 || tokens[0].UserID != "dest-admin" || tokens[0].TokenHash != "token-hash" {
+		t.Fatalf("tokens of merged users should move to the existing user, got %+v", tokens)
+	}
+	if bans, _ := dest.GetIPBans(); len(bans) != 1 || bans[0].Network != "10.0.0.0/8" {
+		t.Fatalf("unexpected bans %+v", bans)
+	}
+}
+
+func TestImportRejectsInvalidRecords(t *testing.T) {
+	user := &User{User: db.User{ID: "bob", Username: "bob", Role: db.RoleTrader}, PasswordHash: "hash"}
+	for name, snapshot := range map[string]*Snapshot{
+		"invalid price":      {Alerts: []*db.Alert{{ID: "1", Symbol: "BTCUSDT", Price: "N/A", Email: "john@example.com"}}},
+		"no recipient":       {Alerts: []*db.Alert{{ID: "1", UserID: "bob", Symbol: "BTCUSDT", Price: "1"}}},
+		"unknown owner":      {Alerts: []*db.Alert{{ID: "1", UserID: "carol", Symbol: "BTCUSDT", Price: "1", Email: "john@example.com"}}},
+		"unknown token user": {APITokens: []*APIToken{{APIToken: db.APIToken{ID: "token", UserID: "carol"}, TokenHash: "hash"}}},
+		"invalid network":    {IPBans: []*db.IPBan{{ID: "ban", Network: "10.0.0.1"}}},
+	} {
+		snapshot.Version = SnapshotVersion
+		snapshot.Users = []*User{user}
+		dest := db.NewMemoryClient()
+		if err := Import(dest, snapshot, validatePrice); err == nil {
+			t.Fatalf("%s: expected the snapshot to be rejected", name)
+		}
+		if users, _ := dest.GetUsers(); len(users) != 0 {
+			t.Fatalf("%s: nothing should be imported from a rejected snapshot, got %+v", name, users)
+		}
+	}
+}
+
+func TestImportVersion1(t *testing.T) {
+	snapshot, err := ReadSnapshot(strings.NewReader(`{"version": 1, "alerts": [{"id": "1", "symbol": "BTCUSDT", "price": "40000", "email": "john@example.com"}], "authRequests": [{"ip": "10.0.0.1", "attempts": 3}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := db.NewMemoryClient()
+	if err = Import(dest, snapshot, validatePrice); err != nil {
+		t.Fatal(err)
+	}
+	if alerts, _ := dest.GetAlerts(); len(alerts) != 1 || alerts[0].ID != "1" {
+		t.Fatalf("alerts of version 1 snapshots should be imported, got %+v", alerts)
+	}
+}
+
+func TestImportRejectsUnknownVersion(t *testing.T) {
+	if err := Import(db.NewMemoryClient(), &Snapshot{Version: 42}, validatePrice); err == nil {
+		t.Fatal("expected version error")
+	}
+}
+
+type fakeBackuper struct{}
+
+func (fakeBackuper) Backup(path string) error {
+	return os.WriteFile(path, []byte("backup"), 0600)
+}
+
+func (fakeBackuper) Restore(string) error {
+	return nil
+}
+
+func TestSchedulerRotation(t *testing.T) {
+	dir := t.TempDir()
+	for _, ts := range []string{"20220101-000000", "20220102-000000", "20220103-000000"} {
+		if err := os.WriteFile(filepath.Join(dir, filePrefix+ts+fileExtension), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := NewScheduler(fakeBackuper{}, dir, time.Hour, 2).BackupNow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	want := []string{filePrefix + "20220103-000000" + fileExtension, filepath.Base(path), "notes.txt"}
+	if len(names) != len(want) {
+		t.Fatalf("got %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("got %v, want %v", names, want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+// SnapshotVersion 2 dropped the auth request counters and 3 added users, API tokens and IP bans,
+// older snapshots are imported with the records they have.
+const SnapshotVersion = 3
+
+// Snapshot is the portable JSON export of the watcher state. Orders and prices are not
+// exported, they are fetched from Binance again on the new host. Sessions are not exported either,
+// users log in again on the new host.
+type Snapshot struct {
+	Version   int         `json:"version"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Alerts    []*db.Alert `json:"alerts"`
+	Users     []*User     `json:"users"`
+	APITokens []*APIToken `json:"apiTokens"`
+	IPBans    []*db.IPBan `json:"ipBans"`
+}
+
+// User keeps the password hash and the two-factor secrets, which are hidden from API responses.
+type User struct {
+	db.User
+	PasswordHash  string   `json:"passwordHash"`
+	TOTPSecret    string   `json:"totpSecret"`
+	TOTPLastStep  int64    `json:"totpLastStep"`
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// APIToken keeps the token hash so scripts keep working on the new host.
+type APIToken struct {
+	db.APIToken
+	TokenHash string `json:"tokenHash"`
+}
+
+// AlertValidator checks an imported alert with the rules of the alert endpoints, it may normalize the alert.
+type AlertValidator func(alert *db.Alert) error
+
+func Export(dbClient db.Client) (*Snapshot, error) {
+	log.Println("exporting watcher state...")
+	alerts, err := dbClient.GetAlerts()
+	if err != nil {
+		return nil, err
+	}
+	users, err := dbClient.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := dbClient.GetAPITokens()
+	if err != nil {
+		return nil, err
+	}
+	bans, err := dbClient.GetIPBans()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{
+		Version:   SnapshotVersion,
+		CreatedAt: time.Now().UTC(),
+		Alerts:    alerts,
+		Users:     make([]*User, 0, len(users)),
+		APITokens: make([]*APIToken, 0, len(tokens)),
+		IPBans:    bans,
+	}
+	for _, u := range users {
+		snapshot.Users = append(snapshot.Users, &User{User: *u, PasswordHash: u.PasswordHash, TOTPSecret: u.TOTPSecret, TOTPLastStep: u.TOTPLastStep, RecoveryCodes: u.RecoveryCodes})
+	}
+	for _, t := range tokens {
+		snapshot.APITokens = append(snapshot.APITokens, &APIToken{APIToken: *t, TokenHash: t.TokenHash})
+	}
+	return snapshot, nil
+}
+
+// Import merges the snapshot into the storage. Every record is checked before anything is stored, so a broken
+// file changes nothing. Records with already known IDs are skipped and snapshot users with the username of
+// a stored user are merged into it, e.g. into the admin created on the first start of the new host.
+func Import(dbClient db.Client, snapshot *Snapshot, validateAlert AlertValidator) error {
+	if snapshot.Version < 1 || snapshot.Version > SnapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", snapshot.Version)
+	}
+	log.Printf("importing %d alerts, %d users, %d API tokens and %d IP bans...", len(snapshot.Alerts), len(snapshot.Users), len(snapshot.APITokens), len(snapshot.IPBans))
+
+	storedUsers, err := dbClient.GetUsers()
+	if err != nil {
+		return err
+	}
+	users := make(map[string]*db.User, len(storedUsers))
+	usernames := make(map[string]*db.User, len(storedUsers))
+	for _, u := range storedUsers {
+		users[u.ID], usernames[u.Username] = u, u
+	}
+	// userIDs maps snapshot user IDs to the IDs of the stored users they were merged into
+	userIDs := make(map[string]string)
+	var newUsers []*db.User
+	for _, u := range snapshot.Users {
+		if u.ID == "" || u.Username == "" {
+			return fmt.Errorf("user %q has no id or username", u.Username)
+		}
+		if users[u.ID] != nil {
+			continue
+		}
+		if existing := usernames[u.Username]; existing != nil {
+			userIDs[u.ID] = existing.ID
+			continue
+		}
+		user := u.User
+		user.PasswordHash, user.TOTPSecret, user.TOTPLastStep, user.RecoveryCodes = u.PasswordHash, u.TOTPSecret, u.TOTPLastStep, u.RecoveryCodes
+		users[user.ID], usernames[user.Username] = &user, &user
+		newUsers = append(newUsers, &user)
+	}
+	ownerID := func(id string) string {
+		if merged, ok := userIDs[id]; ok {
+			return merged
+		}
+		return id
+	}
+
+	storedAlerts, err := dbClient.GetAlerts()
+	if err != nil {
+		return err
+	}
+	alertIDs := make(map[string]bool, len(storedAlerts))
+	for _, a := range storedAlerts {
+		alertIDs[a.ID] = true
+	}
+	var newAlerts []*db.Alert
+	for _, a := range snapshot.Alerts {
+		if a.ID == "" {
+			return fmt.Errorf("alert for symbol %s has no id", a.Symbol)
+		}
+		if alertIDs[a.ID] {
+			continue
+		}
+		alert := *a
+		alert.UserID = ownerID(a.UserID)
+		if err = validateAlert(&alert); err != nil {
+			return fmt.Errorf("alert %s: %w", a.ID, err)
+		}
+		owner := users[alert.UserID]
+		if alert.UserID != "" && owner == nil {
+			return fmt.Errorf("alert %s: user %s is not found", a.ID, alert.UserID)
+		}
+		// alerts are sent to the owner email, the alert email is needed when the owner has none
+		if alert.Email == "" && (owner == nil || owner.Email == "") {
+			return fmt.Errorf("alert %s: email is required because its user has no email", a.ID)
+		}
+		alertIDs[alert.ID] = true
+		newAlerts = append(newAlerts, &alert)
+	}
+
+	storedTokens, err := dbClient.GetAPITokens()
+	if err != nil {
+		return err
+	}
+	tokenIDs := make(map[string]bool, len(storedTokens))
+	tokenHashes := make(map[string]bool, len(storedTokens))
+	for _, t := range storedTokens {
+		tokenIDs[t.ID], tokenHashes[t.TokenHash] = true, true
+	}
+	var newTokens []*db.APIToken
+	for _, t := range snapshot.APITokens {
+		if t.ID == "" || t.TokenHash == "" {
+			return fmt.Errorf("API token %q has no id or hash", t.Name)
+		}
+		if tokenIDs[t.ID] || tokenHashes[t.TokenHash] {
+			continue
+		}
+		token := t.APIToken
+		token.TokenHash = t.TokenHash
+		token.UserID = ownerID(t.UserID)
+		if users[token.UserID] == nil {
+			return fmt.Errorf("API token %s: user %s is not found", t.ID, token.UserID)
+		}
+		tokenIDs[token.ID], tokenHashes[token.TokenHash] = true, true
+		newTokens = append(newTokens, &token)
+	}
+
+	storedBans, err := dbClient.GetIPBans()
+	if err != nil {
+		return err
+	}
+	bannedNetworks := make(map[string]bool, len(storedBans))
+	banIDs := make(map[string]bool, len(storedBans))
+	for _, b := range storedBans {
+		banIDs[b.ID], bannedNetworks[b.Network] = true, true
+	}
+	var newBans []*db.IPBan
+	for _, b := range snapshot.IPBans {
+		if b.ID == "" {
+			return fmt.Errorf("ban of %s has no id", b.Network)
+		}
+		if _, _, err = net.ParseCIDR(b.Network); err != nil {
+			return fmt.Errorf("ban %s: %w", b.ID, err)
+		}
+		if banIDs[b.ID] || bannedNetworks[b.Network] {
+			continue
+		}
+		banIDs[b.ID], bannedNetworks[b.Network] = true, true
+		newBans = append(newBans, b)
+	}
+
+	for _, u := range newUsers {
+		if err = dbClient.AddUser(u); err != nil {
+			return err
+		}
+	}
+	for _, a := range newAlerts {
+		if err = dbClient.AddAlert(a); err != nil {
+			return err
+		}
+	}
+	for _, t := range newTokens {
+		if err = dbClient.AddAPIToken(t); err != nil {
+			return err
+		}
+	}
+	for _, b := range newBans {
+		if err = dbClient.AddIPBan(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func WriteSnapshot(w io.Writer, snapshot *Snapshot) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(snapshot)
+}
+
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	snapshot := &Snapshot{}
+	if err := json.NewDecoder(r).Decode(snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
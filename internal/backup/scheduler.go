@@ -0,0 +1,87 @@
+package backup
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+const (
+	DefaultInterval = time.Hour * 24
+
+	filePrefix     = "backup-"
+	fileExtension  = ".db"
+	fileTimeLayout = "20060102-150405"
+)
+
+type Scheduler interface {
+	Run() error
+	BackupNow() (string, error)
+}
+
+type scheduler struct {
+	backuper db.Backuper
+	dir      string
+	interval time.Duration
+	keep     int
+}
+
+// NewScheduler creates backups in dir every interval keeping at most keep latest files,
+// keep <= 0 disables rotation.
+func NewScheduler(backuper db.Backuper, dir string, interval time.Duration, keep int) Scheduler {
+	return &scheduler{backuper: backuper, dir: dir, interval: interval, keep: keep}
+}
+
+func (s *scheduler) Run() error {
+	for {
+		if _, err := s.BackupNow(); err != nil {
+			log.Println("error in backup scheduler: ", err)
+		}
+		time.Sleep(s.interval)
+	}
+}
+
+func (s *scheduler) BackupNow() (string, error) {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(s.dir, filePrefix+time.Now().UTC().Format(fileTimeLayout)+fileExtension)
+	if err := s.backuper.Backup(path); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, s.rotate()
+}
+
+func (s *scheduler) rotate() error {
+	if s.keep <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	var backups []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), filePrefix) && strings.HasSuffix(e.Name(), fileExtension) {
+			backups = append(backups, e.Name())
+		}
+	}
+	if len(backups) <= s.keep {
+		return nil
+	}
+	// names contain a sortable timestamp, the oldest go first
+	sort.Strings(backups)
+	for _, name := range backups[:len(backups)-s.keep] {
+		log.Printf("removing old backup %s...", name)
+		if err = os.Remove(filepath.Join(s.dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package binance
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/decimal"
+)
+
+// Account is a Binance client bound to the credentials of a named account, Futures and Margin are nil
+// when these markets are not watched for the account.
+type Account struct {
+	ID      string
+	Client  Client
+	Futures FuturesClient
+	Margin  MarginClient
+}
+
+type accountInfo struct {
+	Balances []*assetBalance `json:"balances"`
+}
+
+type assetBalance struct {
+	Asset  string `json:"asset"`
+	Free   string `json:"free"`
+	Locked string `json:"locked"`
+}
+
+// GetBalances returns the non zero asset balances of the account.
+func (c *client) GetBalances() ([]*db.Balance, error) {
+	params := url.Values{}
+	params.Set("omitZeroBalances", "true")
+	info := &accountInfo{}
+	if err := c.signedRequest(http.MethodGet, "/api/v3/account", params, info); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixMilli()
+	balances := make([]*db.Balance, 0, len(info.Balances))
+	for _, b := range info.Balances {
+		free, err := decimal.Parse(b.Free)
+		if err != nil {
+			return nil, err
+		}
+		locked, err := decimal.Parse(b.Locked)
+		if err != nil {
+			return nil, err
+		}
+		if free.IsZero() && locked.IsZero() {
+			continue
+		}
+		balances = append(balances, &db.Balance{Asset: b.Asset, Free: b.Free, Locked: b.Locked, UpdatedAt: now})
+	}
+	return balances, nil
+}
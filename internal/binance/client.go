@@ -1,6 +1,7 @@
 package binance
 
 import (
+	"bytes"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -8,7 +9,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"time"
+	"net/url"
+	"strconv"
 
 	"github.com/morzhanov/binance-orders-watcher/internal/db"
 )
@@ -21,12 +23,22 @@ type Client interface {
 	GetOrders() ([]*BinanceOrder, error)
 	GetAllOrdersForSymbol(symbol string) ([]*BinanceOrder, error)
 	GetPrices() ([]*db.Price, error)
+	GetBalances() ([]*db.Balance, error)
+	GetKlines(symbol, interval string, startTime, endTime int64, limit int) ([]*db.Kline, error)
+	GetExchangeInfo(symbols ...string) (*ExchangeInfo, error)
+	PlaceOrder(req *OrderRequest) (*BinanceOrder, error)
+	TestOrder(req *OrderRequest) error
+	PlaceOCO(req *OCORequest) (*OrderList, error)
+	GetOpenOrderLists() ([]*OrderList, error)
+	CancelOrder(symbol string, orderID int) (*BinanceOrder, error)
+	CancelReplaceOrder(cancelOrderID int, req *OrderRequest) (*CancelReplaceResult, error)
 }
 
 type client struct {
-	apiKey    string
-	apiSecret string
-	prodURI   string
+	apiKey     string
+	apiSecret  string
+	prodURI    string
+	httpClient *http.Client
 }
 
 type BinanceOrder struct {
@@ -50,91 +62,118 @@ type BinanceOrder struct {
 }
 
 func New(apiKey, apiSecret, prodURI string) Client {
-	return &client{apiKey: apiKey, apiSecret: apiSecret, prodURI: prodURI}
+	return NewWithHTTPClient(apiKey, apiSecret, prodURI, http.DefaultClient)
 }
 
-func (c *client) GetOrders() ([]*BinanceOrder, error) {
-	ts := time.Now()
-	query := fmt.Sprintf("timestamp=%d&recvWindow=10000", ts.UnixMilli())
-	signature := c.createSignature(query)
-
-	uri := fmt.Sprintf("%s/api/v3/openOrders?%s&signature=%s", c.prodURI, query, signature)
-	req, err := http.NewRequest(http.MethodGet, uri, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header[ApiKeyHeaderName] = []string{c.apiKey}
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
+// NewWithHTTPClient returns a client sending requests with httpClient, e.g. to record or replay Binance traffic.
+func NewWithHTTPClient(apiKey, apiSecret, prodURI string, httpClient *http.Client) Client {
+	return &client{apiKey: apiKey, apiSecret: apiSecret, prodURI: prodURI, httpClient: httpClient}
+}
 
-	ordersBytes, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, err
+func (c *client) do(req *http.Request) (*http.Response, error) {
+	if c.httpClient == nil {
+		return http.DefaultClient.Do(req)
 	}
+	return c.httpClient.Do(req)
+}
 
+func (c *client) GetOrders() ([]*BinanceOrder, error) {
 	var orders []*BinanceOrder
-	if err = json.Unmarshal(ordersBytes, &orders); err != nil {
+	if err := c.signedRequest(http.MethodGet, "/api/v3/openOrders", url.Values{}, &orders); err != nil {
 		return nil, err
 	}
 	return orders, nil
 }
 
 func (c *client) GetAllOrdersForSymbol(symbol string) ([]*BinanceOrder, error) {
-	ts := time.Now()
-	query := fmt.Sprintf("timestamp=%d&recvWindow=10000&symbol=%s", ts.UnixMilli(), symbol)
-	signature := c.createSignature(query)
-
-	uri := fmt.Sprintf("%s/api/v3/allOrders?%s&signature=%s", c.prodURI, query, signature)
-	req, err := http.NewRequest(http.MethodGet, uri, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header[ApiKeyHeaderName] = []string{c.apiKey}
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	var orders []*BinanceOrder
+	if err := c.signedRequest(http.MethodGet, "/api/v3/allOrders", params, &orders); err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
+	return orders, nil
+}
 
-	ordersBytes, err := io.ReadAll(res.Body)
-	if err != nil {
+func (c *client) GetPrices() ([]*db.Price, error) {
+	var prices []*db.Price
+	if err := c.publicRequest("/api/v3/ticker/price", &prices); err != nil {
 		return nil, err
 	}
+	return prices, nil
+}
 
-	var orders []*BinanceOrder
-	if err = json.Unmarshal(ordersBytes, &orders); err != nil {
-		return nil, err
-	}
-	return orders, nil
+func (c *client) createSignature(text string) string {
+	h := hmac.New(sha256.New, []byte(c.apiSecret))
+	h.Write([]byte(text))
+	return hex.EncodeToString(h.Sum(nil))
 }
 
-func (c *client) GetPrices() ([]*db.Price, error) {
-	uri := fmt.Sprintf("%s/api/v3/ticker/price", c.prodURI)
+// GetKlines returns candles for the symbol, startTime and endTime are unix timestamps in milliseconds
+// and are ignored when zero.
+func (c *client) GetKlines(symbol, interval string, startTime, endTime int64, limit int) ([]*db.Kline, error) {
+	query := url.Values{}
+	query.Set("symbol", symbol)
+	query.Set("interval", interval)
+	if startTime > 0 {
+		query.Set("startTime", strconv.FormatInt(startTime, 10))
+	}
+	if endTime > 0 {
+		query.Set("endTime", strconv.FormatInt(endTime, 10))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	uri := fmt.Sprintf("%s/api/v3/klines?%s", c.prodURI, query.Encode())
 	req, err := http.NewRequest(http.MethodGet, uri, nil)
 	if err != nil {
 		return nil, err
 	}
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
-	priceBytes, err := io.ReadAll(res.Body)
+	defer res.Body.Close()
+	klinesBytes, err := io.ReadAll(res.Body)
 	if err != nil {
 		return nil, err
 	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("binance klines request failed with status %d: %s", res.StatusCode, klinesBytes)
+	}
 
-	var prices []*db.Price
-	if err = json.Unmarshal(priceBytes, &prices); err != nil {
+	// every kline is an array: [openTime, open, high, low, close, volume, closeTime, ...]
+	var rows [][]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(klinesBytes))
+	decoder.UseNumber()
+	if err = decoder.Decode(&rows); err != nil {
 		return nil, err
 	}
-	return prices, nil
-}
-
-func (c *client) createSignature(text string) string {
-	h := hmac.New(sha256.New, []byte(c.apiSecret))
-	h.Write([]byte(text))
-	return hex.EncodeToString(h.Sum(nil))
+	klines := make([]*db.Kline, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 7 {
+			return nil, fmt.Errorf("unexpected kline format: %v", row)
+		}
+		openTime, err := row[0].(json.Number).Int64()
+		if err != nil {
+			return nil, err
+		}
+		closeTime, err := row[6].(json.Number).Int64()
+		if err != nil {
+			return nil, err
+		}
+		klines = append(klines, &db.Kline{
+			Symbol:    symbol,
+			Interval:  interval,
+			OpenTime:  openTime,
+			Open:      fmt.Sprint(row[1]),
+			High:      fmt.Sprint(row[2]),
+			Low:       fmt.Sprint(row[3]),
+			Close:     fmt.Sprint(row[4]),
+			Volume:    fmt.Sprint(row[5]),
+			CloseTime: closeTime,
+		})
+	}
+	return klines, nil
 }
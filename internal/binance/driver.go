@@ -0,0 +1,69 @@
+package binance
+
+import (
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/exchange"
+)
+
+type driver struct {
+	client Client
+}
+
+// NewDriver returns the exchange driver backed by the Binance client.
+func NewDriver(c Client) exchange.Driver {
+	return &driver{client: c}
+}
+
+func (d *driver) Exchange() string {
+	return exchange.Binance
+}
+
+func (d *driver) GetOpenOrders() ([]*exchange.Order, error) {
+	orders, err := d.client.GetOrders()
+	if err != nil {
+		return nil, err
+	}
+	return toExchangeOrders(orders), nil
+}
+
+func (d *driver) GetOrderHistory(symbol string) ([]*exchange.Order, error) {
+	orders, err := d.client.GetAllOrdersForSymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return toExchangeOrders(orders), nil
+}
+
+func (d *driver) GetPrices() ([]*db.Price, error) {
+	return d.client.GetPrices()
+}
+
+func (d *driver) GetBalances() ([]*db.Balance, error) {
+	return d.client.GetBalances()
+}
+
+func toExchangeOrders(orders []*BinanceOrder) []*exchange.Order {
+	res := make([]*exchange.Order, 0, len(orders))
+	for _, o := range orders {
+		res = append(res, &exchange.Order{
+			Symbol:              o.Symbol,
+			OrderID:             o.OrderId,
+			OrderListID:         o.OrderListId,
+			ClientOrderID:       o.ClientOrderId,
+			Price:               o.Price,
+			OrigQty:             o.OrigQty,
+			ExecutedQty:         o.ExecutedQty,
+			CummulativeQuoteQty: o.CummulativeQuoteQty,
+			Status:              o.Status,
+			TimeInForce:         o.TimeInForce,
+			Type:                o.Type,
+			Side:                o.Side,
+			StopPrice:           o.StopPrice,
+			IcebergQty:          o.IcebergQty,
+			Time:                o.Time,
+			UpdateTime:          o.UpdateTime,
+			IsWorking:           o.IsWorking,
+		})
+	}
+	return res
+}
@@ -0,0 +1,140 @@
+package binance
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/decimal"
+)
+
+const DefaultExchangeInfoRefreshInterval = time.Hour
+
+// ExchangeInfoCache keeps trading rules of all symbols in memory. Get loads them on the first call,
+// Lookup and Symbols never call Binance and return only what was loaded before.
+type ExchangeInfoCache interface {
+	Get(symbol string) (*SymbolInfo, error)
+	Lookup(symbol string) *SymbolInfo
+	Symbols() []*SymbolInfo
+	Refresh() error
+	Run(interval time.Duration)
+}
+
+type exchangeInfoCache struct {
+	c       Client
+	mu      sync.RWMutex
+	symbols map[string]*SymbolInfo
+}
+
+func NewExchangeInfoCache(c Client) ExchangeInfoCache {
+	return &exchangeInfoCache{c: c}
+}
+
+func (e *exchangeInfoCache) Get(symbol string) (*SymbolInfo, error) {
+	e.mu.RLock()
+	loaded := e.symbols != nil
+	e.mu.RUnlock()
+	if !loaded {
+		if err := e.Refresh(); err != nil {
+			return nil, err
+		}
+	}
+	return e.Lookup(symbol), nil
+}
+
+func (e *exchangeInfoCache) Lookup(symbol string) *SymbolInfo {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.symbols[symbol]
+}
+
+func (e *exchangeInfoCache) Symbols() []*SymbolInfo {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	symbols := make([]*SymbolInfo, 0, len(e.symbols))
+	for _, s := range e.symbols {
+		symbols = append(symbols, s)
+	}
+	sort.Slice(symbols, func(i, j int) bool { return symbols[i].Symbol < symbols[j].Symbol })
+	return symbols
+}
+
+func (e *exchangeInfoCache) Refresh() error {
+	log.Println("loading exchange info from binance...")
+	info, err := e.c.GetExchangeInfo()
+	if err != nil {
+		return err
+	}
+	symbols := make(map[string]*SymbolInfo, len(info.Symbols))
+	for _, s := range info.Symbols {
+		symbols[s.Symbol] = s
+	}
+
+	e.mu.Lock()
+	e.symbols = symbols
+	e.mu.Unlock()
+	return nil
+}
+
+// Run refreshes exchange info right away and then every interval, failed refreshes keep the previous data.
+func (e *exchangeInfoCache) Run(interval time.Duration) {
+	for {
+		if err := e.Refresh(); err != nil {
+			log.Println("failed to refresh exchange info: ", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// TickSize returns the price step of the symbol or an empty string when it is unknown.
+func (s *SymbolInfo) TickSize() string {
+	if f := s.Filter(FilterTypePrice); f != nil {
+		return f.TickSize
+	}
+	return ""
+}
+
+// StepSize returns the quantity step of the symbol or an empty string when it is unknown.
+func (s *SymbolInfo) StepSize() string {
+	if f := s.Filter(FilterTypeLotSize); f != nil {
+		return f.StepSize
+	}
+	return ""
+}
+
+// MinNotional returns the minimal order value of the symbol or an empty string when it is unknown.
+func (s *SymbolInfo) MinNotional() string {
+	for _, filterType := range []string{FilterTypeNotional, FilterTypeMinNotional} {
+		if f := s.Filter(filterType); f != nil {
+			return f.MinNotional
+		}
+	}
+	return ""
+}
+
+// FormatPrice rounds the price to the symbol tick size, values which are not numbers are returned as is.
+func (s *SymbolInfo) FormatPrice(price string) string {
+	return formatToStep(price, s.TickSize())
+}
+
+func formatToStep(value, step string) string {
+	v, err := decimal.Parse(value)
+	if err != nil {
+		return value
+	}
+	if _, ok := parsePositive(step); !ok {
+		return value
+	}
+	return v.StringFixed(StepDecimals(step))
+}
+
+// StepDecimals returns the number of decimals of a tick or step size like 0.01000000.
+func StepDecimals(step string) int {
+	i := strings.IndexByte(step, '.')
+	if i < 0 {
+		return 0
+	}
+	return len(strings.TrimRight(step[i+1:], "0"))
+}
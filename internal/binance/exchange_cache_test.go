@@ -0,0 +1,54 @@
+package binance
+
+import (
+	"testing"
+)
+
+type fakeExchangeInfoClient struct {
+	Client
+	calls int
+}
+
+func (c *fakeExchangeInfoClient) GetExchangeInfo(_ ...string) (*ExchangeInfo, error) {
+	c.calls++
+	return &ExchangeInfo{Symbols: []*SymbolInfo{btcusdt}}, nil
+}
+
+func TestExchangeInfoCache(t *testing.T) {
+	c := &fakeExchangeInfoClient{}
+	cache := NewExchangeInfoCache(c)
+	if cache.Lookup("BTCUSDT") != nil {
+		t.Fatal("lookup should not load exchange info")
+	}
+	for i := 0; i < 2; i++ {
+		info, err := cache.Get("BTCUSDT")
+		if err != nil || info != btcusdt {
+			t.Fatalf("unexpected symbol info %+v, %v", info, err)
+		}
+	}
+	if unknown, err := cache.Get("FOOUSDT"); unknown != nil || err != nil {
+		t.Fatalf("unknown symbol should not be found, got %+v, %v", unknown, err)
+	}
+	if c.calls != 1 {
+		t.Fatalf("exchange info should be loaded once, loaded %d times", c.calls)
+	}
+	if len(cache.Symbols()) != 1 || cache.Lookup("BTCUSDT") == nil {
+		t.Fatal("loaded symbols should be available without calling Binance")
+	}
+}
+
+func TestFormatPrice(t *testing.T) {
+	for price, expected := range map[string]string{
+		"50000.12345678": "50000.12",
+		"50000.1":        "50000.10",
+		"0.005":          "0.01",
+		"N/A":            "N/A",
+	} {
+		if formatted := btcusdt.FormatPrice(price); formatted != expected {
+			t.Fatalf("%s: expected %s, got %s", price, expected, formatted)
+		}
+	}
+	if decimals := StepDecimals("1.00000000"); decimals != 0 {
+		t.Fatalf("expected 0 decimals, got %d", decimals)
+	}
+}
@@ -0,0 +1,208 @@
+package binance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/decimal"
+)
+
+const (
+	SymbolStatusTrading = "TRADING"
+
+	FilterTypePrice         = "PRICE_FILTER"
+	FilterTypeLotSize       = "LOT_SIZE"
+	FilterTypeMarketLotSize = "MARKET_LOT_SIZE"
+	FilterTypeMinNotional   = "MIN_NOTIONAL"
+	FilterTypeNotional      = "NOTIONAL"
+)
+
+type ExchangeInfo struct {
+	ServerTime int64         `json:"serverTime"`
+	Symbols    []*SymbolInfo `json:"symbols"`
+}
+
+type SymbolInfo struct {
+	Symbol     string          `json:"symbol"`
+	Status     string          `json:"status"`
+	BaseAsset  string          `json:"baseAsset"`
+	QuoteAsset string          `json:"quoteAsset"`
+	Filters    []*SymbolFilter `json:"filters"`
+}
+
+// SymbolFilter holds the fields of the filters used for validation, other filter types are kept with their type only.
+type SymbolFilter struct {
+	FilterType       string `json:"filterType"`
+	MinPrice         string `json:"minPrice,omitempty"`
+	MaxPrice         string `json:"maxPrice,omitempty"`
+	TickSize         string `json:"tickSize,omitempty"`
+	MinQty           string `json:"minQty,omitempty"`
+	MaxQty           string `json:"maxQty,omitempty"`
+	StepSize         string `json:"stepSize,omitempty"`
+	MinNotional      string `json:"minNotional,omitempty"`
+	ApplyToMarket    bool   `json:"applyToMarket,omitempty"`
+	ApplyMinToMarket bool   `json:"applyMinToMarket,omitempty"`
+}
+
+// FilterError is a violation of a symbol filter, Field is the order request field.
+type FilterError struct {
+	Field   string
+	Filter  string
+	Message string
+}
+
+type FilterErrors []*FilterError
+
+func (e FilterErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, f := range e {
+		messages = append(messages, fmt.Sprintf("%s: %s (%s)", f.Field, f.Message, f.Filter))
+	}
+	return "order does not pass symbol filters: " + strings.Join(messages, "; ")
+}
+
+// GetExchangeInfo returns trading rules for the symbols, or for all symbols when none are provided.
+func (c *client) GetExchangeInfo(symbols ...string) (*ExchangeInfo, error) {
+	uri := fmt.Sprintf("%s/api/v3/exchangeInfo", c.prodURI)
+	if len(symbols) > 0 {
+		symbolsJSON, err := json.Marshal(symbols)
+		if err != nil {
+			return nil, err
+		}
+		uri += "?symbols=" + url.QueryEscape(string(symbolsJSON))
+	}
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	infoBytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, newAPIError(res.StatusCode, infoBytes)
+	}
+
+	info := &ExchangeInfo{}
+	if err = json.Unmarshal(infoBytes, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (info *ExchangeInfo) Symbol(symbol string) *SymbolInfo {
+	for _, s := range info.Symbols {
+		if s.Symbol == symbol {
+			return s
+		}
+	}
+	return nil
+}
+
+func (s *SymbolInfo) Filter(filterType string) *SymbolFilter {
+	for _, f := range s.Filters {
+		if f.FilterType == filterType {
+			return f
+		}
+	}
+	return nil
+}
+
+// ValidateOrder checks the order against the symbol filters, marketPrice is used for the notional of market orders.
+func (s *SymbolInfo) ValidateOrder(req *OrderRequest, marketPrice string) error {
+	var errs FilterErrors
+	if s.Status != SymbolStatusTrading {
+		errs = append(errs, &FilterError{Field: "symbol", Filter: "STATUS", Message: fmt.Sprintf("symbol is not trading, status is %s", s.Status)})
+	}
+	market := req.Type == OrderTypeMarket
+
+	quantity, ok := parsePositive(req.Quantity)
+	if !ok {
+		return append(errs, &FilterError{Field: "quantity", Filter: FilterTypeLotSize, Message: "should be a positive number"})
+	}
+	errs = append(errs, checkRange("quantity", quantity, s.Filter(FilterTypeLotSize), true)...)
+	if market {
+		errs = append(errs, checkRange("quantity", quantity, s.Filter(FilterTypeMarketLotSize), true)...)
+	}
+
+	var price decimal.Decimal
+	hasPrice := false
+	if !market {
+		if price, ok = parsePositive(req.Price); !ok {
+			return append(errs, &FilterError{Field: "price", Filter: FilterTypePrice, Message: "should be a positive number"})
+		}
+		hasPrice = true
+		errs = append(errs, checkRange("price", price, s.Filter(FilterTypePrice), false)...)
+	} else {
+		price, hasPrice = parsePositive(marketPrice)
+	}
+	if req.StopPrice != "" {
+		stopPrice, ok := parsePositive(req.StopPrice)
+		if !ok {
+			return append(errs, &FilterError{Field: "stopPrice", Filter: FilterTypePrice, Message: "should be a positive number"})
+		}
+		errs = append(errs, checkRange("stopPrice", stopPrice, s.Filter(FilterTypePrice), false)...)
+	}
+
+	if hasPrice {
+		notional := price.Mul(quantity)
+		for _, filterType := range []string{FilterTypeMinNotional, FilterTypeNotional} {
+			f := s.Filter(filterType)
+			if f == nil || (market && !f.ApplyToMarket && !f.ApplyMinToMarket) {
+				continue
+			}
+			if min, ok := parsePositive(f.MinNotional); ok && notional.LessThan(min) {
+				errs = append(errs, &FilterError{Field: "quantity", Filter: filterType, Message: fmt.Sprintf("order value %s is less than %s", notional, f.MinNotional)})
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkRange checks value against min, max and step of a price or lot size filter, zero values disable a check.
+func checkRange(field string, value decimal.Decimal, f *SymbolFilter, lot bool) FilterErrors {
+	if f == nil {
+		return nil
+	}
+	min, max, step := f.MinPrice, f.MaxPrice, f.TickSize
+	if lot {
+		min, max, step = f.MinQty, f.MaxQty, f.StepSize
+	}
+
+	var errs FilterErrors
+	minValue, hasMin := parsePositive(min)
+	if hasMin && value.LessThan(minValue) {
+		errs = append(errs, &FilterError{Field: field, Filter: f.FilterType, Message: "should be at least " + min})
+	}
+	if maxValue, ok := parsePositive(max); ok && value.GreaterThan(maxValue) {
+		errs = append(errs, &FilterError{Field: field, Filter: f.FilterType, Message: "should be at most " + max})
+	}
+	if stepValue, ok := parsePositive(step); ok {
+		steps, _ := value.Sub(minValue).Div(stepValue)
+		if !steps.IsInteger() {
+			errs = append(errs, &FilterError{Field: field, Filter: f.FilterType, Message: "should be a multiple of " + step})
+		}
+	}
+	return errs
+}
+
+func parsePositive(value string) (decimal.Decimal, bool) {
+	d, err := decimal.Parse(value)
+	if err != nil || d.Sign() <= 0 {
+		return decimal.Zero, false
+	}
+	return d, true
+}
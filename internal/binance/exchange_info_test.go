@@ -0,0 +1,65 @@
+package binance
+
+import (
+	"testing"
+)
+
+var btcusdt = &SymbolInfo{
+	Symbol:     "BTCUSDT",
+	Status:     SymbolStatusTrading,
+	BaseAsset:  "BTC",
+	QuoteAsset: "USDT",
+	Filters: []*SymbolFilter{
+		{FilterType: FilterTypePrice, MinPrice: "0.01000000", MaxPrice: "1000000.00000000", TickSize: "0.01000000"},
+		{FilterType: FilterTypeLotSize, MinQty: "0.00001000", MaxQty: "9000.00000000", StepSize: "0.00001000"},
+		{FilterType: FilterTypeNotional, MinNotional: "5.00000000", ApplyMinToMarket: true},
+	},
+}
+
+func TestValidateOrder(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         *OrderRequest
+		marketPrice string
+		fields      []string
+	}{
+		{name: "valid limit", req: &OrderRequest{Type: OrderTypeLimit, Quantity: "0.001", Price: "50000.01"}},
+		{name: "valid market", req: &OrderRequest{Type: OrderTypeMarket, Quantity: "0.001"}, marketPrice: "50000"},
+		{name: "price off tick", req: &OrderRequest{Type: OrderTypeLimit, Quantity: "0.001", Price: "50000.001"}, fields: []string{"price"}},
+		{name: "quantity off step", req: &OrderRequest{Type: OrderTypeLimit, Quantity: "0.001005", Price: "50000"}, fields: []string{"quantity"}},
+		{name: "quantity below min", req: &OrderRequest{Type: OrderTypeLimit, Quantity: "0.000001", Price: "50000"}, fields: []string{"quantity", "quantity", "quantity"}},
+		{name: "notional below min", req: &OrderRequest{Type: OrderTypeLimit, Quantity: "0.0001", Price: "40000"}, fields: []string{"quantity"}},
+		{name: "market notional", req: &OrderRequest{Type: OrderTypeMarket, Quantity: "0.0001"}, marketPrice: "40000", fields: []string{"quantity"}},
+		{name: "stop price off tick", req: &OrderRequest{Type: OrderTypeStopLossLimit, Quantity: "0.001", Price: "50000", StopPrice: "49999.999"}, fields: []string{"stopPrice"}},
+		{name: "invalid price", req: &OrderRequest{Type: OrderTypeLimit, Quantity: "0.001", Price: "abc"}, fields: []string{"price"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := btcusdt.ValidateOrder(tt.req, tt.marketPrice)
+			if len(tt.fields) == 0 {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			errs, ok := err.(FilterErrors)
+			if !ok || len(errs) != len(tt.fields) {
+				t.Fatalf("expected errors for %v, got %v", tt.fields, err)
+			}
+			for i, f := range errs {
+				if f.Field != tt.fields[i] {
+					t.Fatalf("expected errors for %v, got %v", tt.fields, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateOrderSymbolNotTrading(t *testing.T) {
+	halted := *btcusdt
+	halted.Status = "HALT"
+	err := halted.ValidateOrder(&OrderRequest{Type: OrderTypeLimit, Quantity: "0.001", Price: "50000"}, "")
+	if errs, ok := err.(FilterErrors); !ok || errs[0].Field != "symbol" {
+		t.Fatalf("expected symbol status error, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package binance
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/decimal"
+)
+
+const DefaultFuturesURI = "https://fapi.binance.com"
+
+// FuturesClient loads USDⓈ-M futures orders, positions, funding rates and the account margin ratio.
+type FuturesClient interface {
+	GetOpenOrders() ([]*db.LeveragedOrder, error)
+	GetPositions() ([]*db.Position, error)
+	GetFundingRates() ([]*db.FundingRate, error)
+	GetMarginStatus() (*db.MarginStatus, error)
+}
+
+type futuresClient struct {
+	c *client
+}
+
+type futuresOrder struct {
+	Symbol        string `json:"symbol"`
+	OrderId       int    `json:"orderId"`
+	ClientOrderId string `json:"clientOrderId"`
+	Price         string `json:"price"`
+	OrigQty       string `json:"origQty"`
+	ExecutedQty   string `json:"executedQty"`
+	Status        string `json:"status"`
+	TimeInForce   string `json:"timeInForce"`
+	Type          string `json:"type"`
+	Side          string `json:"side"`
+	PositionSide  string `json:"positionSide"`
+	StopPrice     string `json:"stopPrice"`
+	ReduceOnly    bool   `json:"reduceOnly"`
+	Time          int    `json:"time"`
+	UpdateTime    int    `json:"updateTime"`
+}
+
+type positionRisk struct {
+	Symbol           string `json:"symbol"`
+	PositionSide     string `json:"positionSide"`
+	PositionAmt      string `json:"positionAmt"`
+	EntryPrice       string `json:"entryPrice"`
+	MarkPrice        string `json:"markPrice"`
+	UnRealizedProfit string `json:"unRealizedProfit"`
+	LiquidationPrice string `json:"liquidationPrice"`
+	Leverage         string `json:"leverage"`
+	MarginType       string `json:"marginType"`
+}
+
+type premiumIndex struct {
+	Symbol          string `json:"symbol"`
+	MarkPrice       string `json:"markPrice"`
+	LastFundingRate string `json:"lastFundingRate"`
+	NextFundingTime int64  `json:"nextFundingTime"`
+}
+
+type futuresAccount struct {
+	TotalMaintMargin   string `json:"totalMaintMargin"`
+	TotalMarginBalance string `json:"totalMarginBalance"`
+}
+
+// NewFutures creates the futures client sending requests with httpClient, uri is the futures API URI like DefaultFuturesURI.
+func NewFutures(apiKey, apiSecret, uri string, httpClient *http.Client) FuturesClient {
+	if uri == "" {
+		uri = DefaultFuturesURI
+	}
+	return &futuresClient{c: &client{apiKey: apiKey, apiSecret: apiSecret, prodURI: uri, httpClient: httpClient}}
+}
+
+func (f *futuresClient) GetOpenOrders() ([]*db.LeveragedOrder, error) {
+	var orders []*futuresOrder
+	if err := f.c.signedRequest(http.MethodGet, "/fapi/v1/openOrders", url.Values{}, &orders); err != nil {
+		return nil, err
+	}
+	res := make([]*db.LeveragedOrder, 0, len(orders))
+	for _, o := range orders {
+		res = append(res, &db.LeveragedOrder{
+			Market:        db.MarketFutures,
+			Symbol:        o.Symbol,
+			OrderID:       o.OrderId,
+			ClientOrderID: o.ClientOrderId,
+			Price:         o.Price,
+			OrigQty:       o.OrigQty,
+			ExecutedQty:   o.ExecutedQty,
+			Status:        o.Status,
+			TimeInForce:   o.TimeInForce,
+			Type:          o.Type,
+			Side:          o.Side,
+			PositionSide:  o.PositionSide,
+			StopPrice:     o.StopPrice,
+			ReduceOnly:    o.ReduceOnly,
+			Time:          o.Time,
+			UpdateTime:    o.UpdateTime,
+		})
+	}
+	return res, nil
+}
+
+// GetPositions returns open positions, Binance lists every symbol so empty ones are skipped.
+func (f *futuresClient) GetPositions() ([]*db.Position, error) {
+	var risks []*positionRisk
+	if err := f.c.signedRequest(http.MethodGet, "/fapi/v2/positionRisk", url.Values{}, &risks); err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	positions := make([]*db.Position, 0)
+	for _, r := range risks {
+		amount, err := decimal.Parse(r.PositionAmt)
+		if err != nil {
+			return nil, err
+		}
+		if amount.IsZero() {
+			continue
+		}
+		leverage, err := strconv.Atoi(r.Leverage)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s leverage %q", r.Symbol, r.Leverage)
+		}
+		positions = append(positions, &db.Position{
+			Symbol:           r.Symbol,
+			PositionSide:     r.PositionSide,
+			PositionAmt:      r.PositionAmt,
+			EntryPrice:       r.EntryPrice,
+			MarkPrice:        r.MarkPrice,
+			UnrealizedProfit: r.UnRealizedProfit,
+			LiquidationPrice: r.LiquidationPrice,
+			Leverage:         leverage,
+			MarginType:       r.MarginType,
+			UpdatedAt:        now,
+		})
+	}
+	return positions, nil
+}
+
+func (f *futuresClient) GetFundingRates() ([]*db.FundingRate, error) {
+	var indexes []*premiumIndex
+	if err := f.c.publicRequest("/fapi/v1/premiumIndex", &indexes); err != nil {
+		return nil, err
+	}
+	rates := make([]*db.FundingRate, 0, len(indexes))
+	for _, i := range indexes {
+		rates = append(rates, &db.FundingRate{Symbol: i.Symbol, MarkPrice: i.MarkPrice, FundingRate: i.LastFundingRate, NextFundingTime: i.NextFundingTime})
+	}
+	return rates, nil
+}
+
+// GetMarginStatus returns the maintenance margin to margin balance ratio, Binance liquidates the account at 100%.
+func (f *futuresClient) GetMarginStatus() (*db.MarginStatus, error) {
+	account := &futuresAccount{}
+	if err := f.c.signedRequest(http.MethodGet, "/fapi/v2/account", url.Values{}, account); err != nil {
+		return nil, err
+	}
+	ratio, err := percentOf(account.TotalMaintMargin, account.TotalMarginBalance)
+	if err != nil {
+		return nil, err
+	}
+	return &db.MarginStatus{Market: db.MarketFutures, MarginRatio: ratio, UpdatedAt: time.Now().UnixMilli()}, nil
+}
+
+// percentOf returns part / total in percent rounded to two decimals, zero when total is zero.
+func percentOf(part, total string) (string, error) {
+	p, err := decimal.Parse(part)
+	if err != nil {
+		return "", err
+	}
+	t, err := decimal.Parse(total)
+	if err != nil {
+		return "", err
+	}
+	if t.IsZero() {
+		return "0", nil
+	}
+	ratio, err := p.Mul(decimal.NewFromInt(100)).Div(t)
+	if err != nil {
+		return "", err
+	}
+	return ratio.StringFixed(2), nil
+}
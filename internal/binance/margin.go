@@ -0,0 +1,71 @@
+package binance
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+// MarginClient loads cross margin orders and the account margin level.
+type MarginClient interface {
+	GetOpenOrders() ([]*db.LeveragedOrder, error)
+	GetMarginStatus() (*db.MarginStatus, error)
+}
+
+type marginClient struct {
+	c *client
+}
+
+type marginAccount struct {
+	MarginLevel         string `json:"marginLevel"`
+	TotalAssetOfBtc     string `json:"totalAssetOfBtc"`
+	TotalLiabilityOfBtc string `json:"totalLiabilityOfBtc"`
+}
+
+// NewMargin creates the cross margin client sending requests with httpClient, margin endpoints are served by the spot API URI.
+func NewMargin(apiKey, apiSecret, prodURI string, httpClient *http.Client) MarginClient {
+	return &marginClient{c: &client{apiKey: apiKey, apiSecret: apiSecret, prodURI: prodURI, httpClient: httpClient}}
+}
+
+func (m *marginClient) GetOpenOrders() ([]*db.LeveragedOrder, error) {
+	var orders []*BinanceOrder
+	if err := m.c.signedRequest(http.MethodGet, "/sapi/v1/margin/openOrders", url.Values{}, &orders); err != nil {
+		return nil, err
+	}
+	res := make([]*db.LeveragedOrder, 0, len(orders))
+	for _, o := range orders {
+		res = append(res, &db.LeveragedOrder{
+			Market:        db.MarketMargin,
+			Symbol:        o.Symbol,
+			OrderID:       o.OrderId,
+			ClientOrderID: o.ClientOrderId,
+			Price:         o.Price,
+			OrigQty:       o.OrigQty,
+			ExecutedQty:   o.ExecutedQty,
+			Status:        o.Status,
+			TimeInForce:   o.TimeInForce,
+			Type:          o.Type,
+			Side:          o.Side,
+			StopPrice:     o.StopPrice,
+			Time:          o.Time,
+			UpdateTime:    o.UpdateTime,
+		})
+	}
+	return res, nil
+}
+
+// GetMarginStatus returns the margin level and the liabilities to assets ratio, the ratio is 100% when
+// the margin level falls to 1.
+func (m *marginClient) GetMarginStatus() (*db.MarginStatus, error) {
+	account := &marginAccount{}
+	if err := m.c.signedRequest(http.MethodGet, "/sapi/v1/margin/account", url.Values{}, account); err != nil {
+		return nil, err
+	}
+	ratio, err := percentOf(account.TotalLiabilityOfBtc, account.TotalAssetOfBtc)
+	if err != nil {
+		return nil, err
+	}
+	return &db.MarginStatus{Market: db.MarketMargin, MarginRatio: ratio, MarginLevel: account.MarginLevel, UpdatedAt: time.Now().UnixMilli()}, nil
+}
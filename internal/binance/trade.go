@@ -0,0 +1,238 @@
+package binance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	SideBuy  = "BUY"
+	SideSell = "SELL"
+
+	OrderTypeLimit         = "LIMIT"
+	OrderTypeMarket        = "MARKET"
+	OrderTypeStopLossLimit = "STOP_LOSS_LIMIT"
+	OrderTypeLimitMaker    = "LIMIT_MAKER"
+
+	TimeInForceGTC = "GTC"
+)
+
+// APIError is returned when Binance rejects a request.
+type APIError struct {
+	Status  int    `json:"-"`
+	Code    int    `json:"code"`
+	Message string `json:"msg"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("binance error %d: %s", e.Code, e.Message)
+}
+
+// newAPIError parses the Binance error body, the body is used as the message when it is not JSON.
+func newAPIError(status int, body []byte) *APIError {
+	apiErr := &APIError{Status: status}
+	if err := json.Unmarshal(body, apiErr); err != nil || apiErr.Message == "" {
+		apiErr.Message = string(body)
+	}
+	return apiErr
+}
+
+// OrderRequest is a new order, quantities and prices are decimal strings as Binance expects them.
+type OrderRequest struct {
+	Symbol      string `json:"symbol"`
+	Side        string `json:"side"`
+	Type        string `json:"type"`
+	TimeInForce string `json:"timeInForce,omitempty"`
+	Quantity    string `json:"quantity"`
+	Price       string `json:"price,omitempty"`
+	StopPrice   string `json:"stopPrice,omitempty"`
+}
+
+func (r *OrderRequest) params() url.Values {
+	params := url.Values{}
+	params.Set("symbol", r.Symbol)
+	params.Set("side", r.Side)
+	params.Set("type", r.Type)
+	params.Set("quantity", r.Quantity)
+	if r.Price != "" {
+		params.Set("price", r.Price)
+	}
+	if r.StopPrice != "" {
+		params.Set("stopPrice", r.StopPrice)
+	}
+	timeInForce := r.TimeInForce
+	if timeInForce == "" && (r.Type == OrderTypeLimit || r.Type == OrderTypeStopLossLimit) {
+		timeInForce = TimeInForceGTC
+	}
+	if timeInForce != "" {
+		params.Set("timeInForce", timeInForce)
+	}
+	return params
+}
+
+// OCORequest is a one-cancels-the-other pair of a limit maker order at Price and a stop loss limit order
+// triggered at StopPrice and placed at StopLimitPrice.
+type OCORequest struct {
+	Symbol         string `json:"symbol"`
+	Side           string `json:"side"`
+	Quantity       string `json:"quantity"`
+	Price          string `json:"price"`
+	StopPrice      string `json:"stopPrice"`
+	StopLimitPrice string `json:"stopLimitPrice"`
+}
+
+func (r *OCORequest) params() url.Values {
+	params := url.Values{}
+	params.Set("symbol", r.Symbol)
+	params.Set("side", r.Side)
+	params.Set("quantity", r.Quantity)
+	params.Set("price", r.Price)
+	params.Set("stopPrice", r.StopPrice)
+	params.Set("stopLimitPrice", r.StopLimitPrice)
+	params.Set("stopLimitTimeInForce", TimeInForceGTC)
+	return params
+}
+
+// Legs returns the two orders of the OCO, they are used for validation.
+func (r *OCORequest) Legs() []*OrderRequest {
+	return []*OrderRequest{
+		{Symbol: r.Symbol, Side: r.Side, Type: OrderTypeLimitMaker, Quantity: r.Quantity, Price: r.Price},
+		{Symbol: r.Symbol, Side: r.Side, Type: OrderTypeStopLossLimit, Quantity: r.Quantity, Price: r.StopLimitPrice, StopPrice: r.StopPrice},
+	}
+}
+
+// OrderList is an OCO order list. OrderReports are only returned when the list is placed,
+// open order lists hold their legs in Orders.
+type OrderList struct {
+	OrderListID       int               `json:"orderListId"`
+	ContingencyType   string            `json:"contingencyType"`
+	ListStatusType    string            `json:"listStatusType"`
+	ListOrderStatus   string            `json:"listOrderStatus"`
+	ListClientOrderID string            `json:"listClientOrderId"`
+	TransactionTime   int64             `json:"transactionTime"`
+	Symbol            string            `json:"symbol"`
+	Orders            []*OrderListOrder `json:"orders"`
+	OrderReports      []*BinanceOrder   `json:"orderReports"`
+}
+
+type OrderListOrder struct {
+	Symbol        string `json:"symbol"`
+	OrderID       int    `json:"orderId"`
+	ClientOrderID string `json:"clientOrderId"`
+}
+
+type CancelReplaceResult struct {
+	CancelResult     string        `json:"cancelResult"`
+	NewOrderResult   string        `json:"newOrderResult"`
+	CancelResponse   *BinanceOrder `json:"cancelResponse"`
+	NewOrderResponse *BinanceOrder `json:"newOrderResponse"`
+}
+
+func (c *client) PlaceOrder(req *OrderRequest) (*BinanceOrder, error) {
+	order := &BinanceOrder{}
+	if err := c.signedRequest(http.MethodPost, "/api/v3/order", req.params(), order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// TestOrder validates the order on Binance without sending it to the matching engine.
+func (c *client) TestOrder(req *OrderRequest) error {
+	return c.signedRequest(http.MethodPost, "/api/v3/order/test", req.params(), nil)
+}
+
+func (c *client) PlaceOCO(req *OCORequest) (*OrderList, error) {
+	list := &OrderList{}
+	if err := c.signedRequest(http.MethodPost, "/api/v3/order/oco", req.params(), list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *client) GetOpenOrderLists() ([]*OrderList, error) {
+	var lists []*OrderList
+	if err := c.signedRequest(http.MethodGet, "/api/v3/openOrderList", url.Values{}, &lists); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (c *client) CancelOrder(symbol string, orderID int) (*BinanceOrder, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("orderId", strconv.Itoa(orderID))
+	order := &BinanceOrder{}
+	if err := c.signedRequest(http.MethodDelete, "/api/v3/order", params, order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// CancelReplaceOrder cancels the order and places the new one, the new order is not placed if cancel failed.
+func (c *client) CancelReplaceOrder(cancelOrderID int, req *OrderRequest) (*CancelReplaceResult, error) {
+	params := req.params()
+	params.Set("cancelReplaceMode", "STOP_ON_FAILURE")
+	params.Set("cancelOrderId", strconv.Itoa(cancelOrderID))
+	result := &CancelReplaceResult{}
+	if err := c.signedRequest(http.MethodPost, "/api/v3/order/cancelReplace", params, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *client) publicRequest(path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, c.prodURI+path, nil)
+	if err != nil {
+		return err
+	}
+	res, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return newAPIError(res.StatusCode, body)
+	}
+	return json.Unmarshal(body, v)
+}
+
+func (c *client) signedRequest(method, path string, params url.Values, v interface{}) error {
+	params.Set("timestamp", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	params.Set("recvWindow", "10000")
+	query := params.Encode()
+	signature := c.createSignature(query)
+
+	uri := fmt.Sprintf("%s%s?%s&signature=%s", c.prodURI, path, query, signature)
+	req, err := http.NewRequest(method, uri, nil)
+	if err != nil {
+		return err
+	}
+	req.Header[ApiKeyHeaderName] = []string{c.apiKey}
+	res, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return newAPIError(res.StatusCode, body)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(body, v)
+}
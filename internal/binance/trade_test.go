@@ -0,0 +1,124 @@
+package binance
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+func TestPlaceOrderSignsRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v3/order" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get(ApiKeyHeaderName) != "key" {
+			t.Errorf("api key header is not set")
+		}
+		query := r.URL.RawQuery
+		i := strings.LastIndex(query, "&signature=")
+		h := hmac.New(sha256.New, []byte("secret"))
+		h.Write([]byte(query[:i]))
+		if query[i+len("&signature="):] != hex.EncodeToString(h.Sum(nil)) {
+			t.Errorf("wrong signature for %s", query)
+		}
+		q := r.URL.Query()
+		if q.Get("symbol") != "BTCUSDT" || q.Get("type") != OrderTypeLimit || q.Get("timeInForce") != TimeInForceGTC || q.Get("price") != "50000" {
+			t.Errorf("unexpected params %s", query)
+		}
+		w.Write([]byte(`{"symbol":"BTCUSDT","orderId":42,"status":"NEW","price":"50000.00000000"}`))
+	}))
+	defer srv.Close()
+
+	c := New("key", "secret", srv.URL)
+	order, err := c.PlaceOrder(&OrderRequest{Symbol: "BTCUSDT", Side: SideBuy, Type: OrderTypeLimit, Quantity: "0.001", Price: "50000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.OrderId != 42 || order.Status != "NEW" {
+		t.Fatalf("unexpected order %+v", order)
+	}
+}
+
+func TestSignedRequestReturnsAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":-2011,"msg":"Unknown order sent."}`))
+	}))
+	defer srv.Close()
+
+	_, err := New("key", "secret", srv.URL).CancelOrder("BTCUSDT", 1)
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("expected APIError, got %v", err)
+	}
+	if apiErr.Status != http.StatusBadRequest || apiErr.Code != -2011 || apiErr.Message != "Unknown order sent." {
+		t.Fatalf("unexpected error %+v", apiErr)
+	}
+}
+
+func TestOCOLegs(t *testing.T) {
+	legs := (&OCORequest{Symbol: "BTCUSDT", Side: SideSell, Quantity: "1", Price: "60000", StopPrice: "45000", StopLimitPrice: "44900"}).Legs()
+	if legs[0].Type != OrderTypeLimitMaker || legs[0].Price != "60000" {
+		t.Fatalf("unexpected limit maker leg %+v", legs[0])
+	}
+	if legs[1].Type != OrderTypeStopLossLimit || legs[1].Price != "44900" || legs[1].StopPrice != "45000" {
+		t.Fatalf("unexpected stop loss leg %+v", legs[1])
+	}
+}
+
+func TestGetBalancesSkipsZeroBalances(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/api/v3/account" || r.URL.Query().Get("signature") == "" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.String())
+		}
+		w.Write([]byte(`{"balances":[{"asset":"BTC","free":"0.00100000","locked":"0.00000000"},{"asset":"ETH","free":"0.00000000","locked":"0.00000000"},{"asset":"USDT","free":"0.00000000","locked":"12.50000000"}]}`))
+	}))
+	defer srv.Close()
+
+	balances, err := New("key", "secret", srv.URL).GetBalances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(balances) != 2 || balances[0].Asset != "BTC" || balances[0].Free != "0.00100000" || balances[1].Asset != "USDT" || balances[1].Locked != "12.50000000" {
+		t.Fatalf("unexpected balances %+v", balances)
+	}
+}
+
+func TestFuturesPositionsAndMarginStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fapi/v2/positionRisk":
+			w.Write([]byte(`[{"symbol":"BTCUSDT","positionSide":"BOTH","positionAmt":"0.010","entryPrice":"40000.0","markPrice":"41000.00000000","unRealizedProfit":"10.00000000","liquidationPrice":"36000.1","leverage":"20","marginType":"cross"},{"symbol":"ETHUSDT","positionSide":"BOTH","positionAmt":"0.000","entryPrice":"0.0","markPrice":"2000","unRealizedProfit":"0","liquidationPrice":"0","leverage":"10","marginType":"cross"}]`))
+		case "/fapi/v2/account":
+			w.Write([]byte(`{"totalMaintMargin":"1.5","totalMarginBalance":"200"}`))
+		case "/sapi/v1/margin/account":
+			w.Write([]byte(`{"marginLevel":"4","totalAssetOfBtc":"1","totalLiabilityOfBtc":"0.25"}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.String())
+		}
+	}))
+	defer srv.Close()
+
+	futures := NewFutures("key", "secret", srv.URL, nil)
+	positions, err := futures.GetPositions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) != 1 || positions[0].Symbol != "BTCUSDT" || positions[0].Leverage != 20 || positions[0].LiquidationPrice != "36000.1" {
+		t.Fatalf("expected only the open BTCUSDT position, got %+v", positions)
+	}
+	status, err := futures.GetMarginStatus()
+	if err != nil || status.Market != db.MarketFutures || status.MarginRatio != "0.75" {
+		t.Fatalf("unexpected futures margin status %+v, %v", status, err)
+	}
+	status, err = NewMargin("key", "secret", srv.URL, nil).GetMarginStatus()
+	if err != nil || status.Market != db.MarketMargin || status.MarginRatio != "25.00" || status.MarginLevel != "4" {
+		t.Fatalf("unexpected margin status %+v, %v", status, err)
+	}
+}
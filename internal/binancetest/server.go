@@ -0,0 +1,562 @@
+// Package binancetest is a fake Binance spot API for tests. It keeps prices, orders, balances, trades
+// and symbols in memory, verifies API keys and HMAC signatures like Binance does and lets tests script
+// fills, price moves, errors and rate limits between requests.
+package binancetest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+	"github.com/morzhanov/binance-orders-watcher/internal/decimal"
+)
+
+const (
+	OrderStatusNew             = "NEW"
+	OrderStatusPartiallyFilled = "PARTIALLY_FILLED"
+	OrderStatusFilled          = "FILLED"
+	OrderStatusCanceled        = "CANCELED"
+
+	CodeTooManyRequests  = -1003
+	CodeMandatoryParam   = -1102
+	CodeInvalidSymbol    = -1121
+	CodeInvalidSignature = -1022
+	CodeInvalidListenKey = -1125
+	CodeInvalidAPIKey    = -2015
+)
+
+// Trade is a fill returned by /api/v3/myTrades.
+type Trade struct {
+	Symbol          string `json:"symbol"`
+	ID              int    `json:"id"`
+	OrderID         int    `json:"orderId"`
+	OrderListID     int    `json:"orderListId"`
+	Price           string `json:"price"`
+	Qty             string `json:"qty"`
+	QuoteQty        string `json:"quoteQty"`
+	Commission      string `json:"commission"`
+	CommissionAsset string `json:"commissionAsset"`
+	Time            int64  `json:"time"`
+	IsBuyer         bool   `json:"isBuyer"`
+	IsMaker         bool   `json:"isMaker"`
+	IsBestMatch     bool   `json:"isBestMatch"`
+}
+
+type balance struct {
+	Asset  string `json:"asset"`
+	Free   string `json:"free"`
+	Locked string `json:"locked"`
+}
+
+type failure struct {
+	status     int
+	code       int
+	message    string
+	retryAfter int
+}
+
+// Server is the fake Binance API, use URL as the Binance production URI.
+type Server struct {
+	URL       string
+	APIKey    string
+	APISecret string
+
+	srv *httptest.Server
+
+	mu         sync.Mutex
+	prices     map[string]string
+	orders     []*binance.BinanceOrder
+	orderLists []*binance.OrderList
+	balances   []*balance
+	trades     []*Trade
+	symbols    []*binance.SymbolInfo
+	failures   map[string][]*failure
+	streams    map[string]chan []byte
+	requests   map[string]int
+	nextID     int
+}
+
+// NewServer starts the fake server accepting requests signed with the key and the secret.
+func NewServer(apiKey, apiSecret string) *Server {
+	s := &Server{
+		APIKey:    apiKey,
+		APISecret: apiSecret,
+		prices:    make(map[string]string),
+		failures:  make(map[string][]*failure),
+		streams:   make(map[string]chan []byte),
+		requests:  make(map[string]int),
+		nextID:    1,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/ticker/price", s.handlePrices)
+	mux.HandleFunc("/api/v3/exchangeInfo", s.handleExchangeInfo)
+	mux.HandleFunc("/api/v3/openOrders", s.signed(s.handleOpenOrders))
+	mux.HandleFunc("/api/v3/allOrders", s.signed(s.handleAllOrders))
+	mux.HandleFunc("/api/v3/openOrderList", s.signed(s.handleOpenOrderLists))
+	mux.HandleFunc("/api/v3/account", s.signed(s.handleAccount))
+	mux.HandleFunc("/api/v3/myTrades", s.signed(s.handleMyTrades))
+	mux.HandleFunc("/api/v3/userDataStream", s.withAPIKey(s.handleUserDataStream))
+	mux.HandleFunc("/ws/", s.handleStream)
+	s.srv = httptest.NewServer(s.count(mux))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close stops the server and closes user data streams.
+func (s *Server) Close() {
+	s.mu.Lock()
+	for key, stream := range s.streams {
+		close(stream)
+		delete(s.streams, key)
+	}
+	s.mu.Unlock()
+	s.srv.Close()
+}
+
+// Requests returns the number of requests to the path, failed ones included.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// Step is a scripted change of the server state, e.g. a price move or a fill.
+type Step func(s *Server)
+
+// Run applies the steps in order.
+func (s *Server) Run(steps ...Step) {
+	for _, step := range steps {
+		step(s)
+	}
+}
+
+// MovePrice sets the market price of the symbol.
+func MovePrice(symbol, price string) Step {
+	return func(s *Server) {
+		s.SetPrice(symbol, price)
+	}
+}
+
+// FillOrder fills the open order at its price, records the trade and sends the execution report.
+// The other legs of an OCO are canceled like Binance does.
+func FillOrder(orderID int) Step {
+	return func(s *Server) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		order := s.order(orderID)
+		if order == nil || !isOpen(order) {
+			return
+		}
+		s.fill(order)
+		if order.OrderListId == -1 {
+			return
+		}
+		for _, o := range s.orders {
+			if o.OrderListId == order.OrderListId && isOpen(o) {
+				s.cancel(o)
+			}
+		}
+		s.removeOrderList(order.OrderListId)
+	}
+}
+
+// CancelOrder cancels the open order and sends the execution report.
+func CancelOrder(orderID int) Step {
+	return func(s *Server) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if order := s.order(orderID); order != nil && isOpen(order) {
+			s.cancel(order)
+		}
+	}
+}
+
+// Fail makes the next request to the path fail with the status and the Binance error code.
+func Fail(path string, status, code int, message string) Step {
+	return func(s *Server) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.failures[path] = append(s.failures[path], &failure{status: status, code: code, message: message})
+	}
+}
+
+// RateLimit makes the next request to the path fail with 429 Too Many Requests and a Retry-After header.
+func RateLimit(path string) Step {
+	return func(s *Server) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.failures[path] = append(s.failures[path], &failure{
+			status:     http.StatusTooManyRequests,
+			code:       CodeTooManyRequests,
+			message:    "Too many requests; current limit of IP is 6000 requests per minute.",
+			retryAfter: 1,
+		})
+	}
+}
+
+func (s *Server) SetPrice(symbol, price string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prices[symbol] = price
+}
+
+func (s *Server) SetBalance(asset, free, locked string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range s.balances {
+		if b.Asset == asset {
+			b.Free, b.Locked = free, locked
+			return
+		}
+	}
+	s.balances = append(s.balances, &balance{Asset: asset, Free: free, Locked: locked})
+}
+
+func (s *Server) AddSymbol(info *binance.SymbolInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.symbols = append(s.symbols, info)
+}
+
+// AddOrder stores the order, OrderId, OrderListId and times are set when they are zero.
+// Orders with NEW or PARTIALLY_FILLED status are open, others are only listed by allOrders.
+func (s *Server) AddOrder(order *binance.BinanceOrder) *binance.BinanceOrder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addOrder(order)
+}
+
+// AddOCO stores an open OCO order list of the limit maker and the stop loss limit legs.
+func (s *Server) AddOCO(req *binance.OCORequest) *binance.OrderList {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := &binance.OrderList{
+		OrderListID:       s.id(),
+		ContingencyType:   "OCO",
+		ListStatusType:    "EXEC_STARTED",
+		ListOrderStatus:   "EXECUTING",
+		ListClientOrderID: uuid.NewString(),
+		TransactionTime:   time.Now().UnixMilli(),
+		Symbol:            req.Symbol,
+	}
+	for _, leg := range req.Legs() {
+		order := s.addOrder(&binance.BinanceOrder{
+			Symbol:      leg.Symbol,
+			OrderListId: list.OrderListID,
+			Price:       leg.Price,
+			OrigQty:     leg.Quantity,
+			Status:      OrderStatusNew,
+			Type:        leg.Type,
+			Side:        leg.Side,
+			StopPrice:   leg.StopPrice,
+		})
+		list.Orders = append(list.Orders, &binance.OrderListOrder{Symbol: order.Symbol, OrderID: order.OrderId, ClientOrderID: order.ClientOrderId})
+	}
+	s.orderLists = append(s.orderLists, list)
+	return list
+}
+
+func (s *Server) addOrder(order *binance.BinanceOrder) *binance.BinanceOrder {
+	o := *order
+	if o.OrderId == 0 {
+		o.OrderId = s.id()
+	}
+	if o.OrderListId == 0 {
+		o.OrderListId = -1
+	}
+	if o.ClientOrderId == "" {
+		o.ClientOrderId = uuid.NewString()
+	}
+	if o.Time == 0 {
+		o.Time = int(time.Now().UnixMilli())
+		o.UpdateTime = o.Time
+	}
+	o.IsWorking = isOpen(&o)
+	s.orders = append(s.orders, &o)
+	return &o
+}
+
+func (s *Server) id() int {
+	id := s.nextID
+	s.nextID++
+	return id
+}
+
+func (s *Server) order(orderID int) *binance.BinanceOrder {
+	for _, o := range s.orders {
+		if o.OrderId == orderID {
+			return o
+		}
+	}
+	return nil
+}
+
+func (s *Server) fill(order *binance.BinanceOrder) {
+	price, qty := order.Price, order.OrigQty
+	if p, err := decimal.Parse(price); err != nil || p.IsZero() {
+		price = s.prices[order.Symbol]
+	}
+	quote := ""
+	if p, err := decimal.Parse(price); err == nil {
+		if q, err := decimal.Parse(qty); err == nil {
+			quote = p.Mul(q).String()
+		}
+	}
+	order.Status = OrderStatusFilled
+	order.ExecutedQty = qty
+	order.CummulativeQuoteQty = quote
+	order.IsWorking = false
+	order.UpdateTime = int(time.Now().UnixMilli())
+	s.trades = append(s.trades, &Trade{
+		Symbol:          order.Symbol,
+		ID:              s.id(),
+		OrderID:         order.OrderId,
+		OrderListID:     order.OrderListId,
+		Price:           price,
+		Qty:             qty,
+		QuoteQty:        quote,
+		Commission:      "0",
+		CommissionAsset: "BNB",
+		Time:            int64(order.UpdateTime),
+		IsBuyer:         order.Side == binance.SideBuy,
+		IsMaker:         order.Type != binance.OrderTypeMarket,
+		IsBestMatch:     true,
+	})
+	s.publish(executionReport(order, "TRADE", price, qty))
+}
+
+func (s *Server) cancel(order *binance.BinanceOrder) {
+	order.Status = OrderStatusCanceled
+	order.IsWorking = false
+	order.UpdateTime = int(time.Now().UnixMilli())
+	s.publish(executionReport(order, OrderStatusCanceled, "0", "0"))
+	if order.OrderListId != -1 {
+		s.removeOrderList(order.OrderListId)
+	}
+}
+
+func (s *Server) removeOrderList(orderListID int) {
+	kept := s.orderLists[:0]
+	for _, l := range s.orderLists {
+		if l.OrderListID != orderListID {
+			kept = append(kept, l)
+		}
+	}
+	s.orderLists = kept
+}
+
+func isOpen(o *binance.BinanceOrder) bool {
+	return o.Status == OrderStatusNew || o.Status == OrderStatusPartiallyFilled
+}
+
+// count records the request and answers with the scripted failure of the path if there is one.
+func (s *Server) count(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+		var f *failure
+		if queued := s.failures[r.URL.Path]; len(queued) > 0 {
+			f, s.failures[r.URL.Path] = queued[0], queued[1:]
+		}
+		s.mu.Unlock()
+
+		if f == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if f.retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(f.retryAfter))
+		}
+		writeError(w, f.status, f.code, f.message)
+	})
+}
+
+func (s *Server) withAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(binance.ApiKeyHeaderName) != s.APIKey {
+			writeError(w, http.StatusUnauthorized, CodeInvalidAPIKey, "Invalid API-key, IP, or permissions for action.")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// signed checks the API key and the HMAC SHA256 signature of the query, which should be the last parameter.
+func (s *Server) signed(next http.HandlerFunc) http.HandlerFunc {
+	return s.withAPIKey(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.RawQuery
+		i := strings.LastIndex(query, "signature=")
+		if i < 0 {
+			writeError(w, http.StatusBadRequest, CodeMandatoryParam, "Mandatory parameter 'signature' was not sent, was empty/null, or malformed.")
+			return
+		}
+		payload := strings.TrimSuffix(query[:i], "&")
+		h := hmac.New(sha256.New, []byte(s.APISecret))
+		h.Write([]byte(payload))
+		if !hmac.Equal([]byte(query[i+len("signature="):]), []byte(hex.EncodeToString(h.Sum(nil)))) {
+			writeError(w, http.StatusBadRequest, CodeInvalidSignature, "Signature for this request is not valid.")
+			return
+		}
+		if r.URL.Query().Get("timestamp") == "" {
+			writeError(w, http.StatusBadRequest, CodeMandatoryParam, "Mandatory parameter 'timestamp' was not sent, was empty/null, or malformed.")
+			return
+		}
+		next(w, r)
+	})
+}
+
+func (s *Server) handlePrices(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if symbol := r.URL.Query().Get("symbol"); symbol != "" {
+		price, ok := s.prices[symbol]
+		if !ok {
+			writeError(w, http.StatusBadRequest, CodeInvalidSymbol, "Invalid symbol.")
+			return
+		}
+		writeJSON(w, map[string]string{"symbol": symbol, "price": price})
+		return
+	}
+	symbols := make([]string, 0, len(s.prices))
+	for symbol := range s.prices {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	prices := make([]map[string]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		prices = append(prices, map[string]string{"symbol": symbol, "price": s.prices[symbol]})
+	}
+	writeJSON(w, prices)
+}
+
+func (s *Server) handleExchangeInfo(w http.ResponseWriter, r *http.Request) {
+	var wanted []string
+	if symbol := r.URL.Query().Get("symbol"); symbol != "" {
+		wanted = []string{symbol}
+	}
+	if symbols := r.URL.Query().Get("symbols"); symbols != "" {
+		if err := json.Unmarshal([]byte(symbols), &wanted); err != nil {
+			writeError(w, http.StatusBadRequest, CodeMandatoryParam, "Illegal characters found in parameter 'symbols'.")
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info := &binance.ExchangeInfo{ServerTime: time.Now().UnixMilli(), Symbols: make([]*binance.SymbolInfo, 0, len(s.symbols))}
+	for _, symbol := range s.symbols {
+		if len(wanted) == 0 || contains(wanted, symbol.Symbol) {
+			info.Symbols = append(info.Symbols, symbol)
+		}
+	}
+	if len(wanted) > 0 && len(info.Symbols) == 0 {
+		writeError(w, http.StatusBadRequest, CodeInvalidSymbol, "Invalid symbol.")
+		return
+	}
+	writeJSON(w, info)
+}
+
+func (s *Server) handleOpenOrders(w http.ResponseWriter, r *http.Request) {
+	symbol := r.URL.Query().Get("symbol")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	orders := make([]*binance.BinanceOrder, 0)
+	for _, o := range s.orders {
+		if isOpen(o) && (symbol == "" || o.Symbol == symbol) {
+			orders = append(orders, o)
+		}
+	}
+	writeJSON(w, orders)
+}
+
+func (s *Server) handleAllOrders(w http.ResponseWriter, r *http.Request) {
+	symbol := r.URL.Query().Get("symbol")
+	if symbol == "" {
+		writeError(w, http.StatusBadRequest, CodeMandatoryParam, "Mandatory parameter 'symbol' was not sent, was empty/null, or malformed.")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	orders := make([]*binance.BinanceOrder, 0)
+	for _, o := range s.orders {
+		if o.Symbol == symbol {
+			orders = append(orders, o)
+		}
+	}
+	writeJSON(w, orders)
+}
+
+func (s *Server) handleOpenOrderLists(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lists := make([]*binance.OrderList, 0, len(s.orderLists))
+	lists = append(lists, s.orderLists...)
+	writeJSON(w, lists)
+}
+
+func (s *Server) handleAccount(w http.ResponseWriter, r *http.Request) {
+	omitZero := r.URL.Query().Get("omitZeroBalances") == "true"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	balances := make([]*balance, 0, len(s.balances))
+	for _, b := range s.balances {
+		if omitZero && isZero(b.Free) && isZero(b.Locked) {
+			continue
+		}
+		balances = append(balances, b)
+	}
+	writeJSON(w, map[string]interface{}{"canTrade": true, "accountType": "SPOT", "balances": balances})
+}
+
+func (s *Server) handleMyTrades(w http.ResponseWriter, r *http.Request) {
+	symbol := r.URL.Query().Get("symbol")
+	if symbol == "" {
+		writeError(w, http.StatusBadRequest, CodeMandatoryParam, "Mandatory parameter 'symbol' was not sent, was empty/null, or malformed.")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	trades := make([]*Trade, 0)
+	for _, t := range s.trades {
+		if t.Symbol == symbol {
+			trades = append(trades, t)
+		}
+	}
+	writeJSON(w, trades)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"code":%d,"msg":%q}`, code, message)
+}
+
+func isZero(value string) bool {
+	d, err := decimal.Parse(value)
+	return err == nil && d.IsZero()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
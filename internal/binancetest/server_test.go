@@ -0,0 +1,198 @@
+package binancetest
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+)
+
+func TestSignatureIsVerified(t *testing.T) {
+	s := NewServer("key", "secret")
+	defer s.Close()
+	s.SetBalance("BTC", "0.5", "0")
+	s.SetBalance("ETH", "0", "0")
+
+	balances, err := binance.New("key", "secret", s.URL).GetBalances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(balances) != 1 || balances[0].Asset != "BTC" {
+		t.Fatalf("unexpected balances %+v", balances)
+	}
+
+	_, err = binance.New("key", "wrong", s.URL).GetBalances()
+	if apiErr, ok := err.(*binance.APIError); !ok || apiErr.Code != CodeInvalidSignature {
+		t.Fatalf("expected invalid signature error, got %v", err)
+	}
+	_, err = binance.New("wrong", "secret", s.URL).GetOrders()
+	if apiErr, ok := err.(*binance.APIError); !ok || apiErr.Status != http.StatusUnauthorized || apiErr.Code != CodeInvalidAPIKey {
+		t.Fatalf("expected invalid api key error, got %v", err)
+	}
+}
+
+func TestScriptedFailures(t *testing.T) {
+	s := NewServer("key", "secret")
+	defer s.Close()
+	s.SetPrice("BTCUSDT", "45000")
+	c := binance.New("key", "secret", s.URL)
+
+	s.Run(RateLimit("/api/v3/ticker/price"), Fail("/api/v3/openOrders", http.StatusServiceUnavailable, -1001, "Internal error; unable to process your request. Please try again."))
+	_, err := c.GetPrices()
+	if apiErr, ok := err.(*binance.APIError); !ok || apiErr.Status != http.StatusTooManyRequests || apiErr.Code != CodeTooManyRequests {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+	if _, err = c.GetOrders(); err == nil {
+		t.Fatal("expected scripted error")
+	}
+	// failures are used once
+	if prices, err := c.GetPrices(); err != nil || len(prices) != 1 {
+		t.Fatalf("unexpected prices %+v, %v", prices, err)
+	}
+	if n := s.Requests("/api/v3/ticker/price"); n != 2 {
+		t.Fatalf("expected 2 price requests, got %d", n)
+	}
+}
+
+func TestFillOCO(t *testing.T) {
+	s := NewServer("key", "secret")
+	defer s.Close()
+	s.SetPrice("BTCUSDT", "45000")
+	list := s.AddOCO(&binance.OCORequest{Symbol: "BTCUSDT", Side: binance.SideSell, Quantity: "0.01", Price: "50000", StopPrice: "40000", StopLimitPrice: "39900"})
+	c := binance.New("key", "secret", s.URL)
+
+	if lists, err := c.GetOpenOrderLists(); err != nil || len(lists) != 1 || len(lists[0].Orders) != 2 {
+		t.Fatalf("unexpected order lists %+v, %v", lists, err)
+	}
+	s.Run(MovePrice("BTCUSDT", "50000"), FillOrder(list.Orders[0].OrderID))
+
+	if open, err := c.GetOrders(); err != nil || len(open) != 0 {
+		t.Fatalf("both legs should be closed, got %+v, %v", open, err)
+	}
+	if lists, err := c.GetOpenOrderLists(); err != nil || len(lists) != 0 {
+		t.Fatalf("order list should be closed, got %+v, %v", lists, err)
+	}
+	all, err := c.GetAllOrdersForSymbol("BTCUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].Status != OrderStatusFilled || all[1].Status != OrderStatusCanceled {
+		t.Fatalf("unexpected order history %+v", all)
+	}
+
+	var trades []*Trade
+	if err = signedGet(s, "/api/v3/myTrades", "symbol=BTCUSDT", &trades); err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 1 || trades[0].OrderID != list.Orders[0].OrderID || trades[0].QuoteQty != "500" {
+		t.Fatalf("unexpected trades %+v", trades)
+	}
+}
+
+func TestUserDataStream(t *testing.T) {
+	s := NewServer("key", "secret")
+	defer s.Close()
+	order := s.AddOrder(&binance.BinanceOrder{Symbol: "ETHUSDT", Price: "2000", OrigQty: "1", Status: OrderStatusNew, Type: binance.OrderTypeLimit, Side: binance.SideBuy})
+
+	req, _ := http.NewRequest(http.MethodPost, s.URL+"/api/v3/userDataStream", nil)
+	req.Header.Set(binance.ApiKeyHeaderName, "key")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		ListenKey string `json:"listenKey"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&body)
+	res.Body.Close()
+	if err != nil || body.ListenKey == "" {
+		t.Fatalf("listen key was not created: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(s.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprintf(conn, "GET /ws/%s HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n", body.ListenKey)
+	r := bufio.NewReader(conn)
+	handshake, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handshake.StatusCode != http.StatusSwitchingProtocols || handshake.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected handshake %d %v", handshake.StatusCode, handshake.Header)
+	}
+
+	s.Run(FillOrder(order.OrderId))
+	report := &ExecutionReport{}
+	if err = json.Unmarshal(readFrame(t, r), report); err != nil {
+		t.Fatal(err)
+	}
+	if report.EventType != "executionReport" || report.OrderID != order.OrderId || report.OrderStatus != OrderStatusFilled || report.LastExecutedPrice != "2000" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
+
+func signedGet(s *Server, path, query string, v interface{}) error {
+	query += fmt.Sprintf("&timestamp=%d", time.Now().UnixMilli())
+	h := hmac.New(sha256.New, []byte(s.APISecret))
+	h.Write([]byte(query))
+	req, err := http.NewRequest(http.MethodGet, s.URL+path+"?"+query+"&signature="+hex.EncodeToString(h.Sum(nil)), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(binance.ApiKeyHeaderName, s.APIKey)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// readFrame reads an unmasked text frame written by the server.
+func readFrame(t *testing.T, r *bufio.Reader) []byte {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		t.Fatal(err)
+	}
+	if header[0] != 0x81 {
+		t.Fatalf("expected a final text frame, got %x", header[0])
+	}
+	n := uint64(header[1])
+	switch n {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(r, ext); err != nil {
+			t.Fatal(err)
+		}
+		n = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(r, ext); err != nil {
+			t.Fatal(err)
+		}
+		n = binary.BigEndian.Uint64(ext)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
@@ -0,0 +1,157 @@
+package binancetest
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+)
+
+// websocketGUID is appended to Sec-WebSocket-Key to build the handshake accept key, see RFC 6455.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const streamBufferSize = 64
+
+// ExecutionReport is the user data stream event sent when an order is filled or canceled.
+type ExecutionReport struct {
+	EventType         string `json:"e"`
+	EventTime         int64  `json:"E"`
+	Symbol            string `json:"s"`
+	ClientOrderID     string `json:"c"`
+	Side              string `json:"S"`
+	OrderType         string `json:"o"`
+	Quantity          string `json:"q"`
+	Price             string `json:"p"`
+	StopPrice         string `json:"P"`
+	ExecutionType     string `json:"x"`
+	OrderStatus       string `json:"X"`
+	OrderID           int    `json:"i"`
+	LastExecutedQty   string `json:"l"`
+	CumulativeQty     string `json:"z"`
+	LastExecutedPrice string `json:"L"`
+	OrderListID       int    `json:"g"`
+}
+
+func executionReport(o *binance.BinanceOrder, executionType, lastPrice, lastQty string) *ExecutionReport {
+	return &ExecutionReport{
+		EventType:         "executionReport",
+		EventTime:         time.Now().UnixMilli(),
+		Symbol:            o.Symbol,
+		ClientOrderID:     o.ClientOrderId,
+		Side:              o.Side,
+		OrderType:         o.Type,
+		Quantity:          o.OrigQty,
+		Price:             o.Price,
+		StopPrice:         o.StopPrice,
+		ExecutionType:     executionType,
+		OrderStatus:       o.Status,
+		OrderID:           o.OrderId,
+		LastExecutedQty:   lastQty,
+		CumulativeQty:     o.ExecutedQty,
+		LastExecutedPrice: lastPrice,
+		OrderListID:       o.OrderListId,
+	}
+}
+
+// publish sends the event to every open user data stream, events are dropped for streams which don't keep up.
+func (s *Server) publish(event interface{}) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	for _, stream := range s.streams {
+		select {
+		case stream <- data:
+		default:
+		}
+	}
+}
+
+// handleUserDataStream creates, keeps alive and closes listen keys, events of a listen key
+// are served as a WebSocket at /ws/<listenKey>.
+func (s *Server) handleUserDataStream(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPost:
+		key := strings.ReplaceAll(uuid.NewString(), "-", "")
+		s.streams[key] = make(chan []byte, streamBufferSize)
+		writeJSON(w, map[string]string{"listenKey": key})
+	case http.MethodPut, http.MethodDelete:
+		key := r.URL.Query().Get("listenKey")
+		stream, ok := s.streams[key]
+		if !ok {
+			writeError(w, http.StatusBadRequest, CodeInvalidListenKey, "This listenKey does not exist.")
+			return
+		}
+		if r.Method == http.MethodDelete {
+			close(stream)
+			delete(s.streams, key)
+		}
+		writeJSON(w, struct{}{})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleStream upgrades the connection to a WebSocket and writes stream events as text frames
+// until the listen key is closed.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	stream, ok := s.streams[strings.TrimPrefix(r.URL.Path, "/ws/")]
+	s.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	buf.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(h.Sum(nil)) + "\r\n\r\n")
+	if err = buf.Flush(); err != nil {
+		return
+	}
+	for data := range stream {
+		if _, err = conn.Write(textFrame(data)); err != nil {
+			return
+		}
+	}
+}
+
+// textFrame builds a final unmasked WebSocket text frame, servers never mask their frames.
+func textFrame(data []byte) []byte {
+	frame := []byte{0x81}
+	switch n := len(data); {
+	case n < 126:
+		frame = append(frame, byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(n))
+	default:
+		frame = append(frame, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(n))
+	}
+	return append(frame, data...)
+}
@@ -0,0 +1,246 @@
+package bybit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/decimal"
+	"github.com/morzhanov/binance-orders-watcher/internal/exchange"
+)
+
+const (
+	DefaultURI = "https://api.bybit.com"
+	// DefaultTimeout limits a whole request, so a stalled connection does not block the account fetch forever
+	DefaultTimeout = 30 * time.Second
+
+	recvWindow   = "10000"
+	categorySpot = "spot"
+	pageLimit    = "50"
+)
+
+type client struct {
+	apiKey     string
+	apiSecret  string
+	uri        string
+	httpClient *http.Client
+}
+
+// APIError is an error returned by the Bybit v5 API.
+type APIError struct {
+	Status  int
+	Code    int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("bybit error %d: %s", e.Code, e.Message)
+}
+
+type response struct {
+	RetCode int             `json:"retCode"`
+	RetMsg  string          `json:"retMsg"`
+	Result  json.RawMessage `json:"result"`
+}
+
+type orderList struct {
+	List           []*bybitOrder `json:"list"`
+	NextPageCursor string        `json:"nextPageCursor"`
+}
+
+type bybitOrder struct {
+	OrderID      string `json:"orderId"`
+	OrderLinkID  string `json:"orderLinkId"`
+	Symbol       string `json:"symbol"`
+	Price        string `json:"price"`
+	Qty          string `json:"qty"`
+	Side         string `json:"side"`
+	OrderStatus  string `json:"orderStatus"`
+	CumExecQty   string `json:"cumExecQty"`
+	CumExecValue string `json:"cumExecValue"`
+	TimeInForce  string `json:"timeInForce"`
+	OrderType    string `json:"orderType"`
+	TriggerPrice string `json:"triggerPrice"`
+	CreatedTime  string `json:"createdTime"`
+	UpdatedTime  string `json:"updatedTime"`
+}
+
+type tickerList struct {
+	List []*struct {
+		Symbol    string `json:"symbol"`
+		LastPrice string `json:"lastPrice"`
+	} `json:"list"`
+}
+
+type walletList struct {
+	List []*struct {
+		Coin []*struct {
+			Coin          string `json:"coin"`
+			WalletBalance string `json:"walletBalance"`
+			Locked        string `json:"locked"`
+		} `json:"coin"`
+	} `json:"list"`
+}
+
+// New creates the exchange driver for a Bybit unified trading account, only spot orders are loaded.
+// Requests are sent with httpClient, which should have a timeout.
+func New(apiKey, apiSecret, uri string, httpClient *http.Client) exchange.Driver {
+	if uri == "" {
+		uri = DefaultURI
+	}
+	return &client{apiKey: apiKey, apiSecret: apiSecret, uri: uri, httpClient: httpClient}
+}
+
+func (c *client) Exchange() string {
+	return exchange.Bybit
+}
+
+func (c *client) GetOpenOrders() ([]*exchange.Order, error) {
+	var orders []*exchange.Order
+	params := url.Values{}
+	params.Set("category", categorySpot)
+	params.Set("limit", pageLimit)
+	for {
+		page := &orderList{}
+		if err := c.request("/v5/order/realtime", params, true, page); err != nil {
+			return nil, err
+		}
+		converted, err := toExchangeOrders(page.List)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, converted...)
+		if page.NextPageCursor == "" || len(page.List) == 0 {
+			return orders, nil
+		}
+		// the cursor is returned URL encoded
+		cursor, err := url.QueryUnescape(page.NextPageCursor)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("cursor", cursor)
+	}
+}
+
+// GetOrderHistory returns the latest page of symbol orders, Bybit lists them newest first.
+func (c *client) GetOrderHistory(symbol string) ([]*exchange.Order, error) {
+	params := url.Values{}
+	params.Set("category", categorySpot)
+	params.Set("symbol", symbol)
+	params.Set("limit", pageLimit)
+	page := &orderList{}
+	if err := c.request("/v5/order/history", params, true, page); err != nil {
+		return nil, err
+	}
+	orders, err := toExchangeOrders(page.List)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(orders)-1; i < j; i, j = i+1, j-1 {
+		orders[i], orders[j] = orders[j], orders[i]
+	}
+	return orders, nil
+}
+
+func (c *client) GetPrices() ([]*db.Price, error) {
+	params := url.Values{}
+	params.Set("category", categorySpot)
+	tickers := &tickerList{}
+	if err := c.request("/v5/market/tickers", params, false, tickers); err != nil {
+		return nil, err
+	}
+	prices := make([]*db.Price, 0, len(tickers.List))
+	for _, t := range tickers.List {
+		prices = append(prices, &db.Price{Symbol: t.Symbol, Price: t.LastPrice})
+	}
+	return prices, nil
+}
+
+// GetBalances returns wallet balances of the unified account, locked funds are subtracted from the free ones.
+func (c *client) GetBalances() ([]*db.Balance, error) {
+	params := url.Values{}
+	params.Set("accountType", "UNIFIED")
+	wallets := &walletList{}
+	if err := c.request("/v5/account/wallet-balance", params, true, wallets); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixMilli()
+	var balances []*db.Balance
+	for _, w := range wallets.List {
+		for _, coin := range w.Coin {
+			total, err := parseDecimal(coin.WalletBalance)
+			if err != nil {
+				return nil, err
+			}
+			locked, err := parseDecimal(coin.Locked)
+			if err != nil {
+				return nil, err
+			}
+			if total.IsZero() && locked.IsZero() {
+				continue
+			}
+			balances = append(balances, &db.Balance{Asset: coin.Coin, Free: total.Sub(locked).String(), Locked: locked.String(), UpdatedAt: now})
+		}
+	}
+	return balances, nil
+}
+
+// request calls a GET endpoint and decodes the result field of the response into v. Private endpoints
+// are signed with the HMAC of the timestamp, API key, receive window and query.
+func (c *client) request(path string, params url.Values, signed bool, v interface{}) error {
+	query := params.Encode()
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s%s?%s", c.uri, path, query), nil)
+	if err != nil {
+		return err
+	}
+	if signed {
+		ts := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		req.Header.Set("X-BAPI-API-KEY", c.apiKey)
+		req.Header.Set("X-BAPI-TIMESTAMP", ts)
+		req.Header.Set("X-BAPI-RECV-WINDOW", recvWindow)
+		req.Header.Set("X-BAPI-SIGN", c.createSignature(ts+c.apiKey+recvWindow+query))
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	resp := &response{}
+	if err = json.Unmarshal(body, resp); err != nil {
+		if res.StatusCode != http.StatusOK {
+			return &APIError{Status: res.StatusCode, Message: string(body)}
+		}
+		return err
+	}
+	if res.StatusCode != http.StatusOK || resp.RetCode != 0 {
+		return &APIError{Status: res.StatusCode, Code: resp.RetCode, Message: resp.RetMsg}
+	}
+	return json.Unmarshal(resp.Result, v)
+}
+
+func (c *client) createSignature(text string) string {
+	h := hmac.New(sha256.New, []byte(c.apiSecret))
+	h.Write([]byte(text))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func parseDecimal(s string) (decimal.Decimal, error) {
+	if s == "" {
+		return decimal.Decimal{}, nil
+	}
+	return decimal.Parse(s)
+}
@@ -0,0 +1,121 @@
+package bybit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/exchange"
+)
+
+// fixtureServer serves responses recorded from the Bybit v5 API, keyed by path and cursor.
+func fixtureServer(t *testing.T, fixtures map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v5/market/tickers" && (r.Header.Get("X-BAPI-API-KEY") != "key" || len(r.Header.Get("X-BAPI-SIGN")) != 64) {
+			t.Errorf("unsigned request %s", r.URL.String())
+		}
+		name, ok := fixtures[r.URL.Path+"?"+r.URL.Query().Get("cursor")]
+		if !ok {
+			t.Errorf("unexpected request %s", r.URL.String())
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(body)
+	}))
+}
+
+func TestGetOpenOrders(t *testing.T) {
+	srv := fixtureServer(t, map[string]string{
+		"/v5/order/realtime?": "open_orders_page1.json",
+		"/v5/order/realtime?1321003749386327552:1684738540559,1321003749386327552:1684738540559": "open_orders_page2.json",
+	})
+	defer srv.Close()
+
+	orders, err := New("key", "secret", srv.URL, srv.Client()).GetOpenOrders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 {
+		t.Fatalf("expected orders from both pages, got %d", len(orders))
+	}
+	postOnly, stop := orders[0], orders[1]
+	if postOnly.OrderID != 1321003749386327552 || postOnly.Symbol != "ETHUSDT" || postOnly.Side != exchange.SideBuy ||
+		postOnly.Type != exchange.OrderTypeLimitMaker || postOnly.Status != exchange.OrderStatusNew || postOnly.Time != 1684738540559 || !postOnly.IsWorking {
+		t.Fatalf("unexpected post only order %+v", postOnly)
+	}
+	if stop.Type != exchange.OrderTypeStopLossLimit || stop.StopPrice != "25100" || stop.Side != exchange.SideSell || stop.IsWorking {
+		t.Fatalf("unexpected stop order %+v", stop)
+	}
+}
+
+func TestGetOrderHistoryOldestFirst(t *testing.T) {
+	srv := fixtureServer(t, map[string]string{"/v5/order/history?": "order_history.json"})
+	defer srv.Close()
+
+	orders, err := New("key", "secret", srv.URL, srv.Client()).GetOrderHistory("BTCUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 || orders[0].Price != "26500.00" || orders[1].Type != exchange.OrderTypeMarket || orders[1].Status != exchange.OrderStatusFilled {
+		t.Fatalf("unexpected history %+v %+v", orders[0], orders[1])
+	}
+}
+
+func TestGetPricesAndBalances(t *testing.T) {
+	srv := fixtureServer(t, map[string]string{
+		"/v5/market/tickers?":         "tickers.json",
+		"/v5/account/wallet-balance?": "wallet_balance.json",
+	})
+	defer srv.Close()
+	driver := New("key", "secret", srv.URL, srv.Client())
+
+	prices, err := driver.GetPrices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prices) != 2 || prices[0].Symbol != "BTCUSDT" || prices[0].Price != "26850.02" {
+		t.Fatalf("unexpected prices %+v", prices)
+	}
+
+	balances, err := driver.GetBalances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(balances) != 2 || balances[0].Asset != "BTC" || balances[0].Free != "0.498" || balances[0].Locked != "0.002" ||
+		balances[1].Asset != "USDT" || balances[1].Free != "100" || balances[1].Locked != "0" {
+		t.Fatalf("unexpected balances %+v %+v", balances[0], balances[1])
+	}
+}
+
+func TestRequestReturnsAPIError(t *testing.T) {
+	srv := fixtureServer(t, map[string]string{"/v5/order/realtime?": "error_invalid_key.json"})
+	defer srv.Close()
+
+	_, err := New("key", "secret", srv.URL, srv.Client()).GetOpenOrders()
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.Code != 10003 || apiErr.Message != "API key is invalid." {
+		t.Fatalf("expected API error, got %v", err)
+	}
+}
+
+func TestRequestTimesOut(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	httpClient := srv.Client()
+	httpClient.Timeout = 50 * time.Millisecond
+	if _, err := New("key", "secret", srv.URL, httpClient).GetOpenOrders(); err == nil {
+		t.Fatal("expected a stalled request to time out")
+	}
+}
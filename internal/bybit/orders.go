@@ -0,0 +1,93 @@
+package bybit
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/exchange"
+)
+
+var orderStatuses = map[string]string{
+	"New":                     exchange.OrderStatusNew,
+	"Untriggered":             exchange.OrderStatusNew,
+	"Triggered":               exchange.OrderStatusNew,
+	"PartiallyFilled":         exchange.OrderStatusPartiallyFilled,
+	"Filled":                  exchange.OrderStatusFilled,
+	"Cancelled":               exchange.OrderStatusCanceled,
+	"PartiallyFilledCanceled": exchange.OrderStatusCanceled,
+	"Rejected":                exchange.OrderStatusRejected,
+	"Deactivated":             exchange.OrderStatusExpired,
+}
+
+func toExchangeOrders(orders []*bybitOrder) ([]*exchange.Order, error) {
+	res := make([]*exchange.Order, 0, len(orders))
+	for _, o := range orders {
+		order, err := o.toExchangeOrder()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, order)
+	}
+	return res, nil
+}
+
+// toExchangeOrder converts the order to Binance names: conditional orders become stop loss orders
+// and post only limit orders become limit maker orders.
+func (o *bybitOrder) toExchangeOrder() (*exchange.Order, error) {
+	id, err := strconv.Atoi(o.OrderID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid order id %q: %s", o.OrderID, err)
+	}
+	createdTime, err := strconv.Atoi(o.CreatedTime)
+	if err != nil {
+		return nil, fmt.Errorf("order %s: invalid created time %q", o.OrderID, o.CreatedTime)
+	}
+	updatedTime, err := strconv.Atoi(o.UpdatedTime)
+	if err != nil {
+		return nil, fmt.Errorf("order %s: invalid updated time %q", o.OrderID, o.UpdatedTime)
+	}
+	status, ok := orderStatuses[o.OrderStatus]
+	if !ok {
+		return nil, fmt.Errorf("order %s: unknown status %q", o.OrderID, o.OrderStatus)
+	}
+	triggerPrice, err := parseDecimal(o.TriggerPrice)
+	if err != nil {
+		return nil, err
+	}
+
+	orderType, timeInForce := exchange.OrderTypeLimit, o.TimeInForce
+	switch {
+	case o.OrderType == "Market" && !triggerPrice.IsZero():
+		orderType = exchange.OrderTypeStopLoss
+	case o.OrderType == "Market":
+		orderType = exchange.OrderTypeMarket
+	case !triggerPrice.IsZero():
+		orderType = exchange.OrderTypeStopLossLimit
+	case o.TimeInForce == "PostOnly":
+		orderType, timeInForce = exchange.OrderTypeLimitMaker, ""
+	}
+	side := exchange.SideBuy
+	if o.Side == "Sell" {
+		side = exchange.SideSell
+	}
+
+	return &exchange.Order{
+		Symbol:              o.Symbol,
+		OrderID:             id,
+		OrderListID:         -1,
+		ClientOrderID:       o.OrderLinkID,
+		Price:               o.Price,
+		OrigQty:             o.Qty,
+		ExecutedQty:         o.CumExecQty,
+		CummulativeQuoteQty: o.CumExecValue,
+		Status:              status,
+		TimeInForce:         timeInForce,
+		Type:                orderType,
+		Side:                side,
+		StopPrice:           triggerPrice.String(),
+		IcebergQty:          "0",
+		Time:                createdTime,
+		UpdateTime:          updatedTime,
+		IsWorking:           o.OrderStatus != "Untriggered",
+	}, nil
+}
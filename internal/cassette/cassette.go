@@ -0,0 +1,208 @@
+// Package cassette records Binance API traffic to a file and replays it offline. Cassettes are redacted:
+// request headers, including the API key, and the signature, timestamp, recvWindow and listenKey
+// parameters are never written.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// redactedParams are removed from recorded queries and ignored when a request is matched on replay.
+var redactedParams = []string{"signature", "timestamp", "recvWindow", "listenKey"}
+
+// keptHeaders are the response headers written to cassettes, others may identify the account.
+var keptHeaders = []string{"Content-Type", "Retry-After"}
+
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  *Request  `json:"request"`
+	Response *Response `json:"response"`
+}
+
+// Request is matched by method, path and redacted query with sorted parameters.
+type Request struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query,omitempty"`
+}
+
+// Response holds the body as JSON, Text is set when the body was not JSON and Body is a JSON string.
+type Response struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body"`
+	Text    bool              `json:"text,omitempty"`
+}
+
+func (r *Request) key() string {
+	return r.Method + " " + r.Path + "?" + r.Query
+}
+
+func newRequest(req *http.Request) *Request {
+	query := req.URL.Query()
+	for _, param := range redactedParams {
+		query.Del(param)
+	}
+	return &Request{Method: req.Method, Path: req.URL.Path, Query: query.Encode()}
+}
+
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{}
+	if err = json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Save writes the cassette to a temporary file and renames it, so readers never see a partial cassette.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Recorder is a http.RoundTripper saving every interaction to the cassette file as soon as the response is read.
+type Recorder struct {
+	path     string
+	next     http.RoundTripper
+	mu       sync.Mutex
+	cassette *Cassette
+}
+
+// NewRecorder sends requests with next, http.DefaultTransport when nil, and records them to path.
+func NewRecorder(path string, next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{path: path, next: next, cassette: &Cassette{}}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	recorded := &Response{Status: res.StatusCode, Headers: make(map[string]string)}
+	for _, name := range keptHeaders {
+		if value := res.Header.Get(name); value != "" {
+			recorded.Headers[name] = value
+		}
+	}
+	if json.Valid(body) {
+		recorded.Body = body
+	} else {
+		recorded.Text = true
+		if recorded.Body, err = json.Marshal(string(body)); err != nil {
+			return nil, err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, &Interaction{Request: newRequest(req), Response: recorded})
+	if err = r.cassette.Save(r.path); err != nil {
+		return nil, fmt.Errorf("failed to save cassette: %w", err)
+	}
+	return res, nil
+}
+
+// Replayer is a http.RoundTripper serving recorded responses. Responses to the same request are served
+// in the recorded order and the last one is repeated, so the watcher can keep fetching a short cassette.
+// Requests which were not recorded get a 404 Binance error.
+type Replayer struct {
+	mu        sync.Mutex
+	responses map[string][]*Response
+	served    map[string]int
+}
+
+func NewReplayer(c *Cassette) *Replayer {
+	r := &Replayer{responses: make(map[string][]*Response), served: make(map[string]int)}
+	for _, i := range c.Interactions {
+		key := i.Request.key()
+		r.responses[key] = append(r.responses[key], i.Response)
+	}
+	return r
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	key := newRequest(req).key()
+	r.mu.Lock()
+	responses := r.responses[key]
+	n := r.served[key]
+	if n < len(responses)-1 {
+		r.served[key]++
+	}
+	r.mu.Unlock()
+
+	if len(responses) == 0 {
+		body := fmt.Sprintf(`{"code":-1,"msg":%q}`, "no recorded response for "+strings.TrimSuffix(key, "?"))
+		return newResponse(req, http.StatusNotFound, map[string]string{"Content-Type": "application/json"}, []byte(body)), nil
+	}
+	recorded := responses[n]
+	body := []byte(recorded.Body)
+	if recorded.Text {
+		var text string
+		if err := json.Unmarshal(recorded.Body, &text); err != nil {
+			return nil, err
+		}
+		body = []byte(text)
+	}
+	return newResponse(req, recorded.Status, recorded.Headers, body), nil
+}
+
+func newResponse(req *http.Request, status int, headers map[string]string, body []byte) *http.Response {
+	header := make(http.Header, len(headers))
+	for name, value := range headers {
+		header.Set(name, value)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,110 @@
+package cassette
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+	"github.com/morzhanov/binance-orders-watcher/internal/binancetest"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	s := binancetest.NewServer("recorded-key", "recorded-secret")
+	s.SetPrice("BTCUSDT", "45000")
+	s.SetBalance("BTC", "0.5", "0")
+	s.AddOrder(&binance.BinanceOrder{Symbol: "BTCUSDT", Price: "50000", OrigQty: "0.01", Status: binancetest.OrderStatusNew, Type: binance.OrderTypeLimit, Side: binance.SideSell})
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recorded := binance.NewWithHTTPClient(s.APIKey, s.APISecret, s.URL, &http.Client{Transport: NewRecorder(path, nil)})
+	if _, err := recorded.GetOrders(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recorded.GetBalances(); err != nil {
+		t.Fatal(err)
+	}
+	s.Run(binancetest.MovePrice("BTCUSDT", "46000"))
+	for i := 0; i < 2; i++ {
+		if _, err := recorded.GetPrices(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"recorded-key", "recorded-secret", "signature", "timestamp", "recvWindow"} {
+		if strings.Contains(string(data), secret) {
+			t.Fatalf("cassette should not contain %q:\n%s", secret, data)
+		}
+	}
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the replayed client has no credentials and the server is gone
+	replayed := binance.NewWithHTTPClient("", "", "https://api.binance.com", &http.Client{Transport: NewReplayer(c)})
+	orders, err := replayed.GetOrders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].Price != "50000" {
+		t.Fatalf("unexpected replayed orders %+v", orders)
+	}
+	balances, err := replayed.GetBalances()
+	if err != nil || len(balances) != 1 || balances[0].Free != "0.5" {
+		t.Fatalf("unexpected replayed balances %+v, %v", balances, err)
+	}
+	for _, want := range []string{"46000", "46000", "46000"} {
+		prices, err := replayed.GetPrices()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(prices) != 1 || prices[0].Price != want {
+			t.Fatalf("expected price %s, got %+v", want, prices)
+		}
+	}
+
+	_, err = replayed.GetAllOrdersForSymbol("ETHUSDT")
+	if apiErr, ok := err.(*binance.APIError); !ok || apiErr.Status != http.StatusNotFound {
+		t.Fatalf("expected not recorded error, got %v", err)
+	}
+}
+
+func TestReplayLeveragedClients(t *testing.T) {
+	c := &Cassette{Interactions: []*Interaction{
+		{Request: &Request{Method: http.MethodGet, Path: "/fapi/v2/account"}, Response: &Response{Status: http.StatusOK, Body: []byte(`{"totalMaintMargin":"1.5","totalMarginBalance":"200"}`)}},
+		{Request: &Request{Method: http.MethodGet, Path: "/sapi/v1/margin/account"}, Response: &Response{Status: http.StatusOK, Body: []byte(`{"marginLevel":"4","totalAssetOfBtc":"1","totalLiabilityOfBtc":"0.25"}`)}},
+	}}
+	httpClient := &http.Client{Transport: NewReplayer(c)}
+
+	status, err := binance.NewFutures("", "", "", httpClient).GetMarginStatus()
+	if err != nil || status.MarginRatio != "0.75" {
+		t.Fatalf("unexpected replayed futures margin status %+v, %v", status, err)
+	}
+	status, err = binance.NewMargin("", "", "https://api.binance.com", httpClient).GetMarginStatus()
+	if err != nil || status.MarginLevel != "4" {
+		t.Fatalf("unexpected replayed margin status %+v, %v", status, err)
+	}
+}
+
+func TestReplayServesResponsesInOrder(t *testing.T) {
+	c := &Cassette{Interactions: []*Interaction{
+		{Request: &Request{Method: http.MethodGet, Path: "/api/v3/ticker/price"}, Response: &Response{Status: http.StatusTooManyRequests, Headers: map[string]string{"Retry-After": "1"}, Body: []byte(`{"code":-1003,"msg":"Too many requests."}`)}},
+		{Request: &Request{Method: http.MethodGet, Path: "/api/v3/ticker/price"}, Response: &Response{Status: http.StatusOK, Body: []byte(`[{"symbol":"BTCUSDT","price":"45000.00"}]`)}},
+	}}
+	client := binance.NewWithHTTPClient("", "", "https://api.binance.com", &http.Client{Transport: NewReplayer(c)})
+
+	_, err := client.GetPrices()
+	if apiErr, ok := err.(*binance.APIError); !ok || apiErr.Status != http.StatusTooManyRequests || apiErr.Code != -1003 {
+		t.Fatalf("expected recorded rate limit, got %v", err)
+	}
+	if prices, err := client.GetPrices(); err != nil || len(prices) != 1 || prices[0].Price != "45000.00" {
+		t.Fatalf("unexpected prices %+v, %v", prices, err)
+	}
+}
@@ -0,0 +1,155 @@
+package certs
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	DefaultACMECacheDir = "./acme-cache"
+	challengePathPrefix = "/.well-known/acme-challenge/"
+)
+
+type ACMEConfig struct {
+	// Domains are the only hosts certificates are requested for
+	Domains []string
+	// Email is sent to the CA for expiry and problem notices, optional
+	Email string
+	// DirectoryURL defaults to Let's Encrypt, set it to a test CA such as Pebble
+	DirectoryURL string
+	// CACertPath is a PEM file of CAs trusted for the directory connection, for test CAs with their own root
+	CACertPath string
+	// CacheDir keeps the account key and the certificates between restarts
+	CacheDir string
+}
+
+type acmeSource struct {
+	manager *autocert.Manager
+}
+
+func NewACME(conf *ACMEConfig) (Source, error) {
+	if len(conf.Domains) == 0 {
+		return nil, errors.New("ACME needs at least one domain")
+	}
+	cacheDir := conf.CacheDir
+	if cacheDir == "" {
+		cacheDir = DefaultACMECacheDir
+	}
+	directoryURL := conf.DirectoryURL
+	if directoryURL == "" {
+		directoryURL = autocert.DefaultACMEDirectory
+	}
+	httpClient, err := acmeHTTPClient(conf.CACertPath)
+	if err != nil {
+		return nil, err
+	}
+	return &acmeSource{manager: &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cacheDir),
+		HostPolicy: autocert.HostWhitelist(conf.Domains...),
+		Email:      conf.Email,
+		Client:     &acme.Client{DirectoryURL: directoryURL, HTTPClient: httpClient},
+	}}, nil
+}
+
+// acmeHTTPClient trusts the system CAs and, when set, the CAs of the file.
+func acmeHTTPClient(caCertPath string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caCertPath != "" {
+		pem, err := ioutil.ReadFile(caCertPath)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caCertPath)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &http.Client{Transport: newOrderLocations(transport), Timeout: time.Minute}, nil
+}
+
+// orderLocations adds the order URL to finalize responses without a Location header. RFC 8555 doesn't require
+// the header there and CAs such as Pebble leave it out, but the acme client polls it while the certificate is issued.
+type orderLocations struct {
+	next http.RoundTripper
+
+	mu sync.Mutex
+	// orders maps finalize URLs to the URLs of their orders
+	orders map[string]string
+}
+
+func newOrderLocations(next http.RoundTripper) *orderLocations {
+	return &orderLocations{next: next, orders: make(map[string]string)}
+}
+
+func (t *orderLocations) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if err != nil || req.Method != http.MethodPost {
+		return res, err
+	}
+	t.mu.Lock()
+	order, ok := t.orders[req.URL.String()]
+	t.mu.Unlock()
+	if ok {
+		if res.Header.Get("Location") == "" {
+			res.Header.Set("Location", order)
+		}
+		return res, nil
+	}
+
+	// new orders are the responses with a Location header and a finalize URL
+	location := res.Header.Get("Location")
+	if location == "" || res.StatusCode != http.StatusCreated {
+		return res, nil
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	created := &struct {
+		Finalize string `json:"finalize"`
+	}{}
+	if json.Unmarshal(body, created) == nil && created.Finalize != "" {
+		t.mu.Lock()
+		t.orders[created.Finalize] = location
+		t.mu.Unlock()
+	}
+	return res, nil
+}
+
+func (s *acmeSource) TLSConfig() *tls.Config {
+	config := s.manager.TLSConfig()
+	config.MinVersion = tls.VersionTLS12
+	return config
+}
+
+func (s *acmeSource) HTTPHandler(h http.Handler) http.Handler {
+	challenges := s.manager.HTTPHandler(h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the host policy rejects hosts with a port, which is sent when the HTTP port is not 80
+		if host, _, err := net.SplitHostPort(r.Host); err == nil && strings.HasPrefix(r.URL.Path, challengePathPrefix) {
+			r = r.Clone(r.Context())
+			r.Host = host
+		}
+		challenges.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,154 @@
+package certs
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewACME(t *testing.T) {
+	if _, err := NewACME(&ACMEConfig{}); err == nil {
+		t.Fatal("expected a config without domains to fail")
+	}
+	if _, err := NewACME(&ACMEConfig{Domains: []string{"example.com"}, CACertPath: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Fatal("expected a missing CA file to fail")
+	}
+	invalid := filepath.Join(t.TempDir(), "ca.pem")
+	if err := ioutil.WriteFile(invalid, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewACME(&ACMEConfig{Domains: []string{"example.com"}, CACertPath: invalid}); err == nil {
+		t.Fatal("expected a CA file without certificates to fail")
+	}
+
+	source, err := NewACME(&ACMEConfig{Domains: []string{"example.com"}, CacheDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config := source.TLSConfig(); config.MinVersion != tls.VersionTLS12 || config.GetCertificate == nil {
+		t.Fatalf("unexpected TLS config %+v", config)
+	}
+}
+
+func TestACMEHTTPHandler(t *testing.T) {
+	source, err := NewACME(&ACMEConfig{Domains: []string{"example.com"}, CacheDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := source.HTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com/orders", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("expected other requests to be passed on, got %d", rec.Code)
+	}
+	for _, tc := range []struct {
+		target string
+		status int
+	}{
+		{target: "http://other.example.com/.well-known/acme-challenge/token", status: http.StatusForbidden},
+		{target: "http://example.com/.well-known/acme-challenge/unknown", status: http.StatusNotFound},
+		{target: "http://example.com:5002/.well-known/acme-challenge/unknown", status: http.StatusNotFound},
+	} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.target, nil))
+		if rec.Code != tc.status {
+			t.Fatalf("%s: got %d, want %d", tc.target, rec.Code, tc.status)
+		}
+	}
+}
+
+// TestACMEPebble gets a certificate from a local Pebble test CA, it runs when PEBBLE_DIRECTORY_URL is set.
+// Start Pebble from its repository with pebble-challtestsrv resolving every domain to 127.0.0.1,
+// then point the test at the directory and the minica root of Pebble:
+//
+//	pebble-challtestsrv -defaultIPv4 127.0.0.1 -defaultIPv6 "" -http01 "" -https01 "" -tlsalpn01 "" -doh "" &
+//	PEBBLE_VA_NOSLEEP=1 pebble -config ./test/config/pebble-config.json -dnsserver 127.0.0.1:8053
+//	PEBBLE_DIRECTORY_URL=https://localhost:14000/dir PEBBLE_CA_CERT_PATH=$PEBBLE/test/certs/pebble.minica.pem go test ./internal/certs
+//
+// Pebble validates HTTP-01 challenges on the httpPort of its config, 5002 by default.
+func TestACMEPebble(t *testing.T) {
+	directoryURL := os.Getenv("PEBBLE_DIRECTORY_URL")
+	if directoryURL == "" {
+		t.Skip("PEBBLE_DIRECTORY_URL is not set")
+	}
+	httpPort := os.Getenv("PEBBLE_HTTP_PORT")
+	if httpPort == "" {
+		httpPort = "5002"
+	}
+	domain := "watcher.example.com"
+	source, err := NewACME(&ACMEConfig{
+		Domains:      []string{domain},
+		DirectoryURL: directoryURL,
+		CACertPath:   os.Getenv("PEBBLE_CA_CERT_PATH"),
+		CacheDir:     t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenges := &http.Server{Addr: ":" + httpPort, Handler: source.HTTPHandler(http.NotFoundHandler())}
+	go challenges.ListenAndServe()
+	defer challenges.Close()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", source.TLSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.NotFoundHandler()}
+	go server.Serve(listener)
+	defer server.Close()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	conn, err := tls.Dial("tcp", "127.0.0.1:"+port, &tls.Config{ServerName: domain, InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 || len(certs[0].DNSNames) != 1 || certs[0].DNSNames[0] != domain {
+		t.Fatalf("unexpected certificate %+v", certs)
+	}
+}
+
+func TestOrderLocations(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/new-order":
+			w.Header().Set("Location", server.URL+"/order/1")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"status":"ready","finalize":"` + server.URL + `/finalize/1"}`))
+		case "/finalize/1":
+			w.Write([]byte(`{"status":"processing"}`))
+		}
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: newOrderLocations(http.DefaultTransport)}
+
+	res, err := client.Post(server.URL+"/new-order", "application/jose+json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if !strings.Contains(string(body), "finalize") {
+		t.Fatalf("expected the order body to be passed on, got %s", body)
+	}
+	res, err = client.Post(server.URL+"/finalize/1", "application/jose+json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if location := res.Header.Get("Location"); location != server.URL+"/order/1" {
+		t.Fatalf("expected the order URL, got %q", location)
+	}
+}
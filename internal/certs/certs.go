@@ -0,0 +1,18 @@
+// Package certs provides the TLS certificates of the app, loaded from files or obtained from an ACME CA.
+//
+// Certificate files are reloaded when they change on disk, so renewed certificates are used without a restart.
+// ACME certificates are stored in a cache directory and renewed before they expire, the CA validates the domain
+// with HTTP-01 challenges answered by the HTTP handler on port 80.
+package certs
+
+import (
+	"crypto/tls"
+	"net/http"
+)
+
+type Source interface {
+	// TLSConfig returns the config of the HTTPS server, certificates are picked on every handshake.
+	TLSConfig() *tls.Config
+	// HTTPHandler answers ACME HTTP-01 challenges and passes other requests of the HTTP port to h.
+	HTTPHandler(h http.Handler) http.Handler
+}
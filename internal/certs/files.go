@@ -0,0 +1,101 @@
+package certs
+
+import (
+	"crypto/tls"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultCheckInterval limits how often the certificate files are checked for changes.
+const DefaultCheckInterval = 10 * time.Second
+
+type files struct {
+	certPath      string
+	keyPath       string
+	checkInterval time.Duration
+	now           func() time.Time
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// NewFiles loads the certificate and the key, it fails when they can't be loaded so a broken setup is found on start.
+func NewFiles(certPath, keyPath string, checkInterval time.Duration) (Source, error) {
+	f := &files{certPath: certPath, keyPath: keyPath, checkInterval: checkInterval, now: time.Now}
+	modTime, err := f.lastModified()
+	if err != nil {
+		return nil, err
+	}
+	if err = f.load(modTime); err != nil {
+		return nil, err
+	}
+	f.checkedAt = f.now()
+	return f, nil
+}
+
+func (f *files) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: f.getCertificate,
+	}
+}
+
+func (f *files) HTTPHandler(h http.Handler) http.Handler {
+	return h
+}
+
+// getCertificate reloads the files when they were changed since the last load. The last good certificate
+// is kept when the new files can't be loaded, e.g. while only one of them was replaced.
+func (f *files) getCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.now()
+	if now.Sub(f.checkedAt) < f.checkInterval {
+		return f.cert, nil
+	}
+	f.checkedAt = now
+
+	modTime, err := f.lastModified()
+	if err != nil {
+		log.Println("failed to check the TLS certificate files: ", err)
+		return f.cert, nil
+	}
+	if !modTime.After(f.modTime) {
+		return f.cert, nil
+	}
+	if err = f.load(modTime); err != nil {
+		log.Println("failed to reload the TLS certificate, using the previous one: ", err)
+		return f.cert, nil
+	}
+	log.Printf("reloaded the TLS certificate %s", f.certPath)
+	return f.cert, nil
+}
+
+func (f *files) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(f.certPath, f.keyPath)
+	if err != nil {
+		return err
+	}
+	f.cert, f.modTime = &cert, modTime
+	return nil
+}
+
+// lastModified returns the later modification time of the certificate and the key.
+func (f *files) lastModified() (time.Time, error) {
+	var modTime time.Time
+	for _, path := range []string{f.certPath, f.keyPath} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return modTime, nil
+}
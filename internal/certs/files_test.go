@@ -0,0 +1,114 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate of the common name and its key, modified at modTime.
+func writeCert(t *testing.T, certPath, keyPath, commonName string, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{certPath, keyPath} {
+		if err = os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func commonName(t *testing.T, source Source) string {
+	cert, err := source.TLSConfig().GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Subject.CommonName
+}
+
+func TestFilesReload(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	modTime := time.Now().Add(-time.Hour)
+	writeCert(t, certPath, keyPath, "first.example.com", modTime)
+
+	source, err := NewFiles(certPath, keyPath, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := source.(*files)
+	now := time.Now()
+	f.now = func() time.Time { return now }
+	if name := commonName(t, source); name != "first.example.com" {
+		t.Fatalf("unexpected certificate %s", name)
+	}
+
+	writeCert(t, certPath, keyPath, "second.example.com", modTime.Add(time.Minute))
+	if name := commonName(t, source); name != "first.example.com" {
+		t.Fatalf("files should not be checked before the interval, got %s", name)
+	}
+	now = now.Add(time.Minute)
+	if name := commonName(t, source); name != "second.example.com" {
+		t.Fatalf("expected the changed files to be reloaded, got %s", name)
+	}
+
+	// a half written key keeps the previous certificate until the files are fixed
+	if err = ioutil.WriteFile(keyPath, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chtimes(keyPath, modTime.Add(2*time.Minute), modTime.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+	if name := commonName(t, source); name != "second.example.com" {
+		t.Fatalf("expected the previous certificate to be kept, got %s", name)
+	}
+	writeCert(t, certPath, keyPath, "third.example.com", modTime.Add(3*time.Minute))
+	now = now.Add(time.Minute)
+	if name := commonName(t, source); name != "third.example.com" {
+		t.Fatalf("expected the fixed files to be reloaded, got %s", name)
+	}
+}
+
+func TestFilesMissing(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewFiles(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), time.Minute); err == nil {
+		t.Fatal("expected missing files to fail on start")
+	}
+}
@@ -0,0 +1,54 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/decimal"
+)
+
+// Trigger is a candle during which a backtested alert would have fired.
+type Trigger struct {
+	Time  int64  `json:"time"`
+	Price string `json:"price"`
+}
+
+// Backtest replays klines through the price alert evaluation of Check and returns every trigger.
+// Check deletes an alert when it fires, so each trigger is counted as if the alert was created again:
+// the alert re-arms once a candle closes on the untriggered side of the limit. A candle reaches the
+// limit when its high, or its low for DirectionDown alerts, does.
+func Backtest(alert *db.Alert, klines []*db.Kline) ([]*Trigger, error) {
+	if alertType(alert) != db.AlertTypePrice {
+		return nil, fmt.Errorf("only %s alerts can be backtested", db.AlertTypePrice)
+	}
+	var triggers []*Trigger
+	armed := true
+	for _, k := range klines {
+		extreme := k.High
+		if alert.DirectionDown {
+			extreme = k.Low
+		}
+		if armed {
+			triggered, err := alertTriggered(alert, extreme)
+			if err != nil {
+				return nil, err
+			}
+			if triggered {
+				triggers = append(triggers, &Trigger{Time: k.OpenTime, Price: extreme})
+				armed = false
+			}
+		}
+		if !armed {
+			closePrice, err := decimal.Parse(k.Close)
+			if err != nil {
+				return nil, err
+			}
+			closeReached, err := reached(alert, closePrice)
+			if err != nil {
+				return nil, err
+			}
+			armed = !closeReached
+		}
+	}
+	return triggers, nil
+}
@@ -0,0 +1,46 @@
+package checker
+
+import (
+	"testing"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+func kline(openTime int64, high, low, close string) *db.Kline {
+	return &db.Kline{Symbol: "BTCUSDT", Interval: "1h", OpenTime: openTime, Open: close, High: high, Low: low, Close: close}
+}
+
+func TestBacktest(t *testing.T) {
+	klines := []*db.Kline{
+		kline(1, "49000", "48000", "48500"),
+		// reaches 50000 and closes above it, the alert stays disarmed while the price stays above
+		kline(2, "50500", "48500", "50200"),
+		kline(3, "51000", "50100", "50800"),
+		// closes below the limit and re-arms
+		kline(4, "50900", "49000", "49500"),
+		kline(5, "50000", "49200", "49800"),
+		// wick through the limit re-arms in the same candle
+		kline(6, "50100", "49500", "49600"),
+		kline(7, "49900", "49000", "49100"),
+	}
+
+	triggers, err := Backtest(&db.Alert{Symbol: "BTCUSDT", Price: "50000"}, klines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggers) != 3 || triggers[0].Time != 2 || triggers[0].Price != "50500" || triggers[1].Time != 5 || triggers[2].Time != 6 {
+		t.Fatalf("unexpected up triggers %+v", triggers)
+	}
+
+	triggers, err = Backtest(&db.Alert{Symbol: "BTCUSDT", Price: "49000", DirectionDown: true}, klines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triggers) != 3 || triggers[0].Time != 1 || triggers[1].Time != 4 || triggers[2].Time != 7 || triggers[2].Price != "49000" {
+		t.Fatalf("unexpected down triggers %+v", triggers)
+	}
+
+	if _, err = Backtest(&db.Alert{Type: db.AlertTypeFundingRate, Symbol: "BTCUSDT", Price: "0.1"}, klines); err == nil {
+		t.Fatal("expected funding rate alerts to be rejected")
+	}
+}
@@ -1,13 +1,14 @@
 package checker
 
 import (
-	"errors"
 	"fmt"
 	"log"
-	"strconv"
+	"sync"
 
 	"github.com/morzhanov/binance-orders-watcher/internal/alertmanager"
 	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/decimal"
+	"github.com/morzhanov/binance-orders-watcher/internal/events"
 )
 
 type Checker interface {
@@ -17,51 +18,199 @@ type Checker interface {
 type checkerImp struct {
 	db           db.Client
 	alertManager alertmanager.Manager
+	bus          events.Bus
+	// mu keeps checks of the cron accounts and manual refreshes from sending the same alert twice
+	mu sync.Mutex
 }
 
-func New(dbClient db.Client, alertManager alertmanager.Manager) Checker {
-	return &checkerImp{db: dbClient, alertManager: alertManager}
+// AlertTriggered holds the current price, liquidation distance or funding rate depending on the alert type.
+type AlertTriggered struct {
+	Alert        *db.Alert `json:"alert"`
+	CurrentPrice string    `json:"currentPrice"`
+}
+
+func New(dbClient db.Client, alertManager alertmanager.Manager, bus events.Bus) Checker {
+	return &checkerImp{db: dbClient, alertManager: alertManager, bus: bus}
 }
 
 func (c *checkerImp) Check(prices []*db.Price) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	log.Println("checking alerts...")
 	alerts, err := c.db.GetAlerts()
 	if err != nil {
 		return err
 	}
 
+	var positions []*db.Position
+	var rates []*db.FundingRate
+	var positionsLoaded, ratesLoaded bool
 	for _, alert := range alerts {
-		var currentPrice float64
-		for _, price := range prices {
-			if price.Symbol == alert.Symbol {
-				currentPrice, err = strconv.ParseFloat(price.Price, 64)
-				if err != nil {
+		var current, text string
+		var triggered bool
+		switch alert.Type {
+		case db.AlertTypeLiquidationDistance:
+			if !positionsLoaded {
+				if positions, err = c.db.GetPositions(); err != nil {
+					return err
+				}
+				positionsLoaded = true
+			}
+			if current, triggered, err = liquidationDistanceTriggered(alert, positions); err != nil {
+				return err
+			}
+			text = fmt.Sprintf("Binance Futures ALERT! %s position of account %s is %s%% away from liquidation, limit %s%%", alert.Symbol, alert.AccountID, current, alert.Price)
+		case db.AlertTypeFundingRate:
+			if !ratesLoaded {
+				if rates, err = c.db.GetFundingRates(); err != nil {
 					return err
 				}
-				break
+				ratesLoaded = true
+			}
+			if current, triggered, err = fundingRateTriggered(alert, rates); err != nil {
+				return err
+			}
+			text = fmt.Sprintf("Binance Futures ALERT! %s funding rate %s%% reached limit %s%%", alert.Symbol, current, alert.Price)
+		default:
+			var currentPrice *db.Price
+			for _, price := range prices {
+				if price.Symbol == alert.Symbol {
+					currentPrice = price
+					break
+				}
+			}
+			if currentPrice == nil {
+				log.Printf("skipping alert %s: price for symbol %s is not found in prices array", alert.ID, alert.Symbol)
+				continue
 			}
+			if triggered, err = alertTriggered(alert, currentPrice.Price); err != nil {
+				return err
+			}
+			current = currentPrice.Price
+			text = fmt.Sprintf("Binance Order ALERT! Order %s price %s near limit %s", alert.Symbol, alert.Price, current)
 		}
-		if currentPrice == 0 {
-			return errors.New(fmt.Sprintf("price for symbol %s is not found in prices array", alert.Symbol))
+		if !triggered {
+			continue
 		}
 
-		parsedAlertPrice, err := strconv.ParseFloat(alert.Price, 64)
+		log.Printf("sending %s alert for symbol %s: value %s, limit %s", alertType(alert), alert.Symbol, current, alert.Price)
+		if alert.Text != "" {
+			text += "\n\n Additional info: " + alert.Text
+		}
+		email, name, err := c.recipient(alert)
 		if err != nil {
 			return err
 		}
-		if alert.DirectionDown && currentPrice <= parsedAlertPrice || !alert.DirectionDown && currentPrice >= parsedAlertPrice {
-			log.Printf("sending alert for symbol %s: price %s near limit %f", alert.Symbol, alert.Price, currentPrice)
-			text := fmt.Sprintf("Binance Order ALERT! Order %s price %s near limit %f", alert.Symbol, alert.Price, currentPrice)
-			if alert.Text != "" {
-				text += "\n\n Additional info: " + alert.Text
-			}
-			if err = c.alertManager.SendAlert(alert.Email, alert.Name, text); err != nil {
-				return err
-			}
-			if err = c.db.DeleteAlert(alert.ID); err != nil {
-				return err
-			}
+		if err = c.alertManager.SendAlert(email, name, text); err != nil {
+			return err
+		}
+		if err = c.db.DeleteAlert(alert.ID); err != nil {
+			return err
+		}
+		event := db.NewEvent(db.EventTypeAlertTriggered, alert.Symbol, fmt.Sprintf("%s alert %s triggered at %s, limit %s", alertType(alert), alert.ID, current, alert.Price))
+		event.UserID = alert.UserID
+		if err = c.db.AddEvent(event); err != nil {
+			log.Println("failed to store alert event: ", err)
 		}
+		c.bus.Publish(events.TypeAlertTriggered, &AlertTriggered{Alert: alert, CurrentPrice: current})
 	}
 	return nil
 }
+
+// recipient returns the email and name of the alert owner, alerts without an owner are sent to their own email.
+func (c *checkerImp) recipient(alert *db.Alert) (string, string, error) {
+	if alert.UserID == "" {
+		return alert.Email, alert.Name, nil
+	}
+	user, err := c.db.GetUser(alert.UserID)
+	if err != nil {
+		return "", "", err
+	}
+	if user == nil || user.Email == "" {
+		return alert.Email, alert.Name, nil
+	}
+	return user.Email, user.Name, nil
+}
+
+// alertType returns the alert type, alerts stored before types were introduced are price alerts.
+func alertType(alert *db.Alert) string {
+	if alert.Type == "" {
+		return db.AlertTypePrice
+	}
+	return alert.Type
+}
+
+// alertTriggered compares prices exactly, an alert is triggered when the price reaches the limit.
+func alertTriggered(alert *db.Alert, currentPrice string) (bool, error) {
+	price, err := decimal.Parse(currentPrice)
+	if err != nil {
+		return false, err
+	}
+	return reached(alert, price)
+}
+
+// liquidationDistanceTriggered returns the smallest distance between the mark and the liquidation price of
+// the alert account positions in percent of the mark price. Positions which can't be liquidated are skipped.
+func liquidationDistanceTriggered(alert *db.Alert, positions []*db.Position) (string, bool, error) {
+	var closest *decimal.Decimal
+	for _, p := range positions {
+		if p.AccountID != alert.AccountID || p.Symbol != alert.Symbol {
+			continue
+		}
+		mark, err := decimal.Parse(p.MarkPrice)
+		if err != nil {
+			return "", false, err
+		}
+		liquidation, err := decimal.Parse(p.LiquidationPrice)
+		if err != nil {
+			return "", false, err
+		}
+		if liquidation.IsZero() || mark.IsZero() {
+			continue
+		}
+		distance, err := mark.Sub(liquidation).Abs().Mul(decimal.NewFromInt(100)).Div(mark)
+		if err != nil {
+			return "", false, err
+		}
+		if closest == nil || distance.LessThan(*closest) {
+			closest = &distance
+		}
+	}
+	if closest == nil {
+		return "", false, nil
+	}
+	limit, err := decimal.Parse(alert.Price)
+	if err != nil {
+		return "", false, err
+	}
+	return closest.StringFixed(2), closest.Cmp(limit) <= 0, nil
+}
+
+// fundingRateTriggered converts the symbol funding rate to percent and compares it with the alert limit.
+func fundingRateTriggered(alert *db.Alert, rates []*db.FundingRate) (string, bool, error) {
+	for _, r := range rates {
+		if r.Symbol != alert.Symbol {
+			continue
+		}
+		rate, err := decimal.Parse(r.FundingRate)
+		if err != nil {
+			return "", false, err
+		}
+		percent := rate.Mul(decimal.NewFromInt(100))
+		triggered, err := reached(alert, percent)
+		return percent.String(), triggered, err
+	}
+	return "", false, nil
+}
+
+// reached reports whether the value reached the alert limit in the alert direction.
+func reached(alert *db.Alert, value decimal.Decimal) (bool, error) {
+	limit, err := decimal.Parse(alert.Price)
+	if err != nil {
+		return false, err
+	}
+	if alert.DirectionDown {
+		return value.Cmp(limit) <= 0, nil
+	}
+	return value.Cmp(limit) >= 0, nil
+}
@@ -0,0 +1,170 @@
+package checker
+
+import (
+	"testing"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/events"
+)
+
+type sentAlert struct {
+	email, name, text string
+}
+
+type fakeAlertManager struct {
+	sent []sentAlert
+}
+
+func (m *fakeAlertManager) SendAlert(toEmail, toName, text string) error {
+	m.sent = append(m.sent, sentAlert{email: toEmail, name: toName, text: text})
+	return nil
+}
+
+func TestCheck(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	alerts := []*db.Alert{
+		{ID: "down-hit", Symbol: "BTCUSDT", Price: "40000", Email: "a@example.com", DirectionDown: true},
+		{ID: "down-miss", Symbol: "BTCUSDT", Price: "30000", Email: "b@example.com", DirectionDown: true},
+		{ID: "up-hit", Symbol: "ETHUSDT", Price: "3000", Email: "c@example.com"},
+		{ID: "up-miss", Symbol: "ETHUSDT", Price: "3500", Email: "d@example.com"},
+	}
+	for _, a := range alerts {
+		if err := dbClient.AddAlert(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manager := &fakeAlertManager{}
+
+	prices := []*db.Price{{Symbol: "BTCUSDT", Price: "39000"}, {Symbol: "ETHUSDT", Price: "3000"}}
+	if err := New(dbClient, manager, events.NewBus()).Check(prices); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(manager.sent) != 2 || manager.sent[0].email != "a@example.com" || manager.sent[1].email != "c@example.com" {
+		t.Fatalf("unexpected alerts sent: %+v", manager.sent)
+	}
+	left, err := dbClient.GetAlerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 2 || left[0].ID != "down-miss" || left[1].ID != "up-miss" {
+		t.Fatalf("triggered alerts should be deleted, left %+v", left)
+	}
+}
+
+func TestCheckMissingPrice(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	if err := dbClient.AddAlert(&db.Alert{ID: "1", Symbol: "BNBUSDT", Price: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbClient.AddAlert(&db.Alert{ID: "2", Symbol: "BTCUSDT", Price: "1", Email: "a@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	manager := &fakeAlertManager{}
+	checker := New(dbClient, manager, events.NewBus())
+	for _, prices := range [][]*db.Price{nil, {{Symbol: "BTCUSDT", Price: "1"}}} {
+		if err := checker.Check(prices); err != nil {
+			t.Fatalf("alerts without a price should be skipped, got %s", err)
+		}
+	}
+	left, err := dbClient.GetAlerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manager.sent) != 1 || len(left) != 1 || left[0].ID != "1" {
+		t.Fatalf("expected only the alert with a price to be sent, sent %+v, left %+v", manager.sent, left)
+	}
+}
+
+func TestAlertTriggeredExactThreshold(t *testing.T) {
+	for _, tc := range []struct {
+		limit, price   string
+		down, expected bool
+	}{
+		{"0.00000123", "0.00000123", false, true},
+		{"0.00000123", "0.000001229", false, false},
+		{"0.00000123", "0.00000123000", true, true},
+		{"0.3", "0.30000000", false, true},
+		{"0.30000001", "0.3", false, false},
+		{"0.29999999", "0.3", true, false},
+	} {
+		triggered, err := alertTriggered(&db.Alert{Price: tc.limit, DirectionDown: tc.down}, tc.price)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if triggered != tc.expected {
+			t.Fatalf("%+v: got %t", tc, triggered)
+		}
+	}
+	if _, err := alertTriggered(&db.Alert{Price: "N/A"}, "1"); err == nil {
+		t.Fatal("expected error for invalid alert price")
+	}
+}
+
+func TestCheckFuturesAlerts(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	if err := dbClient.SetPositions("main", []*db.Position{
+		{Symbol: "BTCUSDT", PositionSide: "LONG", MarkPrice: "40000", LiquidationPrice: "36000"},
+		{Symbol: "BTCUSDT", PositionSide: "SHORT", MarkPrice: "40000", LiquidationPrice: "0"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbClient.SetFundingRates([]*db.FundingRate{{Symbol: "BTCUSDT", FundingRate: "-0.00075"}}); err != nil {
+		t.Fatal(err)
+	}
+	alerts := []*db.Alert{
+		{ID: "liquidation-hit", AccountID: "main", Type: db.AlertTypeLiquidationDistance, Symbol: "BTCUSDT", Price: "10"},
+		{ID: "liquidation-miss", AccountID: "main", Type: db.AlertTypeLiquidationDistance, Symbol: "BTCUSDT", Price: "9.99"},
+		{ID: "liquidation-other-account", AccountID: "sub", Type: db.AlertTypeLiquidationDistance, Symbol: "BTCUSDT", Price: "50"},
+		{ID: "funding-hit", Type: db.AlertTypeFundingRate, Symbol: "BTCUSDT", Price: "-0.05", DirectionDown: true},
+		{ID: "funding-miss", Type: db.AlertTypeFundingRate, Symbol: "BTCUSDT", Price: "0.05"},
+		{ID: "funding-unknown-symbol", Type: db.AlertTypeFundingRate, Symbol: "ETHUSDT", Price: "0.01"},
+	}
+	for _, a := range alerts {
+		if err := dbClient.AddAlert(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manager := &fakeAlertManager{}
+
+	if err := New(dbClient, manager, events.NewBus()).Check(nil); err != nil {
+		t.Fatal(err)
+	}
+	left, err := dbClient.GetAlerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manager.sent) != 2 || len(left) != 4 || left[0].ID != "liquidation-miss" || left[2].ID != "funding-miss" {
+		t.Fatalf("unexpected alerts sent %+v, left %+v", manager.sent, left)
+	}
+}
+
+func TestCheckSendsToAlertOwner(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	if err := dbClient.AddUser(&db.User{ID: "u1", Username: "jane", Role: db.RoleTrader, Name: "Jane", Email: "jane@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []*db.Alert{
+		{ID: "owned", UserID: "u1", Symbol: "BTCUSDT", Price: "40000", Email: "old@example.com"},
+		{ID: "legacy", Symbol: "BTCUSDT", Price: "40000", Name: "John", Email: "john@example.com"},
+	} {
+		if err := dbClient.AddAlert(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manager := &fakeAlertManager{}
+
+	if err := New(dbClient, manager, events.NewBus()).Check([]*db.Price{{Symbol: "BTCUSDT", Price: "41000"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(manager.sent) != 2 || manager.sent[0].email != "jane@example.com" || manager.sent[0].name != "Jane" || manager.sent[1].email != "john@example.com" {
+		t.Fatalf("unexpected alerts sent: %+v", manager.sent)
+	}
+	owned, _, err := dbClient.GetEvents(&db.EventQuery{UserID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(owned) != 1 || owned[0].UserID != "u1" {
+		t.Fatalf("the alert event should belong to the alert owner, got %+v", owned)
+	}
+}
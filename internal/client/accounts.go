@@ -0,0 +1,161 @@
+package client
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/decimal"
+)
+
+type accountResponse struct {
+	ID       string `json:"id"`
+	Orders   int    `json:"orders"`
+	Balances int    `json:"balances"`
+	Alerts   int    `json:"alerts"`
+}
+
+// balanceTotal is the balance of an asset summed over accounts.
+type balanceTotal struct {
+	Asset    string `json:"asset"`
+	Free     string `json:"free"`
+	Locked   string `json:"locked"`
+	Total    string `json:"total"`
+	Accounts int    `json:"accounts"`
+}
+
+// resolveAccount returns the account ID to use for the request field. An empty ID selects the only
+// configured account, unknown accounts and empty IDs with several accounts are validation errors.
+func (c *client) resolveAccount(field, accountID string) (string, error) {
+	accountID = strings.ToLower(strings.TrimSpace(accountID))
+	errs := &validationError{}
+	switch {
+	case accountID == "" && len(c.accounts) == 1:
+		return c.accounts[0], nil
+	case accountID == "":
+		errs.add(field, "is required when several accounts are configured, use one of "+strings.Join(c.accounts, ", "))
+	case !contains(c.accounts, accountID):
+		errs.add(field, "should be one of "+strings.Join(c.accounts, ", "))
+	}
+	return accountID, errs.orNil()
+}
+
+func (c *client) apiListAccounts(w http.ResponseWriter, r *http.Request) {
+	orders, err := c.db.GetOrders()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	balances, err := c.db.GetBalances()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	alerts, err := c.db.GetAlerts()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	alerts = visibleAlerts(r, alerts)
+
+	accounts := make([]*accountResponse, 0, len(c.accounts))
+	byID := make(map[string]*accountResponse, len(c.accounts))
+	for _, id := range c.accounts {
+		account := &accountResponse{ID: id}
+		accounts = append(accounts, account)
+		byID[id] = account
+	}
+	for _, o := range orders {
+		if account, ok := byID[o.AccountID]; ok {
+			account.Orders++
+		}
+	}
+	for _, b := range balances {
+		if account, ok := byID[b.AccountID]; ok {
+			account.Balances++
+		}
+	}
+	for _, a := range alerts {
+		if account, ok := byID[a.AccountID]; ok {
+			account.Alerts++
+		}
+	}
+	c.writeList(w, r, accountsResource, accounts)
+}
+
+func (c *client) apiListBalances(w http.ResponseWriter, r *http.Request) {
+	balances, err := c.db.GetBalances()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	c.writeList(w, r, balancesResource, balances)
+}
+
+// apiListBalanceTotals sums balances of the accounts selected with the accountId filter, all accounts by default.
+func (c *client) apiListBalanceTotals(w http.ResponseWriter, r *http.Request) {
+	q, err := parseListQuery(r, balanceTotalsResource)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+	accountIDs := q.filters["accountId"]
+	delete(q.filters, "accountId")
+
+	balances, err := c.db.GetBalances()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	selected := make([]*db.Balance, 0, len(balances))
+	for _, b := range balances {
+		if len(accountIDs) == 0 || contains(accountIDs, b.AccountID) {
+			selected = append(selected, b)
+		}
+	}
+	totals, err := sumBalances(selected)
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, q.apply(totals))
+}
+
+func sumBalances(balances []*db.Balance) ([]*balanceTotal, error) {
+	type sum struct {
+		free, locked decimal.Decimal
+		accounts     int
+	}
+	sums := make(map[string]*sum)
+	for _, b := range balances {
+		free, err := decimal.Parse(b.Free)
+		if err != nil {
+			return nil, err
+		}
+		locked, err := decimal.Parse(b.Locked)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := sums[b.Asset]
+		if !ok {
+			s = &sum{}
+			sums[b.Asset] = s
+		}
+		s.free, s.locked = s.free.Add(free), s.locked.Add(locked)
+		s.accounts++
+	}
+
+	totals := make([]*balanceTotal, 0, len(sums))
+	for asset, s := range sums {
+		totals = append(totals, &balanceTotal{
+			Asset:    asset,
+			Free:     s.free.String(),
+			Locked:   s.locked.String(),
+			Total:    s.free.Add(s.locked).String(),
+			Accounts: s.accounts,
+		})
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Asset < totals[j].Asset })
+	return totals, nil
+}
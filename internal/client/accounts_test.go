@@ -0,0 +1,124 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/events"
+)
+
+func newTestAccountsAPI(t *testing.T) (http.Handler, db.Client) {
+	dbClient := db.NewMemoryClient()
+	if err := dbClient.SetOrders("main", []*db.Order{{Symbol: "BTCUSDT", OrderID: 1}, {Symbol: "ETHUSDT", OrderID: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbClient.SetOrders("sub", []*db.Order{{Symbol: "BTCUSDT", OrderID: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbClient.SetBalances("main", []*db.Balance{{Asset: "BTC", Free: "0.1", Locked: "0.2"}, {Asset: "USDT", Free: "100", Locked: "0"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbClient.SetBalances("sub", []*db.Balance{{Asset: "BTC", Free: "0.00000001", Locked: "0"}}); err != nil {
+		t.Fatal(err)
+	}
+	c := &client{db: dbClient, bus: events.NewBus(), exchangeInfo: newTestExchangeInfo(), accounts: []string{"main", "sub"}}
+	r := mux.NewRouter()
+	c.registerAPI(r)
+	return r, dbClient
+}
+
+func TestAPIAccounts(t *testing.T) {
+	api, dbClient := newTestAccountsAPI(t)
+	rec := doRequest(api, http.MethodGet, "/api/v1/accounts", "")
+	var accounts struct {
+		Data []*accountResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &accounts); err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts.Data) != 2 || *accounts.Data[0] != (accountResponse{ID: "main", Orders: 2, Balances: 2}) || *accounts.Data[1] != (accountResponse{ID: "sub", Orders: 1, Balances: 1}) {
+		t.Fatalf("unexpected accounts %+v", accounts.Data)
+	}
+
+	// viewers only count their own alerts
+	trader := &db.User{ID: "trader-id", Username: "trader", Role: db.RoleTrader}
+	for _, a := range []*db.Alert{
+		{ID: "1", AccountID: "main", UserID: trader.ID, Symbol: "BTCUSDT", Price: "1"},
+		{ID: "2", AccountID: "main", UserID: testAdmin.ID, Symbol: "BTCUSDT", Price: "1"},
+	} {
+		if err := dbClient.AddAlert(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		user   *db.User
+		alerts int
+	}{{user: testAdmin, alerts: 2}, {user: trader, alerts: 1}} {
+		rec = doRequestAs(api, tc.user, http.MethodGet, "/api/v1/accounts", "")
+		if err := json.Unmarshal(rec.Body.Bytes(), &accounts); err != nil {
+			t.Fatal(err)
+		}
+		if accounts.Data[0].Alerts != tc.alerts {
+			t.Fatalf("%s: expected %d alerts, got %+v", tc.user.Username, tc.alerts, accounts.Data[0])
+		}
+	}
+
+	rec = doRequest(api, http.MethodGet, "/api/v1/orders?accountId=sub", "")
+	var orders struct {
+		Data []*db.Order `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &orders); err != nil {
+		t.Fatal(err)
+	}
+	if len(orders.Data) != 1 || orders.Data[0].OrderID != 3 {
+		t.Fatalf("unexpected sub account orders %+v", orders.Data)
+	}
+}
+
+func TestAPIBalanceTotals(t *testing.T) {
+	api, _ := newTestAccountsAPI(t)
+	var totals struct {
+		Data []*balanceTotal `json:"data"`
+	}
+	rec := doRequest(api, http.MethodGet, "/api/v1/balances/totals", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &totals); err != nil {
+		t.Fatal(err)
+	}
+	btc := balanceTotal{Asset: "BTC", Free: "0.10000001", Locked: "0.2", Total: "0.30000001", Accounts: 2}
+	if len(totals.Data) != 2 || *totals.Data[0] != btc || totals.Data[1].Asset != "USDT" {
+		t.Fatalf("unexpected totals %+v", totals.Data)
+	}
+
+	rec = doRequest(api, http.MethodGet, "/api/v1/balances/totals?accountId=sub&asset=BTC", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &totals); err != nil {
+		t.Fatal(err)
+	}
+	if len(totals.Data) != 1 || totals.Data[0].Total != "0.00000001" || totals.Data[0].Accounts != 1 {
+		t.Fatalf("unexpected sub account totals %+v", totals.Data)
+	}
+}
+
+func TestAPIAlertAccount(t *testing.T) {
+	api, dbClient := newTestAccountsAPI(t)
+	alert := `{"symbol":"BTCUSDT","price":"40000","email":"john@example.com"`
+	if rec := doRequest(api, http.MethodPost, "/api/v1/alerts", alert+`}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected account to be required, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := doRequest(api, http.MethodPost, "/api/v1/alerts", alert+`,"accountId":"sub"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body)
+	}
+
+	// alerts of accounts removed from the configuration can still be edited
+	if err := dbClient.AddAlert(&db.Alert{ID: "old", AccountID: db.DefaultAccountID, Symbol: "BTCUSDT", Price: "1", Email: "john@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if rec := doRequest(api, http.MethodPatch, "/api/v1/alerts/old", `{"price":"2"}`); rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := doRequest(api, http.MethodPatch, "/api/v1/alerts/old", `{"accountId":"unknown"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected unknown account error, got %d: %s", rec.Code, rec.Body)
+	}
+}
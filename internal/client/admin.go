@@ -0,0 +1,68 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/backup"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+func (c *client) backupHandler(w http.ResponseWriter, r *http.Request) {
+	backuper, ok := c.db.(db.Backuper)
+	if !ok {
+		w.WriteHeader(http.StatusNotImplemented)
+		w.Write([]byte(db.ErrBackupNotSupported.Error()))
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "watcher-backup")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "backup.db")
+	if err = backuper.Backup(path); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	name := fmt.Sprintf("backup-%s.db", time.Now().UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeFile(w, r, path)
+}
+
+func (c *client) exportHandler(w http.ResponseWriter, _ *http.Request) {
+	snapshot, err := backup.Export(c.db)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="watcher-export.json"`)
+	backup.WriteSnapshot(w, snapshot)
+}
+
+func (c *client) importHandler(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := backup.ReadSnapshot(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err = backup.Import(c.db, snapshot, ValidateAlert); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Data successfully imported"))
+}
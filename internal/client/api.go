@@ -0,0 +1,622 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/simulator"
+)
+
+const apiPrefix = "/api/v1"
+
+type apiResource struct {
+	name    string
+	model   interface{}
+	filters []string
+	sorts   []string
+}
+
+var (
+	ordersResource = &apiResource{
+		name:    "orders",
+		model:   db.Order{},
+		filters: []string{"accountId", "symbol", "side", "status", "type", "orderListId"},
+		sorts:   []string{"accountId", "symbol", "orderId", "price", "time", "updateTime", "percentCompleted", "orderMarketPriceSpread"},
+	}
+	orderListsResource = &apiResource{
+		name:    "orderLists",
+		model:   db.OrderList{},
+		filters: []string{"accountId", "symbol", "contingencyType", "listOrderStatus"},
+		sorts:   []string{"accountId", "symbol", "orderListId", "transactionTime", "triggerDistance"},
+	}
+	pricesResource = &apiResource{
+		name:    "prices",
+		model:   db.Price{},
+		filters: []string{"symbol"},
+		sorts:   []string{"symbol", "price"},
+	}
+	alertsResource = &apiResource{
+		name:    "alerts",
+		model:   db.Alert{},
+		filters: []string{"accountId", "type", "symbol", "email", "directionDown", "createdBy", "updatedBy"},
+		sorts:   []string{"symbol", "price", "name", "email", "createdAt", "updatedAt"},
+	}
+	accountsResource = &apiResource{
+		name:  "accounts",
+		model: accountResponse{},
+		sorts: []string{"id", "orders", "balances", "alerts"},
+	}
+	balancesResource = &apiResource{
+		name:    "balances",
+		model:   db.Balance{},
+		filters: []string{"accountId", "asset"},
+		sorts:   []string{"accountId", "asset", "free", "locked", "updatedAt"},
+	}
+	balanceTotalsResource = &apiResource{
+		name:    "balanceTotals",
+		model:   balanceTotal{},
+		filters: []string{"accountId", "asset"},
+		sorts:   []string{"asset", "free", "locked", "total"},
+	}
+	leveragedOrdersResource = &apiResource{
+		name:    "leveragedOrders",
+		model:   db.LeveragedOrder{},
+		filters: []string{"accountId", "symbol", "side", "status", "type", "positionSide"},
+		sorts:   []string{"accountId", "symbol", "orderId", "price", "time", "updateTime"},
+	}
+	positionsResource = &apiResource{
+		name:    "positions",
+		model:   db.Position{},
+		filters: []string{"accountId", "symbol", "positionSide", "marginType"},
+		sorts:   []string{"accountId", "symbol", "positionAmt", "unrealizedProfit", "leverage"},
+	}
+	fundingRatesResource = &apiResource{
+		name:    "fundingRates",
+		model:   db.FundingRate{},
+		filters: []string{"symbol"},
+		sorts:   []string{"symbol", "fundingRate", "nextFundingTime"},
+	}
+	marginStatusResource = &apiResource{
+		name:    "marginStatus",
+		model:   db.MarginStatus{},
+		filters: []string{"accountId", "market"},
+		sorts:   []string{"accountId", "market", "marginRatio"},
+	}
+	klinesResource = &apiResource{
+		name:    "klines",
+		model:   db.Kline{},
+		filters: []string{"symbol", "interval"},
+		sorts:   []string{"openTime"},
+	}
+	tradesResource = &apiResource{
+		name:  "trades",
+		model: tradeResult{},
+	}
+	auditResource = &apiResource{
+		name:    "audit",
+		model:   db.AuditEntry{},
+		filters: []string{"action", "username", "accountId", "symbol", "test"},
+		sorts:   []string{"createdAt", "action", "symbol"},
+	}
+	symbolsResource = &apiResource{
+		name:    "symbols",
+		model:   symbolResponse{},
+		filters: []string{"symbol", "status", "baseAsset", "quoteAsset"},
+		sorts:   []string{"symbol", "baseAsset", "quoteAsset"},
+	}
+	backtestResource = &apiResource{
+		name:  "backtest",
+		model: backtestResult{},
+	}
+	simulationsResource = &apiResource{
+		name:  "simulations",
+		model: simulator.Result{},
+	}
+	usersResource = &apiResource{
+		name:    "users",
+		model:   db.User{},
+		filters: []string{"username", "role"},
+		sorts:   []string{"username", "role", "createdAt"},
+	}
+	sessionsResource = &apiResource{
+		name:  "sessions",
+		model: sessionResponse{},
+		sorts: []string{"createdAt", "lastSeenAt"},
+	}
+	apiTokensResource = &apiResource{
+		name:    "apiTokens",
+		model:   db.APIToken{},
+		filters: []string{"userId"},
+		sorts:   []string{"name", "createdAt", "expiresAt", "lastUsedAt"},
+	}
+	lockoutsResource = &apiResource{
+		name:  "lockouts",
+		model: lockoutResponse{},
+		sorts: []string{"ip", "failures", "lockouts", "lockedUntil", "lastFailureAt"},
+	}
+	bansResource = &apiResource{
+		name:  "bans",
+		model: db.IPBan{},
+		sorts: []string{"network", "createdAt"},
+	}
+	twoFactorResource = &apiResource{
+		name:  "twoFactor",
+		model: twoFactorSetup{},
+	}
+	recoveryCodesResource = &apiResource{
+		name:  "recoveryCodes",
+		model: recoveryCodesResult{},
+	}
+	eventsResource = &apiResource{
+		name:    "events",
+		model:   db.Event{},
+		filters: []string{"type", "symbol"},
+		sorts:   db.EventSortFields,
+	}
+)
+
+// apiRoute describes an endpoint, the same table is used to register handlers and to generate the OpenAPI document.
+type apiRoute struct {
+	method   string
+	path     string
+	summary  string
+	resource *apiResource
+	list     bool
+	body     interface{}
+	status   int
+	handler  http.HandlerFunc
+	// contentType is set for non JSON responses
+	contentType string
+	// role is required to call the endpoint, by default reads need the viewer role and changes the trader role
+	role string
+	// scope lets API tokens with the scope call the endpoint, endpoints without a scope can't be called with tokens
+	scope string
+}
+
+func (route *apiRoute) requiredRole() string {
+	if route.role != "" {
+		return route.role
+	}
+	if route.method == http.MethodGet {
+		return db.RoleViewer
+	}
+	return db.RoleTrader
+}
+
+type apiError struct {
+	Code    string        `json:"code"`
+	Message string        `json:"message"`
+	Details []*fieldError `json:"details,omitempty"`
+}
+
+type apiErrorEnvelope struct {
+	Error *apiError `json:"error"`
+}
+
+type refreshResult struct {
+	Orders int `json:"orders"`
+	Prices int `json:"prices"`
+}
+
+func (c *client) apiRoutes() []*apiRoute {
+	return []*apiRoute{
+		{method: http.MethodGet, path: "/orders", summary: "List open orders", resource: ordersResource, list: true, status: http.StatusOK, handler: c.apiListOrders, scope: ScopeReadOrders},
+		{method: http.MethodPost, path: "/orders", summary: "Place a LIMIT, MARKET, STOP_LOSS_LIMIT or OCO order, set test to only validate it or confirm to place it", resource: tradesResource, body: orderInput{}, status: http.StatusOK, handler: c.apiPlaceOrder, scope: ScopeTrade},
+		{method: http.MethodGet, path: "/orders/{id}", summary: "Get an open order by Binance order ID, requires the symbol query parameter and accountId with several accounts", resource: ordersResource, status: http.StatusOK, handler: c.apiGetOrder, scope: ScopeReadOrders},
+		{method: http.MethodDelete, path: "/orders/{id}", summary: "Cancel an open order, requires the confirm=true and symbol query parameters and accountId with several accounts", resource: tradesResource, status: http.StatusOK, handler: c.apiCancelOrder, scope: ScopeTrade},
+		{method: http.MethodPost, path: "/orders/{id}/replace", summary: "Cancel an open order found like GET /orders/{id} and place a new one, set test to only validate it or confirm to place it", resource: tradesResource, body: orderInput{}, status: http.StatusOK, handler: c.apiReplaceOrder, scope: ScopeTrade},
+		{method: http.MethodGet, path: "/order-lists", summary: "List open OCO order lists with the distance to their closest trigger, legs are orders with the same orderListId", resource: orderListsResource, list: true, status: http.StatusOK, handler: c.apiListOrderLists, scope: ScopeReadOrders},
+		{method: http.MethodGet, path: "/audit", summary: "List the trading audit log", resource: auditResource, list: true, status: http.StatusOK, handler: c.apiListAudit},
+		{method: http.MethodGet, path: "/accounts", summary: "List configured Binance accounts", resource: accountsResource, list: true, status: http.StatusOK, handler: c.apiListAccounts},
+		{method: http.MethodGet, path: "/balances", summary: "List non zero asset balances of every account", resource: balancesResource, list: true, status: http.StatusOK, handler: c.apiListBalances},
+		{method: http.MethodGet, path: "/balances/totals", summary: "List asset balances summed over accounts, accountId selects the accounts", resource: balanceTotalsResource, list: true, status: http.StatusOK, handler: c.apiListBalanceTotals},
+		{method: http.MethodGet, path: "/futures/orders", summary: "List open USDⓈ-M futures orders", resource: leveragedOrdersResource, list: true, status: http.StatusOK, handler: c.apiListFuturesOrders, scope: ScopeReadOrders},
+		{method: http.MethodGet, path: "/futures/positions", summary: "List open USDⓈ-M futures positions with mark and liquidation prices", resource: positionsResource, list: true, status: http.StatusOK, handler: c.apiListPositions},
+		{method: http.MethodGet, path: "/futures/funding-rates", summary: "List the last funding rates of futures symbols", resource: fundingRatesResource, list: true, status: http.StatusOK, handler: c.apiListFundingRates},
+		{method: http.MethodGet, path: "/margin/orders", summary: "List open cross margin orders", resource: leveragedOrdersResource, list: true, status: http.StatusOK, handler: c.apiListMarginOrders, scope: ScopeReadOrders},
+		{method: http.MethodGet, path: "/margin/status", summary: "List margin ratios of futures and cross margin accounts", resource: marginStatusResource, list: true, status: http.StatusOK, handler: c.apiListMarginStatus},
+		{method: http.MethodGet, path: "/prices", summary: "List market prices", resource: pricesResource, list: true, status: http.StatusOK, handler: c.apiListPrices},
+		{method: http.MethodGet, path: "/prices/{symbol}", summary: "Get a market price by symbol", resource: pricesResource, status: http.StatusOK, handler: c.apiGetPrice},
+		{method: http.MethodGet, path: "/alerts", summary: "List alerts", resource: alertsResource, list: true, status: http.StatusOK, handler: c.apiListAlerts, scope: ScopeWriteAlerts},
+		{method: http.MethodPost, path: "/alerts", summary: "Create an alert", resource: alertsResource, body: alertInput{}, status: http.StatusCreated, handler: c.apiCreateAlert, scope: ScopeWriteAlerts},
+		{method: http.MethodPost, path: "/alerts/backtest", summary: "Replay historical candles through a price alert and list every time it would have fired, the alert re-arms when a candle closes back on the other side of the limit", resource: backtestResource, body: backtestInput{}, status: http.StatusOK, handler: c.apiBacktestAlert, role: db.RoleViewer},
+		{method: http.MethodGet, path: "/alerts/{id}", summary: "Get an alert", resource: alertsResource, status: http.StatusOK, handler: c.apiGetAlert, scope: ScopeWriteAlerts},
+		{method: http.MethodPut, path: "/alerts/{id}", summary: "Replace an alert", resource: alertsResource, body: alertInput{}, status: http.StatusOK, handler: c.apiReplaceAlert, scope: ScopeWriteAlerts},
+		{method: http.MethodPatch, path: "/alerts/{id}", summary: "Update some alert fields", resource: alertsResource, body: alertPatch{}, status: http.StatusOK, handler: c.apiPatchAlert, scope: ScopeWriteAlerts},
+		{method: http.MethodDelete, path: "/alerts/{id}", summary: "Delete an alert", status: http.StatusNoContent, handler: c.apiDeleteAlert, scope: ScopeWriteAlerts},
+		{method: http.MethodGet, path: "/symbols", summary: "List Binance symbols with their trading rules", resource: symbolsResource, list: true, status: http.StatusOK, handler: c.apiListSymbols},
+		{method: http.MethodGet, path: "/klines", summary: "List the latest candles for a symbol, limit is the number of candles and interval defaults to " + defaultKlinesInterval, resource: klinesResource, list: true, status: http.StatusOK, handler: c.apiListKlines},
+		{method: http.MethodPost, path: "/simulations", summary: "Paper trade the open orders and balances against a price path, nothing is sent to Binance", resource: simulationsResource, body: simulationInput{}, status: http.StatusOK, handler: c.apiSimulate, role: db.RoleViewer},
+		{method: http.MethodGet, path: "/users", summary: "List dashboard users", resource: usersResource, list: true, status: http.StatusOK, handler: c.apiListUsers, role: db.RoleAdmin},
+		{method: http.MethodPost, path: "/users", summary: "Create a user with an admin, trader or viewer role", resource: usersResource, body: userInput{}, status: http.StatusCreated, handler: c.apiCreateUser, role: db.RoleAdmin},
+		{method: http.MethodGet, path: "/users/{id}", summary: "Get a user", resource: usersResource, status: http.StatusOK, handler: c.apiGetUser, role: db.RoleAdmin},
+		{method: http.MethodPatch, path: "/users/{id}", summary: "Change the password, role, name or email of a user", resource: usersResource, body: userPatch{}, status: http.StatusOK, handler: c.apiPatchUser, role: db.RoleAdmin},
+		{method: http.MethodDelete, path: "/users/{id}", summary: "Delete a user and its alerts", status: http.StatusNoContent, handler: c.apiDeleteUser, role: db.RoleAdmin},
+		{method: http.MethodPost, path: "/users/{id}/2fa/reset", summary: "Turn two-factor authentication off for a user who lost the authenticator", resource: usersResource, status: http.StatusOK, handler: c.apiResetUserTwoFactor, role: db.RoleAdmin},
+		{method: http.MethodDelete, path: "/users/{id}/sessions", summary: "Log out every session of a user", status: http.StatusNoContent, handler: c.apiDeleteUserSessions, role: db.RoleAdmin},
+		{method: http.MethodGet, path: "/tokens", summary: "List personal API tokens of every user", resource: apiTokensResource, list: true, status: http.StatusOK, handler: c.apiListAPITokens, role: db.RoleAdmin},
+		{method: http.MethodPost, path: "/tokens", summary: "Create a personal API token for a user with the " + strings.Join(apiScopes, ", ") + " scopes, the token is only returned once", resource: apiTokensResource, body: apiTokenInput{}, status: http.StatusCreated, handler: c.apiCreateAPIToken, role: db.RoleAdmin},
+		{method: http.MethodDelete, path: "/tokens/{id}", summary: "Revoke a personal API token", status: http.StatusNoContent, handler: c.apiDeleteAPIToken, role: db.RoleAdmin},
+		{method: http.MethodGet, path: "/lockouts", summary: "List IPs with failed logins and their lockouts", resource: lockoutsResource, list: true, status: http.StatusOK, handler: c.apiListLockouts, role: db.RoleAdmin},
+		{method: http.MethodDelete, path: "/lockouts/{ip}", summary: "Unblock an IP, or the /64 network of an IPv6 address, and forget its failed logins", status: http.StatusNoContent, handler: c.apiDeleteLockout, role: db.RoleAdmin},
+		{method: http.MethodGet, path: "/bans", summary: "List banned IPs and CIDR ranges", resource: bansResource, list: true, status: http.StatusOK, handler: c.apiListBans, role: db.RoleAdmin},
+		{method: http.MethodPost, path: "/bans", summary: "Ban an IP or a CIDR range until the ban is deleted", resource: bansResource, body: ipBanInput{}, status: http.StatusCreated, handler: c.apiCreateBan, role: db.RoleAdmin},
+		{method: http.MethodDelete, path: "/bans/{id}", summary: "Lift a ban", status: http.StatusNoContent, handler: c.apiDeleteBan, role: db.RoleAdmin},
+		{method: http.MethodGet, path: "/me/sessions", summary: "List the sessions of the current user with their device, IP and last use", resource: sessionsResource, list: true, status: http.StatusOK, handler: c.apiListSessions, role: db.RoleViewer},
+		{method: http.MethodDelete, path: "/me/sessions", summary: "Log out every session of the current user", status: http.StatusNoContent, handler: c.apiDeleteSessions, role: db.RoleViewer},
+		{method: http.MethodDelete, path: "/me/sessions/{id}", summary: "Log out a session of the current user", status: http.StatusNoContent, handler: c.apiDeleteSession, role: db.RoleViewer},
+		{method: http.MethodPost, path: "/me/2fa/setup", summary: "Generate a two-factor secret and its QR code for the current user", resource: twoFactorResource, status: http.StatusOK, handler: c.apiSetupTwoFactor, role: db.RoleViewer},
+		{method: http.MethodPost, path: "/me/2fa/enable", summary: "Enable two-factor authentication with a code of the new secret, returns recovery codes", resource: recoveryCodesResource, body: codeInput{}, status: http.StatusOK, handler: c.apiEnableTwoFactor, role: db.RoleViewer},
+		{method: http.MethodPost, path: "/me/2fa/disable", summary: "Disable two-factor authentication with a code or a recovery code", resource: usersResource, body: codeInput{}, status: http.StatusOK, handler: c.apiDisableTwoFactor, role: db.RoleViewer},
+		{method: http.MethodPost, path: "/me/2fa/recovery-codes", summary: "Replace the recovery codes, requires a code or a recovery code", resource: recoveryCodesResource, body: codeInput{}, status: http.StatusOK, handler: c.apiRegenerateRecoveryCodes, role: db.RoleViewer},
+		{method: http.MethodGet, path: "/events", summary: "List watcher events", resource: eventsResource, list: true, status: http.StatusOK, handler: c.apiListEvents},
+		{method: http.MethodPost, path: "/refresh", summary: "Fetch orders, balances and prices from Binance and check alerts, the accountId query parameter fetches a single account", status: http.StatusOK, handler: c.apiRefresh},
+		{method: http.MethodGet, path: "/stream", summary: "Subscribe to orders, balances, positions, prices, alerts, finished order lists and fetch status updates as Server-Sent Events", status: http.StatusOK, handler: c.streamHandler, contentType: "text/event-stream"},
+		{method: http.MethodGet, path: "/openapi.json", summary: "Get this OpenAPI document", status: http.StatusOK, handler: c.apiOpenAPI},
+	}
+}
+
+func (c *client) registerAPI(r *mux.Router) {
+	api := r.PathPrefix(apiPrefix).Subrouter()
+	for _, route := range c.apiRoutes() {
+		api.HandleFunc(route.path, requireScope(route.scope, requireRole(route.requiredRole(), route.handler))).Methods(route.method)
+	}
+	api.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeAPIError(w, http.StatusNotFound, "not_found", "endpoint not found")
+	})
+	api.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method is not allowed for this endpoint")
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if v != nil {
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, &apiErrorEnvelope{Error: &apiError{Code: code, Message: message}})
+}
+
+func writeAPIErrorFrom(w http.ResponseWriter, err error) {
+	var vErr *validationError
+	if errors.As(err, &vErr) {
+		writeJSON(w, http.StatusUnprocessableEntity, &apiErrorEnvelope{Error: &apiError{Code: "validation_failed", Message: "request validation failed", Details: vErr.fields}})
+		return
+	}
+	writeAPIError(w, http.StatusInternalServerError, "internal", err.Error())
+}
+
+func (c *client) writeList(w http.ResponseWriter, r *http.Request, resource *apiResource, items interface{}) {
+	q, err := parseListQuery(r, resource)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, q.apply(items))
+}
+
+func (c *client) apiListOrders(w http.ResponseWriter, r *http.Request) {
+	orders, err := c.db.GetOrders()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	c.writeList(w, r, ordersResource, orders)
+}
+
+func (c *client) apiListOrderLists(w http.ResponseWriter, r *http.Request) {
+	lists, err := c.db.GetOrderLists()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	c.writeList(w, r, orderListsResource, lists)
+}
+
+func (c *client) apiGetOrder(w http.ResponseWriter, r *http.Request) {
+	if order, ok := c.orderFromRequest(w, r); ok {
+		writeJSON(w, http.StatusOK, order)
+	}
+}
+
+// orderFromRequest finds the open order of the id path variable. Binance order IDs are only unique within a
+// symbol of an account, so the symbol query parameter is required and so is accountId with several accounts.
+func (c *client) orderFromRequest(w http.ResponseWriter, r *http.Request) (*db.Order, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_id", "order id should be a number")
+		return nil, false
+	}
+	symbol := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("symbol")))
+	if symbol == "" {
+		writeAPIError(w, http.StatusBadRequest, "invalid_query", "symbol is required, order IDs are only unique within a symbol")
+		return nil, false
+	}
+	accountID, err := c.resolveAccount("accountId", r.URL.Query().Get("accountId"))
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return nil, false
+	}
+	orders, err := c.db.GetOrders()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return nil, false
+	}
+	var matches []*db.Order
+	for _, o := range orders {
+		if o.OrderID == id && o.Symbol == symbol && o.AccountID == accountID {
+			matches = append(matches, o)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		writeAPIError(w, http.StatusNotFound, "not_found", "order not found")
+		return nil, false
+	case 1:
+		return matches[0], true
+	}
+	writeAPIError(w, http.StatusConflict, "ambiguous_order", "several open orders match the order id, symbol and account")
+	return nil, false
+}
+
+func (c *client) apiListPrices(w http.ResponseWriter, r *http.Request) {
+	prices, err := c.db.GetPrices()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	c.writeList(w, r, pricesResource, prices)
+}
+
+func (c *client) apiGetPrice(w http.ResponseWriter, r *http.Request) {
+	symbol := mux.Vars(r)["symbol"]
+	prices, err := c.db.GetPrices()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	for _, p := range prices {
+		if p.Symbol == symbol {
+			writeJSON(w, http.StatusOK, p)
+			return
+		}
+	}
+	writeAPIError(w, http.StatusNotFound, "not_found", "price not found")
+}
+
+func (c *client) apiListAlerts(w http.ResponseWriter, r *http.Request) {
+	alerts, err := c.db.GetAlerts()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	c.writeList(w, r, alertsResource, visibleAlerts(r, alerts))
+}
+
+func (c *client) findAlert(id string) (*db.Alert, error) {
+	alerts, err := c.db.GetAlerts()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range alerts {
+		if a.ID == id {
+			return a, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *client) apiGetAlert(w http.ResponseWriter, r *http.Request) {
+	alert, ok := c.alertForUpdate(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, alert)
+}
+
+func (c *client) apiCreateAlert(w http.ResponseWriter, r *http.Request) {
+	in := &alertInput{}
+	if err := decodeJSONBody(r, in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_body", err.Error())
+		return
+	}
+	in.normalize()
+	if err := in.validate(); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	if in.Type == db.AlertTypePrice {
+		if err := c.checkSymbol(in.Symbol); err != nil {
+			writeAPIErrorFrom(w, err)
+			return
+		}
+	}
+	accountID, err := c.resolveAccount("accountId", in.AccountID)
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	in.AccountID = accountID
+	if err = checkAlertRecipient(r, in); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	alert := &db.Alert{
+		ID:        uuid.NewString(),
+		UserID:    userFromRequest(r).ID,
+		CreatedAt: now,
+		CreatedBy: usernameFromRequest(r),
+		UpdatedAt: now,
+		UpdatedBy: usernameFromRequest(r),
+	}
+	in.applyTo(alert)
+	if err := c.db.AddAlert(alert); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	c.publishAlerts()
+	w.Header().Set("Location", apiPrefix+"/alerts/"+alert.ID)
+	writeJSON(w, http.StatusCreated, alert)
+}
+
+func (c *client) apiReplaceAlert(w http.ResponseWriter, r *http.Request) {
+	in := &alertInput{}
+	if err := decodeJSONBody(r, in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_body", err.Error())
+		return
+	}
+	alert, ok := c.alertForUpdate(w, r)
+	if !ok {
+		return
+	}
+	c.updateAlert(w, r, alert, in)
+}
+
+func (c *client) apiPatchAlert(w http.ResponseWriter, r *http.Request) {
+	patch := &alertPatch{}
+	if err := decodeJSONBody(r, patch); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_body", err.Error())
+		return
+	}
+	alert, ok := c.alertForUpdate(w, r)
+	if !ok {
+		return
+	}
+	in := alertInputFrom(alert)
+	patch.applyTo(in)
+	c.updateAlert(w, r, alert, in)
+}
+
+func (c *client) alertForUpdate(w http.ResponseWriter, r *http.Request) (*db.Alert, bool) {
+	alert, err := c.findAlert(mux.Vars(r)["id"])
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return nil, false
+	}
+	// alerts of other users are hidden
+	if alert == nil || !canAccessAlert(r, alert) {
+		writeAPIError(w, http.StatusNotFound, "not_found", "alert not found")
+		return nil, false
+	}
+	return alert, true
+}
+
+func (c *client) updateAlert(w http.ResponseWriter, r *http.Request, alert *db.Alert, in *alertInput) {
+	in.normalize()
+	if err := in.validate(); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	// futures symbols are not listed in the spot exchange info
+	if in.Type == db.AlertTypePrice && (in.Symbol != alert.Symbol || alert.Type != db.AlertTypePrice) {
+		if err := c.checkSymbol(in.Symbol); err != nil {
+			writeAPIErrorFrom(w, err)
+			return
+		}
+	}
+	// alerts stored before their account was removed from the configuration can still be edited
+	if in.AccountID != alert.AccountID {
+		accountID, err := c.resolveAccount("accountId", in.AccountID)
+		if err != nil {
+			writeAPIErrorFrom(w, err)
+			return
+		}
+		in.AccountID = accountID
+	}
+	if in.Email != alert.Email {
+		if err := checkAlertRecipient(r, in); err != nil {
+			writeAPIErrorFrom(w, err)
+			return
+		}
+	}
+
+	in.applyTo(alert)
+	alert.UpdatedAt = time.Now().UnixMilli()
+	alert.UpdatedBy = usernameFromRequest(r)
+	if err := c.db.UpdateAlert(alert); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeAPIError(w, http.StatusNotFound, "not_found", "alert not found")
+			return
+		}
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	c.publishAlerts()
+	writeJSON(w, http.StatusOK, alert)
+}
+
+func (c *client) apiDeleteAlert(w http.ResponseWriter, r *http.Request) {
+	alert, ok := c.alertForUpdate(w, r)
+	if !ok {
+		return
+	}
+	if err := c.db.DeleteAlert(alert.ID); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	c.publishAlerts()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiListEvents filters and pages events in the database, the events table grows with every fetch.
+func (c *client) apiListEvents(w http.ResponseWriter, r *http.Request) {
+	q, err := parseListQuery(r, eventsResource)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+	query := &db.EventQuery{Limit: q.limit, Offset: q.offset}
+	// alert events are only visible to the alert owner, like the alerts
+	if user := userFromRequest(r); !hasRole(user, db.RoleAdmin) {
+		query.UserID = user.ID
+	}
+	for _, t := range q.filters["type"] {
+		query.Types = append(query.Types, strings.ToLower(t))
+	}
+	for _, symbol := range q.filters["symbol"] {
+		query.Symbols = append(query.Symbols, strings.ToUpper(symbol))
+	}
+	for _, key := range q.sort {
+		query.Sort = append(query.Sort, db.EventSort{Field: key.field, Desc: key.desc})
+	}
+	events, total, err := c.db.GetEvents(query)
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &listPage{Data: events, Total: total, Limit: q.limit, Offset: q.offset})
+}
+
+func (c *client) apiRefresh(w http.ResponseWriter, r *http.Request) {
+	fetch := c.fetcher.Fetch
+	if accountID := r.URL.Query().Get("accountId"); accountID != "" {
+		if !contains(c.accounts, accountID) {
+			writeAPIError(w, http.StatusBadRequest, "invalid_query", "accountId should be one of "+strings.Join(c.accounts, ", "))
+			return
+		}
+		fetch = func() ([]*db.Order, []*db.Price, error) { return c.fetcher.FetchAccount(accountID) }
+	}
+	orders, prices, err := fetch()
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, "fetch_failed", err.Error())
+		return
+	}
+	if err = c.checker.Check(prices); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &refreshResult{Orders: len(orders), Prices: len(prices)})
+}
@@ -0,0 +1,186 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/decimal"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+
+	sortParam   = "sort"
+	limitParam  = "limit"
+	offsetParam = "offset"
+)
+
+type sortKey struct {
+	field string
+	desc  bool
+}
+
+// listQuery is parsed from the query string of list endpoints:
+// ?symbol=BTCUSDT,ETHUSDT&sort=-price,symbol&limit=20&offset=40
+type listQuery struct {
+	filters map[string][]string
+	sort    []sortKey
+	limit   int
+	offset  int
+}
+
+type listPage struct {
+	Data   interface{} `json:"data"`
+	Total  int         `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+}
+
+func parseListQuery(r *http.Request, resource *apiResource) (*listQuery, error) {
+	q := &listQuery{filters: make(map[string][]string), limit: defaultPageLimit}
+	for key, values := range r.URL.Query() {
+		value := values[len(values)-1]
+		switch key {
+		case sortParam:
+			for _, field := range strings.Split(value, ",") {
+				key := sortKey{field: strings.TrimPrefix(field, "-"), desc: strings.HasPrefix(field, "-")}
+				if !contains(resource.sorts, key.field) {
+					return nil, fmt.Errorf("sorting by %q is not supported, use one of %s", key.field, strings.Join(resource.sorts, ", "))
+				}
+				q.sort = append(q.sort, key)
+			}
+		case limitParam:
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 1 || limit > maxPageLimit {
+				return nil, fmt.Errorf("limit should be a number between 1 and %d", maxPageLimit)
+			}
+			q.limit = limit
+		case offsetParam:
+			offset, err := strconv.Atoi(value)
+			if err != nil || offset < 0 {
+				return nil, fmt.Errorf("offset should be a non-negative number")
+			}
+			q.offset = offset
+		default:
+			if !contains(resource.filters, key) {
+				return nil, fmt.Errorf("filtering by %q is not supported, use one of %s", key, strings.Join(resource.filters, ", "))
+			}
+			q.filters[key] = strings.Split(value, ",")
+		}
+	}
+	return q, nil
+}
+
+// apply filters, sorts and paginates items, which should be a slice of struct pointers.
+func (q *listQuery) apply(items interface{}) *listPage {
+	v := reflect.ValueOf(items)
+	filtered := make([]reflect.Value, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		if q.matches(v.Index(i)) {
+			filtered = append(filtered, v.Index(i))
+		}
+	}
+
+	if len(q.sort) > 0 {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			for _, key := range q.sort {
+				cmp := compareValues(jsonField(filtered[i], key.field), jsonField(filtered[j], key.field))
+				if cmp == 0 {
+					continue
+				}
+				if key.desc {
+					return cmp > 0
+				}
+				return cmp < 0
+			}
+			return false
+		})
+	}
+
+	total := len(filtered)
+	start, end := q.offset, q.offset+q.limit
+	if start > total {
+		start = total
+	}
+	if end > total {
+		end = total
+	}
+	data := reflect.MakeSlice(v.Type(), 0, end-start)
+	for _, item := range filtered[start:end] {
+		data = reflect.Append(data, item)
+	}
+	return &listPage{Data: data.Interface(), Total: total, Limit: q.limit, Offset: q.offset}
+}
+
+func (q *listQuery) matches(item reflect.Value) bool {
+	for field, values := range q.filters {
+		actual := fmt.Sprint(jsonField(item, field).Interface())
+		found := false
+		for _, value := range values {
+			if strings.EqualFold(actual, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// compareValues compares numbers stored as strings numerically, Binance sends prices as strings.
+func compareValues(a, b reflect.Value) int {
+	as, bs := fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface())
+	ad, aErr := decimal.Parse(as)
+	bd, bErr := decimal.Parse(bs)
+	switch {
+	case aErr == nil && bErr == nil:
+		return ad.Cmp(bd)
+	case aErr == nil:
+		// numbers go before N/A and other text
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(as, bs)
+}
+
+var jsonFieldIndexes sync.Map
+
+// jsonField returns the struct field of the pointer item tagged with the json name.
+func jsonField(item reflect.Value, name string) reflect.Value {
+	s := reflect.Indirect(item)
+	indexes, ok := jsonFieldIndexes.Load(s.Type())
+	if !ok {
+		indexes = jsonFieldIndex(s.Type())
+		jsonFieldIndexes.Store(s.Type(), indexes)
+	}
+	return s.Field(indexes.(map[string]int)[name])
+}
+
+func jsonFieldIndex(t reflect.Type) map[string]int {
+	indexes := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			indexes[name] = i
+		}
+	}
+	return indexes
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,301 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/events"
+)
+
+func newTestAPI(t *testing.T, dbClient db.Client) http.Handler {
+	c := &client{db: dbClient, bus: events.NewBus(), exchangeInfo: newTestExchangeInfo(), accounts: []string{db.DefaultAccountID}}
+	r := mux.NewRouter()
+	c.registerAPI(r)
+	return r
+}
+
+var testAdmin = &db.User{ID: "admin-id", Username: "admin", Role: db.RoleAdmin, Email: "admin@example.com"}
+
+// doRequest sends the request as testAdmin, authentication is done by the root router middleware.
+func doRequest(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	return doRequestAs(h, testAdmin, method, target, body)
+}
+
+func doRequestAs(h http.Handler, user *db.User, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if user != nil {
+		req = withUser(req, user)
+	}
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIListOrders(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	err := dbClient.SetOrders(db.DefaultAccountID, []*db.Order{
+		{Symbol: "BTCUSDT", OrderID: 1, Price: "9.5", Side: "BUY"},
+		{Symbol: "ETHUSDT", OrderID: 2, Price: "10", Side: "SELL"},
+		{Symbol: "BTCUSDT", OrderID: 3, Price: "100", Side: "SELL"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newTestAPI(t, dbClient)
+
+	rec := doRequest(h, http.MethodGet, "/api/v1/orders?symbol=btcusdt&sort=-price&limit=1", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	var page struct {
+		Data  []*db.Order `json:"data"`
+		Total int         `json:"total"`
+		Limit int         `json:"limit"`
+	}
+	if err = json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || page.Limit != 1 || len(page.Data) != 1 || page.Data[0].OrderID != 3 {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+
+	rec = doRequest(h, http.MethodGet, "/api/v1/orders?sort=price&offset=1", "")
+	if err = json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Data) != 2 || page.Data[0].OrderID != 2 || page.Data[1].OrderID != 3 {
+		t.Fatalf("prices should be sorted numerically: %+v", page.Data)
+	}
+
+	for _, target := range []string{"/api/v1/orders?clientOrderId=x", "/api/v1/orders?sort=unknown", "/api/v1/orders?limit=0"} {
+		if rec = doRequest(h, http.MethodGet, target, ""); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected bad request, got %d", target, rec.Code)
+		}
+	}
+}
+
+func TestAPIListOrderLists(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	err := dbClient.SetOrderLists(db.DefaultAccountID, []*db.OrderList{
+		{Symbol: "BTCUSDT", OrderListID: 1, ContingencyType: "OCO", TriggerDistance: "12.5"},
+		{Symbol: "ETHUSDT", OrderListID: 2, ContingencyType: "OCO", TriggerDistance: "N/A"},
+		{Symbol: "BNBUSDT", OrderListID: 3, ContingencyType: "OCO", TriggerDistance: "2.10"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newTestAPI(t, dbClient)
+
+	rec := doRequest(h, http.MethodGet, "/api/v1/order-lists?sort=triggerDistance", "")
+	var page struct {
+		Data []*db.OrderList `json:"data"`
+	}
+	if err = json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || len(page.Data) != 3 || page.Data[0].OrderListID != 3 || page.Data[2].OrderListID != 2 {
+		t.Fatalf("order lists should be sorted by distance with N/A last: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAPIListEvents(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	for i, e := range []*db.Event{
+		{ID: "1", Type: db.EventTypeFetchCompleted},
+		{ID: "2", Type: db.EventTypeAlertTriggered, Symbol: "BTCUSDT", UserID: "trader-id"},
+		{ID: "3", Type: db.EventTypeAlertTriggered, Symbol: "BTCUSDT", UserID: testAdmin.ID},
+		{ID: "4", Type: db.EventTypeAlertTriggered, Symbol: "ETHUSDT"},
+	} {
+		e.CreatedAt = int64(i + 1)
+		if err := dbClient.AddEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := newTestAPI(t, dbClient)
+
+	rec := doRequest(h, http.MethodGet, "/api/v1/events?type=ALERT_TRIGGERED&symbol=btcusdt&limit=1&offset=1", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	var page struct {
+		Data  []*db.Event `json:"data"`
+		Total int         `json:"total"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || len(page.Data) != 1 || page.Data[0].ID != "2" {
+		t.Fatalf("expected the second newest BTCUSDT alert event, got %+v", page)
+	}
+
+	// alert events of other users are hidden from non admins
+	trader := &db.User{ID: "trader-id", Username: "trader", Role: db.RoleViewer}
+	rec = doRequestAs(h, trader, http.MethodGet, "/api/v1/events", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || page.Data[0].ID != "2" || page.Data[1].ID != "1" {
+		t.Fatalf("expected the alert event of the user and the fetch event, got %+v", page)
+	}
+	if rec = doRequest(h, http.MethodGet, "/api/v1/events?sort=message", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected unsupported sorts to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestAPIAlerts(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	h := newTestAPI(t, dbClient)
+
+	rec := doRequest(h, http.MethodPost, "/api/v1/alerts", `{"symbol":"btcusdt","price":"40000","email":"john@example.com","directionDown":true}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	created := &db.Alert{}
+	if err := json.Unmarshal(rec.Body.Bytes(), created); err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" || created.Symbol != "BTCUSDT" || rec.Header().Get("Location") != "/api/v1/alerts/"+created.ID {
+		t.Fatalf("unexpected alert %+v, location %s", created, rec.Header().Get("Location"))
+	}
+
+	if rec = doRequest(h, http.MethodGet, "/api/v1/alerts/"+created.ID, ""); rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	if rec = doRequest(h, http.MethodDelete, "/api/v1/alerts/"+created.ID, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	if rec = doRequest(h, http.MethodDelete, "/api/v1/alerts/"+created.ID, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected not found, got %d", rec.Code)
+	}
+}
+
+func TestAPIAlertValidation(t *testing.T) {
+	h := newTestAPI(t, db.NewMemoryClient())
+
+	rec := doRequest(h, http.MethodPost, "/api/v1/alerts", `{"symbol":"BTC-USDT","price":"-1","email":"nope"}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	envelope := &apiErrorEnvelope{}
+	if err := json.Unmarshal(rec.Body.Bytes(), envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope.Error.Code != "validation_failed" || len(envelope.Error.Details) != 3 {
+		t.Fatalf("unexpected error: %+v", envelope.Error)
+	}
+
+	if rec = doRequest(h, http.MethodPost, "/api/v1/alerts", `{"symbol":"BTCUSDT","unknown":1}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown fields should be rejected, got %d", rec.Code)
+	}
+}
+
+func TestValidateAlert(t *testing.T) {
+	if err := ValidateAlert(&db.Alert{Symbol: "BTCUSDT", Price: "N/A"}); err == nil {
+		t.Fatal("expected an invalid price to be rejected")
+	}
+	// alerts stored before types were introduced are price alerts
+	alert := &db.Alert{Symbol: " btcusdt", Price: "40000"}
+	if err := ValidateAlert(alert); err != nil {
+		t.Fatal(err)
+	}
+	if alert.Type != db.AlertTypePrice || alert.Symbol != "BTCUSDT" {
+		t.Fatalf("expected the alert to be normalized, got %+v", alert)
+	}
+}
+
+func TestAPIErrorEnvelopes(t *testing.T) {
+	h := newTestAPI(t, db.NewMemoryClient())
+
+	for _, tc := range []struct {
+		method, target string
+		status         int
+		code           string
+	}{
+		{http.MethodGet, "/api/v1/unknown", http.StatusNotFound, "not_found"},
+		{http.MethodPut, "/api/v1/orders", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{http.MethodGet, "/api/v1/prices/BTCUSDT", http.StatusNotFound, "not_found"},
+	} {
+		rec := doRequest(h, tc.method, tc.target, "")
+		envelope := &apiErrorEnvelope{}
+		if err := json.Unmarshal(rec.Body.Bytes(), envelope); err != nil {
+			t.Fatalf("%s %s: %s", tc.method, tc.target, err)
+		}
+		if rec.Code != tc.status || envelope.Error.Code != tc.code {
+			t.Fatalf("%s %s: got %d %+v", tc.method, tc.target, rec.Code, envelope.Error)
+		}
+	}
+}
+
+func TestAPIOpenAPIDocument(t *testing.T) {
+	h := newTestAPI(t, db.NewMemoryClient())
+	rec := doRequest(h, http.MethodGet, "/api/v1/openapi.json", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	var doc struct {
+		Paths      map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	c := &client{}
+	for _, route := range c.apiRoutes() {
+		if _, ok := doc.Paths[apiPrefix+route.path][strings.ToLower(route.method)]; !ok {
+			t.Fatalf("%s %s is missing in the document", route.method, route.path)
+		}
+	}
+	for _, name := range []string{"Order", "Price", "Alert", "Event", "AlertInput", "ApiErrorEnvelope"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Fatalf("schema %s is missing", name)
+		}
+	}
+}
+
+func TestAPIUpdateAlert(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	if err := dbClient.AddAlert(&db.Alert{ID: "1", Symbol: "BTCUSDT", Price: "40000", Email: "john@example.com", CreatedAt: 1, CreatedBy: "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	c := &client{db: dbClient, bus: events.NewBus(), exchangeInfo: newTestExchangeInfo(), accounts: []string{db.DefaultAccountID}}
+	r := mux.NewRouter()
+	r.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, withUsername(r, "editor"))
+		})
+	})
+	c.registerAPI(r)
+
+	rec := doRequest(r, http.MethodPatch, "/api/v1/alerts/1", `{"price":"41000.5"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	alerts, _ := dbClient.GetAlerts()
+	a := alerts[0]
+	if a.Price != "41000.5" || a.Symbol != "BTCUSDT" || a.UpdatedBy != "editor" || a.UpdatedAt == 0 || a.CreatedBy != "admin" || a.CreatedAt != 1 {
+		t.Fatalf("unexpected alert after patch: %+v", a)
+	}
+
+	rec = doRequest(r, http.MethodPut, "/api/v1/alerts/1", `{"symbol":"ETHUSDT","price":"3000","email":"jane@example.com"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	alerts, _ = dbClient.GetAlerts()
+	if a = alerts[0]; a.Symbol != "ETHUSDT" || a.Email != "jane@example.com" || a.DirectionDown {
+		t.Fatalf("unexpected alert after put: %+v", a)
+	}
+
+	if rec = doRequest(r, http.MethodPatch, "/api/v1/alerts/1", `{"price":"abc"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected validation error, got %d", rec.Code)
+	}
+	if rec = doRequest(r, http.MethodPut, "/api/v1/alerts/2", `{"symbol":"ETHUSDT","price":"3000","email":"jane@example.com"}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected not found, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,208 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/decimal"
+)
+
+const (
+	maxRequestBodySize = 1 << 20
+	maxTextLength      = 1000
+	maxNameLength      = 100
+)
+
+var symbolRegexp = regexp.MustCompile(`^[A-Z0-9]{2,20}$`)
+
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type validationError struct {
+	fields []*fieldError
+}
+
+func (e *validationError) Error() string {
+	messages := make([]string, 0, len(e.fields))
+	for _, f := range e.fields {
+		messages = append(messages, f.Field+": "+f.Message)
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+func (e *validationError) add(field, message string) {
+	e.fields = append(e.fields, &fieldError{Field: field, Message: message})
+}
+
+func (e *validationError) orNil() error {
+	if len(e.fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// alertInput is the request body accepted by alert endpoints.
+type alertInput struct {
+	AccountID     string `json:"accountId"`
+	Type          string `json:"type"`
+	Symbol        string `json:"symbol"`
+	Price         string `json:"price"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	Text          string `json:"text"`
+	DirectionDown bool   `json:"directionDown"`
+}
+
+// alertPatch is the PATCH body, only provided fields are changed.
+type alertPatch struct {
+	AccountID     *string `json:"accountId"`
+	Type          *string `json:"type"`
+	Symbol        *string `json:"symbol"`
+	Price         *string `json:"price"`
+	Name          *string `json:"name"`
+	Email         *string `json:"email"`
+	Text          *string `json:"text"`
+	DirectionDown *bool   `json:"directionDown"`
+}
+
+func (p *alertPatch) applyTo(in *alertInput) {
+	if p.AccountID != nil {
+		in.AccountID = *p.AccountID
+	}
+	if p.Type != nil {
+		in.Type = *p.Type
+	}
+	if p.Symbol != nil {
+		in.Symbol = *p.Symbol
+	}
+	if p.Price != nil {
+		in.Price = *p.Price
+	}
+	if p.Name != nil {
+		in.Name = *p.Name
+	}
+	if p.Email != nil {
+		in.Email = *p.Email
+	}
+	if p.Text != nil {
+		in.Text = *p.Text
+	}
+	if p.DirectionDown != nil {
+		in.DirectionDown = *p.DirectionDown
+	}
+}
+
+func alertInputFrom(alert *db.Alert) *alertInput {
+	return &alertInput{
+		AccountID:     alert.AccountID,
+		Type:          alert.Type,
+		Symbol:        alert.Symbol,
+		Price:         alert.Price,
+		Name:          alert.Name,
+		Email:         alert.Email,
+		Text:          alert.Text,
+		DirectionDown: alert.DirectionDown,
+	}
+}
+
+func (in *alertInput) applyTo(alert *db.Alert) {
+	alert.AccountID = in.AccountID
+	alert.Type = in.Type
+	alert.Symbol = in.Symbol
+	alert.Price = in.Price
+	alert.Name = in.Name
+	alert.Email = in.Email
+	alert.Text = in.Text
+	alert.DirectionDown = in.DirectionDown
+}
+
+func (in *alertInput) normalize() {
+	in.Type = strings.ToLower(strings.TrimSpace(in.Type))
+	if in.Type == "" {
+		in.Type = db.AlertTypePrice
+	}
+	in.Symbol = strings.ToUpper(strings.TrimSpace(in.Symbol))
+	in.Price = strings.TrimSpace(in.Price)
+	in.Name = strings.TrimSpace(in.Name)
+	in.Email = strings.TrimSpace(in.Email)
+}
+
+func (in *alertInput) validate() error {
+	errs := &validationError{}
+	if !symbolRegexp.MatchString(in.Symbol) {
+		errs.add("symbol", "should be a Binance symbol like BTCUSDT")
+	}
+	switch in.Type {
+	case db.AlertTypePrice:
+		validatePositive(errs, "price", in.Price)
+	case db.AlertTypeLiquidationDistance:
+		if distance, err := decimal.Parse(in.Price); err != nil || distance.Sign() <= 0 || distance.GreaterThan(decimal.NewFromInt(100)) {
+			errs.add("price", "should be a liquidation distance in percent between 0 and 100")
+		}
+	case db.AlertTypeFundingRate:
+		if _, err := decimal.Parse(in.Price); err != nil {
+			errs.add("price", "should be a funding rate in percent")
+		}
+	default:
+		errs.add("type", fmt.Sprintf("should be one of %s, %s, %s", db.AlertTypePrice, db.AlertTypeLiquidationDistance, db.AlertTypeFundingRate))
+	}
+	if len(in.Name) > maxNameLength {
+		errs.add("name", fmt.Sprintf("should be at most %d characters long", maxNameLength))
+	}
+	if in.Email != "" {
+		if _, err := mail.ParseAddress(in.Email); err != nil {
+			errs.add("email", "should be a valid email address")
+		}
+	}
+	if len(in.Text) > maxTextLength {
+		errs.add("text", fmt.Sprintf("should be at most %d characters long", maxTextLength))
+	}
+	return errs.orNil()
+}
+
+// ValidateAlert checks a stored alert with the rules of the alert endpoints and normalizes it, it is used
+// for alerts which don't come from the API, e.g. imported ones.
+func ValidateAlert(alert *db.Alert) error {
+	in := alertInputFrom(alert)
+	in.normalize()
+	if err := in.validate(); err != nil {
+		return err
+	}
+	in.applyTo(alert)
+	return nil
+}
+
+// checkAlertRecipient requires an alert email when the alert owner has none, alerts are sent to the owner email.
+func checkAlertRecipient(r *http.Request, in *alertInput) error {
+	if in.Email != "" {
+		return nil
+	}
+	if user := userFromRequest(r); user != nil && user.Email != "" {
+		return nil
+	}
+	errs := &validationError{}
+	errs.add("email", "is required because your user has no email")
+	return errs
+}
+
+// decodeJSONBody strictly decodes the request body: unknown fields and trailing data are rejected.
+func decodeJSONBody(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxRequestBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid JSON body: %s", err)
+	}
+	if decoder.More() {
+		return errors.New("invalid JSON body: unexpected data after the object")
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/checker"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/klines"
+)
+
+const (
+	defaultBacktestDays = 30
+	maxBacktestDays     = 365
+	// maxBacktestCandles limits the klines downloaded for a single backtest, e.g. 1m candles of 35 days.
+	maxBacktestCandles = 50000
+)
+
+// backtestInput is the price alert to backtest and the history to replay.
+type backtestInput struct {
+	Symbol        string `json:"symbol"`
+	Price         string `json:"price"`
+	DirectionDown bool   `json:"directionDown"`
+	Interval      string `json:"interval"`
+	Days          int    `json:"days"`
+}
+
+type backtestResult struct {
+	Symbol   string             `json:"symbol"`
+	Interval string             `json:"interval"`
+	From     int64              `json:"from"`
+	To       int64              `json:"to"`
+	Candles  int                `json:"candles"`
+	Triggers []*checker.Trigger `json:"triggers"`
+}
+
+func (in *backtestInput) normalize() {
+	in.Symbol = strings.ToUpper(strings.TrimSpace(in.Symbol))
+	in.Price = strings.TrimSpace(in.Price)
+	if in.Interval == "" {
+		in.Interval = defaultKlinesInterval
+	}
+	if in.Days == 0 {
+		in.Days = defaultBacktestDays
+	}
+}
+
+func (in *backtestInput) validate() error {
+	errs := &validationError{}
+	if !symbolRegexp.MatchString(in.Symbol) {
+		errs.add("symbol", "should be a Binance symbol like BTCUSDT")
+	}
+	validatePositive(errs, "price", in.Price)
+	if in.Days < 1 || in.Days > maxBacktestDays {
+		errs.add("days", fmt.Sprintf("should be between 1 and %d", maxBacktestDays))
+	}
+	if duration, ok := klines.Intervals[in.Interval]; !ok {
+		errs.add("interval", "unsupported interval "+in.Interval)
+	} else if candles := time.Duration(in.Days) * 24 * time.Hour / duration; candles > maxBacktestCandles {
+		errs.add("interval", fmt.Sprintf("%d days of %s candles is more than %d candles, use a longer interval", in.Days, in.Interval, maxBacktestCandles))
+	}
+	return errs.orNil()
+}
+
+// apiBacktestAlert estimates how often a price alert would have fired over the last days.
+func (c *client) apiBacktestAlert(w http.ResponseWriter, r *http.Request) {
+	in := &backtestInput{}
+	if err := decodeJSONBody(r, in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_body", err.Error())
+		return
+	}
+	in.normalize()
+	if err := in.validate(); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	if err := c.checkSymbol(in.Symbol); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+
+	to := time.Now()
+	from := to.Add(-time.Duration(in.Days) * 24 * time.Hour)
+	items, err := c.klines.Get(in.Symbol, in.Interval, from, to)
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, "klines_failed", err.Error())
+		return
+	}
+	alert := &db.Alert{Type: db.AlertTypePrice, Symbol: in.Symbol, Price: in.Price, DirectionDown: in.DirectionDown}
+	triggers, err := checker.Backtest(alert, items)
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	if triggers == nil {
+		triggers = []*checker.Trigger{}
+	}
+	writeJSON(w, http.StatusOK, &backtestResult{
+		Symbol:   in.Symbol,
+		Interval: in.Interval,
+		From:     from.UnixMilli(),
+		To:       to.UnixMilli(),
+		Candles:  len(items),
+		Triggers: triggers,
+	})
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/events"
+)
+
+func TestAPIBacktestAlert(t *testing.T) {
+	cache := &fakeKlinesCache{klines: []*db.Kline{
+		{Symbol: "BTCUSDT", Interval: "1h", OpenTime: 1, High: "50500", Low: "49000", Close: "49500"},
+		{Symbol: "BTCUSDT", Interval: "1h", OpenTime: 2, High: "49800", Low: "49000", Close: "49100"},
+		{Symbol: "BTCUSDT", Interval: "1h", OpenTime: 3, High: "50000", Low: "49100", Close: "50000"},
+	}}
+	c := &client{db: db.NewMemoryClient(), bus: events.NewBus(), klines: cache, exchangeInfo: newTestExchangeInfo()}
+	r := mux.NewRouter()
+	c.registerAPI(r)
+
+	rec := doRequest(r, http.MethodPost, "/api/v1/alerts/backtest", `{"symbol":"btcusdt","price":"50000","days":7}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	result := &backtestResult{}
+	if err := json.Unmarshal(rec.Body.Bytes(), result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Interval != defaultKlinesInterval || result.Candles != 3 || len(result.Triggers) != 2 || result.Triggers[1].Time != 3 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if since := time.Since(cache.from); since < 7*24*time.Hour || since > 7*24*time.Hour+time.Minute {
+		t.Fatalf("unexpected range start %s", cache.from)
+	}
+
+	for _, body := range []string{
+		`{"symbol":"BTCUSDT","price":"-1"}`,
+		`{"symbol":"BTCUSDT","price":"50000","interval":"7m"}`,
+		`{"symbol":"BTCUSDT","price":"50000","interval":"1m","days":365}`,
+		`{"symbol":"DOGEUSDT","price":"1"}`,
+	} {
+		if rec = doRequest(r, http.MethodPost, "/api/v1/alerts/backtest", body); rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%s: expected 422, got %d: %s", body, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package client
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+type BlocklistPageTemplateData struct {
+	AppURI    string
+	AppSchema string
+	AppPort   string
+	IP        string
+	Nonce     string
+	CSRFToken string
+}
+
+type lockoutResponse struct {
+	IP       string `json:"ip"`
+	Failures int    `json:"failures"`
+	Lockouts int    `json:"lockouts"`
+	// LockedUntil is 0 when the IP is not locked out
+	LockedUntil   int64 `json:"lockedUntil"`
+	LastFailureAt int64 `json:"lastFailureAt"`
+}
+
+type ipBanInput struct {
+	// Network is an IP or a CIDR range
+	Network string `json:"network"`
+	Reason  string `json:"reason"`
+}
+
+// banList caches the banned networks, it is loaded from the database on first use and after every change.
+type banList struct {
+	mu       sync.RWMutex
+	loaded   bool
+	networks []*net.IPNet
+}
+
+// ParseNetworks parses a comma separated list of IPs and CIDR ranges.
+func ParseNetworks(list string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		network, err := parseNetwork(s)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// parseNetwork parses an IP as a single address network or a CIDR range.
+func parseNetwork(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, network, err := net.ParseCIDR(s)
+		return network, err
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("%q is not an IP or a CIDR range", s)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP of the client. Forwarding headers are only read from trusted proxies, the client is
+// the last X-Forwarded-For address which is not a trusted proxy, so clients can't spoof their IP.
+func (c *client) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	remote := net.ParseIP(host)
+	if remote == nil || !containsIP(c.trustedProxies, remote) {
+		return host
+	}
+
+	if header := r.Header.Get("X-Forwarded-For"); header != "" {
+		forwarded := strings.Split(header, ",")
+		for i := len(forwarded) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+			if ip == nil {
+				return host
+			}
+			if !containsIP(c.trustedProxies, ip) || i == 0 {
+				return ip.String()
+			}
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-Ip"))); ip != nil {
+		return ip.String()
+	}
+	return host
+}
+
+// lockoutKey returns the key failed logins of the IP are counted by. IPv6 clients usually get a whole /64
+// network and can pick any address in it, so they are counted by the /64 prefix, IPv4 clients by their address.
+func lockoutKey(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if parsed.To4() != nil {
+		return parsed.String()
+	}
+	mask := net.CIDRMask(64, 128)
+	return (&net.IPNet{IP: parsed.Mask(mask), Mask: mask}).String()
+}
+
+func (c *client) loadBans() error {
+	bans, err := c.db.GetIPBans()
+	if err != nil {
+		return err
+	}
+	networks := make([]*net.IPNet, 0, len(bans))
+	for _, ban := range bans {
+		network, err := parseNetwork(ban.Network)
+		if err != nil {
+			log.Printf("skipping invalid ban %s: %s", ban.Network, err)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	c.bans.mu.Lock()
+	c.bans.networks, c.bans.loaded = networks, true
+	c.bans.mu.Unlock()
+	return nil
+}
+
+func (c *client) isBanned(ip string) (bool, error) {
+	c.bans.mu.RLock()
+	loaded := c.bans.loaded
+	c.bans.mu.RUnlock()
+	if !loaded {
+		if err := c.loadBans(); err != nil {
+			return false, err
+		}
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false, nil
+	}
+	c.bans.mu.RLock()
+	defer c.bans.mu.RUnlock()
+	return containsIP(c.bans.networks, parsed), nil
+}
+
+// banMiddleware rejects every request from banned networks, including the login form.
+func (c *client) banMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		banned, err := c.isBanned(c.clientIP(r))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if banned {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("Forbidden.\n"))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// authFailed counts a failed login of the lockout key of an IP and tells the admin when the key gets locked out.
+func (c *client) authFailed(key string) {
+	lockout := c.limiter.Fail(key)
+	if lockout == 0 {
+		return
+	}
+	text := fmt.Sprintf("IP %s is locked out for %s after too many failed logins.", key, lockout)
+	log.Println(text)
+	c.alertManager.SendAlert(c.authReqAlertAdminEmail, c.authReqAlertAdminName, text)
+}
+
+// writeLockedOut responds to locked out IPs, Retry-After tells scripts when to try again.
+func writeLockedOut(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds()+1)))
+	if strings.HasPrefix(r.URL.Path, apiPrefix+"/") {
+		writeAPIError(w, http.StatusTooManyRequests, "too_many_attempts", "too many failed logins, try again later")
+		return
+	}
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write([]byte("Too many failed attempts.\n"))
+}
+
+func (c *client) blocklistHandler(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := template.ParseFiles("./internal/client/templates/blocklist.html")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	data := &BlocklistPageTemplateData{
+		AppURI:    c.appUri,
+		AppSchema: c.appSchema,
+		AppPort:   c.appPort,
+		IP:        c.clientIP(r),
+		Nonce:     cspNonce(r),
+		CSRFToken: c.csrfToken(r),
+	}
+	if err = tmpl.Execute(w, data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+	}
+}
+
+func (c *client) apiListLockouts(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	entries := c.limiter.Entries()
+	lockouts := make([]*lockoutResponse, 0, len(entries))
+	for _, e := range entries {
+		lockout := &lockoutResponse{IP: e.Key, Failures: e.Failures, Lockouts: e.Lockouts, LastFailureAt: e.LastFailure.UnixMilli()}
+		if e.LockedUntil.After(now) {
+			lockout.LockedUntil = e.LockedUntil.UnixMilli()
+		}
+		lockouts = append(lockouts, lockout)
+	}
+	c.writeList(w, r, lockoutsResource, lockouts)
+}
+
+// apiDeleteLockout unblocks an IP, or the /64 network of an IPv6 address, and forgets its failures.
+func (c *client) apiDeleteLockout(w http.ResponseWriter, r *http.Request) {
+	key := lockoutKey(mux.Vars(r)["ip"])
+	for _, e := range c.limiter.Entries() {
+		if e.Key == key {
+			c.limiter.Reset(key)
+			log.Printf("user %s unblocked IP %s", usernameFromRequest(r), key)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeAPIError(w, http.StatusNotFound, "not_found", "IP has no failed logins")
+}
+
+func (c *client) apiListBans(w http.ResponseWriter, r *http.Request) {
+	bans, err := c.db.GetIPBans()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	c.writeList(w, r, bansResource, bans)
+}
+
+func (c *client) apiCreateBan(w http.ResponseWriter, r *http.Request) {
+	in := &ipBanInput{}
+	if err := decodeJSONBody(r, in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_body", err.Error())
+		return
+	}
+	errs := &validationError{}
+	in.Reason = strings.TrimSpace(in.Reason)
+	network, err := parseNetwork(strings.TrimSpace(in.Network))
+	if err != nil {
+		errs.add("network", "should be an IP or a CIDR range")
+	} else if ip := net.ParseIP(c.clientIP(r)); ip != nil && network.Contains(ip) {
+		errs.add("network", "contains your own IP "+ip.String())
+	}
+	if len(in.Reason) > maxNameLength {
+		errs.add("reason", fmt.Sprintf("should be at most %d characters long", maxNameLength))
+	}
+	if err = errs.orNil(); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+
+	bans, err := c.db.GetIPBans()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	for _, ban := range bans {
+		if ban.Network == network.String() {
+			writeAPIError(w, http.StatusConflict, "conflict", network.String()+" is already banned")
+			return
+		}
+	}
+	ban := &db.IPBan{
+		ID:        uuid.NewString(),
+		Network:   network.String(),
+		Reason:    in.Reason,
+		CreatedBy: usernameFromRequest(r),
+		CreatedAt: time.Now().UnixMilli(),
+	}
+	if err = c.db.AddIPBan(ban); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	if err = c.loadBans(); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	log.Printf("user %s banned %s", ban.CreatedBy, ban.Network)
+	writeJSON(w, http.StatusCreated, ban)
+}
+
+func (c *client) apiDeleteBan(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	bans, err := c.db.GetIPBans()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	for _, ban := range bans {
+		if ban.ID != id {
+			continue
+		}
+		if err = c.db.DeleteIPBan(id); err != nil {
+			writeAPIErrorFrom(w, err)
+			return
+		}
+		if err = c.loadBans(); err != nil {
+			writeAPIErrorFrom(w, err)
+			return
+		}
+		log.Printf("user %s lifted the ban of %s", usernameFromRequest(r), ban.Network)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeAPIError(w, http.StatusNotFound, "not_found", "ban not found")
+}
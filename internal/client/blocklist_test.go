@@ -0,0 +1,215 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/ratelimit"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseNetworks("192.0.2.1, 10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &client{trustedProxies: trusted}
+	for _, tc := range []struct {
+		name, remote, forwarded, realIP, want string
+	}{
+		{name: "direct", remote: "203.0.113.1:1234", want: "203.0.113.1"},
+		{name: "untrusted proxy", remote: "203.0.113.1:1234", forwarded: "198.51.100.1", realIP: "198.51.100.2", want: "203.0.113.1"},
+		{name: "trusted proxy", remote: "192.0.2.1:1234", forwarded: "198.51.100.1", want: "198.51.100.1"},
+		{name: "proxy chain", remote: "192.0.2.1:1234", forwarded: "198.51.100.1, 10.0.0.2", want: "198.51.100.1"},
+		{name: "spoofed forwarded for", remote: "192.0.2.1:1234", forwarded: "6.6.6.6, 198.51.100.1", want: "198.51.100.1"},
+		{name: "only proxies", remote: "192.0.2.1:1234", forwarded: "10.0.0.3, 10.0.0.2", want: "10.0.0.3"},
+		{name: "invalid forwarded for", remote: "192.0.2.1:1234", forwarded: "nope", realIP: "198.51.100.2", want: "192.0.2.1"},
+		{name: "real ip", remote: "192.0.2.1:1234", realIP: "198.51.100.2", want: "198.51.100.2"},
+		{name: "ipv6", remote: "[2001:db8::1]:1234", forwarded: "198.51.100.1", want: "2001:db8::1"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tc.remote
+		if tc.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tc.forwarded)
+		}
+		if tc.realIP != "" {
+			req.Header.Set("X-Real-Ip", tc.realIP)
+		}
+		if got := c.clientIP(req); got != tc.want {
+			t.Fatalf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func postLoginFrom(c *client, ip string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, loginPath, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Real-Ip", ip)
+	rec := httptest.NewRecorder()
+	c.r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLoginLockout(t *testing.T) {
+	chdirToRoot(t)
+	dbClient := db.NewMemoryClient()
+	addTestUser(t, dbClient, "alice", "alice-password")
+	c := newTestRouter(t, dbClient)
+	wrong := url.Values{"username": {"alice"}, "password": {"wrong-password"}}
+	right := url.Values{"username": {"alice"}, "password": {"alice-password"}}
+
+	// page loads and successful logins don't count as failures
+	for i := 0; i < ratelimit.DefaultMaxFailures; i++ {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Real-Ip", "198.51.100.1")
+		c.r.ServeHTTP(rec, req)
+	}
+	for i := 0; i < ratelimit.DefaultMaxFailures-1; i++ {
+		if rec := postLoginFrom(c, "198.51.100.1", wrong); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("unexpected status %d", rec.Code)
+		}
+	}
+	if rec := postLoginFrom(c, "198.51.100.1", right); rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected the login to succeed, got %d", rec.Code)
+	}
+
+	for i := 0; i < ratelimit.DefaultMaxFailures; i++ {
+		postLoginFrom(c, "198.51.100.1", wrong)
+	}
+	rec := postLoginFrom(c, "198.51.100.1", right)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" || !strings.Contains(rec.Body.String(), "Too many failed attempts") {
+		t.Fatalf("expected the IP to be locked out, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = postLoginFrom(c, "198.51.100.2", right); rec.Code != http.StatusSeeOther {
+		t.Fatalf("other IPs should not be locked out, got %d", rec.Code)
+	}
+	alerts := strings.Join(c.alertManager.(*fakeAlertManager).sent, "\n")
+	if !strings.Contains(alerts, "IP 198.51.100.1 is locked out for 1m0s") {
+		t.Fatalf("expected the admin to be told about the lockout, got %s", alerts)
+	}
+
+	// basic auth and API tokens of a locked out IP are rejected before checking them
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/alerts", nil)
+	req.Header.Set("X-Real-Ip", "198.51.100.1")
+	req.SetBasicAuth("alice", "alice-password")
+	rec = httptest.NewRecorder()
+	c.r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected basic auth to be locked out, got %d", rec.Code)
+	}
+
+	c.limiter.Reset("198.51.100.1")
+	if rec = postLoginFrom(c, "198.51.100.1", right); rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected the unblocked IP to log in, got %d", rec.Code)
+	}
+}
+
+func TestLockoutKey(t *testing.T) {
+	for ip, want := range map[string]string{
+		"198.51.100.1":         "198.51.100.1",
+		"::ffff:198.51.100.1":  "198.51.100.1",
+		"2001:db8:1:2:3:4:5:6": "2001:db8:1:2::/64",
+		"2001:db8:1:2::":       "2001:db8:1:2::/64",
+		"2001:db8:1:2::/64":    "2001:db8:1:2::/64",
+		"not an ip":            "not an ip",
+	} {
+		if got := lockoutKey(ip); got != want {
+			t.Fatalf("%s: got %s, want %s", ip, got, want)
+		}
+	}
+}
+
+func TestLoginLockoutIPv6(t *testing.T) {
+	chdirToRoot(t)
+	dbClient := db.NewMemoryClient()
+	addTestUser(t, dbClient, "alice", "alice-password")
+	c := newTestRouter(t, dbClient)
+	api := mux.NewRouter()
+	c.registerAPI(api)
+	wrong := url.Values{"username": {"alice"}, "password": {"wrong-password"}}
+	right := url.Values{"username": {"alice"}, "password": {"alice-password"}}
+
+	// a client rotating the addresses of its /64 network is locked out
+	for i := 0; i < ratelimit.DefaultMaxFailures; i++ {
+		postLoginFrom(c, fmt.Sprintf("2001:db8:1:2::%x", i+1), wrong)
+	}
+	if rec := postLoginFrom(c, "2001:db8:1:2:ffff::1", right); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the /64 network to be locked out, got %d", rec.Code)
+	}
+	if rec := postLoginFrom(c, "2001:db8:1:3::1", right); rec.Code != http.StatusSeeOther {
+		t.Fatalf("other networks should not be locked out, got %d", rec.Code)
+	}
+
+	if rec := doRequest(api, http.MethodDelete, "/api/v1/lockouts/2001:db8:1:2::", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected the network to be unblocked, got %d", rec.Code)
+	}
+	if rec := postLoginFrom(c, "2001:db8:1:2::1", right); rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected the unblocked network to log in, got %d", rec.Code)
+	}
+}
+
+func TestAPIBlocklist(t *testing.T) {
+	chdirToRoot(t)
+	dbClient := db.NewMemoryClient()
+	addTestUser(t, dbClient, "alice", "alice-password")
+	c := newTestRouter(t, dbClient)
+	api := mux.NewRouter()
+	c.registerAPI(api)
+
+	c.limiter.Fail("198.51.100.1")
+	rec := doRequest(api, http.MethodGet, "/api/v1/lockouts", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"ip":"198.51.100.1","failures":1`) {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = doRequest(api, http.MethodDelete, "/api/v1/lockouts/198.51.100.1", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	if rec = doRequest(api, http.MethodDelete, "/api/v1/lockouts/198.51.100.1", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected the IP to be forgotten, got %d", rec.Code)
+	}
+
+	for _, tc := range []struct {
+		body   string
+		status int
+	}{
+		{body: `{"network":"nope"}`, status: http.StatusUnprocessableEntity},
+		{body: `{"network":"192.0.2.0/24"}`, status: http.StatusUnprocessableEntity},
+		{body: `{"network":"2001:db8::/32"}`, status: http.StatusCreated},
+		{body: `{"network":"203.0.113.0/24","reason":"scanner"}`, status: http.StatusCreated},
+		{body: `{"network":"203.0.113.9/24"}`, status: http.StatusConflict},
+	} {
+		if rec = doRequest(api, http.MethodPost, "/api/v1/bans", tc.body); rec.Code != tc.status {
+			t.Fatalf("%s: got %d, want %d: %s", tc.body, rec.Code, tc.status, rec.Body.String())
+		}
+	}
+
+	login := func(ip string) int {
+		req := httptest.NewRequest(http.MethodGet, loginPath, nil)
+		req.Header.Set("X-Real-Ip", ip)
+		rec := httptest.NewRecorder()
+		c.r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := login("203.0.113.9"); code != http.StatusForbidden {
+		t.Fatalf("expected the banned range to be rejected, got %d", code)
+	}
+	if code := login("198.51.100.1"); code != http.StatusOK {
+		t.Fatalf("expected other IPs to be allowed, got %d", code)
+	}
+
+	bans, _ := dbClient.GetIPBans()
+	for _, ban := range bans {
+		if rec = doRequest(api, http.MethodDelete, "/api/v1/bans/"+ban.ID, ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("unexpected status %d", rec.Code)
+		}
+	}
+	if code := login("203.0.113.9"); code != http.StatusOK {
+		t.Fatalf("expected the lifted ban to allow the IP, got %d", code)
+	}
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/morzhanov/binance-orders-watcher/internal/klines"
+)
+
+const defaultKlinesInterval = "1h"
+
+type ChartPageTemplateData struct {
+	AppURI    string
+	AppSchema string
+	AppPort   string
+	Symbol    string
+	Intervals []string
+	Interval  string
+	Nonce     string
+	CSRFToken string
+}
+
+var chartIntervals = []string{"1m", "5m", "15m", "30m", "1h", "4h", "12h", "1d", "1w"}
+
+func (c *client) chartHandler(w http.ResponseWriter, r *http.Request) {
+	symbol := strings.ToUpper(mux.Vars(r)["symbol"])
+	if !symbolRegexp.MatchString(symbol) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("wrong symbol provided"))
+		return
+	}
+	tmpl, err := template.ParseFiles("./internal/client/templates/chart.html")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	chartPageData := &ChartPageTemplateData{
+		AppURI:    c.appUri,
+		AppSchema: c.appSchema,
+		AppPort:   c.appPort,
+		Symbol:    symbol,
+		Intervals: chartIntervals,
+		Interval:  defaultKlinesInterval,
+		Nonce:     cspNonce(r),
+		CSRFToken: c.csrfToken(r),
+	}
+	if err = tmpl.Execute(w, chartPageData); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+	}
+}
+
+func (c *client) apiListKlines(w http.ResponseWriter, r *http.Request) {
+	q, err := parseListQuery(r, klinesResource)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_query", err.Error())
+		return
+	}
+	symbol := r.URL.Query().Get("symbol")
+	if !symbolRegexp.MatchString(symbol) {
+		writeAPIError(w, http.StatusBadRequest, "invalid_query", "symbol should be a Binance symbol like BTCUSDT")
+		return
+	}
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = defaultKlinesInterval
+	}
+	duration, ok := klines.Intervals[interval]
+	if !ok {
+		writeAPIError(w, http.StatusBadRequest, "invalid_query", "unsupported interval "+interval)
+		return
+	}
+
+	to := time.Now()
+	from := to.Add(-duration * time.Duration(q.limit)).Truncate(duration)
+	items, err := c.klines.Get(symbol, interval, from, to)
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, "klines_failed", err.Error())
+		return
+	}
+	if len(items) > q.limit {
+		items = items[len(items)-q.limit:]
+	}
+	writeJSON(w, http.StatusOK, q.apply(items))
+}
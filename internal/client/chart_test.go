@@ -0,0 +1,74 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/events"
+)
+
+type fakeKlinesCache struct {
+	klines []*db.Kline
+	from   time.Time
+}
+
+func (c *fakeKlinesCache) Get(_, _ string, from, _ time.Time) ([]*db.Kline, error) {
+	c.from = from
+	return c.klines, nil
+}
+
+func TestChartTemplate(t *testing.T) {
+	tmpl, err := template.ParseFiles("templates/chart.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := &ChartPageTemplateData{Symbol: "BTCUSDT", Intervals: chartIntervals, Interval: defaultKlinesInterval}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<option value="1h" selected>`) {
+		t.Fatal("default interval is not selected")
+	}
+}
+
+func TestAPIListKlines(t *testing.T) {
+	cache := &fakeKlinesCache{}
+	for i := 0; i < 5; i++ {
+		cache.klines = append(cache.klines, &db.Kline{Symbol: "BTCUSDT", Interval: "1h", OpenTime: int64(i) * time.Hour.Milliseconds()})
+	}
+	c := &client{db: db.NewMemoryClient(), bus: events.NewBus(), klines: cache}
+	r := mux.NewRouter()
+	c.registerAPI(r)
+
+	rec := doRequest(r, http.MethodGet, "/api/v1/klines?symbol=BTCUSDT&limit=3", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var page struct {
+		Data  []*db.Kline `json:"data"`
+		Total int         `json:"total"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Data) != 3 || page.Data[0].OpenTime != 2*time.Hour.Milliseconds() {
+		t.Fatalf("expected the latest 3 klines, got %+v", page.Data)
+	}
+	if since := time.Since(cache.from); since < 3*time.Hour || since > 4*time.Hour {
+		t.Fatalf("unexpected range start %s", cache.from)
+	}
+
+	for _, target := range []string{"/api/v1/klines", "/api/v1/klines?symbol=BTCUSDT&interval=7m"} {
+		if rec = doRequest(r, http.MethodGet, target, ""); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", target, rec.Code)
+		}
+	}
+}
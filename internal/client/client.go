@@ -1,18 +1,21 @@
 package client
 
 import (
-	"crypto/subtle"
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"html/template"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/morzhanov/binance-orders-watcher/internal/alertmanager"
+	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+	"github.com/morzhanov/binance-orders-watcher/internal/certs"
 
 	"github.com/morzhanov/binance-orders-watcher/internal/checker"
 
@@ -20,7 +23,13 @@ import (
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/events"
 	"github.com/morzhanov/binance-orders-watcher/internal/fetcher"
+	"github.com/morzhanov/binance-orders-watcher/internal/klines"
+	"github.com/morzhanov/binance-orders-watcher/internal/ratelimit"
+	"github.com/morzhanov/binance-orders-watcher/internal/simulator"
+	"github.com/morzhanov/binance-orders-watcher/internal/totp"
+	"github.com/morzhanov/binance-orders-watcher/internal/trader"
 )
 
 const (
@@ -31,27 +40,41 @@ const (
 )
 
 type Client interface {
-	Run(tlsCertPath, tlsKeyPath string) error
+	// Run serves the app over HTTPS with the certificates of the source, or over HTTP when it is nil.
+	// A non-empty httpPort also serves ACME challenges and redirects to HTTPS.
+	Run(certSource certs.Source, httpPort string) error
 }
 
 type client struct {
-	r                      *mux.Router
+	r                      http.Handler
 	appSchema              string
 	appPort                string
 	appUri                 string
 	authUsername           string
 	authPassword           string
 	authSecret             string
+	sessionIdleTimeout     time.Duration
+	trustedProxies         []*net.IPNet
+	limiter                ratelimit.Limiter
+	bans                   banList
 	authReqAlertAdminName  string
 	authReqAlertAdminEmail string
 	db                     db.Client
 	fetcher                fetcher.Fetcher
 	checker                checker.Checker
 	alertManager           alertmanager.Manager
+	bus                    events.Bus
+	klines                 klines.Cache
+	trader                 trader.Trader
+	simulator              simulator.Simulator
+	totp                   totp.Authenticator
+	exchangeInfo           binance.ExchangeInfoCache
+	accounts               []string
 }
 
 type JWTPayload struct {
 	ID        string    `json:"id"`
+	Subject   string    `json:"sub"`
 	IssuedAt  time.Time `json:"issued_at"`
 	ExpiredAt time.Time `json:"expired_at"`
 }
@@ -60,9 +83,14 @@ type HomePageTemplateData struct {
 	AppURI    string
 	AppSchema string
 	AppPort   string
+	Accounts  []string
 	Orders    []*db.Order
 	Prices    []*db.Price
 	Alerts    []*db.Alert
+	Username  string
+	Role      string
+	Nonce     string
+	CSRFToken string
 }
 
 func (payload *JWTPayload) Valid() error {
@@ -72,7 +100,7 @@ func (payload *JWTPayload) Valid() error {
 	return nil
 }
 
-func New(authUsername, authPassword, authSecret, appUri, appSchema, appPort, authReqAlertAdminName, authReqAlertAdminEmail string, dbClient db.Client, fetcherClient fetcher.Fetcher, checker checker.Checker, alertManager alertmanager.Manager) Client {
+func New(authUsername, authPassword, authSecret string, sessionIdleTimeout time.Duration, trustedProxies []*net.IPNet, appUri, appSchema, appPort, authReqAlertAdminName, authReqAlertAdminEmail string, dbClient db.Client, fetcherClient fetcher.Fetcher, checker checker.Checker, alertManager alertmanager.Manager, bus events.Bus, klinesCache klines.Cache, traderClient trader.Trader, simulatorClient simulator.Simulator, exchangeInfo binance.ExchangeInfoCache, accounts []string) Client {
 	c := &client{
 		appUri:                 appUri,
 		appSchema:              appSchema,
@@ -80,37 +108,87 @@ func New(authUsername, authPassword, authSecret, appUri, appSchema, appPort, aut
 		authUsername:           authUsername,
 		authPassword:           authPassword,
 		authSecret:             authSecret,
+		sessionIdleTimeout:     sessionIdleTimeout,
+		trustedProxies:         trustedProxies,
+		limiter:                ratelimit.New(ratelimit.DefaultMaxFailures, ratelimit.DefaultWindow, ratelimit.DefaultLockout, ratelimit.DefaultMaxLockout),
 		authReqAlertAdminName:  authReqAlertAdminName,
 		authReqAlertAdminEmail: authReqAlertAdminEmail,
 		db:                     dbClient,
 		fetcher:                fetcherClient,
 		checker:                checker,
 		alertManager:           alertManager,
+		bus:                    bus,
+		klines:                 klinesCache,
+		trader:                 traderClient,
+		simulator:              simulatorClient,
+		totp:                   totp.New(),
+		exchangeInfo:           exchangeInfo,
+		accounts:               accounts,
 	}
 
 	r := mux.NewRouter()
+	r.Use(c.banMiddleware)
 	r.Use(c.authMiddleware)
-	r.HandleFunc("/", c.homeHandler)
-	r.HandleFunc("/refresh", c.refreshDataHandler)
-	r.HandleFunc("/alert", c.addAlertHandler)
-	r.HandleFunc("/alert/{id}", c.deleteAlertHandler)
-	r.PathPrefix("/").Handler(http.FileServer(http.Dir("./internal/client/static/")))
-	c.r = r
+	r.Use(c.csrfMiddleware)
+	r.HandleFunc(loginPath, c.loginPageHandler).Methods(http.MethodGet)
+	r.HandleFunc(loginPath, c.loginHandler).Methods(http.MethodPost)
+	r.HandleFunc("/logout", requireRole(db.RoleViewer, c.logoutHandler)).Methods(http.MethodPost)
+	r.HandleFunc("/", requireRole(db.RoleViewer, c.homeHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/refresh", requireRole(db.RoleTrader, c.refreshDataHandler)).Methods(http.MethodPost)
+	r.HandleFunc("/alert", requireRole(db.RoleTrader, c.addAlertHandler)).Methods(http.MethodPost)
+	r.HandleFunc("/alert/{id}", requireRole(db.RoleTrader, c.deleteAlertHandler)).Methods(http.MethodDelete)
+	r.HandleFunc("/chart/{symbol}", requireRole(db.RoleViewer, c.chartHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/simulator", requireRole(db.RoleViewer, c.simulatorHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/account/2fa", requireRole(db.RoleViewer, c.twoFactorHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/account/sessions", requireRole(db.RoleViewer, c.sessionsHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/admin/backup", requireRole(db.RoleAdmin, c.backupHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/admin/export", requireRole(db.RoleAdmin, c.exportHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/admin/import", requireRole(db.RoleAdmin, c.importHandler)).Methods(http.MethodPost)
+	r.HandleFunc("/admin/users", requireRole(db.RoleAdmin, c.usersHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/admin/tokens", requireRole(db.RoleAdmin, c.tokensHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/admin/blocklist", requireRole(db.RoleAdmin, c.blocklistHandler)).Methods(http.MethodGet)
+	c.registerAPI(r)
+	r.PathPrefix("/").Handler(http.FileServer(http.Dir("./internal/client/static/"))).Methods(http.MethodGet, http.MethodHead)
+	c.r = c.securityHeaders(r)
 
 	return c
 }
 
-func (c *client) Run(tlsCertPath, tlsKeyPath string) error {
+func (c *client) Run(certSource certs.Source, httpPort string) error {
+	if err := c.bootstrapAdmin(); err != nil {
+		return err
+	}
 	log.Printf("starting client application on %s://%s:%s", c.appSchema, c.appUri, c.appPort)
 	addr := ":" + c.appPort
-	if c.appSchema == AppSchemaHTTPS && tlsCertPath != "" && tlsKeyPath != "" {
-		return http.ListenAndServeTLS(addr, tlsCertPath, tlsKeyPath, c.r)
+	if c.appSchema != AppSchemaHTTPS || certSource == nil {
+		return http.ListenAndServe(addr, c.r)
+	}
+
+	errs := make(chan error, 2)
+	if httpPort != "" {
+		go func() {
+			log.Printf("redirecting HTTP on port %s to HTTPS", httpPort)
+			errs <- http.ListenAndServe(":"+httpPort, certSource.HTTPHandler(http.HandlerFunc(c.redirectToHTTPS)))
+		}()
 	}
-	return http.ListenAndServe(addr, c.r)
+	go func() {
+		server := &http.Server{Addr: addr, Handler: c.r, TLSConfig: certSource.TLSConfig()}
+		errs <- server.ListenAndServeTLS("", "")
+	}()
+	return <-errs
 }
 
-func (c *client) homeHandler(w http.ResponseWriter, _ *http.Request) {
-	tmpl, err := template.ParseFiles("./internal/client/templates/home.html")
+// redirectToHTTPS sends plain HTTP requests to the app URI, the Host header is not used so it can't redirect elsewhere.
+func (c *client) redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	target := "https://" + c.appUri
+	if c.appPort != "443" {
+		target += ":" + c.appPort
+	}
+	http.Redirect(w, r, target+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
+
+func (c *client) homeHandler(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := template.New("home.html").Funcs(c.templateFuncs()).ParseFiles("./internal/client/templates/home.html")
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(err.Error()))
@@ -139,9 +217,14 @@ func (c *client) homeHandler(w http.ResponseWriter, _ *http.Request) {
 		AppURI:    c.appUri,
 		AppSchema: c.appSchema,
 		AppPort:   c.appPort,
+		Accounts:  c.accounts,
 		Orders:    orders,
 		Prices:    prices,
-		Alerts:    alerts,
+		Alerts:    visibleAlerts(r, alerts),
+		Username:  usernameFromRequest(r),
+		Role:      userFromRequest(r).Role,
+		Nonce:     cspNonce(r),
+		CSRFToken: c.csrfToken(r),
 	}
 	if err = tmpl.Execute(w, homePageData); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -162,10 +245,11 @@ func (c *client) refreshDataHandler(w http.ResponseWriter, _ *http.Request) {
 		return
 	}
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Data successfully loaded from Binance, the page could be reloaded."))
+	w.Write([]byte("Data successfully loaded from Binance."))
 	return
 }
 
+// addAlertHandler is the legacy form endpoint, it validates alerts like POST /api/v1/alerts and answers in plain text.
 func (c *client) addAlertHandler(w http.ResponseWriter, r *http.Request) {
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -175,27 +259,53 @@ func (c *client) addAlertHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Println("unmarshalling alert object: ", string(body))
-	var alert db.Alert
-	if err = json.Unmarshal(body, &alert); err != nil {
+	in := &alertInput{}
+	if err = json.Unmarshal(body, in); err != nil {
 		log.Println("failed to unmarshal alert object: ", err)
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(err.Error()))
 		return
 	}
-
-	alertID, err := uuid.NewUUID()
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	in.normalize()
+	if err = in.validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(err.Error()))
 		return
 	}
-	alert.ID = alertID.String()
-	err = c.db.AddAlert(&alert)
-	if err != nil {
+	if in.Type == db.AlertTypePrice {
+		if err = c.checkSymbol(in.Symbol); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+	}
+	if in.AccountID, err = c.resolveAccount("accountId", in.AccountID); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err = checkAlertRecipient(r, in); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	alert := &db.Alert{
+		ID:        uuid.NewString(),
+		UserID:    userFromRequest(r).ID,
+		CreatedAt: now,
+		CreatedBy: usernameFromRequest(r),
+		UpdatedAt: now,
+		UpdatedBy: usernameFromRequest(r),
+	}
+	in.applyTo(alert)
+	if err = c.db.AddAlert(alert); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(err.Error()))
 		return
 	}
+	c.publishAlerts()
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Alert successfully created"))
@@ -209,99 +319,114 @@ func (c *client) deleteAlertHandler(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("wrong id provided"))
 		return
 	}
-	if err := c.db.DeleteAlert(id); err != nil {
+	alert, err := c.findAlert(id)
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(err.Error()))
+		return
 	}
+	if alert == nil || !canAccessAlert(r, alert) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("alert not found"))
+		return
+	}
+	if err = c.db.DeleteAlert(id); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	c.publishAlerts()
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Alert successfully deleted"))
 }
 
 func (c *client) authMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if c.checkAccessToken(w, r) {
+		if r.URL.Path == loginPath {
 			h.ServeHTTP(w, r)
 			return
 		}
-
-		if !c.checkAuthAttempts(r) {
-			w.WriteHeader(403)
-			w.Write([]byte("Forbidden.\n"))
+		if value, ok := bearerToken(r); ok {
+			c.apiTokenAuth(w, r, h, value)
+			return
+		}
+		user, session, err := c.sessionUser(r)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if user != nil {
+			h.ServeHTTP(w, withSession(withUser(r, user), session))
 			return
 		}
 
-		log.Println("access token is not found in cookies, starting base auth flow...")
-		token := c.basicAuth(w, r)
-		log.Println("performed basic auth, token = ", token)
-		if token == "" {
-			w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
-			w.WriteHeader(401)
-			w.Write([]byte("Unauthorised.\n"))
+		// scripts can still use basic auth, browsers are sent to the login form
+		if _, _, ok := r.BasicAuth(); !ok {
+			if strings.HasPrefix(r.URL.Path, apiPrefix+"/") {
+				writeAPIError(w, http.StatusUnauthorized, "unauthorized", "log in at "+loginPath)
+				return
+			}
+			http.Redirect(w, r, loginPath+"?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
 			return
 		}
 
-		ip := readUserIP(r)
-		c.alertManager.SendAlert(c.authReqAlertAdminEmail, c.authReqAlertAdminName, fmt.Sprintf("user with IP = %s successfully logged into the system", ip))
+		key := lockoutKey(c.clientIP(r))
+		if wait := c.limiter.Locked(key); wait > 0 {
+			writeLockedOut(w, r, wait)
+			return
+		}
 
-		if err := c.clearAuthAttempts(r); err != nil {
+		log.Println("session is not found, starting base auth flow...")
+		user = c.basicAuth(w, r)
+		if user == nil {
+			c.authFailed(key)
 			w.WriteHeader(401)
 			w.Write([]byte("Unauthorised.\n"))
+			return
 		}
-
-		log.Println("creating auth cookie")
-		authCookie := &http.Cookie{
-			Name:    AuthCookieName,
-			Value:   BearerTokenPrefix + token,
-			Expires: time.Now().Add(time.Second * TokenExpirationDurationInSec),
-		}
-		http.SetCookie(w, authCookie)
-		h.ServeHTTP(w, r)
+		c.limiter.Reset(key)
+		// basic auth requests are authenticated one by one and don't start sessions
+		h.ServeHTTP(w, withUser(r, user))
 	})
 }
 
-func (c *client) checkAuthAttempts(r *http.Request) bool {
-	ip := readUserIP(r)
-	req, err := c.db.GetAuthRequest(ip)
-	if err != nil {
-		return false
+// apiTokenAuth authenticates API requests of scripts with a personal API token.
+func (c *client) apiTokenAuth(w http.ResponseWriter, r *http.Request, h http.Handler, value string) {
+	if !strings.HasPrefix(r.URL.Path, apiPrefix+"/") {
+		writeAPIError(w, http.StatusUnauthorized, "unauthorized", "API tokens are only accepted by "+apiPrefix)
+		return
 	}
-
-	if req == nil {
-		if err = c.db.AddAuthRequest(ip); err != nil {
-			return false
-		}
-		return true
+	ip := c.clientIP(r)
+	key := lockoutKey(ip)
+	if wait := c.limiter.Locked(key); wait > 0 {
+		writeLockedOut(w, r, wait)
+		return
 	}
-	if req.Attempts >= 3 {
-		if !req.AlertSent {
-			text := fmt.Sprintf("User with IP %s is blocket: auth req count threshold reached.", ip)
-			c.alertManager.SendAlert(c.authReqAlertAdminEmail, c.authReqAlertAdminName, text)
-			c.db.UpdateAuthRequest(ip, 3, true)
-		}
-		return false
+	user, token, err := c.apiTokenUser(value)
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
 	}
-
-	if err = c.db.UpdateAuthRequest(ip, req.Attempts+1, false); err != nil {
-		return false
+	if user == nil {
+		log.Printf("invalid or expired API token from IP %s", ip)
+		c.authFailed(key)
+		writeAPIError(w, http.StatusUnauthorized, "unauthorized", "the API token is invalid or expired")
+		return
 	}
-	return true
-}
-
-func (c *client) clearAuthAttempts(r *http.Request) error {
-	ip := readUserIP(r)
-	return c.db.UpdateAuthRequest(ip, 0, false)
+	h.ServeHTTP(w, withAPIToken(withUser(r, user), token))
 }
 
-func (c *client) checkAccessToken(_ http.ResponseWriter, r *http.Request) bool {
+func (c *client) checkAccessToken(r *http.Request) *JWTPayload {
 	authCookie, err := r.Cookie(AuthCookieName)
 	if err != nil || authCookie == nil || authCookie.Value == "" {
-		return false
+		return nil
 	}
 	token := strings.Replace(authCookie.Value, BearerTokenPrefix, "", 1)
 	return c.verifyToken(token)
 }
 
-func (c *client) verifyToken(token string) bool {
+func (c *client) verifyToken(token string) *JWTPayload {
 	keyFunc := func(token *jwt.Token) (interface{}, error) {
 		_, ok := token.Method.(*jwt.SigningMethodHMAC)
 		if !ok {
@@ -312,58 +437,52 @@ func (c *client) verifyToken(token string) bool {
 
 	jwtToken, err := jwt.ParseWithClaims(token, &JWTPayload{}, keyFunc)
 	if err != nil {
-		return false
+		return nil
 	}
-	if _, ok := jwtToken.Claims.(*JWTPayload); !ok {
-		return false
+	payload, ok := jwtToken.Claims.(*JWTPayload)
+	if !ok {
+		return nil
 	}
-	return true
+	return payload
 }
 
-func (c *client) basicAuth(_ http.ResponseWriter, r *http.Request) string {
-	ip := readUserIP(r)
-	c.alertManager.SendAlert(c.authReqAlertAdminEmail, c.authReqAlertAdminName, fmt.Sprintf("user with IP = %s tries to perform basic auth", ip))
-
-	user, pass, ok := r.BasicAuth()
-	if !ok ||
-		subtle.ConstantTimeCompare([]byte(user), []byte(c.authUsername)) != 1 ||
-		subtle.ConstantTimeCompare([]byte(pass), []byte(c.authPassword)) != 1 {
-		log.Println("basic auth failed")
-		log.Printf("user = %s, pass = %s, c.AuthUser = %s, c.authPass = %s", user, pass, c.authUsername, c.authPassword)
-		return ""
+// basicAuth authenticates users without two-factor authentication, the others have to use the login form.
+func (c *client) basicAuth(_ http.ResponseWriter, r *http.Request) *db.User {
+	username, pass, _ := r.BasicAuth()
+	user, err := c.authenticate(username, pass)
+	if err != nil || user == nil {
+		log.Printf("basic auth failed for user %s, %v", username, err)
+		return nil
 	}
-
-	token, err := c.createAccessToken()
-	if err != nil {
-		log.Println("basic auth failed, ", err)
-		return ""
+	if user.TOTPEnabled {
+		log.Printf("basic auth failed for user %s, two-factor authentication is enabled", username)
+		return nil
 	}
-	log.Println("basic auth succeeded, token = ", token)
-	return token
+	log.Println("basic auth succeeded for user ", user.Username)
+	return user
 }
 
-func (c *client) createAccessToken() (string, error) {
-	tokenID, err := uuid.NewRandom()
-	if err != nil {
-		return "", err
-	}
-
+// createAccessToken signs a token of the session, ID is the session ID.
+func (c *client) createAccessToken(sessionID, subject string, issuedAt, expiredAt time.Time) (string, error) {
 	payload := &JWTPayload{
-		ID:        tokenID.String(),
-		IssuedAt:  time.Now(),
-		ExpiredAt: time.Now().Add(time.Second * TokenExpirationDurationInSec),
+		ID:        sessionID,
+		Subject:   subject,
+		IssuedAt:  issuedAt,
+		ExpiredAt: expiredAt,
 	}
 	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
 	return jwtToken.SignedString([]byte(c.authSecret))
 }
 
-func readUserIP(r *http.Request) string {
-	IPAddress := r.Header.Get("X-Real-Ip")
-	if IPAddress == "" {
-		IPAddress = r.Header.Get("X-Forwarded-For")
-	}
-	if IPAddress == "" {
-		IPAddress = r.RemoteAddr
-	}
-	return IPAddress
+type contextKey string
+
+const usernameContextKey contextKey = "username"
+
+func withUsername(r *http.Request, username string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), usernameContextKey, username))
+}
+
+func usernameFromRequest(r *http.Request) string {
+	username, _ := r.Context().Value(usernameContextKey).(string)
+	return username
 }
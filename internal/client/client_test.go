@@ -0,0 +1,116 @@
+package client
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/events"
+)
+
+func TestAlertHandlers(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	c := &client{db: dbClient, bus: events.NewBus(), exchangeInfo: newTestExchangeInfo(), accounts: []string{db.DefaultAccountID}}
+
+	body := `{"symbol":"BTCUSDT","price":"40000","name":"John","email":"john@example.com","directionDown":true}`
+	rec := httptest.NewRecorder()
+	c.addAlertHandler(rec, withUser(httptest.NewRequest(http.MethodPost, "/alert", strings.NewReader(body)), testAdmin))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	alerts, err := dbClient.GetAlerts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].ID == "" || alerts[0].Symbol != "BTCUSDT" || !alerts[0].DirectionDown {
+		t.Fatalf("unexpected alerts: %+v", alerts)
+	}
+	if alerts[0].UserID != testAdmin.ID || alerts[0].UpdatedAt == 0 || alerts[0].UpdatedBy != testAdmin.Username {
+		t.Fatalf("audit fields are not set: %+v", alerts[0])
+	}
+
+	rec = httptest.NewRecorder()
+	req := mux.SetURLVars(withUser(httptest.NewRequest(http.MethodDelete, "/alert/"+alerts[0].ID, nil), testAdmin), map[string]string{"id": alerts[0].ID})
+	c.deleteAlertHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if alerts, _ = dbClient.GetAlerts(); len(alerts) != 0 {
+		t.Fatalf("alert was not deleted: %+v", alerts)
+	}
+}
+
+func TestAddAlertHandlerRejectsInvalidJSON(t *testing.T) {
+	c := &client{db: db.NewMemoryClient(), bus: events.NewBus()}
+	rec := httptest.NewRecorder()
+	c.addAlertHandler(rec, withUser(httptest.NewRequest(http.MethodPost, "/alert", strings.NewReader("{")), testAdmin))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected bad request, got %d", rec.Code)
+	}
+}
+
+func TestAddAlertHandlerValidatesAlerts(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	c := &client{db: dbClient, bus: events.NewBus(), exchangeInfo: newTestExchangeInfo(), accounts: []string{db.DefaultAccountID}}
+	noEmail := &db.User{ID: "trader-id", Username: "trader", Role: db.RoleTrader}
+	for name, tc := range map[string]struct {
+		body string
+		user *db.User
+	}{
+		"invalid price": {body: `{"symbol":"BTCUSDT","price":"N/A","email":"john@example.com"}`, user: testAdmin},
+		"invalid email": {body: `{"symbol":"BTCUSDT","price":"40000","email":"john"}`, user: testAdmin},
+		"no recipient":  {body: `{"symbol":"BTCUSDT","price":"40000"}`, user: noEmail},
+	} {
+		rec := httptest.NewRecorder()
+		c.addAlertHandler(rec, withUser(httptest.NewRequest(http.MethodPost, "/alert", strings.NewReader(tc.body)), tc.user))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected bad request, got %d", name, rec.Code)
+		}
+	}
+	if alerts, _ := dbClient.GetAlerts(); len(alerts) != 0 {
+		t.Fatalf("invalid alerts were stored: %+v", alerts)
+	}
+}
+
+func TestHomeTemplate(t *testing.T) {
+	c := &client{exchangeInfo: newTestExchangeInfo()}
+	tmpl, err := template.New("home.html").Funcs(c.templateFuncs()).ParseFiles("templates/home.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := &HomePageTemplateData{
+		Orders: []*db.Order{{Symbol: "BTCUSDT", PercentCompleted: "N/A"}},
+		Prices: []*db.Price{{Symbol: "BTCUSDT", Price: "1"}},
+		Alerts: []*db.Alert{{ID: "1", Symbol: "BTCUSDT", Price: "1", CreatedAt: 1}},
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `data-alert-id="1"`) {
+		t.Fatal("alert row is not rendered")
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	for _, tc := range []struct {
+		port, want string
+	}{
+		{port: "443", want: "https://watcher.example.com/orders?x=1"},
+		{port: "8443", want: "https://watcher.example.com:8443/orders?x=1"},
+	} {
+		c := &client{appUri: "watcher.example.com", appPort: tc.port}
+		req := httptest.NewRequest(http.MethodGet, "http://evil.example.com/orders?x=1", nil)
+		rec := httptest.NewRecorder()
+		c.redirectToHTTPS(rec, req)
+		if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != tc.want {
+			t.Fatalf("port %s: unexpected redirect %d %s", tc.port, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+}
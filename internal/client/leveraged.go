@@ -0,0 +1,57 @@
+package client
+
+import (
+	"net/http"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+func (c *client) apiListFuturesOrders(w http.ResponseWriter, r *http.Request) {
+	c.writeLeveragedOrders(w, r, db.MarketFutures)
+}
+
+func (c *client) apiListMarginOrders(w http.ResponseWriter, r *http.Request) {
+	c.writeLeveragedOrders(w, r, db.MarketMargin)
+}
+
+func (c *client) writeLeveragedOrders(w http.ResponseWriter, r *http.Request, market string) {
+	orders, err := c.db.GetLeveragedOrders()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	marketOrders := make([]*db.LeveragedOrder, 0, len(orders))
+	for _, o := range orders {
+		if o.Market == market {
+			marketOrders = append(marketOrders, o)
+		}
+	}
+	c.writeList(w, r, leveragedOrdersResource, marketOrders)
+}
+
+func (c *client) apiListPositions(w http.ResponseWriter, r *http.Request) {
+	positions, err := c.db.GetPositions()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	c.writeList(w, r, positionsResource, positions)
+}
+
+func (c *client) apiListFundingRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := c.db.GetFundingRates()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	c.writeList(w, r, fundingRatesResource, rates)
+}
+
+func (c *client) apiListMarginStatus(w http.ResponseWriter, r *http.Request) {
+	statuses, err := c.db.GetMarginStatuses()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	c.writeList(w, r, marginStatusResource, statuses)
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+func TestAPILeveragedOrders(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	if err := dbClient.SetLeveragedOrders(db.DefaultAccountID, db.MarketFutures, []*db.LeveragedOrder{{Symbol: "BTCUSDT", OrderID: 1, PositionSide: "SHORT"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbClient.SetLeveragedOrders(db.DefaultAccountID, db.MarketMargin, []*db.LeveragedOrder{{Symbol: "ETHUSDT", OrderID: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	h := newTestAPI(t, dbClient)
+
+	for target, want := range map[string]int{"/api/v1/futures/orders?positionSide=SHORT": 1, "/api/v1/margin/orders": 2} {
+		rec := doRequest(h, http.MethodGet, target, "")
+		var page struct {
+			Data []*db.LeveragedOrder `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusOK || len(page.Data) != 1 || page.Data[0].OrderID != want {
+			t.Fatalf("%s: unexpected response %d %s", target, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestAPIFuturesAlerts(t *testing.T) {
+	h := newTestAPI(t, db.NewMemoryClient())
+
+	// futures symbols are not checked against the spot exchange info
+	rec := doRequest(h, http.MethodPost, "/api/v1/alerts", `{"type":"liquidation_distance","symbol":"1000PEPEUSDT","price":"5","email":"john@example.com"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(h, http.MethodPost, "/api/v1/alerts", `{"type":"funding_rate","symbol":"BTCUSDT","price":"-0.05","directionDown":true,"email":"john@example.com"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("negative funding rate should be accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	for _, body := range []string{
+		`{"type":"liquidation_distance","symbol":"BTCUSDT","price":"150","email":"john@example.com"}`,
+		`{"type":"volume","symbol":"BTCUSDT","price":"1","email":"john@example.com"}`,
+		`{"symbol":"BTCUSDT","price":"-1","email":"john@example.com"}`,
+	} {
+		if rec = doRequest(h, http.MethodPost, "/api/v1/alerts", body); rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%s: expected validation error, got %d", body, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package client
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var pathParamRegexp = regexp.MustCompile(`{([^}]+)}`)
+
+type openAPIObject map[string]interface{}
+
+func (c *client) apiOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, c.openAPIDocument())
+}
+
+// openAPIDocument generates the OpenAPI 3 document from the API route table and the models.
+func (c *client) openAPIDocument() openAPIObject {
+	schemas := openAPIObject{}
+	addSchema(schemas, reflect.TypeOf(apiErrorEnvelope{}))
+	paths := openAPIObject{}
+
+	for _, route := range c.apiRoutes() {
+		path := apiPrefix + route.path
+		item, ok := paths[path].(openAPIObject)
+		if !ok {
+			item = openAPIObject{}
+			paths[path] = item
+		}
+		item[strings.ToLower(route.method)] = route.operation(schemas)
+	}
+
+	return openAPIObject{
+		"openapi": "3.0.3",
+		"info": openAPIObject{
+			"title":   "Binance Orders Watcher API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": openAPIObject{
+			"schemas": schemas,
+			"securitySchemes": openAPIObject{
+				"cookieAuth": openAPIObject{"type": "apiKey", "in": "cookie", "name": AuthCookieName, "description": "Session cookie of the login form, state changing requests also need the " + CSRFHeaderName + " header"},
+				"basicAuth":  openAPIObject{"type": "http", "scheme": "basic"},
+				"bearerAuth": openAPIObject{"type": "http", "scheme": "bearer", "description": "Personal API token, only accepted by endpoints which list its scope"},
+			},
+		},
+		"security": []openAPIObject{{"cookieAuth": []string{}}, {"basicAuth": []string{}}},
+	}
+}
+
+func (route *apiRoute) operation(schemas openAPIObject) openAPIObject {
+	var params []openAPIObject
+	for _, match := range pathParamRegexp.FindAllStringSubmatch(route.path, -1) {
+		params = append(params, openAPIObject{"name": match[1], "in": "path", "required": true, "schema": openAPIObject{"type": "string"}})
+	}
+	if route.list {
+		for _, filter := range route.resource.filters {
+			params = append(params, openAPIObject{
+				"name":        filter,
+				"in":          "query",
+				"description": "Comma separated list of values to match",
+				"schema":      openAPIObject{"type": "string"},
+			})
+		}
+		params = append(params,
+			openAPIObject{
+				"name":        sortParam,
+				"in":          "query",
+				"description": "Comma separated list of fields, prefix a field with - for descending order: " + strings.Join(route.resource.sorts, ", "),
+				"schema":      openAPIObject{"type": "string"},
+			},
+			openAPIObject{"name": limitParam, "in": "query", "schema": openAPIObject{"type": "integer", "minimum": 1, "maximum": maxPageLimit, "default": defaultPageLimit}},
+			openAPIObject{"name": offsetParam, "in": "query", "schema": openAPIObject{"type": "integer", "minimum": 0, "default": 0}},
+		)
+	}
+
+	operation := openAPIObject{
+		"operationId": operationID(route.method, route.path),
+		"summary":     route.summary,
+		"responses": openAPIObject{
+			"default": openAPIObject{
+				"description": "Error",
+				"content":     jsonContent(schemaRef(reflect.TypeOf(apiErrorEnvelope{}))),
+			},
+		},
+	}
+	if len(params) > 0 {
+		operation["parameters"] = params
+	}
+	if route.scope != "" {
+		operation["security"] = []openAPIObject{{"cookieAuth": []string{}}, {"basicAuth": []string{}}, {"bearerAuth": []string{route.scope}}}
+	}
+	if route.body != nil {
+		operation["requestBody"] = openAPIObject{
+			"required": true,
+			"content":  jsonContent(addSchema(schemas, reflect.TypeOf(route.body))),
+		}
+	}
+
+	response := openAPIObject{"description": http.StatusText(route.status)}
+	if route.resource != nil {
+		schema := addSchema(schemas, reflect.TypeOf(route.resource.model))
+		if route.list {
+			schema = openAPIObject{
+				"type": "object",
+				"properties": openAPIObject{
+					"data":   openAPIObject{"type": "array", "items": schema},
+					"total":  openAPIObject{"type": "integer"},
+					"limit":  openAPIObject{"type": "integer"},
+					"offset": openAPIObject{"type": "integer"},
+				},
+			}
+		}
+		response["content"] = jsonContent(schema)
+	} else if route.contentType != "" {
+		response["content"] = openAPIObject{route.contentType: openAPIObject{"schema": openAPIObject{"type": "string"}}}
+	} else if route.status != http.StatusNoContent {
+		response["content"] = jsonContent(openAPIObject{"type": "object"})
+	}
+	operation["responses"].(openAPIObject)[strconv.Itoa(route.status)] = response
+	return operation
+}
+
+// addSchema registers the struct schema in components and returns a reference to it.
+func addSchema(schemas openAPIObject, t reflect.Type) openAPIObject {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	name := schemaName(t)
+	if _, ok := schemas[name]; ok {
+		return schemaRef(t)
+	}
+	schemas[name] = openAPIObject{}
+
+	properties := openAPIObject{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		if jsonName == "" || jsonName == "-" {
+			continue
+		}
+		properties[jsonName] = typeSchema(schemas, field.Type)
+	}
+	schemas[name] = openAPIObject{"type": "object", "properties": properties}
+	return schemaRef(t)
+}
+
+func typeSchema(schemas openAPIObject, t reflect.Type) openAPIObject {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(schemas, t.Elem())
+	case reflect.String:
+		return openAPIObject{"type": "string"}
+	case reflect.Bool:
+		return openAPIObject{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return openAPIObject{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return openAPIObject{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return openAPIObject{"type": "array", "items": typeSchema(schemas, t.Elem())}
+	case reflect.Map:
+		return openAPIObject{"type": "object", "additionalProperties": typeSchema(schemas, t.Elem())}
+	case reflect.Struct:
+		if t.PkgPath() == "time" && t.Name() == "Time" {
+			return openAPIObject{"type": "string", "format": "date-time"}
+		}
+		return addSchema(schemas, t)
+	}
+	return openAPIObject{}
+}
+
+func schemaRef(t reflect.Type) openAPIObject {
+	return openAPIObject{"$ref": "#/components/schemas/" + schemaName(t)}
+}
+
+func schemaName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
+
+func jsonContent(schema openAPIObject) openAPIObject {
+	return openAPIObject{"application/json": openAPIObject{"schema": schema}}
+}
+
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	CSRFHeaderName = "X-CSRF-Token"
+	csrfFormField  = "csrf_token"
+	// hstsMaxAge is two years, browsers only use HTTPS for the app once they saw it over HTTPS.
+	hstsMaxAge = "63072000"
+)
+
+const cspNonceContextKey contextKey = "cspNonce"
+
+// cspNonce returns the nonce which lets the inline scripts of the page run.
+func cspNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(cspNonceContextKey).(string)
+	return nonce
+}
+
+func generateNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// contentSecurityPolicy only runs the page scripts with the nonce, images can be data URLs for the two-factor QR code.
+func (c *client) contentSecurityPolicy(nonce string) string {
+	return strings.Join([]string{
+		"default-src 'self'",
+		"script-src 'nonce-" + nonce + "'",
+		"style-src 'self' 'unsafe-inline'",
+		"img-src 'self' data:",
+		"connect-src 'self' " + c.appSchema + "://" + c.appUri + ":" + c.appPort,
+		"object-src 'none'",
+		"base-uri 'none'",
+		"form-action 'self'",
+		"frame-ancestors 'none'",
+	}, "; ")
+}
+
+// securityHeaders wraps the whole router, so error responses of bans, unknown routes and wrong methods get the headers too.
+func (c *client) securityHeaders(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce, err := generateNonce()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		header := w.Header()
+		header.Set("Content-Security-Policy", c.contentSecurityPolicy(nonce))
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Referrer-Policy", "no-referrer")
+		if c.appSchema == AppSchemaHTTPS {
+			header.Set("Strict-Transport-Security", "max-age="+hstsMaxAge+"; includeSubDomains")
+		}
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), cspNonceContextKey, nonce)))
+	})
+}
+
+// csrfToken is bound to the session of the request, it is empty for basic auth and API token requests.
+func (c *client) csrfToken(r *http.Request) string {
+	session := sessionFromRequest(r)
+	if session == nil {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(c.authSecret))
+	mac.Write([]byte("csrf:" + session.ID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// requestCSRFToken reads the token from the header of scripts or the hidden field of HTML forms.
+func requestCSRFToken(r *http.Request) string {
+	if token := r.Header.Get(CSRFHeaderName); token != "" {
+		return token
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data" {
+		return r.FormValue(csrfFormField)
+	}
+	return ""
+}
+
+// csrfMiddleware checks the CSRF token of state changing requests of users, only API token requests and the
+// login form, which has no user yet, don't need one. Browsers cache basic auth credentials and send them on
+// their own like cookies, basic auth requests have no session to bind a token to, so they can only read and
+// scripts change data with API tokens.
+func (c *client) csrfMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isSafeMethod(r.Method) || userFromRequest(r) == nil || apiTokenFromRequest(r) != nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		expected := c.csrfToken(r)
+		if expected != "" && subtle.ConstantTimeCompare([]byte(requestCSRFToken(r)), []byte(expected)) == 1 {
+			h.ServeHTTP(w, r)
+			return
+		}
+		log.Printf("invalid CSRF token for %s %s from IP %s", r.Method, r.URL.Path, c.clientIP(r))
+		if strings.HasPrefix(r.URL.Path, apiPrefix+"/") {
+			message := "missing or invalid " + CSRFHeaderName + " header"
+			if expected == "" {
+				message = "basic auth requests can only read, use an API token"
+			}
+			writeAPIError(w, http.StatusForbidden, "csrf_failed", message)
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("Invalid CSRF token.\n"))
+	})
+}
@@ -0,0 +1,197 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+// cookieCSRFToken returns the CSRF token the pages of the cookie session get.
+func cookieCSRFToken(c *client, cookie *http.Cookie) string {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	payload := c.checkAccessToken(req)
+	if payload == nil {
+		return ""
+	}
+	return c.csrfToken(withSession(req, &db.Session{ID: payload.ID}))
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	chdirToRoot(t)
+	c := newTestRouter(t, db.NewMemoryClient())
+	for _, tc := range []struct {
+		method, target string
+		status         int
+	}{
+		{method: http.MethodGet, target: loginPath, status: http.StatusOK},
+		{method: http.MethodGet, target: "/", status: http.StatusSeeOther},
+		{method: http.MethodPut, target: loginPath, status: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, target: "/api/v1/unknown", status: http.StatusNotFound},
+	} {
+		rec := httptest.NewRecorder()
+		c.r.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, nil))
+		if rec.Code != tc.status {
+			t.Fatalf("%s %s: got %d, want %d", tc.method, tc.target, rec.Code, tc.status)
+		}
+		header := rec.Header()
+		csp := header.Get("Content-Security-Policy")
+		for _, directive := range []string{"default-src 'self'", "script-src 'nonce-", "img-src 'self' data:", "object-src 'none'", "frame-ancestors 'none'"} {
+			if !strings.Contains(csp, directive) {
+				t.Fatalf("%s %s: expected %s in the CSP %s", tc.method, tc.target, directive, csp)
+			}
+		}
+		if strings.Contains(csp, "unsafe-eval") || strings.Contains(csp, "script-src 'self' 'unsafe-inline'") {
+			t.Fatalf("unexpected CSP %s", csp)
+		}
+		if header.Get("X-Frame-Options") != "DENY" || header.Get("Referrer-Policy") != "no-referrer" || header.Get("X-Content-Type-Options") != "nosniff" {
+			t.Fatalf("%s %s: unexpected headers %v", tc.method, tc.target, header)
+		}
+		if header.Get("Strict-Transport-Security") != "" {
+			t.Fatal("HSTS should only be sent over HTTPS")
+		}
+	}
+
+	c.appSchema = AppSchemaHTTPS
+	rec := httptest.NewRecorder()
+	c.r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, loginPath, nil))
+	if hsts := rec.Header().Get("Strict-Transport-Security"); hsts != "max-age=63072000; includeSubDomains" {
+		t.Fatalf("unexpected HSTS header %q", hsts)
+	}
+}
+
+func TestPageScriptNonce(t *testing.T) {
+	chdirToRoot(t)
+	dbClient := db.NewMemoryClient()
+	addTestUser(t, dbClient, "alice", "alice-password")
+	c := newTestRouter(t, dbClient)
+	cookie := loginCookie(t, c, "alice", "alice-password")
+
+	nonces := map[string]bool{}
+	for _, page := range []string{"/", "/simulator", "/account/2fa", "/account/sessions"} {
+		rec := doCookieRequest(c, cookie, http.MethodGet, page)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected status %d: %s", page, rec.Code, rec.Body.String())
+		}
+		nonce := regexp.MustCompile(`script-src 'nonce-([^']+)'`).FindStringSubmatch(rec.Header().Get("Content-Security-Policy"))
+		if nonce == nil || nonces[nonce[1]] {
+			t.Fatalf("%s: expected a new nonce for every response, got %v", page, nonce)
+		}
+		nonces[nonce[1]] = true
+		body := rec.Body.String()
+		if strings.Count(body, "<script") != strings.Count(body, `<script nonce="`+nonce[1]+`">`) {
+			t.Fatalf("%s: expected every script to have the nonce %s", page, nonce[1])
+		}
+		if strings.Contains(body, "onclick=") {
+			t.Fatalf("%s: inline event handlers are blocked by the CSP", page)
+		}
+		if !strings.Contains(body, cookieCSRFToken(c, cookie)) {
+			t.Fatalf("%s: expected the page to have the CSRF token", page)
+		}
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	chdirToRoot(t)
+	dbClient := db.NewMemoryClient()
+	addTestUser(t, dbClient, "alice", "alice-password")
+	c := newTestRouter(t, dbClient)
+	cookie := loginCookie(t, c, "alice", "alice-password")
+	other := loginCookie(t, c, "alice", "alice-password")
+
+	send := func(method, target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.AddCookie(cookie)
+		if token != "" {
+			req.Header.Set(CSRFHeaderName, token)
+		}
+		rec := httptest.NewRecorder()
+		c.r.ServeHTTP(rec, req)
+		return rec
+	}
+	for _, token := range []string{"", "wrong", cookieCSRFToken(c, other)} {
+		rec := send(http.MethodDelete, "/api/v1/alerts/unknown", token)
+		if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "csrf_failed") {
+			t.Fatalf("expected token %q to be rejected, got %d: %s", token, rec.Code, rec.Body.String())
+		}
+		if rec = send(http.MethodDelete, "/alert/unknown", token); rec.Code != http.StatusForbidden {
+			t.Fatalf("expected token %q to be rejected by pages, got %d", token, rec.Code)
+		}
+	}
+	token := cookieCSRFToken(c, cookie)
+	if rec := send(http.MethodDelete, "/api/v1/alerts/unknown", token); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected the valid token to be accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := send(http.MethodGet, "/api/v1/alerts", ""); rec.Code != http.StatusOK {
+		t.Fatalf("safe methods don't need a token, got %d", rec.Code)
+	}
+
+	// browsers send cached basic auth credentials on their own, basic auth can only read
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		for _, target := range []string{"/api/v1/alerts/unknown", "/alert/unknown"} {
+			req := httptest.NewRequest(method, target, nil)
+			req.SetBasicAuth("alice", "alice-password")
+			req.Header.Set(CSRFHeaderName, "")
+			rec := httptest.NewRecorder()
+			c.r.ServeHTTP(rec, req)
+			want := http.StatusForbidden
+			if method == http.MethodGet {
+				want = http.StatusNotFound
+			}
+			if rec.Code != want {
+				t.Fatalf("basic auth %s %s: got %d, want %d", method, target, rec.Code, want)
+			}
+		}
+	}
+
+	// the logout form sends the token in a hidden field
+	logout := func(form url.Values) int {
+		req := httptest.NewRequest(http.MethodPost, "/logout", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		c.r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := logout(url.Values{}); code != http.StatusForbidden {
+		t.Fatalf("expected a cross site logout to be rejected, got %d", code)
+	}
+	if code := logout(url.Values{csrfFormField: {token}}); code != http.StatusSeeOther {
+		t.Fatalf("expected the logout form to work, got %d", code)
+	}
+}
+
+func TestRouteMethods(t *testing.T) {
+	chdirToRoot(t)
+	dbClient := db.NewMemoryClient()
+	addTestUser(t, dbClient, "alice", "alice-password")
+	c := newTestRouter(t, dbClient)
+
+	// GET requests of other routes fall through to the static files
+	for _, tc := range []struct {
+		method, target string
+		status         int
+	}{
+		{method: http.MethodPost, target: "/", status: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, target: "/refresh", status: http.StatusNotFound},
+		{method: http.MethodPut, target: "/refresh", status: http.StatusMethodNotAllowed},
+		{method: http.MethodPut, target: "/alert", status: http.StatusMethodNotAllowed},
+		{method: http.MethodPost, target: "/alert/1", status: http.StatusMethodNotAllowed},
+		{method: http.MethodDelete, target: "/admin/users", status: http.StatusMethodNotAllowed},
+		{method: http.MethodPost, target: "/favicon.ico", status: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, target: "/favicon.ico", status: http.StatusOK},
+	} {
+		req := httptest.NewRequest(tc.method, tc.target, nil)
+		req.SetBasicAuth("alice", "alice-password")
+		rec := httptest.NewRecorder()
+		c.r.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Fatalf("%s %s: got %d, want %d", tc.method, tc.target, rec.Code, tc.status)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package client
+
+import (
+	"context"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+const (
+	DefaultSessionIdleTimeout = 30 * time.Minute
+	// sessionTouchInterval limits last seen updates to one write a minute for every session.
+	sessionTouchInterval = time.Minute
+	maxUserAgentLength   = 256
+)
+
+type SessionsPageTemplateData struct {
+	AppURI    string
+	AppSchema string
+	AppPort   string
+	Username  string
+	Nonce     string
+	CSRFToken string
+}
+
+type sessionResponse struct {
+	ID         string `json:"id"`
+	UserAgent  string `json:"userAgent"`
+	IP         string `json:"ip"`
+	CreatedAt  int64  `json:"createdAt"`
+	LastSeenAt int64  `json:"lastSeenAt"`
+	ExpiresAt  int64  `json:"expiresAt"`
+	// Current is the session of the request
+	Current bool `json:"current"`
+}
+
+// startSession stores a new session of the user and sets its cookie.
+func (c *client) startSession(w http.ResponseWriter, r *http.Request, user *db.User) error {
+	now := time.Now()
+	if err := c.db.DeleteExpiredSessions(now.UnixMilli(), now.Add(-c.sessionIdleTimeout).UnixMilli()); err != nil {
+		log.Println("failed to delete expired sessions: ", err)
+	}
+
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	expiresAt := now.Add(time.Second * TokenExpirationDurationInSec)
+	session := &db.Session{
+		ID:         uuid.NewString(),
+		UserID:     user.ID,
+		UserAgent:  userAgent,
+		IP:         c.clientIP(r),
+		CreatedAt:  now.UnixMilli(),
+		LastSeenAt: now.UnixMilli(),
+		ExpiresAt:  expiresAt.UnixMilli(),
+	}
+	if err := c.db.AddSession(session); err != nil {
+		return err
+	}
+	token, err := c.createAccessToken(session.ID, user.Username, now, expiresAt)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, c.authCookie(BearerTokenPrefix+token, expiresAt))
+	return nil
+}
+
+// authCookie can't be read by scripts, is only sent over HTTPS when the app is served over HTTPS
+// and is not sent with cross site form posts.
+func (c *client) authCookie(value string, expires time.Time) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     AuthCookieName,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   c.appSchema == AppSchemaHTTPS,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+	return cookie
+}
+
+// sessionUser returns the user of the session cookie, nil when there is no valid session.
+// Sessions which were idle for too long are deleted, others are refreshed by updating when they were last seen.
+func (c *client) sessionUser(r *http.Request) (*db.User, *db.Session, error) {
+	payload := c.checkAccessToken(r)
+	if payload == nil {
+		return nil, nil, nil
+	}
+	session, err := c.db.GetSession(payload.ID)
+	if err != nil || session == nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	lastSeen := time.UnixMilli(session.LastSeenAt)
+	if c.sessionExpired(session, now) {
+		log.Printf("session %s of user %s expired", session.ID, session.UserID)
+		return nil, nil, c.db.DeleteSession(session.ID)
+	}
+	user, err := c.db.GetUser(session.UserID)
+	if err != nil || user == nil {
+		return nil, nil, err
+	}
+	if now.Sub(lastSeen) > sessionTouchInterval {
+		session.LastSeenAt, session.IP = now.UnixMilli(), c.clientIP(r)
+		if err = c.db.TouchSession(session.ID, session.LastSeenAt, session.IP); err != nil {
+			return nil, nil, err
+		}
+	}
+	return user, session, nil
+}
+
+func (c *client) sessionExpired(session *db.Session, now time.Time) bool {
+	return now.UnixMilli() >= session.ExpiresAt || now.Sub(time.UnixMilli(session.LastSeenAt)) > c.sessionIdleTimeout
+}
+
+const sessionContextKey contextKey = "session"
+
+func withSession(r *http.Request, session *db.Session) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), sessionContextKey, session))
+}
+
+// sessionFromRequest returns nil for basic auth requests.
+func sessionFromRequest(r *http.Request) *db.Session {
+	session, _ := r.Context().Value(sessionContextKey).(*db.Session)
+	return session
+}
+
+func (c *client) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if session := sessionFromRequest(r); session != nil {
+		if err := c.db.DeleteSession(session.ID); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+	}
+	http.SetCookie(w, c.authCookie("", time.Unix(0, 0)))
+	http.Redirect(w, r, loginPath, http.StatusSeeOther)
+}
+
+func (c *client) sessionsHandler(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := template.ParseFiles("./internal/client/templates/sessions.html")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	data := &SessionsPageTemplateData{
+		AppURI:    c.appUri,
+		AppSchema: c.appSchema,
+		AppPort:   c.appPort,
+		Username:  usernameFromRequest(r),
+		Nonce:     cspNonce(r),
+		CSRFToken: c.csrfToken(r),
+	}
+	if err = tmpl.Execute(w, data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+	}
+}
+
+func (c *client) apiListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := c.db.GetUserSessions(userFromRequest(r).ID)
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	current := sessionFromRequest(r)
+	items := make([]*sessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		items = append(items, &sessionResponse{
+			ID:         s.ID,
+			UserAgent:  s.UserAgent,
+			IP:         s.IP,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			ExpiresAt:  s.ExpiresAt,
+			Current:    current != nil && current.ID == s.ID,
+		})
+	}
+	c.writeList(w, r, sessionsResource, items)
+}
+
+// apiDeleteSession logs out one session of the current user.
+func (c *client) apiDeleteSession(w http.ResponseWriter, r *http.Request) {
+	session, err := c.db.GetSession(mux.Vars(r)["id"])
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	if session == nil || session.UserID != userFromRequest(r).ID {
+		writeAPIError(w, http.StatusNotFound, "not_found", "session not found")
+		return
+	}
+	if err = c.db.DeleteSession(session.ID); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	if current := sessionFromRequest(r); current != nil && current.ID == session.ID {
+		http.SetCookie(w, c.authCookie("", time.Unix(0, 0)))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiDeleteSessions logs out every session of the current user including the current one.
+func (c *client) apiDeleteSessions(w http.ResponseWriter, r *http.Request) {
+	if err := c.db.DeleteUserSessions(userFromRequest(r).ID); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	http.SetCookie(w, c.authCookie("", time.Unix(0, 0)))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiDeleteUserSessions lets admins log out every session of a user.
+func (c *client) apiDeleteUserSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.userForUpdate(w, r)
+	if !ok {
+		return
+	}
+	if err := c.db.DeleteUserSessions(user.ID); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,179 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+func doCookieRequest(c *client, cookie *http.Cookie, method, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.AddCookie(cookie)
+	req.Header.Set("User-Agent", "test-browser")
+	req.Header.Set(CSRFHeaderName, cookieCSRFToken(c, cookie))
+	rec := httptest.NewRecorder()
+	c.r.ServeHTTP(rec, req)
+	return rec
+}
+
+func loginCookie(t *testing.T, c *client, username, password string) *http.Cookie {
+	rec := postLogin(c, url.Values{"username": {username}, "password": {password}})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("login failed with %d: %s", rec.Code, rec.Body.String())
+	}
+	return authCookie(t, rec)
+}
+
+func listSessions(t *testing.T, c *client, cookie *http.Cookie) []*sessionResponse {
+	rec := doCookieRequest(c, cookie, http.MethodGet, "/api/v1/me/sessions")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	body := &struct {
+		Data []*sessionResponse `json:"data"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), body); err != nil {
+		t.Fatal(err)
+	}
+	return body.Data
+}
+
+func TestLoginStartsSession(t *testing.T) {
+	chdirToRoot(t)
+	dbClient := db.NewMemoryClient()
+	user := addTestUser(t, dbClient, "alice", "alice-password")
+	c := newTestRouter(t, dbClient)
+
+	cookie := loginCookie(t, c, "alice", "alice-password")
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/" {
+		t.Fatalf("unexpected cookie %+v", cookie)
+	}
+	sessions, err := dbClient.GetUserSessions(user.ID)
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("expected one session, got %v %v", sessions, err)
+	}
+
+	listed := listSessions(t, c, cookie)
+	if len(listed) != 1 || !listed[0].Current || listed[0].ID != sessions[0].ID || listed[0].IP == "" {
+		t.Fatalf("unexpected sessions %+v", listed[0])
+	}
+
+	rec := doCookieRequest(c, cookie, http.MethodPost, "/logout")
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != loginPath {
+		t.Fatalf("unexpected logout response %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	if cleared := authCookie(t, rec); cleared.Value != "" || cleared.MaxAge >= 0 {
+		t.Fatalf("expected the cookie to be cleared, got %+v", cleared)
+	}
+	if session, _ := dbClient.GetSession(sessions[0].ID); session != nil {
+		t.Fatal("expected the session to be deleted")
+	}
+	if rec = doCookieRequest(c, cookie, http.MethodGet, "/api/v1/alerts"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the token of a deleted session to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	chdirToRoot(t)
+	dbClient := db.NewMemoryClient()
+	user := addTestUser(t, dbClient, "bob", "bob-password")
+	c := newTestRouter(t, dbClient)
+
+	cookie := loginCookie(t, c, "bob", "bob-password")
+	sessions, _ := dbClient.GetUserSessions(user.ID)
+	session := sessions[0]
+
+	// activity refreshes the session
+	lastSeen := time.Now().Add(-c.sessionIdleTimeout + time.Minute).UnixMilli()
+	if err := dbClient.TouchSession(session.ID, lastSeen, session.IP); err != nil {
+		t.Fatal(err)
+	}
+	if rec := doCookieRequest(c, cookie, http.MethodGet, "/api/v1/alerts"); rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if refreshed, _ := dbClient.GetSession(session.ID); refreshed.LastSeenAt <= lastSeen {
+		t.Fatal("expected the last seen time to be updated")
+	}
+
+	lastSeen = time.Now().Add(-c.sessionIdleTimeout - time.Minute).UnixMilli()
+	if err := dbClient.TouchSession(session.ID, lastSeen, session.IP); err != nil {
+		t.Fatal(err)
+	}
+	if rec := doCookieRequest(c, cookie, http.MethodGet, "/api/v1/alerts"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected an idle session to be rejected, got %d", rec.Code)
+	}
+	if idle, _ := dbClient.GetSession(session.ID); idle != nil {
+		t.Fatal("expected the idle session to be deleted")
+	}
+}
+
+func TestAPIRevokeSessions(t *testing.T) {
+	chdirToRoot(t)
+	dbClient := db.NewMemoryClient()
+	alice := addTestUser(t, dbClient, "alice", "alice-password")
+	addTestUser(t, dbClient, "bob", "bob-password")
+	c := newTestRouter(t, dbClient)
+
+	first := loginCookie(t, c, "alice", "alice-password")
+	second := loginCookie(t, c, "alice", "alice-password")
+	bob := loginCookie(t, c, "bob", "bob-password")
+
+	listed := listSessions(t, c, first)
+	if len(listed) != 2 {
+		t.Fatalf("expected two sessions, got %d", len(listed))
+	}
+	var other *sessionResponse
+	for _, s := range listed {
+		if !s.Current {
+			other = s
+		}
+	}
+	if other == nil {
+		t.Fatal("expected the other session to be listed")
+	}
+
+	if rec := doCookieRequest(c, bob, http.MethodDelete, "/api/v1/me/sessions/"+other.ID); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected sessions of other users to be hidden, got %d", rec.Code)
+	}
+	if rec := doCookieRequest(c, first, http.MethodDelete, "/api/v1/me/sessions/"+other.ID); rec.Code != http.StatusNoContent {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := doCookieRequest(c, second, http.MethodGet, "/api/v1/alerts"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the revoked session to be rejected, got %d", rec.Code)
+	}
+
+	if rec := doCookieRequest(c, first, http.MethodDelete, "/api/v1/me/sessions"); rec.Code != http.StatusNoContent {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if sessions, _ := dbClient.GetUserSessions(alice.ID); len(sessions) != 0 {
+		t.Fatalf("expected every session to be deleted, got %d", len(sessions))
+	}
+	if rec := doCookieRequest(c, bob, http.MethodGet, "/api/v1/alerts"); rec.Code != http.StatusOK {
+		t.Fatalf("expected sessions of other users to stay, got %d", rec.Code)
+	}
+}
+
+func TestBasicAuthDoesNotStartSession(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	user := addTestUser(t, dbClient, "carol", "carol-password")
+	c := newTestRouter(t, dbClient)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/alerts", nil)
+	req.SetBasicAuth("carol", "carol-password")
+	rec := httptest.NewRecorder()
+	c.r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(rec.Result().Cookies()) != 0 {
+		t.Fatal("basic auth should not set cookies")
+	}
+	if sessions, _ := dbClient.GetUserSessions(user.ID); len(sessions) != 0 {
+		t.Fatalf("expected no sessions, got %d", len(sessions))
+	}
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/simulator"
+)
+
+const maxPathPoints = 100
+
+type SimulatorPageTemplateData struct {
+	AppURI    string
+	AppSchema string
+	AppPort   string
+	Accounts  []string
+	Nonce     string
+	CSRFToken string
+}
+
+// simulationInput is the scenario to simulate, an empty accountId simulates all accounts.
+type simulationInput struct {
+	AccountID      string                  `json:"accountId"`
+	ValuationAsset string                  `json:"valuationAsset"`
+	Path           []*simulator.PricePoint `json:"path"`
+}
+
+func (in *simulationInput) normalize() {
+	in.AccountID = strings.ToLower(strings.TrimSpace(in.AccountID))
+	in.ValuationAsset = strings.ToUpper(strings.TrimSpace(in.ValuationAsset))
+	for _, p := range in.Path {
+		if p == nil {
+			continue
+		}
+		p.Symbol = strings.ToUpper(strings.TrimSpace(p.Symbol))
+		p.Price = strings.TrimSpace(p.Price)
+	}
+}
+
+func (c *client) validateSimulation(in *simulationInput) error {
+	errs := &validationError{}
+	if in.AccountID != "" && !contains(c.accounts, in.AccountID) {
+		errs.add("accountId", "should be one of "+strings.Join(c.accounts, ", "))
+	}
+	if in.ValuationAsset != "" && !symbolRegexp.MatchString(in.ValuationAsset) {
+		errs.add("valuationAsset", "should be an asset like USDT")
+	}
+	if len(in.Path) == 0 || len(in.Path) > maxPathPoints {
+		errs.add("path", fmt.Sprintf("should have between 1 and %d price points", maxPathPoints))
+	}
+	for i, p := range in.Path {
+		field := fmt.Sprintf("path[%d]", i)
+		if p == nil {
+			errs.add(field, "should be a price point")
+			continue
+		}
+		if !symbolRegexp.MatchString(p.Symbol) {
+			errs.add(field+".symbol", "should be a Binance symbol like BTCUSDT")
+		}
+		validatePositive(errs, field+".price", p.Price)
+	}
+	return errs.orNil()
+}
+
+func (c *client) apiSimulate(w http.ResponseWriter, r *http.Request) {
+	in := &simulationInput{}
+	if err := decodeJSONBody(r, in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_body", err.Error())
+		return
+	}
+	in.normalize()
+	if err := c.validateSimulation(in); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	result, err := c.simulator.Simulate(&simulator.Scenario{AccountID: in.AccountID, ValuationAsset: in.ValuationAsset, Path: in.Path})
+	if errors.Is(err, simulator.ErrUnsupportedOrderType) {
+		writeAPIError(w, http.StatusUnprocessableEntity, "unsupported_order", err.Error())
+		return
+	}
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (c *client) simulatorHandler(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := template.ParseFiles("./internal/client/templates/simulator.html")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	data := &SimulatorPageTemplateData{
+		AppURI:    c.appUri,
+		AppSchema: c.appSchema,
+		AppPort:   c.appPort,
+		Accounts:  c.accounts,
+		Nonce:     cspNonce(r),
+		CSRFToken: c.csrfToken(r),
+	}
+	if err = tmpl.Execute(w, data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+	}
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/events"
+	"github.com/morzhanov/binance-orders-watcher/internal/simulator"
+)
+
+func TestSimulatorTemplate(t *testing.T) {
+	tmpl, err := template.ParseFiles("templates/simulator.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, &SimulatorPageTemplateData{Accounts: []string{"main", "savings"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<option value="savings">`) {
+		t.Fatal("accounts are not listed")
+	}
+}
+
+func TestAPISimulate(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	orders := []*db.Order{
+		{AccountID: "main", Symbol: "BTCUSDT", OrderID: 1, OrderListID: -1, Type: binance.OrderTypeLimit, Side: binance.SideBuy, Price: "45000", OrigQty: "0.1", ExecutedQty: "0"},
+	}
+	if err := dbClient.SetOrders("main", orders); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbClient.SetBalances("main", []*db.Balance{{Asset: "USDT", Free: "0", Locked: "4500"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbClient.SetPrices([]*db.Price{{Symbol: "BTCUSDT", Price: "50000"}}); err != nil {
+		t.Fatal(err)
+	}
+	c := &client{db: dbClient, bus: events.NewBus(), accounts: []string{"main", "savings"}, simulator: simulator.New(dbClient, newTestExchangeInfo())}
+	r := mux.NewRouter()
+	c.registerAPI(r)
+
+	rec := doRequest(r, http.MethodPost, "/api/v1/simulations", `{"accountId":"Main","path":[{"symbol":"btcusdt","price":"44000"},{"symbol":"BTCUSDT","price":"48000"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	result := &simulator.Result{}
+	if err := json.Unmarshal(rec.Body.Bytes(), result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Fills) != 1 || result.Fills[0].Price != "45000" || result.EndValue != "4800" || result.PnL != "300" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	for _, body := range []string{
+		`{"path":[]}`,
+		`{"accountId":"other","path":[{"symbol":"BTCUSDT","price":"1"}]}`,
+		`{"path":[{"symbol":"BTCUSDT","price":"-1"}]}`,
+		`{"path":[null]}`,
+	} {
+		if rec = doRequest(r, http.MethodPost, "/api/v1/simulations", body); rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%s: expected 422, got %d: %s", body, rec.Code, rec.Body)
+		}
+	}
+}
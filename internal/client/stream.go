@@ -0,0 +1,101 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/checker"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/events"
+)
+
+var streamKeepAliveInterval = time.Second * 25
+
+// streamHandler pushes bus events to the dashboard as Server-Sent Events. The session is checked again on every
+// keep-alive, the stream ends when the user logs out, the session is revoked or it expires.
+func (c *client) streamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIError(w, http.StatusInternalServerError, "internal", "streaming is not supported")
+		return
+	}
+	published, unsubscribe := c.bus.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 5000\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if !c.streamSessionActive(r) {
+				return
+			}
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case e, ok := <-published:
+			if !ok {
+				return
+			}
+			payload, visible := streamPayload(r, e)
+			if !visible {
+				continue
+			}
+			data, err := json.Marshal(payload)
+			if err != nil {
+				log.Printf("failed to marshal %s event: %s", e.Type, err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// streamSessionActive reports whether the session of the stream request still exists and is not expired,
+// basic auth and API token requests have no session.
+func (c *client) streamSessionActive(r *http.Request) bool {
+	session := sessionFromRequest(r)
+	if session == nil {
+		return true
+	}
+	current, err := c.db.GetSession(session.ID)
+	if err != nil {
+		log.Println("failed to check the stream session: ", err)
+		return false
+	}
+	return current != nil && !c.sessionExpired(current, time.Now())
+}
+
+// streamPayload hides alerts of other users, admins see every alert.
+func streamPayload(r *http.Request, e *events.Event) (interface{}, bool) {
+	switch data := e.Data.(type) {
+	case []*db.Alert:
+		return visibleAlerts(r, data), true
+	case *checker.AlertTriggered:
+		return data, canAccessAlert(r, data.Alert)
+	}
+	return e.Data, true
+}
+
+// publishAlerts sends the current alerts list to the dashboards after alerts were changed.
+func (c *client) publishAlerts() {
+	alerts, err := c.db.GetAlerts()
+	if err != nil {
+		log.Println("failed to get alerts for publishing: ", err)
+		return
+	}
+	c.bus.Publish(events.TypeAlerts, alerts)
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/events"
+)
+
+func TestStreamHandler(t *testing.T) {
+	bus := events.NewBus()
+	c := &client{db: db.NewMemoryClient(), bus: bus}
+	server := httptest.NewServer(http.HandlerFunc(c.streamHandler))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %s", ct)
+	}
+
+	reader := bufio.NewReader(res.Body)
+	// wait for the retry preamble, the handler is subscribed by then
+	if line, err := reader.ReadString('\n'); err != nil || !strings.HasPrefix(line, "retry:") {
+		t.Fatalf("unexpected first line %q: %v", line, err)
+	}
+	bus.Publish(events.TypePrices, []*db.Price{{Symbol: "BTCUSDT", Price: "1"}})
+
+	var lines []string
+	for len(lines) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if lines[0] != "event: prices" || lines[1] != `data: [{"symbol":"BTCUSDT","price":"1"}]` {
+		t.Fatalf("unexpected event %q", lines)
+	}
+}
+
+func TestStreamHandlerEndsWithSession(t *testing.T) {
+	interval := streamKeepAliveInterval
+	streamKeepAliveInterval = 10 * time.Millisecond
+	defer func() { streamKeepAliveInterval = interval }()
+
+	dbClient := db.NewMemoryClient()
+	now := time.Now()
+	session := &db.Session{ID: "session", UserID: testAdmin.ID, CreatedAt: now.UnixMilli(), LastSeenAt: now.UnixMilli(), ExpiresAt: now.Add(time.Hour).UnixMilli()}
+	if err := dbClient.AddSession(session); err != nil {
+		t.Fatal(err)
+	}
+	c := &client{db: dbClient, bus: events.NewBus(), sessionIdleTimeout: time.Hour}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.streamHandler(w, withSession(withUser(r, testAdmin), session))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	reader := bufio.NewReader(res.Body)
+	// keep-alives are sent while the session exists
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("the stream ended with an active session: %v", err)
+		}
+		if strings.HasPrefix(line, ": keep-alive") {
+			break
+		}
+	}
+	if err = dbClient.DeleteSession(session.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ioutil.ReadAll(reader); err != nil {
+		t.Fatalf("expected the stream to end after logout, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+)
+
+type symbolResponse struct {
+	Symbol      string `json:"symbol"`
+	Status      string `json:"status"`
+	BaseAsset   string `json:"baseAsset"`
+	QuoteAsset  string `json:"quoteAsset"`
+	TickSize    string `json:"tickSize"`
+	StepSize    string `json:"stepSize"`
+	MinNotional string `json:"minNotional"`
+}
+
+func newSymbolResponse(s *binance.SymbolInfo) *symbolResponse {
+	return &symbolResponse{
+		Symbol:      s.Symbol,
+		Status:      s.Status,
+		BaseAsset:   s.BaseAsset,
+		QuoteAsset:  s.QuoteAsset,
+		TickSize:    s.TickSize(),
+		StepSize:    s.StepSize(),
+		MinNotional: s.MinNotional(),
+	}
+}
+
+func (c *client) apiListSymbols(w http.ResponseWriter, r *http.Request) {
+	// Get loads exchange info if it was not loaded yet
+	if _, err := c.exchangeInfo.Get(""); err != nil {
+		writeAPIError(w, http.StatusBadGateway, "exchange_info_failed", err.Error())
+		return
+	}
+	symbols := c.exchangeInfo.Symbols()
+	items := make([]*symbolResponse, 0, len(symbols))
+	for _, s := range symbols {
+		items = append(items, newSymbolResponse(s))
+	}
+	c.writeList(w, r, symbolsResource, items)
+}
+
+// checkSymbol returns a validation error when the symbol is not listed on Binance. When exchange info
+// can't be loaded the check is skipped, so alerts can be managed while Binance is not reachable.
+func (c *client) checkSymbol(symbol string) error {
+	info, err := c.exchangeInfo.Get(symbol)
+	if err != nil {
+		log.Println("skipping symbol check, failed to load exchange info: ", err)
+		return nil
+	}
+	if info == nil {
+		errs := &validationError{}
+		errs.add("symbol", "is not listed on Binance")
+		return errs
+	}
+	return nil
+}
+
+func (c *client) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"formatPrice": func(symbol, price string) string {
+			if info := c.exchangeInfo.Lookup(symbol); info != nil {
+				return info.FormatPrice(price)
+			}
+			return price
+		},
+	}
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+type fakeExchangeInfo struct {
+	symbols []*binance.SymbolInfo
+}
+
+func newTestExchangeInfo() *fakeExchangeInfo {
+	symbol := func(name, base, tickSize string) *binance.SymbolInfo {
+		return &binance.SymbolInfo{
+			Symbol:     name,
+			Status:     binance.SymbolStatusTrading,
+			BaseAsset:  base,
+			QuoteAsset: "USDT",
+			Filters:    []*binance.SymbolFilter{{FilterType: binance.FilterTypePrice, TickSize: tickSize}},
+		}
+	}
+	return &fakeExchangeInfo{symbols: []*binance.SymbolInfo{symbol("BTCUSDT", "BTC", "0.01000000"), symbol("ETHUSDT", "ETH", "0.10000000")}}
+}
+
+func (e *fakeExchangeInfo) Get(symbol string) (*binance.SymbolInfo, error) {
+	return e.Lookup(symbol), nil
+}
+
+func (e *fakeExchangeInfo) Lookup(symbol string) *binance.SymbolInfo {
+	for _, s := range e.symbols {
+		if s.Symbol == symbol {
+			return s
+		}
+	}
+	return nil
+}
+
+func (e *fakeExchangeInfo) Symbols() []*binance.SymbolInfo {
+	return e.symbols
+}
+
+func (e *fakeExchangeInfo) Refresh() error {
+	return nil
+}
+
+func (e *fakeExchangeInfo) Run(_ time.Duration) {}
+
+func TestAPIListSymbols(t *testing.T) {
+	h := newTestAPI(t, db.NewMemoryClient())
+	rec := doRequest(h, http.MethodGet, "/api/v1/symbols?baseAsset=eth", "")
+	var page struct {
+		Data []*symbolResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body)
+	}
+	if len(page.Data) != 1 || page.Data[0].Symbol != "ETHUSDT" || page.Data[0].TickSize != "0.10000000" {
+		t.Fatalf("unexpected symbols %+v", page.Data)
+	}
+}
+
+func TestAPIAlertUnknownSymbol(t *testing.T) {
+	h := newTestAPI(t, db.NewMemoryClient())
+	rec := doRequest(h, http.MethodPost, "/api/v1/alerts", `{"symbol":"FOOUSDT","price":"1","email":"john@example.com"}`)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "not listed") {
+		t.Fatalf("expected unknown symbol to be rejected, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestFormatPriceTemplateFunc(t *testing.T) {
+	c := &client{exchangeInfo: newTestExchangeInfo()}
+	tmpl := template.Must(template.New("t").Funcs(c.templateFuncs()).Parse(`{{ formatPrice "BTCUSDT" "40000.12345" }} {{ formatPrice "ETHUSDT" "3000.00000000" }} {{ formatPrice "FOOUSDT" "1.5000" }} {{ formatPrice "BTCUSDT" "N/A" }}`))
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "40000.12 3000.0 1.5000 N/A" {
+		t.Fatalf("unexpected formatted prices %q", buf.String())
+	}
+}
@@ -0,0 +1,278 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+const (
+	ScopeReadOrders  = "read:orders"
+	ScopeWriteAlerts = "write:alerts"
+	ScopeTrade       = "trade"
+
+	// apiTokenPrefix makes tokens easy to recognize in scripts and secret scanners
+	apiTokenPrefix = "bow_"
+	// apiTokenTouchInterval limits last used updates to one write a minute for every token.
+	apiTokenTouchInterval = time.Minute
+)
+
+var apiScopes = []string{ScopeReadOrders, ScopeWriteAlerts, ScopeTrade}
+
+type TokensPageTemplateData struct {
+	AppURI    string
+	AppSchema string
+	AppPort   string
+	Scopes    []string
+	Nonce     string
+	CSRFToken string
+}
+
+// apiTokenInput is the request body to create a token, ExpiresAt is a unix time in milliseconds or 0 for no expiry.
+type apiTokenInput struct {
+	UserID    string   `json:"userId"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresAt int64    `json:"expiresAt"`
+}
+
+// createdAPIToken is only returned when the token is created, the token can't be shown again.
+type createdAPIToken struct {
+	*db.APIToken
+	Token string `json:"token"`
+}
+
+func (in *apiTokenInput) validate(now time.Time) error {
+	errs := &validationError{}
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		errs.add("name", "is required")
+	}
+	if len(in.Name) > maxNameLength {
+		errs.add("name", fmt.Sprintf("should be at most %d characters long", maxNameLength))
+	}
+	if len(in.Scopes) == 0 {
+		errs.add("scopes", "at least one scope is required")
+	}
+	seen := make(map[string]bool, len(in.Scopes))
+	for _, scope := range in.Scopes {
+		if !isAPIScope(scope) {
+			errs.add("scopes", fmt.Sprintf("%q is not one of %s", scope, strings.Join(apiScopes, ", ")))
+		}
+		if seen[scope] {
+			errs.add("scopes", fmt.Sprintf("%q is listed twice", scope))
+		}
+		seen[scope] = true
+	}
+	if in.ExpiresAt != 0 && in.ExpiresAt <= now.UnixMilli() {
+		errs.add("expiresAt", "should be in the future")
+	}
+	return errs.orNil()
+}
+
+func isAPIScope(scope string) bool {
+	for _, s := range apiScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func hasScope(token *db.APIToken, scope string) bool {
+	for _, s := range token.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// generateAPIToken returns a random token, its hash and the prefix shown to tell tokens apart.
+func generateAPIToken() (token, hash, prefix string, err error) {
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+	token = apiTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return token, hashAPIToken(token), token[:len(apiTokenPrefix)+6], nil
+}
+
+// hashAPIToken doesn't need a slow hash like bcrypt, tokens are random and long enough to not be guessed.
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// bearerToken returns the token of the Authorization header.
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, BearerTokenPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, BearerTokenPrefix)), true
+}
+
+// apiTokenUser returns the user of the bearer token, nil when the token is unknown or expired.
+func (c *client) apiTokenUser(value string) (*db.User, *db.APIToken, error) {
+	token, err := c.db.GetAPITokenByHash(hashAPIToken(value))
+	if err != nil || token == nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	if token.ExpiresAt != 0 && now.UnixMilli() >= token.ExpiresAt {
+		log.Printf("API token %s of user %s expired", token.Prefix, token.UserID)
+		return nil, nil, nil
+	}
+	user, err := c.db.GetUser(token.UserID)
+	if err != nil || user == nil {
+		return nil, nil, err
+	}
+	if now.Sub(time.UnixMilli(token.LastUsedAt)) > apiTokenTouchInterval {
+		token.LastUsedAt = now.UnixMilli()
+		if err = c.db.TouchAPIToken(token.ID, token.LastUsedAt); err != nil {
+			return nil, nil, err
+		}
+	}
+	return user, token, nil
+}
+
+const apiTokenContextKey contextKey = "apiToken"
+
+func withAPIToken(r *http.Request, token *db.APIToken) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), apiTokenContextKey, token))
+}
+
+// apiTokenFromRequest returns nil for requests which are not authenticated with an API token.
+func apiTokenFromRequest(r *http.Request) *db.APIToken {
+	token, _ := r.Context().Value(apiTokenContextKey).(*db.APIToken)
+	return token
+}
+
+// requireScope only lets API tokens with the scope call h, endpoints without a scope can't be called with tokens.
+// Sessions and basic auth are not limited by scopes.
+func requireScope(scope string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := apiTokenFromRequest(r)
+		if token == nil || (scope != "" && hasScope(token, scope)) {
+			h(w, r)
+			return
+		}
+		if scope == "" {
+			writeAPIError(w, http.StatusForbidden, "forbidden", "the endpoint can't be called with an API token")
+			return
+		}
+		writeAPIError(w, http.StatusForbidden, "forbidden", "the "+scope+" scope is required")
+	}
+}
+
+func (c *client) tokensHandler(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := template.ParseFiles("./internal/client/templates/tokens.html")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	data := &TokensPageTemplateData{
+		AppURI:    c.appUri,
+		AppSchema: c.appSchema,
+		AppPort:   c.appPort,
+		Scopes:    apiScopes,
+		Nonce:     cspNonce(r),
+		CSRFToken: c.csrfToken(r),
+	}
+	if err = tmpl.Execute(w, data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+	}
+}
+
+func (c *client) apiListAPITokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := c.db.GetAPITokens()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	c.writeList(w, r, apiTokensResource, tokens)
+}
+
+func (c *client) apiCreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	in := &apiTokenInput{}
+	if err := decodeJSONBody(r, in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_body", err.Error())
+		return
+	}
+	now := time.Now()
+	if err := in.validate(now); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	user, err := c.db.GetUser(in.UserID)
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	if user == nil {
+		errs := &validationError{}
+		errs.add("userId", "user not found")
+		writeAPIErrorFrom(w, errs)
+		return
+	}
+
+	value, hash, prefix, err := generateAPIToken()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	token := &db.APIToken{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		Name:      in.Name,
+		Prefix:    prefix,
+		TokenHash: hash,
+		Scopes:    in.Scopes,
+		CreatedAt: now.UnixMilli(),
+		ExpiresAt: in.ExpiresAt,
+	}
+	if err = c.db.AddAPIToken(token); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	log.Printf("user %s created API token %s for user %s", usernameFromRequest(r), token.Prefix, user.Username)
+	writeJSON(w, http.StatusCreated, &createdAPIToken{APIToken: token, Token: value})
+}
+
+func (c *client) apiDeleteAPIToken(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	tokens, err := c.db.GetAPITokens()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	for _, token := range tokens {
+		if token.ID != id {
+			continue
+		}
+		if err = c.db.DeleteAPIToken(id); err != nil {
+			writeAPIErrorFrom(w, err)
+			return
+		}
+		log.Printf("user %s revoked API token %s", usernameFromRequest(r), token.Prefix)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeAPIError(w, http.StatusNotFound, "not_found", "API token not found")
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+func addTestAPIToken(t *testing.T, dbClient db.Client, user *db.User, expiresAt int64, scopes ...string) string {
+	value, hash, prefix, err := generateAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := &db.APIToken{ID: prefix, UserID: user.ID, Name: "script", Prefix: prefix, TokenHash: hash, Scopes: scopes, ExpiresAt: expiresAt}
+	if err = dbClient.AddAPIToken(token); err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func doTokenRequest(c *client, token, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	c.r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPITokens(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	user := addTestUser(t, dbClient, "alice", "alice-password")
+	h := newTestAPI(t, dbClient)
+
+	rec := doRequest(h, http.MethodPost, "/api/v1/tokens", `{"userId":"`+user.ID+`","name":" ","scopes":["read:orders","admin","read:orders"],"expiresAt":1}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, field := range []string{`"name"`, `\"admin\" is not one of`, `listed twice`, `"expiresAt"`} {
+		if !strings.Contains(rec.Body.String(), field) {
+			t.Fatalf("expected %s in %s", field, rec.Body.String())
+		}
+	}
+	if rec = doRequest(h, http.MethodPost, "/api/v1/tokens", `{"userId":"unknown","name":"ci","scopes":["trade"]}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected an unknown user to be rejected, got %d", rec.Code)
+	}
+
+	expiresAt := time.Now().Add(time.Hour).UnixMilli()
+	rec = doRequest(h, http.MethodPost, "/api/v1/tokens", `{"userId":"`+user.ID+`","name":"ci","scopes":["read:orders","write:alerts"],"expiresAt":`+strconv.FormatInt(expiresAt, 10)+`}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	created := &createdAPIToken{}
+	if err := json.Unmarshal(rec.Body.Bytes(), created); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(created.Token, apiTokenPrefix) || !strings.HasPrefix(created.Token, created.Prefix) || created.ExpiresAt != expiresAt {
+		t.Fatalf("unexpected token %+v", created)
+	}
+	stored, _ := dbClient.GetAPITokenByHash(hashAPIToken(created.Token))
+	if stored == nil || stored.UserID != user.ID {
+		t.Fatalf("expected the token hash to be stored, got %+v", stored)
+	}
+
+	rec = doRequest(h, http.MethodGet, "/api/v1/tokens", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), created.ID) {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), created.Token) || strings.Contains(rec.Body.String(), stored.TokenHash) {
+		t.Fatal("tokens and their hashes should not be listed")
+	}
+	if rec = doRequestAs(h, user, http.MethodGet, "/api/v1/tokens", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected tokens to be managed by admins only, got %d", rec.Code)
+	}
+
+	if rec = doRequest(h, http.MethodDelete, "/api/v1/tokens/"+created.ID, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = doRequest(h, http.MethodDelete, "/api/v1/tokens/"+created.ID, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected a revoked token to be gone, got %d", rec.Code)
+	}
+}
+
+func TestAPITokenAuth(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	if err := dbClient.SetOrders(db.DefaultAccountID, []*db.Order{{Symbol: "BTCUSDT", OrderID: 1, Price: "10", Side: "BUY"}}); err != nil {
+		t.Fatal(err)
+	}
+	trader := addTestUser(t, dbClient, "trader", "trader-password")
+	viewer := addTestUser(t, dbClient, "viewer", "viewer-password")
+	viewer.Role = db.RoleViewer
+	if err := dbClient.UpdateUser(viewer); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestRouter(t, dbClient)
+
+	readOrders := addTestAPIToken(t, dbClient, trader, 0, ScopeReadOrders)
+	rec := doTokenRequest(c, readOrders, http.MethodGet, "/api/v1/orders", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "BTCUSDT") {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+	if stored, _ := dbClient.GetAPITokenByHash(hashAPIToken(readOrders)); stored.LastUsedAt == 0 {
+		t.Fatal("expected the last use to be recorded")
+	}
+	if rec = doTokenRequest(c, readOrders, http.MethodGet, "/api/v1/alerts", ""); rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), ScopeWriteAlerts) {
+		t.Fatalf("expected the missing scope to be reported, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = doTokenRequest(c, readOrders, http.MethodGet, "/api/v1/balances", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected endpoints without a scope to be rejected, got %d", rec.Code)
+	}
+	if rec = doTokenRequest(c, readOrders, http.MethodGet, "/", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected pages to reject tokens, got %d", rec.Code)
+	}
+	if len(rec.Result().Cookies()) != 0 {
+		t.Fatal("tokens should not start sessions")
+	}
+
+	// the role of the token user still applies
+	viewerAlerts := addTestAPIToken(t, dbClient, viewer, 0, ScopeWriteAlerts)
+	if rec = doTokenRequest(c, viewerAlerts, http.MethodGet, "/api/v1/alerts", ""); rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = doTokenRequest(c, viewerAlerts, http.MethodDelete, "/api/v1/alerts/1", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected viewers to be read only, got %d", rec.Code)
+	}
+
+	expired := addTestAPIToken(t, dbClient, trader, time.Now().Add(-time.Minute).UnixMilli(), ScopeReadOrders)
+	for _, token := range []string{expired, apiTokenPrefix + "unknown"} {
+		if rec = doTokenRequest(c, token, http.MethodGet, "/api/v1/orders", ""); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected token %s to be rejected, got %d", token, rec.Code)
+		}
+	}
+}
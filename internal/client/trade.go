@@ -0,0 +1,222 @@
+package client
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+	"github.com/morzhanov/binance-orders-watcher/internal/decimal"
+	"github.com/morzhanov/binance-orders-watcher/internal/trader"
+)
+
+const orderTypeOCO = "OCO"
+
+var orderTypes = []string{binance.OrderTypeLimit, binance.OrderTypeMarket, binance.OrderTypeStopLossLimit, orderTypeOCO}
+
+// orderInput is the body of order endpoints. Orders are placed only when Confirm is set,
+// with Test set they are validated without being placed.
+type orderInput struct {
+	AccountID      string `json:"accountId"`
+	Symbol         string `json:"symbol"`
+	Side           string `json:"side"`
+	Type           string `json:"type"`
+	Quantity       string `json:"quantity"`
+	Price          string `json:"price"`
+	StopPrice      string `json:"stopPrice"`
+	StopLimitPrice string `json:"stopLimitPrice"`
+	Test           bool   `json:"test"`
+	Confirm        bool   `json:"confirm"`
+}
+
+type tradeResult struct {
+	Test      bool                  `json:"test"`
+	Order     *binance.BinanceOrder `json:"order,omitempty"`
+	OrderList *binance.OrderList    `json:"orderList,omitempty"`
+	Canceled  *binance.BinanceOrder `json:"canceled,omitempty"`
+}
+
+func (in *orderInput) normalize() {
+	in.AccountID = strings.ToLower(strings.TrimSpace(in.AccountID))
+	in.Symbol = strings.ToUpper(strings.TrimSpace(in.Symbol))
+	in.Side = strings.ToUpper(strings.TrimSpace(in.Side))
+	in.Type = strings.ToUpper(strings.TrimSpace(in.Type))
+	in.Quantity = strings.TrimSpace(in.Quantity)
+	in.Price = strings.TrimSpace(in.Price)
+	in.StopPrice = strings.TrimSpace(in.StopPrice)
+	in.StopLimitPrice = strings.TrimSpace(in.StopLimitPrice)
+}
+
+func (in *orderInput) validate() error {
+	errs := &validationError{}
+	if !symbolRegexp.MatchString(in.Symbol) {
+		errs.add("symbol", "should be a Binance symbol like BTCUSDT")
+	}
+	if in.Side != binance.SideBuy && in.Side != binance.SideSell {
+		errs.add("side", "should be BUY or SELL")
+	}
+	if !contains(orderTypes, in.Type) {
+		errs.add("type", "should be one of "+strings.Join(orderTypes, ", "))
+	}
+	validatePositive(errs, "quantity", in.Quantity)
+	if in.Type != binance.OrderTypeMarket {
+		validatePositive(errs, "price", in.Price)
+	}
+	if in.Type == binance.OrderTypeStopLossLimit || in.Type == orderTypeOCO {
+		validatePositive(errs, "stopPrice", in.StopPrice)
+	}
+	if in.Type == orderTypeOCO {
+		validatePositive(errs, "stopLimitPrice", in.StopLimitPrice)
+	}
+	if in.Test && in.Confirm {
+		errs.add("confirm", "should not be set together with test")
+	}
+	return errs.orNil()
+}
+
+func validatePositive(errs *validationError, field, value string) {
+	if number, err := decimal.Parse(value); err != nil || number.Sign() <= 0 {
+		errs.add(field, "should be a positive number")
+	}
+}
+
+func (in *orderInput) orderRequest() *binance.OrderRequest {
+	req := &binance.OrderRequest{Symbol: in.Symbol, Side: in.Side, Type: in.Type, Quantity: in.Quantity, StopPrice: in.StopPrice}
+	if in.Type != binance.OrderTypeMarket {
+		req.Price = in.Price
+	}
+	return req
+}
+
+func (in *orderInput) ocoRequest() *binance.OCORequest {
+	return &binance.OCORequest{
+		Symbol:         in.Symbol,
+		Side:           in.Side,
+		Quantity:       in.Quantity,
+		Price:          in.Price,
+		StopPrice:      in.StopPrice,
+		StopLimitPrice: in.StopLimitPrice,
+	}
+}
+
+// decodeOrderInput writes the error response and returns false when the order can't be submitted.
+func decodeOrderInput(w http.ResponseWriter, r *http.Request) (*orderInput, bool) {
+	in := &orderInput{}
+	if err := decodeJSONBody(r, in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_body", err.Error())
+		return nil, false
+	}
+	in.normalize()
+	if err := in.validate(); err != nil {
+		writeAPIErrorFrom(w, err)
+		return nil, false
+	}
+	if !in.Test && !in.Confirm {
+		writeAPIError(w, http.StatusPreconditionRequired, "confirmation_required", "set test to validate the order or confirm to place it")
+		return nil, false
+	}
+	return in, true
+}
+
+func (c *client) apiPlaceOrder(w http.ResponseWriter, r *http.Request) {
+	in, ok := decodeOrderInput(w, r)
+	if !ok {
+		return
+	}
+	accountID, err := c.resolveAccount("accountId", in.AccountID)
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+
+	result := &tradeResult{Test: in.Test}
+	if in.Type == orderTypeOCO {
+		result.OrderList, err = c.trader.PlaceOCO(usernameFromRequest(r), accountID, in.ocoRequest(), in.Test)
+	} else {
+		result.Order, err = c.trader.PlaceOrder(usernameFromRequest(r), accountID, in.orderRequest(), in.Test)
+	}
+	c.writeTradeResult(w, accountID, result, err)
+}
+
+func (c *client) apiCancelOrder(w http.ResponseWriter, r *http.Request) {
+	order, ok := c.orderFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if r.URL.Query().Get("confirm") != "true" {
+		writeAPIError(w, http.StatusPreconditionRequired, "confirmation_required", "set confirm=true to cancel the order")
+		return
+	}
+
+	canceled, err := c.trader.CancelOrder(usernameFromRequest(r), order.AccountID, order.Symbol, order.OrderID)
+	c.writeTradeResult(w, order.AccountID, &tradeResult{Canceled: canceled}, err)
+}
+
+func (c *client) apiReplaceOrder(w http.ResponseWriter, r *http.Request) {
+	order, ok := c.orderFromRequest(w, r)
+	if !ok {
+		return
+	}
+	in, ok := decodeOrderInput(w, r)
+	if !ok {
+		return
+	}
+	if in.Type == orderTypeOCO || in.Symbol != order.Symbol || (in.AccountID != "" && in.AccountID != order.AccountID) {
+		writeAPIError(w, http.StatusBadRequest, "invalid_body", "an order can only be replaced with a non OCO order of the same symbol and account")
+		return
+	}
+
+	res, err := c.trader.CancelReplaceOrder(usernameFromRequest(r), order.AccountID, order.OrderID, in.orderRequest(), in.Test)
+	result := &tradeResult{Test: in.Test}
+	if res != nil {
+		result.Canceled, result.Order = res.CancelResponse, res.NewOrderResponse
+	}
+	c.writeTradeResult(w, order.AccountID, result, err)
+}
+
+func (c *client) writeTradeResult(w http.ResponseWriter, accountID string, result *tradeResult, err error) {
+	var filterErrs binance.FilterErrors
+	var binErr *binance.APIError
+	switch {
+	case errors.As(err, &filterErrs):
+		vErr := &validationError{}
+		for _, f := range filterErrs {
+			vErr.add(f.Field, f.Message)
+		}
+		writeAPIErrorFrom(w, vErr)
+		return
+	case errors.Is(err, trader.ErrUnknownAccount):
+		vErr := &validationError{}
+		vErr.add("accountId", "trading is only available for Binance accounts")
+		writeAPIErrorFrom(w, vErr)
+		return
+	case errors.As(err, &binErr):
+		writeAPIError(w, http.StatusBadGateway, "binance_rejected", binErr.Error())
+		return
+	case err != nil:
+		writeAPIErrorFrom(w, err)
+		return
+	}
+
+	if !result.Test {
+		go c.refreshOrders(accountID)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// refreshOrders fetches the account after a trading action so the dashboards get the changes.
+func (c *client) refreshOrders(accountID string) {
+	if _, _, err := c.fetcher.FetchAccount(accountID); err != nil {
+		log.Println("failed to refresh orders after trading action: ", err)
+	}
+}
+
+func (c *client) apiListAudit(w http.ResponseWriter, r *http.Request) {
+	entries, err := c.db.GetAuditEntries()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	c.writeList(w, r, auditResource, entries)
+}
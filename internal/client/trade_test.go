@@ -0,0 +1,200 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/events"
+)
+
+type fakeTrader struct {
+	accounts []string
+	placed   []*binance.OrderRequest
+	test     []bool
+	canceled []int
+	err      error
+}
+
+func (t *fakeTrader) PlaceOrder(_, accountID string, req *binance.OrderRequest, test bool) (*binance.BinanceOrder, error) {
+	t.accounts = append(t.accounts, accountID)
+	t.placed = append(t.placed, req)
+	t.test = append(t.test, test)
+	if t.err != nil || test {
+		return nil, t.err
+	}
+	return &binance.BinanceOrder{Symbol: req.Symbol, OrderId: 10}, nil
+}
+
+func (t *fakeTrader) PlaceOCO(_, _ string, _ *binance.OCORequest, _ bool) (*binance.OrderList, error) {
+	return &binance.OrderList{OrderListID: 5}, t.err
+}
+
+func (t *fakeTrader) CancelOrder(_, accountID, _ string, orderID int) (*binance.BinanceOrder, error) {
+	t.accounts = append(t.accounts, accountID)
+	t.canceled = append(t.canceled, orderID)
+	return &binance.BinanceOrder{OrderId: orderID, Status: "CANCELED"}, t.err
+}
+
+func (t *fakeTrader) CancelReplaceOrder(_, _ string, orderID int, req *binance.OrderRequest, _ bool) (*binance.CancelReplaceResult, error) {
+	return &binance.CancelReplaceResult{CancelResponse: &binance.BinanceOrder{OrderId: orderID}, NewOrderResponse: &binance.BinanceOrder{OrderId: 11, Symbol: req.Symbol}}, t.err
+}
+
+type fakeFetcher struct{}
+
+func (f *fakeFetcher) Fetch() ([]*db.Order, []*db.Price, error) {
+	return nil, nil, nil
+}
+
+func (f *fakeFetcher) FetchAccount(_ string) ([]*db.Order, []*db.Price, error) {
+	return nil, nil, nil
+}
+
+// newTestTradeAPI creates the API with the main account and optional other accounts, order 3 belongs to main.
+func newTestTradeAPI(t *testing.T, tr *fakeTrader, otherAccounts ...string) http.Handler {
+	dbClient := db.NewMemoryClient()
+	if err := dbClient.SetOrders("main", []*db.Order{{Symbol: "BTCUSDT", OrderID: 3, Side: "BUY"}}); err != nil {
+		t.Fatal(err)
+	}
+	c := &client{db: dbClient, bus: events.NewBus(), trader: tr, fetcher: &fakeFetcher{}, accounts: append([]string{"main"}, otherAccounts...)}
+	r := mux.NewRouter()
+	c.registerAPI(r)
+	return r
+}
+
+func TestAPIPlaceOrderRequiresConfirmation(t *testing.T) {
+	tr := &fakeTrader{}
+	api := newTestTradeAPI(t, tr)
+	order := `{"symbol":"btcusdt","side":"buy","type":"LIMIT","quantity":"0.001","price":"50000"`
+
+	rec := doRequest(api, http.MethodPost, "/api/v1/orders", order+`}`)
+	if rec.Code != http.StatusPreconditionRequired || len(tr.placed) != 0 {
+		t.Fatalf("expected confirmation to be required, got %d: %s", rec.Code, rec.Body)
+	}
+
+	if rec = doRequest(api, http.MethodPost, "/api/v1/orders", order+`,"test":true}`); rec.Code != http.StatusOK || !tr.test[0] {
+		t.Fatalf("expected test order, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec = doRequest(api, http.MethodPost, "/api/v1/orders", order+`,"confirm":true}`); rec.Code != http.StatusOK || tr.test[1] {
+		t.Fatalf("expected placed order, got %d: %s", rec.Code, rec.Body)
+	}
+	result := &tradeResult{}
+	if err := json.Unmarshal(rec.Body.Bytes(), result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Order.OrderId != 10 || tr.placed[1].Symbol != "BTCUSDT" || tr.placed[1].Side != binance.SideBuy || tr.accounts[1] != "main" {
+		t.Fatalf("unexpected result %+v for request %+v", result.Order, tr.placed[1])
+	}
+}
+
+func TestAPIPlaceOrderErrors(t *testing.T) {
+	tr := &fakeTrader{err: binance.FilterErrors{{Field: "price", Filter: binance.FilterTypePrice, Message: "should be a multiple of 0.01"}}}
+	api := newTestTradeAPI(t, tr)
+	rec := doRequest(api, http.MethodPost, "/api/v1/orders", `{"symbol":"BTCUSDT","side":"BUY","type":"LIMIT","quantity":"1","price":"1.001","test":true}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected filter errors as validation errors, got %d: %s", rec.Code, rec.Body)
+	}
+
+	tr.err = &binance.APIError{Code: -2010, Message: "Account has insufficient balance"}
+	rec = doRequest(api, http.MethodPost, "/api/v1/orders", `{"symbol":"BTCUSDT","side":"BUY","type":"MARKET","quantity":"1","confirm":true}`)
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected Binance rejection, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = doRequest(api, http.MethodPost, "/api/v1/orders", `{"symbol":"BTCUSDT","side":"HOLD","type":"OCO","quantity":"1","price":"2","confirm":true}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected validation error, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestAPICancelOrder(t *testing.T) {
+	tr := &fakeTrader{}
+	api := newTestTradeAPI(t, tr)
+	if rec := doRequest(api, http.MethodDelete, "/api/v1/orders/3?symbol=BTCUSDT", ""); rec.Code != http.StatusPreconditionRequired {
+		t.Fatalf("expected confirmation to be required, got %d", rec.Code)
+	}
+	if rec := doRequest(api, http.MethodDelete, "/api/v1/orders/4?symbol=BTCUSDT&confirm=true", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown order, got %d", rec.Code)
+	}
+	if rec := doRequest(api, http.MethodDelete, "/api/v1/orders/3?symbol=BTCUSDT&confirm=true", ""); rec.Code != http.StatusOK || len(tr.canceled) != 1 || tr.accounts[0] != "main" {
+		t.Fatalf("expected order to be canceled in its account, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestAPIOrdersSharingID(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	if err := dbClient.SetOrders("main", []*db.Order{{Symbol: "BTCUSDT", OrderID: 3}, {Symbol: "ETHUSDT", OrderID: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbClient.SetOrders("sub", []*db.Order{{Symbol: "BTCUSDT", OrderID: 3}, {Symbol: "XRPUSDT", OrderID: 7}, {Symbol: "XRPUSDT", OrderID: 7}}); err != nil {
+		t.Fatal(err)
+	}
+	tr := &fakeTrader{}
+	c := &client{db: dbClient, bus: events.NewBus(), trader: tr, fetcher: &fakeFetcher{}, accounts: []string{"main", "sub"}}
+	api := mux.NewRouter()
+	c.registerAPI(api)
+
+	for _, tc := range []struct {
+		target string
+		status int
+	}{
+		{target: "/api/v1/orders/3?confirm=true", status: http.StatusBadRequest},
+		{target: "/api/v1/orders/3?symbol=BTCUSDT&confirm=true", status: http.StatusUnprocessableEntity},
+		{target: "/api/v1/orders/3?symbol=XRPUSDT&accountId=sub&confirm=true", status: http.StatusNotFound},
+		{target: "/api/v1/orders/7?symbol=XRPUSDT&accountId=sub&confirm=true", status: http.StatusConflict},
+	} {
+		if rec := doRequest(api, http.MethodDelete, tc.target, ""); rec.Code != tc.status {
+			t.Fatalf("%s: got %d, want %d: %s", tc.target, rec.Code, tc.status, rec.Body)
+		}
+	}
+	if len(tr.canceled) != 0 {
+		t.Fatalf("no order should be canceled, got %v", tr.canceled)
+	}
+
+	rec := doRequest(api, http.MethodGet, "/api/v1/orders/3?symbol=ethusdt&accountId=main", "")
+	order := &db.Order{}
+	if err := json.Unmarshal(rec.Body.Bytes(), order); err != nil || order.Symbol != "ETHUSDT" || order.AccountID != "main" {
+		t.Fatalf("unexpected order %d: %s", rec.Code, rec.Body)
+	}
+	if rec = doRequest(api, http.MethodDelete, "/api/v1/orders/3?symbol=BTCUSDT&accountId=sub&confirm=true", ""); rec.Code != http.StatusOK || tr.accounts[0] != "sub" {
+		t.Fatalf("expected the order of the sub account to be canceled, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestAPIPlaceOrderAccount(t *testing.T) {
+	tr := &fakeTrader{}
+	api := newTestTradeAPI(t, tr, "sub")
+	order := `{"symbol":"BTCUSDT","side":"BUY","type":"MARKET","quantity":"1","confirm":true`
+
+	if rec := doRequest(api, http.MethodPost, "/api/v1/orders", order+`}`); rec.Code != http.StatusUnprocessableEntity || len(tr.placed) != 0 {
+		t.Fatalf("expected account to be required with several accounts, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := doRequest(api, http.MethodPost, "/api/v1/orders", order+`,"accountId":"other"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected unknown account error, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := doRequest(api, http.MethodPost, "/api/v1/orders", order+`,"accountId":"Sub"}`); rec.Code != http.StatusOK || tr.accounts[0] != "sub" {
+		t.Fatalf("expected order in sub account, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := doRequest(api, http.MethodPost, "/api/v1/orders/3/replace?symbol=BTCUSDT&accountId=main", `{"symbol":"BTCUSDT","side":"BUY","type":"LIMIT","quantity":"1","price":"2","confirm":true,"accountId":"sub"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("order should not be replaced in another account, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestAPIReplaceOrder(t *testing.T) {
+	api := newTestTradeAPI(t, &fakeTrader{})
+	rec := doRequest(api, http.MethodPost, "/api/v1/orders/3/replace?symbol=BTCUSDT", `{"symbol":"ETHUSDT","side":"BUY","type":"LIMIT","quantity":"1","price":"2","confirm":true}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected symbol mismatch error, got %d", rec.Code)
+	}
+	rec = doRequest(api, http.MethodPost, "/api/v1/orders/3/replace?symbol=BTCUSDT", `{"symbol":"BTCUSDT","side":"BUY","type":"LIMIT","quantity":"1","price":"2","confirm":true}`)
+	result := &tradeResult{}
+	if err := json.Unmarshal(rec.Body.Bytes(), result); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("expected order to be replaced, got %d: %s", rec.Code, rec.Body)
+	}
+	if result.Canceled.OrderId != 3 || result.Order.OrderId != 11 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
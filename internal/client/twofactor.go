@@ -0,0 +1,360 @@
+package client
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/totp"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	loginPath  = "/login"
+	totpIssuer = "Binance Orders Watcher"
+	qrCodeSize = 256
+)
+
+type LoginPageTemplateData struct {
+	Username string
+	Next     string
+	NeedCode bool
+	Error    string
+}
+
+type TwoFactorPageTemplateData struct {
+	AppURI      string
+	AppSchema   string
+	AppPort     string
+	Username    string
+	TOTPEnabled bool
+	Nonce       string
+	CSRFToken   string
+}
+
+// twoFactorSetup is the enrolment secret, the QR code is a PNG data URI of the otpauth URI.
+type twoFactorSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	QRCode string `json:"qrCode"`
+}
+
+type recoveryCodesResult struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// codeInput is a code from the authenticator app or a recovery code.
+type codeInput struct {
+	Code string `json:"code"`
+}
+
+func (c *client) loginPageHandler(w http.ResponseWriter, r *http.Request) {
+	c.renderLogin(w, http.StatusOK, &LoginPageTemplateData{Next: r.URL.Query().Get("next")})
+}
+
+// loginHandler checks the password and, when the user enrolled, the second factor, then starts a session.
+func (c *client) loginHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	data := &LoginPageTemplateData{Username: r.PostForm.Get("username"), Next: r.PostForm.Get("next")}
+	ip := c.clientIP(r)
+	key := lockoutKey(ip)
+	if wait := c.limiter.Locked(key); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds()+1)))
+		data.Error = fmt.Sprintf("Too many failed attempts, try again in %s.", wait.Round(time.Second))
+		c.renderLogin(w, http.StatusTooManyRequests, data)
+		return
+	}
+
+	user, err := c.authenticate(data.Username, r.PostForm.Get("password"))
+	if err != nil {
+		log.Println("login failed, ", err)
+	}
+	if user == nil {
+		c.authFailed(key)
+		data.Error = "Invalid username or password."
+		c.renderLogin(w, http.StatusUnauthorized, data)
+		return
+	}
+	if user.TOTPEnabled {
+		data.NeedCode = true
+		code := r.PostForm.Get("code")
+		if code == "" {
+			data.Error = "Enter the code from your authenticator app or a recovery code."
+			c.renderLogin(w, http.StatusUnauthorized, data)
+			return
+		}
+		if err = c.verifySecondFactor(user, code); err != nil {
+			log.Printf("second factor of user %s failed, %s", user.Username, err)
+			c.authFailed(key)
+			data.Error = "Invalid two-factor code."
+			c.renderLogin(w, http.StatusUnauthorized, data)
+			return
+		}
+	}
+
+	c.limiter.Reset(key)
+	if err = c.startSession(w, r, user); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	c.alertManager.SendAlert(c.authReqAlertAdminEmail, c.authReqAlertAdminName, fmt.Sprintf("user %s with IP = %s successfully logged into the system", user.Username, ip))
+	http.Redirect(w, r, safeRedirect(data.Next), http.StatusSeeOther)
+}
+
+func (c *client) renderLogin(w http.ResponseWriter, status int, data *LoginPageTemplateData) {
+	tmpl, err := template.ParseFiles("./internal/client/templates/login.html")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err = tmpl.Execute(w, data); err != nil {
+		log.Println("failed to render login page: ", err)
+	}
+}
+
+// safeRedirect only allows redirects to paths of this app.
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") || next == loginPath {
+		return "/"
+	}
+	return next
+}
+
+// verifySecondFactor accepts an authenticator app code or an unused recovery code and stores that it was used.
+func (c *client) verifySecondFactor(user *db.User, code string) error {
+	step, err := c.totp.Verify(user.TOTPSecret, code, user.TOTPLastStep)
+	if err == nil {
+		user.TOTPLastStep = step
+		return c.db.UpdateUser(user)
+	}
+	hash := totp.HashRecoveryCode(code)
+	for i, h := range user.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			user.RecoveryCodes = append(user.RecoveryCodes[:i:i], user.RecoveryCodes[i+1:]...)
+			log.Printf("user %s used a recovery code, %d left", user.Username, len(user.RecoveryCodes))
+			return c.db.UpdateUser(user)
+		}
+	}
+	return err
+}
+
+func (c *client) twoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := template.ParseFiles("./internal/client/templates/twofactor.html")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	user := userFromRequest(r)
+	data := &TwoFactorPageTemplateData{
+		AppURI:      c.appUri,
+		AppSchema:   c.appSchema,
+		AppPort:     c.appPort,
+		Username:    user.Username,
+		TOTPEnabled: user.TOTPEnabled,
+		Nonce:       cspNonce(r),
+		CSRFToken:   c.csrfToken(r),
+	}
+	if err = tmpl.Execute(w, data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+	}
+}
+
+// apiSetupTwoFactor generates a new secret for the current user, it is used once a code is confirmed.
+func (c *client) apiSetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user := userFromRequest(r)
+	if user.TOTPEnabled {
+		writeAPIError(w, http.StatusConflict, "conflict", "two-factor authentication is already enabled")
+		return
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	uri := totp.KeyURI(totpIssuer, user.Username, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, qrCodeSize)
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	user.TOTPSecret = secret
+	user.UpdatedAt = time.Now().UnixMilli()
+	if err = c.db.UpdateUser(user); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &twoFactorSetup{
+		Secret: secret,
+		URI:    uri,
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+// apiEnableTwoFactor confirms the enrolment with a code of the new secret and returns the recovery codes.
+func (c *client) apiEnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	key := lockoutKey(c.clientIP(r))
+	if wait := c.limiter.Locked(key); wait > 0 {
+		writeLockedOut(w, r, wait)
+		return
+	}
+	in, ok := decodeCode(w, r)
+	if !ok {
+		return
+	}
+	user := userFromRequest(r)
+	if user.TOTPEnabled {
+		writeAPIError(w, http.StatusConflict, "conflict", "two-factor authentication is already enabled")
+		return
+	}
+	if user.TOTPSecret == "" {
+		writeAPIError(w, http.StatusConflict, "conflict", "two-factor setup is not started")
+		return
+	}
+	step, err := c.totp.Verify(user.TOTPSecret, in.Code, user.TOTPLastStep)
+	if err != nil {
+		c.codeFailed(w, key, err)
+		return
+	}
+	c.limiter.Reset(key)
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+	c.writeNewRecoveryCodes(w, user)
+}
+
+// apiDisableTwoFactor turns two-factor authentication off for the current user.
+func (c *client) apiDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	key := lockoutKey(c.clientIP(r))
+	if wait := c.limiter.Locked(key); wait > 0 {
+		writeLockedOut(w, r, wait)
+		return
+	}
+	in, ok := decodeCode(w, r)
+	if !ok {
+		return
+	}
+	user := userFromRequest(r)
+	if !user.TOTPEnabled {
+		writeAPIError(w, http.StatusConflict, "conflict", "two-factor authentication is not enabled")
+		return
+	}
+	if err := c.verifySecondFactor(user, in.Code); err != nil {
+		c.codeFailed(w, key, err)
+		return
+	}
+	c.limiter.Reset(key)
+	c.resetTwoFactor(w, user)
+}
+
+// apiRegenerateRecoveryCodes replaces the recovery codes of the current user.
+func (c *client) apiRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	key := lockoutKey(c.clientIP(r))
+	if wait := c.limiter.Locked(key); wait > 0 {
+		writeLockedOut(w, r, wait)
+		return
+	}
+	in, ok := decodeCode(w, r)
+	if !ok {
+		return
+	}
+	user := userFromRequest(r)
+	if !user.TOTPEnabled {
+		writeAPIError(w, http.StatusConflict, "conflict", "two-factor authentication is not enabled")
+		return
+	}
+	if err := c.verifySecondFactor(user, in.Code); err != nil {
+		c.codeFailed(w, key, err)
+		return
+	}
+	c.limiter.Reset(key)
+	c.writeNewRecoveryCodes(w, user)
+}
+
+// apiResetUserTwoFactor lets admins turn two-factor authentication off for users who lost their device.
+func (c *client) apiResetUserTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.userForUpdate(w, r)
+	if !ok {
+		return
+	}
+	log.Printf("user %s resets two-factor authentication of %s", usernameFromRequest(r), user.Username)
+	c.resetTwoFactor(w, user)
+}
+
+func (c *client) resetTwoFactor(w http.ResponseWriter, user *db.User) {
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = nil
+	user.UpdatedAt = time.Now().UnixMilli()
+	if err := c.db.UpdateUser(user); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (c *client) writeNewRecoveryCodes(w http.ResponseWriter, user *db.User) {
+	codes, hashes, err := totp.GenerateRecoveryCodes()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	user.RecoveryCodes = hashes
+	user.UpdatedAt = time.Now().UnixMilli()
+	if err = c.db.UpdateUser(user); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &recoveryCodesResult{RecoveryCodes: codes})
+}
+
+func decodeCode(w http.ResponseWriter, r *http.Request) (*codeInput, bool) {
+	in := &codeInput{}
+	if err := decodeJSONBody(r, in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_body", err.Error())
+		return nil, false
+	}
+	if strings.TrimSpace(in.Code) == "" {
+		errs := &validationError{}
+		errs.add("code", "is required")
+		writeAPIErrorFrom(w, errs)
+		return nil, false
+	}
+	return in, true
+}
+
+// codeFailed counts a wrong code like a failed login, so a stolen session can not be used to guess codes.
+func (c *client) codeFailed(w http.ResponseWriter, key string, err error) {
+	if errors.Is(err, totp.ErrInvalidCode) || errors.Is(err, totp.ErrReusedCode) {
+		c.authFailed(key)
+	}
+	writeCodeError(w, err)
+}
+
+func writeCodeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, totp.ErrInvalidCode) || errors.Is(err, totp.ErrReusedCode) {
+		errs := &validationError{}
+		errs.add("code", err.Error())
+		writeAPIErrorFrom(w, errs)
+		return
+	}
+	writeAPIErrorFrom(w, err)
+}
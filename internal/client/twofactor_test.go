@@ -0,0 +1,291 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/ratelimit"
+	"github.com/morzhanov/binance-orders-watcher/internal/totp"
+)
+
+type fakeAlertManager struct {
+	sent []string
+}
+
+func (m *fakeAlertManager) SendAlert(_, _, text string) error {
+	m.sent = append(m.sent, text)
+	return nil
+}
+
+// chdirToRoot runs the test from the repository root, page handlers load templates relative to it.
+func chdirToRoot(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir("../.."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func newTestRouter(t *testing.T, dbClient db.Client) *client {
+	return New("", "", "test-secret", DefaultSessionIdleTimeout, testTrustedProxies, "localhost", "http", "8080", "Admin", "admin@example.com", dbClient, nil, nil, &fakeAlertManager{}, nil, nil, nil, nil, newTestExchangeInfo(), []string{db.DefaultAccountID}).(*client)
+}
+
+// testTrustedProxies trusts the remote address of httptest requests, so tests set the client IP with X-Real-Ip.
+var testTrustedProxies, _ = ParseNetworks("192.0.2.1")
+
+func addTestUser(t *testing.T, dbClient db.Client, username, password string) *db.User {
+	hash, err := hashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &db.User{ID: username + "-id", Username: username, PasswordHash: hash, Role: db.RoleTrader, Email: username + "@example.com"}
+	if err = dbClient.AddUser(user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+var testIPs = 0
+
+func postLogin(c *client, form url.Values) *httptest.ResponseRecorder {
+	testIPs++
+	req := httptest.NewRequest(http.MethodPost, loginPath, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Real-Ip", fmt.Sprintf("10.0.0.%d", testIPs))
+	rec := httptest.NewRecorder()
+	c.r.ServeHTTP(rec, req)
+	return rec
+}
+
+func authCookie(t *testing.T, rec *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == AuthCookieName {
+			return cookie
+		}
+	}
+	t.Fatalf("auth cookie is not set, status %d: %s", rec.Code, rec.Body.String())
+	return nil
+}
+
+func TestLoginRedirectsAnonymousRequests(t *testing.T) {
+	c := newTestRouter(t, db.NewMemoryClient())
+
+	rec := httptest.NewRecorder()
+	c.r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/simulator?x=1", nil))
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/login?next=%2Fsimulator%3Fx%3D1" {
+		t.Fatalf("expected redirect to the login form, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec.Header().Get("WWW-Authenticate") != "" {
+		t.Fatal("browsers should not get the basic auth prompt")
+	}
+
+	rec = httptest.NewRecorder()
+	c.r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/alerts", nil))
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), `"unauthorized"`) {
+		t.Fatalf("unexpected API response %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestLoginWithTwoFactor(t *testing.T) {
+	chdirToRoot(t)
+	dbClient := db.NewMemoryClient()
+	user := addTestUser(t, dbClient, "alice", "alice-password")
+	secret, _ := totp.GenerateSecret()
+	codes, hashes, _ := totp.GenerateRecoveryCodes()
+	user.TOTPSecret, user.TOTPEnabled, user.RecoveryCodes = secret, true, hashes
+	if err := dbClient.UpdateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestRouter(t, dbClient)
+
+	form := url.Values{"username": {"alice"}, "password": {"wrong-password"}}
+	if rec := postLogin(c, form); rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "Invalid username or password") {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+	form.Set("password", "alice-password")
+	rec := postLogin(c, form)
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), `name="code"`) {
+		t.Fatalf("expected the code to be asked, got %d: %s", rec.Code, rec.Body.String())
+	}
+	form.Set("code", "000000")
+	if rec = postLogin(c, form); rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "Invalid two-factor code") {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+
+	code, _ := totp.CodeAt(secret, time.Now())
+	form.Set("code", code)
+	form.Set("next", "/simulator")
+	rec = postLogin(c, form)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/simulator" {
+		t.Fatalf("unexpected response %d %s: %s", rec.Code, rec.Header().Get("Location"), rec.Body.String())
+	}
+	cookie := authCookie(t, rec)
+	if rec = postLogin(c, form); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a used code to be rejected, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/alerts", nil)
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	c.r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the session to be accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// recovery codes work once
+	form.Set("code", codes[3])
+	form.Set("next", "https://evil.example.com")
+	rec = postLogin(c, form)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/" {
+		t.Fatalf("unexpected response %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec = postLogin(c, form); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a used recovery code to be rejected, got %d", rec.Code)
+	}
+	stored, _ := dbClient.GetUser(user.ID)
+	if len(stored.RecoveryCodes) != totp.RecoveryCodes-1 {
+		t.Fatalf("expected the recovery code to be consumed, %d left", len(stored.RecoveryCodes))
+	}
+
+	// basic auth skips the second factor so it is not accepted for enrolled users
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/alerts", nil)
+	req.SetBasicAuth("alice", "alice-password")
+	rec = httptest.NewRecorder()
+	c.r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected basic auth to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestLoginWithoutTwoFactor(t *testing.T) {
+	chdirToRoot(t)
+	dbClient := db.NewMemoryClient()
+	addTestUser(t, dbClient, "bob", "bob-password")
+	c := newTestRouter(t, dbClient)
+
+	rec := postLogin(c, url.Values{"username": {"bob"}, "password": {"bob-password"}})
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/" {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+	authCookie(t, rec)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/alerts", nil)
+	req.SetBasicAuth("bob", "bob-password")
+	rec = httptest.NewRecorder()
+	c.r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected basic auth to be accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAPITwoFactorEnrolment(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	user := addTestUser(t, dbClient, "carol", "carol-password")
+	c := &client{db: dbClient, totp: totp.New(), limiter: ratelimit.New(3, time.Minute, time.Minute, time.Hour), alertManager: &fakeAlertManager{}}
+	r := mux.NewRouter()
+	c.registerAPI(r)
+
+	if rec := doRequestAs(r, user, http.MethodPost, "/api/v1/me/2fa/enable", `{"code":"123456"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("expected enable before setup to fail, got %d", rec.Code)
+	}
+	rec := doRequestAs(r, user, http.MethodPost, "/api/v1/me/2fa/setup", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	setup := &twoFactorSetup{}
+	if err := json.Unmarshal(rec.Body.Bytes(), setup); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(setup.QRCode, "data:image/png;base64,") || !strings.Contains(setup.URI, "secret="+setup.Secret) {
+		t.Fatalf("unexpected setup %+v", setup)
+	}
+
+	user, _ = dbClient.GetUser(user.ID)
+	if rec = doRequestAs(r, user, http.MethodPost, "/api/v1/me/2fa/enable", `{"code":"abcdef"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected invalid code to fail, got %d", rec.Code)
+	}
+	code, _ := totp.CodeAt(setup.Secret, time.Now())
+	rec = doRequestAs(r, user, http.MethodPost, "/api/v1/me/2fa/enable", `{"code":"`+code+`"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	codes := &recoveryCodesResult{}
+	if err := json.Unmarshal(rec.Body.Bytes(), codes); err != nil {
+		t.Fatal(err)
+	}
+	user, _ = dbClient.GetUser(user.ID)
+	if !user.TOTPEnabled || len(codes.RecoveryCodes) != totp.RecoveryCodes || len(user.RecoveryCodes) != totp.RecoveryCodes {
+		t.Fatalf("unexpected user after enabling %+v, codes %v", user, codes.RecoveryCodes)
+	}
+	if strings.Contains(rec.Body.String(), user.RecoveryCodes[0]) {
+		t.Fatal("recovery code hashes should not be returned")
+	}
+
+	rec = doRequestAs(r, user, http.MethodPost, "/api/v1/me/2fa/disable", `{"code":"`+codes.RecoveryCodes[0]+`"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	user, _ = dbClient.GetUser(user.ID)
+	if user.TOTPEnabled || user.TOTPSecret != "" || len(user.RecoveryCodes) != 0 {
+		t.Fatalf("unexpected user after disabling %+v", user)
+	}
+}
+
+func TestAPITwoFactorCodesAreRateLimited(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	user := addTestUser(t, dbClient, "dave", "dave-password")
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.TOTPEnabled = true
+	user.TOTPSecret = secret
+	if err = dbClient.UpdateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	alerts := &fakeAlertManager{}
+	c := &client{db: dbClient, totp: totp.New(), limiter: ratelimit.New(2, time.Minute, time.Minute, time.Hour), alertManager: alerts}
+	r := mux.NewRouter()
+	c.registerAPI(r)
+
+	for _, path := range []string{"/api/v1/me/2fa/disable", "/api/v1/me/2fa/recovery-codes"} {
+		if rec := doRequestAs(r, user, http.MethodPost, path, `{"code":"000000"}`); rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%s: expected invalid code to fail, got %d", path, rec.Code)
+		}
+	}
+	code, _ := totp.CodeAt(secret, time.Now())
+	rec := doRequestAs(r, user, http.MethodPost, "/api/v1/me/2fa/disable", `{"code":"`+code+`"}`)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" || len(alerts.sent) != 1 {
+		t.Fatalf("expected the IP to be locked out after wrong codes, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if user, _ = dbClient.GetUser(user.ID); !user.TOTPEnabled {
+		t.Fatal("two-factor authentication should stay enabled while locked out")
+	}
+}
+
+func TestSafeRedirect(t *testing.T) {
+	for next, want := range map[string]string{
+		"":                 "/",
+		"/simulator":       "/simulator",
+		"//evil.com":       "/",
+		"/\\evil.com":      "/",
+		"https://evil.com": "/",
+		"/login":           "/",
+	} {
+		if got := safeRedirect(next); got != want {
+			t.Fatalf("safeRedirect(%q) = %q, want %q", next, got, want)
+		}
+	}
+}
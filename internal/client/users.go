@@ -0,0 +1,397 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const minPasswordLength = 8
+
+var (
+	usernameRegexp = regexp.MustCompile(`^[a-z0-9_.-]{3,32}$`)
+	// roleRanks orders roles, a user can access routes of its own and lower ranked roles.
+	roleRanks = map[string]int{db.RoleViewer: 1, db.RoleTrader: 2, db.RoleAdmin: 3}
+)
+
+type UsersPageTemplateData struct {
+	AppURI    string
+	AppSchema string
+	AppPort   string
+	Username  string
+	Roles     []string
+	Nonce     string
+	CSRFToken string
+}
+
+// userInput is the request body to create a user.
+type userInput struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+}
+
+// userPatch is the PATCH body, only provided fields are changed and an empty password is not allowed.
+type userPatch struct {
+	Password *string `json:"password"`
+	Role     *string `json:"role"`
+	Name     *string `json:"name"`
+	Email    *string `json:"email"`
+}
+
+func (in *userInput) normalize() {
+	in.Username = strings.ToLower(strings.TrimSpace(in.Username))
+	in.Role = strings.ToLower(strings.TrimSpace(in.Role))
+	in.Name = strings.TrimSpace(in.Name)
+	in.Email = strings.TrimSpace(in.Email)
+}
+
+func (in *userInput) validate() error {
+	errs := &validationError{}
+	if !usernameRegexp.MatchString(in.Username) {
+		errs.add("username", "should be 3 to 32 lowercase letters, digits, dots, dashes or underscores")
+	}
+	validatePassword(errs, in.Password)
+	validateUserFields(errs, in.Role, in.Name, in.Email)
+	return errs.orNil()
+}
+
+func validatePassword(errs *validationError, password string) {
+	if len(password) < minPasswordLength {
+		errs.add("password", fmt.Sprintf("should be at least %d characters long", minPasswordLength))
+	}
+	// bcrypt ignores everything after 72 bytes
+	if len(password) > 72 {
+		errs.add("password", "should be at most 72 bytes long")
+	}
+}
+
+func validateUserFields(errs *validationError, role, name, email string) {
+	if _, ok := roleRanks[role]; !ok {
+		errs.add("role", fmt.Sprintf("should be one of %s, %s, %s", db.RoleAdmin, db.RoleTrader, db.RoleViewer))
+	}
+	if len(name) > maxNameLength {
+		errs.add("name", fmt.Sprintf("should be at most %d characters long", maxNameLength))
+	}
+	if email != "" {
+		if _, err := mail.ParseAddress(email); err != nil {
+			errs.add("email", "should be a valid email address")
+		}
+	}
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// bootstrapAdmin creates an admin from the basic auth credentials when there are no users yet,
+// so existing installations keep their login after upgrading.
+func (c *client) bootstrapAdmin() error {
+	users, err := c.db.GetUsers()
+	if err != nil {
+		return err
+	}
+	if len(users) > 0 {
+		return nil
+	}
+	if c.authUsername == "" || c.authPassword == "" {
+		return errors.New("there are no users, set BASE_AUTH_USERNAME and BASE_AUTH_PASSWORD to create the first admin")
+	}
+	hash, err := hashPassword(c.authPassword)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixMilli()
+	user := &db.User{
+		ID:           uuid.NewString(),
+		Username:     strings.ToLower(c.authUsername),
+		PasswordHash: hash,
+		Role:         db.RoleAdmin,
+		Name:         c.authReqAlertAdminName,
+		Email:        c.authReqAlertAdminEmail,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err = c.db.AddUser(user); err != nil {
+		return err
+	}
+	log.Printf("created admin user %s", user.Username)
+	return nil
+}
+
+// authenticate returns the user with the username and password or nil when they don't match.
+func (c *client) authenticate(username, password string) (*db.User, error) {
+	user, err := c.db.GetUserByUsername(strings.ToLower(username))
+	if err != nil || user == nil {
+		return nil, err
+	}
+	if err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, nil
+	}
+	return user, nil
+}
+
+const userContextKey contextKey = "user"
+
+func withUser(r *http.Request, user *db.User) *http.Request {
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	return r.WithContext(context.WithValue(ctx, usernameContextKey, user.Username))
+}
+
+func userFromRequest(r *http.Request) *db.User {
+	user, _ := r.Context().Value(userContextKey).(*db.User)
+	return user
+}
+
+func hasRole(user *db.User, role string) bool {
+	return user != nil && roleRanks[user.Role] >= roleRanks[role]
+}
+
+// requireRole only calls h for users with the role or a higher ranked one.
+func requireRole(role string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if hasRole(userFromRequest(r), role) {
+			h(w, r)
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, apiPrefix+"/") {
+			writeAPIError(w, http.StatusForbidden, "forbidden", "the "+role+" role is required")
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("Forbidden.\n"))
+	}
+}
+
+// canAccessAlert tells if the request user can see and change the alert: admins can access every alert,
+// other users only their own.
+func canAccessAlert(r *http.Request, alert *db.Alert) bool {
+	user := userFromRequest(r)
+	return hasRole(user, db.RoleAdmin) || (user != nil && alert.UserID == user.ID)
+}
+
+func visibleAlerts(r *http.Request, alerts []*db.Alert) []*db.Alert {
+	visible := make([]*db.Alert, 0, len(alerts))
+	for _, a := range alerts {
+		if canAccessAlert(r, a) {
+			visible = append(visible, a)
+		}
+	}
+	return visible
+}
+
+func (c *client) apiListUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := c.db.GetUsers()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	c.writeList(w, r, usersResource, users)
+}
+
+func (c *client) apiGetUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.userForUpdate(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (c *client) apiCreateUser(w http.ResponseWriter, r *http.Request) {
+	in := &userInput{}
+	if err := decodeJSONBody(r, in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_body", err.Error())
+		return
+	}
+	in.normalize()
+	if err := in.validate(); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	existing, err := c.db.GetUserByUsername(in.Username)
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	if existing != nil {
+		writeAPIError(w, http.StatusConflict, "conflict", "username is already taken")
+		return
+	}
+	hash, err := hashPassword(in.Password)
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	now := time.Now().UnixMilli()
+	user := &db.User{
+		ID:           uuid.NewString(),
+		Username:     in.Username,
+		PasswordHash: hash,
+		Role:         in.Role,
+		Name:         in.Name,
+		Email:        in.Email,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err = c.db.AddUser(user); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	w.Header().Set("Location", apiPrefix+"/users/"+user.ID)
+	writeJSON(w, http.StatusCreated, user)
+}
+
+func (c *client) apiPatchUser(w http.ResponseWriter, r *http.Request) {
+	patch := &userPatch{}
+	if err := decodeJSONBody(r, patch); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_body", err.Error())
+		return
+	}
+	user, ok := c.userForUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	role, name, email := user.Role, user.Name, user.Email
+	if patch.Role != nil {
+		role = strings.ToLower(strings.TrimSpace(*patch.Role))
+	}
+	if patch.Name != nil {
+		name = strings.TrimSpace(*patch.Name)
+	}
+	if patch.Email != nil {
+		email = strings.TrimSpace(*patch.Email)
+	}
+	errs := &validationError{}
+	if patch.Password != nil {
+		validatePassword(errs, *patch.Password)
+	}
+	validateUserFields(errs, role, name, email)
+	if err := errs.orNil(); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	if user.Role == db.RoleAdmin && role != db.RoleAdmin {
+		if ok = c.checkNotLastAdmin(w, user); !ok {
+			return
+		}
+	}
+
+	if patch.Password != nil {
+		hash, err := hashPassword(*patch.Password)
+		if err != nil {
+			writeAPIErrorFrom(w, err)
+			return
+		}
+		user.PasswordHash = hash
+	}
+	user.Role, user.Name, user.Email = role, name, email
+	user.UpdatedAt = time.Now().UnixMilli()
+	if err := c.db.UpdateUser(user); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeAPIError(w, http.StatusNotFound, "not_found", "user not found")
+			return
+		}
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	// sessions started with the old password are logged out
+	if patch.Password != nil {
+		if err := c.db.DeleteUserSessions(user.ID); err != nil {
+			writeAPIErrorFrom(w, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (c *client) apiDeleteUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.userForUpdate(w, r)
+	if !ok {
+		return
+	}
+	if current := userFromRequest(r); current != nil && current.ID == user.ID {
+		writeAPIError(w, http.StatusConflict, "conflict", "you can't delete yourself")
+		return
+	}
+	if user.Role == db.RoleAdmin && !c.checkNotLastAdmin(w, user) {
+		return
+	}
+	if err := c.db.DeleteUser(user.ID); err != nil {
+		writeAPIErrorFrom(w, err)
+		return
+	}
+	// alerts of the user are deleted with it
+	c.publishAlerts()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *client) userForUpdate(w http.ResponseWriter, r *http.Request) (*db.User, bool) {
+	user, err := c.db.GetUser(mux.Vars(r)["id"])
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return nil, false
+	}
+	if user == nil {
+		writeAPIError(w, http.StatusNotFound, "not_found", "user not found")
+		return nil, false
+	}
+	return user, true
+}
+
+// checkNotLastAdmin writes a conflict when the admin user is the only one, nobody could manage users without it.
+func (c *client) checkNotLastAdmin(w http.ResponseWriter, user *db.User) bool {
+	users, err := c.db.GetUsers()
+	if err != nil {
+		writeAPIErrorFrom(w, err)
+		return false
+	}
+	for _, u := range users {
+		if u.ID != user.ID && u.Role == db.RoleAdmin {
+			return true
+		}
+	}
+	writeAPIError(w, http.StatusConflict, "conflict", "at least one admin is required")
+	return false
+}
+
+func (c *client) usersHandler(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := template.ParseFiles("./internal/client/templates/users.html")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	data := &UsersPageTemplateData{
+		AppURI:    c.appUri,
+		AppSchema: c.appSchema,
+		AppPort:   c.appPort,
+		Username:  usernameFromRequest(r),
+		Roles:     []string{db.RoleViewer, db.RoleTrader, db.RoleAdmin},
+		Nonce:     cspNonce(r),
+		CSRFToken: c.csrfToken(r),
+	}
+	if err = tmpl.Execute(w, data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+	}
+}
@@ -0,0 +1,191 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+func TestAPIRoles(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	viewer := &db.User{ID: "v", Username: "viewer", Role: db.RoleViewer}
+	trader := &db.User{ID: "t", Username: "trader", Role: db.RoleTrader, Email: "trader@example.com"}
+	h := newTestAPI(t, dbClient)
+
+	if rec := doRequestAs(h, nil, http.MethodGet, "/api/v1/alerts", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected anonymous requests to be forbidden, got %d", rec.Code)
+	}
+	if rec := doRequestAs(h, viewer, http.MethodGet, "/api/v1/alerts", ""); rec.Code != http.StatusOK {
+		t.Fatalf("expected viewer to list alerts, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec := doRequestAs(h, viewer, http.MethodPost, "/api/v1/alerts", `{"symbol":"BTCUSDT","price":"40000"}`)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), `"forbidden"`) {
+		t.Fatalf("expected viewer to be forbidden to create alerts, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = doRequestAs(h, trader, http.MethodGet, "/api/v1/users", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected trader to be forbidden to list users, got %d", rec.Code)
+	}
+
+	// trader alerts are sent to the trader email
+	rec = doRequestAs(h, trader, http.MethodPost, "/api/v1/alerts", `{"symbol":"BTCUSDT","price":"40000"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	created := &db.Alert{}
+	if err := json.Unmarshal(rec.Body.Bytes(), created); err != nil {
+		t.Fatal(err)
+	}
+	if created.UserID != trader.ID {
+		t.Fatalf("alert should be owned by the trader: %+v", created)
+	}
+	if rec = doRequestAs(h, viewer, http.MethodPost, "/api/v1/alerts", `{"symbol":"BTCUSDT","price":"40000"}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	if rec = doRequest(h, http.MethodPost, "/api/v1/alerts", `{"symbol":"ETHUSDT","price":"3000"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	list := &struct {
+		Total int `json:"total"`
+	}{}
+	rec = doRequestAs(h, trader, http.MethodGet, "/api/v1/alerts", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 1 {
+		t.Fatalf("trader should only see its own alert, got %d", list.Total)
+	}
+	rec = doRequest(h, http.MethodGet, "/api/v1/alerts", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 2 {
+		t.Fatalf("admin should see every alert, got %d", list.Total)
+	}
+
+	alerts, _ := dbClient.GetAlerts()
+	for _, a := range alerts {
+		if a.UserID == testAdmin.ID {
+			if rec = doRequestAs(h, trader, http.MethodDelete, "/api/v1/alerts/"+a.ID, ""); rec.Code != http.StatusNotFound {
+				t.Fatalf("expected alerts of other users to be hidden, got %d", rec.Code)
+			}
+		}
+	}
+	if rec = doRequestAs(h, trader, http.MethodDelete, "/api/v1/alerts/"+created.ID, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAPIAlertEmailRequiredWithoutUserEmail(t *testing.T) {
+	h := newTestAPI(t, db.NewMemoryClient())
+	user := &db.User{ID: "t", Username: "trader", Role: db.RoleTrader}
+	rec := doRequestAs(h, user, http.MethodPost, "/api/v1/alerts", `{"symbol":"BTCUSDT","price":"40000"}`)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `"email"`) {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAPIUsers(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	if err := dbClient.AddUser(testAdmin); err != nil {
+		t.Fatal(err)
+	}
+	h := newTestAPI(t, dbClient)
+
+	rec := doRequest(h, http.MethodPost, "/api/v1/users", `{"username":"Jane","password":"secret-password","role":"trader","email":"jane@example.com"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "secret-password") || strings.Contains(rec.Body.String(), "$2a$") {
+		t.Fatalf("password should not be returned: %s", rec.Body.String())
+	}
+	user := &db.User{}
+	if err := json.Unmarshal(rec.Body.Bytes(), user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "jane" || user.Role != db.RoleTrader {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+
+	if rec = doRequest(h, http.MethodPost, "/api/v1/users", `{"username":"jane","password":"secret-password","role":"viewer"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("expected duplicate username conflict, got %d", rec.Code)
+	}
+	rec = doRequest(h, http.MethodPost, "/api/v1/users", `{"username":"x","password":"short","role":"owner","email":"nope"}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, field := range []string{"username", "password", "role", "email"} {
+		if !strings.Contains(rec.Body.String(), `"field":"`+field+`"`) {
+			t.Fatalf("expected %s error: %s", field, rec.Body.String())
+		}
+	}
+
+	c := &client{db: dbClient}
+	if authenticated, err := c.authenticate("JANE", "secret-password"); err != nil || authenticated == nil || authenticated.ID != user.ID {
+		t.Fatalf("expected jane to authenticate, got %+v, %v", authenticated, err)
+	}
+	if rec = doRequest(h, http.MethodPatch, "/api/v1/users/"+user.ID, `{"role":"viewer","password":"another-password"}`); rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if authenticated, _ := c.authenticate("jane", "secret-password"); authenticated != nil {
+		t.Fatal("old password should not authenticate")
+	}
+	if authenticated, _ := c.authenticate("jane", "another-password"); authenticated == nil || authenticated.Role != db.RoleViewer {
+		t.Fatalf("unexpected user after patch: %+v", authenticated)
+	}
+
+	if rec = doRequest(h, http.MethodPatch, "/api/v1/users/"+testAdmin.ID, `{"role":"trader"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("expected the last admin to keep its role, got %d", rec.Code)
+	}
+	if rec = doRequest(h, http.MethodDelete, "/api/v1/users/"+testAdmin.ID, ""); rec.Code != http.StatusConflict {
+		t.Fatalf("expected deleting yourself to fail, got %d", rec.Code)
+	}
+	if rec = doRequest(h, http.MethodDelete, "/api/v1/users/"+user.ID, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = doRequest(h, http.MethodGet, "/api/v1/users/"+user.ID, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected deleted user to be not found, got %d", rec.Code)
+	}
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	c := &client{db: dbClient, authUsername: "Admin", authPassword: "admin-password", authReqAlertAdminEmail: "admin@example.com"}
+	if err := c.bootstrapAdmin(); err != nil {
+		t.Fatal(err)
+	}
+	// the admin is only created once
+	if err := c.bootstrapAdmin(); err != nil {
+		t.Fatal(err)
+	}
+	users, _ := dbClient.GetUsers()
+	if len(users) != 1 || users[0].Username != "admin" || users[0].Role != db.RoleAdmin || users[0].PasswordHash == "admin-password" {
+		t.Fatalf("unexpected users: %+v", users)
+	}
+	if user, err := c.authenticate("admin", "admin-password"); err != nil || user == nil {
+		t.Fatalf("expected admin to authenticate, got %+v, %v", user, err)
+	}
+
+	if err := (&client{db: db.NewMemoryClient()}).bootstrapAdmin(); err == nil {
+		t.Fatal("expected an error without users and credentials")
+	}
+}
+
+func TestUsersTemplate(t *testing.T) {
+	tmpl, err := template.ParseFiles("templates/users.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, &UsersPageTemplateData{Username: "admin", Roles: []string{db.RoleViewer, db.RoleTrader, db.RoleAdmin}}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<option value="trader">`) {
+		t.Fatal("roles are not listed")
+	}
+}
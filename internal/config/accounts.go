@@ -0,0 +1,116 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/exchange"
+	"github.com/spf13/viper"
+)
+
+var accountIDRegexp = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// Account holds credentials and the fetch schedule of an exchange account, a zero FetchInterval
+// means the default cron interval. Futures and Margin enable watching of Binance USDⓈ-M futures
+// and cross margin markets.
+type Account struct {
+	ID            string
+	Exchange      string
+	ApiKey        string
+	ApiSecret     string
+	FetchInterval time.Duration
+	Futures       bool
+	Margin        bool
+}
+
+// Accounts returns Binance accounts listed in BINANCE_ACCOUNTS followed by Bybit accounts listed in BYBIT_ACCOUNTS
+// as comma separated IDs. Every account is configured with <EXCHANGE>_<ID>_API_KEY, <EXCHANGE>_<ID>_API_SECRET
+// and optional <EXCHANGE>_<ID>_FETCH_INTERVAL variables, Binance accounts also accept BINANCE_<ID>_FUTURES and
+// BINANCE_<ID>_MARGIN flags. Without BINANCE_ACCOUNTS a single default account uses BINANCE_API_KEY,
+// BINANCE_API_SECRET, BINANCE_FUTURES and BINANCE_MARGIN, it is skipped when only Bybit accounts are configured.
+func (c *Config) Accounts() ([]*Account, error) {
+	return parseAccounts(c, viper.GetString)
+}
+
+func parseAccounts(c *Config, get func(key string) string) ([]*Account, error) {
+	var accounts []*Account
+	switch {
+	case strings.TrimSpace(c.BinAccounts) != "":
+		binanceAccounts, err := parseAccountList(c.BinAccounts, exchange.Binance, get)
+		if err != nil {
+			return nil, err
+		}
+		accounts = binanceAccounts
+	case c.BinApiKey != "" || strings.TrimSpace(c.BybitAccounts) == "":
+		accounts = []*Account{{ID: db.DefaultAccountID, Exchange: exchange.Binance, ApiKey: c.BinApiKey, ApiSecret: c.BinApiSecret, Futures: c.BinFutures, Margin: c.BinMargin}}
+	}
+	if strings.TrimSpace(c.BybitAccounts) != "" {
+		bybitAccounts, err := parseAccountList(c.BybitAccounts, exchange.Bybit, get)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, bybitAccounts...)
+	}
+
+	seen := make(map[string]bool)
+	for _, account := range accounts {
+		if seen[account.ID] {
+			return nil, fmt.Errorf("account %s is listed twice", account.ID)
+		}
+		seen[account.ID] = true
+	}
+	return accounts, nil
+}
+
+func parseAccountList(list, exchangeName string, get func(key string) string) ([]*Account, error) {
+	var accounts []*Account
+	for _, id := range strings.Split(list, ",") {
+		id = strings.ToLower(strings.TrimSpace(id))
+		if !accountIDRegexp.MatchString(id) {
+			return nil, fmt.Errorf("invalid account id %q: use up to 32 lowercase letters, digits and underscores", id)
+		}
+
+		prefix := strings.ToUpper(exchangeName) + "_" + strings.ToUpper(id) + "_"
+		account := &Account{ID: id, Exchange: exchangeName, ApiKey: get(prefix + "API_KEY"), ApiSecret: get(prefix + "API_SECRET")}
+		if account.ApiKey == "" || account.ApiSecret == "" {
+			return nil, fmt.Errorf("account %s: %sAPI_KEY and %sAPI_SECRET are required", id, prefix, prefix)
+		}
+		if interval := get(prefix + "FETCH_INTERVAL"); interval != "" {
+			parsed, err := time.ParseDuration(interval)
+			if err != nil {
+				return nil, fmt.Errorf("account %s: %s", id, err)
+			}
+			if parsed <= 0 {
+				return nil, fmt.Errorf("account %s: fetch interval should be positive", id)
+			}
+			account.FetchInterval = parsed
+		}
+		if exchangeName == exchange.Binance {
+			var err error
+			if account.Futures, err = parseFlag(get(prefix + "FUTURES")); err != nil {
+				return nil, fmt.Errorf("account %s: %sFUTURES %s", id, prefix, err)
+			}
+			if account.Margin, err = parseFlag(get(prefix + "MARGIN")); err != nil {
+				return nil, fmt.Errorf("account %s: %sMARGIN %s", id, prefix, err)
+			}
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+func parseFlag(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.New("should be true or false")
+	}
+	return enabled, nil
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/exchange"
+)
+
+func TestParseAccounts(t *testing.T) {
+	env := map[string]string{
+		"BINANCE_MAIN_API_KEY":         "main-key",
+		"BINANCE_MAIN_API_SECRET":      "main-secret",
+		"BINANCE_SUB_1_API_KEY":        "sub-key",
+		"BINANCE_SUB_1_API_SECRET":     "sub-secret",
+		"BINANCE_SUB_1_FETCH_INTERVAL": "5m",
+		"BINANCE_SUB_1_FUTURES":        "true",
+		"BYBIT_SPOT_API_KEY":           "bybit-key",
+		"BYBIT_SPOT_API_SECRET":        "bybit-secret",
+	}
+	get := func(key string) string { return env[key] }
+
+	accounts, err := parseAccounts(&Config{BinAccounts: " Main, sub_1"}, get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 2 || accounts[0].ID != "main" || accounts[0].ApiKey != "main-key" || accounts[0].FetchInterval != 0 ||
+		accounts[1].ID != "sub_1" || accounts[1].ApiSecret != "sub-secret" || accounts[1].FetchInterval != 5*time.Minute ||
+		accounts[0].Futures || !accounts[1].Futures || accounts[1].Margin {
+		t.Fatalf("unexpected accounts %+v %+v", accounts[0], accounts[1])
+	}
+
+	accounts, err = parseAccounts(&Config{BinApiKey: "key", BinApiSecret: "secret"}, get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || accounts[0].ID != db.DefaultAccountID || accounts[0].ApiKey != "key" {
+		t.Fatalf("expected single default account, got %+v", accounts)
+	}
+
+	accounts, err = parseAccounts(&Config{BinAccounts: "main", BybitAccounts: "spot"}, get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 2 || accounts[0].Exchange != exchange.Binance || accounts[1].ID != "spot" || accounts[1].Exchange != exchange.Bybit || accounts[1].ApiKey != "bybit-key" {
+		t.Fatalf("unexpected accounts %+v %+v", accounts[0], accounts[1])
+	}
+	if accounts, err = parseAccounts(&Config{BybitAccounts: "spot"}, get); err != nil || len(accounts) != 1 || accounts[0].ID != "spot" {
+		t.Fatalf("default Binance account should be skipped without its keys, got %+v, %v", accounts, err)
+	}
+	if _, err = parseAccounts(&Config{BinAccounts: "spot", BybitAccounts: "spot"}, func(string) string { return "value" }); err == nil {
+		t.Fatal("expected duplicate account error")
+	}
+
+	for _, list := range []string{"main,main", "main,other", "main,", "with-dash"} {
+		if _, err = parseAccounts(&Config{BinAccounts: list}, get); err == nil {
+			t.Fatalf("expected error for %q", list)
+		}
+	}
+}
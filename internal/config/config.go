@@ -8,16 +8,38 @@ type Config struct {
 	AppSchema          string `mapstructure:"APP_SCHEMA"`
 	AppTlsCertPath     string `mapstructure:"APP_TLS_CERT_PATH"`
 	AppTlsKeyPath      string `mapstructure:"APP_TLS_KEY_PATH"`
+	AppHTTPPort        string `mapstructure:"APP_HTTP_PORT"`
+	ACMEEnabled        bool   `mapstructure:"ACME_ENABLED"`
+	ACMEEmail          string `mapstructure:"ACME_EMAIL"`
+	ACMEDirectoryURL   string `mapstructure:"ACME_DIRECTORY_URL"`
+	ACMECACertPath     string `mapstructure:"ACME_CA_CERT_PATH"`
+	ACMECacheDir       string `mapstructure:"ACME_CACHE_DIR"`
+	BinAccounts        string `mapstructure:"BINANCE_ACCOUNTS"`
 	BinApiKey          string `mapstructure:"BINANCE_API_KEY"`
 	BinApiSecret       string `mapstructure:"BINANCE_API_SECRET"`
 	BinProdURI         string `mapstructure:"BINANCE_PRODUCTION_URI"`
+	BinFuturesURI      string `mapstructure:"BINANCE_FUTURES_URI"`
+	BinFutures         bool   `mapstructure:"BINANCE_FUTURES"`
+	BinMargin          bool   `mapstructure:"BINANCE_MARGIN"`
+	BinRecord          string `mapstructure:"BINANCE_RECORD"`
+	BinReplay          string `mapstructure:"BINANCE_REPLAY"`
+	BybitAccounts      string `mapstructure:"BYBIT_ACCOUNTS"`
+	BybitURI           string `mapstructure:"BYBIT_URI"`
 	BaseAuthUsername   string `mapstructure:"BASE_AUTH_USERNAME"`
 	BaseAuthPassword   string `mapstructure:"BASE_AUTH_PASSWORD"`
 	BaseAuthSecret     string `mapstructure:"BASE_AUTH_SECRET"`
+	SessionIdleTimeout string `mapstructure:"SESSION_IDLE_TIMEOUT"`
+	TrustedProxies     string `mapstructure:"TRUSTED_PROXIES"`
 	MailjetApiKey      string `mapstructure:"MAILJET_API_KEY"`
 	MailjetApiSecret   string `mapstructure:"MAILJET_API_SECRET"`
 	MailjetSenderName  string `mapstructure:"MAILJET_SENDER_NAME"`
 	MailjetSenderEmail string `mapstructure:"MAILJET_SENDER_EMAIL"`
+	DBDriver           string `mapstructure:"DB_DRIVER"`
+	DBDSN              string `mapstructure:"DB_DSN"`
+	BackupDir          string `mapstructure:"BACKUP_DIR"`
+	BackupInterval     string `mapstructure:"BACKUP_INTERVAL"`
+	BackupKeep         int    `mapstructure:"BACKUP_KEEP"`
+	EventRetention     string `mapstructure:"EVENT_RETENTION"`
 }
 
 func New(path string, name string) (config *Config, err error) {
@@ -18,23 +18,40 @@ type Cron interface {
 }
 
 type cronImp struct {
-	fetcher fetcher.Fetcher
-	checker checker.Checker
+	fetcher   fetcher.Fetcher
+	checker   checker.Checker
+	intervals map[string]time.Duration
 }
 
-func New(fetcherClient fetcher.Fetcher, checkerClient checker.Checker) Cron {
-	return &cronImp{fetcher: fetcherClient, checker: checkerClient}
+// New creates a cron fetching every account on its own interval, zero intervals fall back to Interval.
+func New(fetcherClient fetcher.Fetcher, checkerClient checker.Checker, intervals map[string]time.Duration) Cron {
+	return &cronImp{fetcher: fetcherClient, checker: checkerClient, intervals: intervals}
 }
 
+// Run fetches accounts until alert checking fails.
 func (c *cronImp) Run() error {
+	errs := make(chan error, len(c.intervals))
+	for accountID, interval := range c.intervals {
+		if interval <= 0 {
+			interval = Interval
+		}
+		go func(accountID string, interval time.Duration) {
+			errs <- c.runAccount(accountID, interval)
+		}(accountID, interval)
+	}
+	return <-errs
+}
+
+func (c *cronImp) runAccount(accountID string, interval time.Duration) error {
+	log.Printf("fetching account %s every %s...", accountID, interval)
 	for {
-		_, prices, err := c.fetcher.Fetch()
+		// alerts are not checked without prices, the next fetch of the account retries
+		_, prices, err := c.fetcher.FetchAccount(accountID)
 		if err != nil {
-			log.Println("error in fetcher: ", err)
-		}
-		if err = c.checker.Check(prices); err != nil {
+			log.Println("error in fetcher, skipping alert check: ", err)
+		} else if err = c.checker.Check(prices); err != nil {
 			return err
 		}
-		time.Sleep(Interval)
+		time.Sleep(interval)
 	}
 }
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var ErrBackupNotSupported = errors.New("online backup is supported only by the sqlite3 driver")
+
+// Backuper is implemented by clients able to copy the whole database while it is in use.
+type Backuper interface {
+	Backup(path string) error
+	Restore(path string) error
+}
+
+// Backup copies the live database into the sqlite file at path using the sqlite online backup API.
+func (c *client) Backup(path string) error {
+	if c.dialect.name != DriverSQLite {
+		return ErrBackupNotSupported
+	}
+	log.Printf("backing up database to %s...", path)
+	dest, err := sql.Open(DriverSQLite, path)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+	return copySQLite(dest, c.db)
+}
+
+// Restore replaces the live database content with the sqlite backup file at path.
+func (c *client) Restore(path string) error {
+	if c.dialect.name != DriverSQLite {
+		return ErrBackupNotSupported
+	}
+	log.Printf("restoring database from %s...", path)
+	src, err := sql.Open(DriverSQLite, fmt.Sprintf("file:%s?mode=ro", path))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err = copySQLite(c.db, src); err != nil {
+		return err
+	}
+	// the backup may come from an older version
+	return migrate(c.db, c.dialect)
+}
+
+func copySQLite(dest, src *sql.DB) error {
+	ctx := context.Background()
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(destDriverConn interface{}) error {
+		return srcConn.Raw(func(srcDriverConn interface{}) error {
+			destSQLite, ok := destDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return ErrBackupNotSupported
+			}
+			srcSQLite, ok := srcDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return ErrBackupNotSupported
+			}
+			backup, err := destSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return err
+			}
+			for {
+				done, err := backup.Step(-1)
+				if err != nil {
+					backup.Finish()
+					return err
+				}
+				if done {
+					break
+				}
+			}
+			return backup.Finish()
+		})
+	})
+}
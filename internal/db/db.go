@@ -2,40 +2,81 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
-	"os"
-	"strings"
-
-	"github.com/morzhanov/binance-orders-watcher/internal/debug"
+	"time"
 
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"           // Import postgres driver
 	_ "github.com/mattn/go-sqlite3" // Import go-sqlite3 library
 )
 
 const (
+	DriverSQLite   = "sqlite3"
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory"
+
 	dbFileName = "sqlite-database.db"
 )
 
-var instance Client
-
 type Client interface {
-	SetOrders(orders []*Order) error
+	SetOrders(accountID string, orders []*Order) error
 	GetOrders() ([]*Order, error)
+	SetBalances(accountID string, balances []*Balance) error
+	GetBalances() ([]*Balance, error)
 	SetPrices(prices []*Price) error
 	GetPrices() ([]*Price, error)
 	AddAlert(alert *Alert) error
+	UpdateAlert(alert *Alert) error
 	DeleteAlert(id string) error
 	GetAlerts() ([]*Alert, error)
-	AddAuthRequest(ip string) error
-	UpdateAuthRequest(ip string, attempts int, alertSent bool) error
-	GetAuthRequest(ip string) (*AuthRequest, error)
+	AddEvent(event *Event) error
+	GetEvents(query *EventQuery) ([]*Event, int, error)
+	DeleteEventsBefore(createdAt int64) error
+	SaveKlines(klines []*Kline) error
+	GetKlines(symbol, interval string, from, to int64) ([]*Kline, error)
+	AddAuditEntry(entry *AuditEntry) error
+	GetAuditEntries() ([]*AuditEntry, error)
+	SetLeveragedOrders(accountID, market string, orders []*LeveragedOrder) error
+	GetLeveragedOrders() ([]*LeveragedOrder, error)
+	SetPositions(accountID string, positions []*Position) error
+	GetPositions() ([]*Position, error)
+	SetFundingRates(rates []*FundingRate) error
+	GetFundingRates() ([]*FundingRate, error)
+	SetMarginStatus(status *MarginStatus) error
+	GetMarginStatuses() ([]*MarginStatus, error)
+	SetOrderLists(accountID string, lists []*OrderList) error
+	GetOrderLists() ([]*OrderList, error)
+	AddUser(user *User) error
+	UpdateUser(user *User) error
+	DeleteUser(id string) error
+	GetUser(id string) (*User, error)
+	GetUserByUsername(username string) (*User, error)
+	GetUsers() ([]*User, error)
+	AddSession(session *Session) error
+	GetSession(id string) (*Session, error)
+	GetUserSessions(userID string) ([]*Session, error)
+	TouchSession(id string, lastSeenAt int64, ip string) error
+	DeleteSession(id string) error
+	DeleteUserSessions(userID string) error
+	DeleteExpiredSessions(now, lastSeenBefore int64) error
+	AddAPIToken(token *APIToken) error
+	GetAPITokens() ([]*APIToken, error)
+	GetAPITokenByHash(hash string) (*APIToken, error)
+	TouchAPIToken(id string, lastUsedAt int64) error
+	DeleteAPIToken(id string) error
+	AddIPBan(ban *IPBan) error
+	GetIPBans() ([]*IPBan, error)
+	DeleteIPBan(id string) error
 }
 
-type client struct {
-	db *sql.DB
-}
+// DefaultAccountID is the account used when a single Binance account is configured,
+// records stored before accounts were introduced belong to it.
+const DefaultAccountID = "default"
 
 type Order struct {
+	AccountID              string `json:"accountId"`
 	Symbol                 string `json:"symbol"`
 	OrderID                int    `json:"orderId"`
 	OrderListID            int    `json:"orderListId"`
@@ -59,358 +100,331 @@ type Order struct {
 	OrderMarketPriceSpread string `json:"orderMarketPriceSpread"`
 }
 
+// OrderList is an open OCO order list, its legs are the orders with the same OrderListID.
+// TriggerDistance is the distance between the market price and the closest leg trigger price
+// in percent of the market price, N/A when the market price is unknown.
+type OrderList struct {
+	AccountID         string `json:"accountId"`
+	Symbol            string `json:"symbol"`
+	OrderListID       int    `json:"orderListId"`
+	ContingencyType   string `json:"contingencyType"`
+	ListStatusType    string `json:"listStatusType"`
+	ListOrderStatus   string `json:"listOrderStatus"`
+	ListClientOrderID string `json:"listClientOrderId"`
+	TransactionTime   int64  `json:"transactionTime"`
+	TriggerDistance   string `json:"triggerDistance"`
+}
+
+// Balance is a non zero asset balance of an account, UpdatedAt is a unix timestamp in milliseconds.
+type Balance struct {
+	AccountID string `json:"accountId"`
+	Asset     string `json:"asset"`
+	Free      string `json:"free"`
+	Locked    string `json:"locked"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+const (
+	MarketFutures = "futures"
+	MarketMargin  = "margin"
+)
+
+// LeveragedOrder is an open USDⓈ-M futures or cross margin order, PositionSide and ReduceOnly are
+// only set for futures. Times are unix timestamps in milliseconds.
+type LeveragedOrder struct {
+	AccountID     string `json:"accountId"`
+	Market        string `json:"market"`
+	Symbol        string `json:"symbol"`
+	OrderID       int    `json:"orderId"`
+	ClientOrderID string `json:"clientOrderId"`
+	Price         string `json:"price"`
+	OrigQty       string `json:"origQty"`
+	ExecutedQty   string `json:"executedQty"`
+	Status        string `json:"status"`
+	TimeInForce   string `json:"timeInForce"`
+	Type          string `json:"type"`
+	Side          string `json:"side"`
+	PositionSide  string `json:"positionSide"`
+	StopPrice     string `json:"stopPrice"`
+	ReduceOnly    bool   `json:"reduceOnly"`
+	Time          int    `json:"time"`
+	UpdateTime    int    `json:"updateTime"`
+}
+
+// Position is an open USDⓈ-M futures position, LiquidationPrice is zero when the position can't be liquidated.
+type Position struct {
+	AccountID        string `json:"accountId"`
+	Symbol           string `json:"symbol"`
+	PositionSide     string `json:"positionSide"`
+	PositionAmt      string `json:"positionAmt"`
+	EntryPrice       string `json:"entryPrice"`
+	MarkPrice        string `json:"markPrice"`
+	UnrealizedProfit string `json:"unrealizedProfit"`
+	LiquidationPrice string `json:"liquidationPrice"`
+	Leverage         int    `json:"leverage"`
+	MarginType       string `json:"marginType"`
+	UpdatedAt        int64  `json:"updatedAt"`
+}
+
+// FundingRate is the last funding rate of a futures symbol, NextFundingTime is a unix timestamp in milliseconds.
+type FundingRate struct {
+	Symbol          string `json:"symbol"`
+	MarkPrice       string `json:"markPrice"`
+	FundingRate     string `json:"fundingRate"`
+	NextFundingTime int64  `json:"nextFundingTime"`
+}
+
+// MarginStatus is the margin ratio of a futures or cross margin account in percent, the account is
+// liquidated when it reaches 100. MarginLevel is only set for cross margin.
+type MarginStatus struct {
+	AccountID   string `json:"accountId"`
+	Market      string `json:"market"`
+	MarginRatio string `json:"marginRatio"`
+	MarginLevel string `json:"marginLevel"`
+	UpdatedAt   int64  `json:"updatedAt"`
+}
+
 type Price struct {
 	Symbol string `json:"symbol"`
 	Price  string `json:"price"`
 }
 
+// Kline is a cached Binance candle, times are unix timestamps in milliseconds.
+type Kline struct {
+	Symbol    string `json:"symbol"`
+	Interval  string `json:"interval"`
+	OpenTime  int64  `json:"openTime"`
+	Open      string `json:"open"`
+	High      string `json:"high"`
+	Low       string `json:"low"`
+	Close     string `json:"close"`
+	Volume    string `json:"volume"`
+	CloseTime int64  `json:"closeTime"`
+}
+
+const (
+	AlertTypePrice               = "price"
+	AlertTypeLiquidationDistance = "liquidation_distance"
+	AlertTypeFundingRate         = "funding_rate"
+)
+
+// Alert is owned by the user with UserID, timestamps are unix timestamps in milliseconds.
 type Alert struct {
-	ID            string `json:"id"`
-	Symbol        string `json:"symbol"`
-	Price         string `json:"price"`
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+	// AccountID tells which account the alert was set up for, price alerts are checked against market prices
+	AccountID string `json:"accountId"`
+	Type      string `json:"type"`
+	Symbol    string `json:"symbol"`
+	// Price is the distance between the mark and the liquidation price for liquidation distance alerts and
+	// the funding rate for funding rate alerts, both in percent
+	Price string `json:"price"`
+	// Name and Email are only used for alerts created before users, others are sent to the user email
 	Name          string `json:"name"`
 	Email         string `json:"email"`
 	Text          string `json:"text"`
 	DirectionDown bool   `json:"directionDown"`
+	CreatedAt     int64  `json:"createdAt"`
+	// CreatedBy and UpdatedBy hold the username
+	CreatedBy string `json:"createdBy"`
+	UpdatedAt int64  `json:"updatedAt"`
+	UpdatedBy string `json:"updatedBy"`
 }
 
-type AuthRequest struct {
-	IP        string `json:"ip"`
-	Attempts  int    `json:"attempts"`
-	AlertSent bool   `json:"alertSent"`
-}
-
-func NewClient() (Client, error) {
-	if instance != nil {
-		return instance, nil
-	}
-
-	var sqlDB *sql.DB
-	if !debug.IsDebug() && !dbExists() {
-		if err := os.Remove(dbFileName); err != nil {
-			log.Println(dbFileName + " is not exists")
-		}
-		log.Println("creating " + dbFileName)
-		file, err := os.Create(dbFileName)
-		if err != nil {
-			return nil, err
-		}
-		if err = file.Close(); err != nil {
-			return nil, err
-		}
-
-		sqlDB, _ = sql.Open("sqlite3", "./"+dbFileName)
-		if err = createTables(sqlDB); err != nil {
-			return nil, err
-		}
-	} else {
-		sqlDB, _ = sql.Open("sqlite3", "./"+dbFileName)
-	}
+const (
+	RoleAdmin  = "admin"
+	RoleTrader = "trader"
+	RoleViewer = "viewer"
+)
 
-	return &client{db: sqlDB}, nil
+// User is a dashboard user. Viewers can only read, traders can also manage their alerts and trade,
+// admins can also manage users. PasswordHash is a bcrypt hash and is never sent to clients.
+// TOTPSecret is set on two-factor enrolment and used once TOTPEnabled, TOTPLastStep is the time step of
+// the last accepted code and RecoveryCodes are SHA-256 hashes of the unused recovery codes.
+type User struct {
+	ID            string   `json:"id"`
+	Username      string   `json:"username"`
+	PasswordHash  string   `json:"-"`
+	Role          string   `json:"role"`
+	Name          string   `json:"name"`
+	Email         string   `json:"email"`
+	TOTPSecret    string   `json:"-"`
+	TOTPEnabled   bool     `json:"totpEnabled"`
+	TOTPLastStep  int64    `json:"-"`
+	RecoveryCodes []string `json:"-"`
+	CreatedAt     int64    `json:"createdAt"`
+	UpdatedAt     int64    `json:"updatedAt"`
 }
 
-func dbExists() bool {
-	if _, err := os.Stat("./" + dbFileName); err != nil {
-		return false
-	}
-	return true
+// Session is a logged in browser, ID is the ID of its access token. A session ends at ExpiresAt,
+// when it was not used for the idle timeout or when it is deleted on logout.
+type Session struct {
+	ID         string `json:"id"`
+	UserID     string `json:"userId"`
+	UserAgent  string `json:"userAgent"`
+	IP         string `json:"ip"`
+	CreatedAt  int64  `json:"createdAt"`
+	LastSeenAt int64  `json:"lastSeenAt"`
+	ExpiresAt  int64  `json:"expiresAt"`
 }
 
-func createTables(sqlDB *sql.DB) error {
-	ordersTableSQL := `CREATE TABLE orders (		
-		"symbol" TEXT,
-		"orderId" INTEGER,
-		"orderListId" INTEGER,
-		"clientOrderId" TEXT,
-		"price" TEXT,
-		"origQty" TEXT,
-		"executedQty" TEXT,
-		"cummulativeQuoteQty" TEXT,
-		"status" TEXT,
-		"timeInForce" TEXT,
-		"type" TEXT,
-		"side" TEXT,
-		"stopPrice" TEXT,
-		"icebergQty" TEXT,
-		"time" INTEGER,
-		"updateTime" INTEGER,
-		"isWorking" BOOLEAN,
-		"lastOrderPrice" TEXT,
-		"marketPrice" TEXT,
-		"percentCompleted" TEXT,
-		"orderMarketPriceSpread" TEXT
-	  );`
-
-	log.Println("create orders table...")
-	statement, err := sqlDB.Prepare(ordersTableSQL)
-	if err != nil {
-		return err
-	}
-	if _, err = statement.Exec(); err != nil {
-		return err
-	}
-	log.Println("orders table created")
+// APIToken is a personal access token of a user for scripts. Only the SHA-256 hash of the token is stored,
+// Prefix is its beginning to tell tokens apart. ExpiresAt is 0 for tokens which don't expire.
+type APIToken struct {
+	ID         string   `json:"id"`
+	UserID     string   `json:"userId"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	TokenHash  string   `json:"-"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  int64    `json:"createdAt"`
+	ExpiresAt  int64    `json:"expiresAt"`
+	LastUsedAt int64    `json:"lastUsedAt"`
+}
 
-	pricesTableSQL := `CREATE TABLE prices (		
-		"symbol" TEXT,
-		"price" TEXT	
-	  );`
+// IPBan blocks every request from a network until it is deleted, Network is in CIDR notation
+// and single IPs are stored as /32 or /128 networks.
+type IPBan struct {
+	ID        string `json:"id"`
+	Network   string `json:"network"`
+	Reason    string `json:"reason"`
+	CreatedBy string `json:"createdBy"`
+	CreatedAt int64  `json:"createdAt"`
+}
 
-	log.Println("create prices table...")
-	statement, err = sqlDB.Prepare(pricesTableSQL)
-	if err != nil {
-		return err
-	}
-	if _, err = statement.Exec(); err != nil {
-		return err
-	}
-	log.Println("prices table created")
-
-	alertsTableSQL := `CREATE TABLE alerts (		
-		"id" TEXT,
-		"symbol" TEXT,
-		"price" TEXT,
-		"name" TEXT,
-		"email" TEXT,
-		"text" TEXT,
-		"directionDown" BOOLEAN
-	  );`
-
-	log.Println("create alerts table...")
-	statement, err = sqlDB.Prepare(alertsTableSQL)
-	if err != nil {
-		return err
-	}
-	if _, err = statement.Exec(); err != nil {
-		return err
-	}
-	log.Println("alerts table created")
+var ErrNotFound = errors.New("record not found")
 
-	authRequestsTableSQL := `CREATE TABLE auth_requests (		
-		"ip" TEXT,
-		"attempts" INTEGER,
-		"alertSent" BOOLEAN
-	  );`
+const (
+	EventTypeFetchCompleted = "fetch_completed"
+	EventTypeFetchFailed    = "fetch_failed"
+	EventTypeAlertTriggered = "alert_triggered"
+	EventTypeOrderListDone  = "order_list_done"
+)
 
-	log.Println("create auth requests table...")
-	statement, err = sqlDB.Prepare(authRequestsTableSQL)
-	if err != nil {
-		return err
-	}
-	if _, err = statement.Exec(); err != nil {
-		return err
-	}
-	log.Println("auth requests table created")
-	return nil
+// Event is a record of something the watcher did, CreatedAt is a unix timestamp in milliseconds.
+// Events without a UserID are visible to every user, alert events belong to the alert owner.
+type Event struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Symbol    string `json:"symbol"`
+	Message   string `json:"message"`
+	UserID    string `json:"userId"`
+	CreatedAt int64  `json:"createdAt"`
 }
 
-func (c *client) SetOrders(orders []*Order) error {
-	log.Println("inserting order records into db...")
-	if err := c.deleteOrders(); err != nil {
-		return err
-	}
-	for _, o := range orders {
-		if err := c.createOrder(o); err != nil {
-			return err
-		}
-	}
-	return nil
+// EventQuery selects events matching any of the Types and any of the Symbols, empty lists match every event.
+// A UserID limits events to the ones of the user and the ones without a user, except alert events stored
+// before events had users. Events are ordered by Sort and then newest first, Offset is only used with a Limit
+// and Limit 0 returns every matching event.
+type EventQuery struct {
+	Types   []string
+	Symbols []string
+	UserID  string
+	Sort    []EventSort
+	Limit   int
+	Offset  int
 }
 
-func (c *client) createOrder(o *Order) error {
-	insertSQL := fmt.Sprintf(`
-			INSERT INTO orders ('symbol', 'orderId', 'orderListId', 'clientOrderId', 'price', 'origQty', 'executedQty', 'cummulativeQuoteQty', 'status', 'timeInForce', 'type', 'side', 'stopPrice', 'icebergQty', 'time', 'updateTime', 'isWorking', 'lastOrderPrice', 'marketPrice', 'percentCompleted', 'orderMarketPriceSpread')
-			VALUES('%s', '%d', '%d', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%d', '%d', '%t', '%s', '%s', '%s', '%s');
-	`, o.Symbol, o.OrderID, o.OrderListID, o.ClientOrderID, o.Price, o.OrigQty, o.ExecutedQty, o.CummulativeQuoteQty, o.Status, o.TimeInForce, o.Type, o.Side, o.StopPrice, o.IcebergQty, o.Time, o.UpdateTime, o.IsWorking, o.LastOrderPrice, o.MarketPrice, o.PercentCompleted, o.OrderMarketPriceSpread)
-	statement, err := c.db.Prepare(insertSQL)
-	if err != nil {
-		return err
-	}
-	_, err = statement.Exec()
-	return err
+// EventSort orders events by the event field with the json name Field, one of EventSortFields.
+type EventSort struct {
+	Field string
+	Desc  bool
 }
 
-func (c *client) deleteOrders() error {
-	deleteSQL := fmt.Sprintf("DELETE FROM orders")
-	statement, err := c.db.Prepare(deleteSQL)
-	if err != nil {
-		return err
-	}
-	_, err = statement.Exec()
-	return err
+var EventSortFields = []string{"createdAt", "type", "symbol"}
+
+func isEventSortField(field string) bool {
+	return containsString(EventSortFields, field)
 }
 
-func (c *client) GetOrders() ([]*Order, error) {
-	log.Println("getting order records from db...")
-	row, err := c.db.Query("SELECT * FROM orders")
-	if err != nil {
-		return nil, err
-	}
-	defer row.Close()
-
-	orders := make([]*Order, 0)
-	for row.Next() {
-		order := &Order{}
-		err = row.Scan(&order.Symbol, &order.OrderID, &order.OrderListID, &order.ClientOrderID, &order.Price, &order.OrigQty, &order.ExecutedQty, &order.CummulativeQuoteQty, &order.Status, &order.TimeInForce, &order.Type, &order.Side, &order.StopPrice, &order.IcebergQty, &order.Time, &order.UpdateTime, &order.IsWorking, &order.LastOrderPrice, &order.MarketPrice, &order.PercentCompleted, &order.OrderMarketPriceSpread)
-		if err != nil {
-			return nil, err
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
 		}
-		orders = append(orders, order)
 	}
-	return orders, nil
+	return false
 }
 
-func (c *client) SetPrices(prices []*Price) error {
-	log.Println("inserting price records into db...")
-	if err := c.deletePrices(); err != nil {
-		return err
+func NewEvent(eventType, symbol, message string) *Event {
+	return &Event{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		Symbol:    symbol,
+		Message:   message,
+		CreatedAt: time.Now().UnixMilli(),
 	}
-	for _, p := range prices {
-		if err := c.createPrice(p); err != nil {
-			return err
-		}
-	}
-	return nil
 }
 
-func (c *client) createPrice(p *Price) error {
-	insertSQL := fmt.Sprintf(`
-			INSERT INTO prices ('symbol', 'price')
-			VALUES('%s', '%s');
-		`, p.Symbol, p.Price)
-	statement, err := c.db.Prepare(insertSQL)
-	if err != nil {
-		return err
-	}
-	_, err = statement.Exec()
-	return err
+const (
+	AuditActionPlaceOrder         = "place_order"
+	AuditActionPlaceOCO           = "place_oco"
+	AuditActionCancelOrder        = "cancel_order"
+	AuditActionCancelReplaceOrder = "cancel_replace_order"
+)
+
+// AuditEntry records a trading action, Request and Response hold JSON documents and Error is
+// set when the action was rejected by validation or by the exchange.
+type AuditEntry struct {
+	ID        string `json:"id"`
+	Action    string `json:"action"`
+	Username  string `json:"username"`
+	AccountID string `json:"accountId"`
+	Symbol    string `json:"symbol"`
+	Test      bool   `json:"test"`
+	Request   string `json:"request"`
+	Response  string `json:"response"`
+	Error     string `json:"error"`
+	CreatedAt int64  `json:"createdAt"`
 }
 
-func (c *client) deletePrices() error {
-	deleteSQL := fmt.Sprintf("DELETE FROM prices")
-	statement, err := c.db.Prepare(deleteSQL)
-	if err != nil {
-		return err
+func NewAuditEntry(action, username, accountID, symbol string, test bool) *AuditEntry {
+	return &AuditEntry{
+		ID:        uuid.NewString(),
+		Action:    action,
+		Username:  username,
+		AccountID: accountID,
+		Symbol:    symbol,
+		Test:      test,
+		CreatedAt: time.Now().UnixMilli(),
 	}
-	_, err = statement.Exec()
-	return err
 }
 
-func (c *client) GetPrices() ([]*Price, error) {
-	log.Println("getting price records from db...")
-	row, err := c.db.Query("SELECT * FROM prices")
-	if err != nil {
-		return nil, err
+// NewClient opens the storage selected by driver. An empty driver falls back to
+// sqlite and an empty dsn to the sqlite file in the working directory.
+func NewClient(driver, dsn string) (Client, error) {
+	if driver == DriverMemory {
+		log.Println("using in-memory database, data will be lost on exit")
+		return NewMemoryClient(), nil
 	}
-	defer row.Close()
-
-	orders := make([]*Price, 0)
-	for row.Next() {
-		order := &Price{}
-		err = row.Scan(&order.Symbol, &order.Price)
-		if err != nil {
-			return nil, err
-		}
-		orders = append(orders, order)
+	if driver == "" {
+		driver = DriverSQLite
 	}
-	return orders, nil
-}
-
-func (c *client) AddAlert(alert *Alert) error {
-	log.Println("inserting alert into db...")
-	insertSQL := fmt.Sprintf(`
-			INSERT INTO alerts ('id' ,'symbol', 'price', 'name', 'email', 'text', 'directionDown')
-			VALUES('%s', '%s', '%s', '%s', '%s', '%s', '%t');
-	`, alert.ID, alert.Symbol, alert.Price, alert.Name, alert.Email, alert.Text, alert.DirectionDown)
-
-	statement, err := c.db.Prepare(insertSQL)
-	if err != nil {
-		return err
+	if driver == DriverSQLite && dsn == "" {
+		dsn = "./" + dbFileName
 	}
-	_, err = statement.Exec()
-	return err
-}
-
-func (c *client) DeleteAlert(id string) error {
-	log.Printf("deleting alert with id %s...", id)
-	deleteSQL := fmt.Sprintf(`
-		DELETE FROM alerts
-		WHERE id = '%s'
-	`, id)
 
-	statement, err := c.db.Prepare(deleteSQL)
-	if err != nil {
-		return err
+	d, ok := dialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database driver %s", driver)
 	}
-	_, err = statement.Exec()
-	return err
-}
-
-func (c *client) GetAlerts() ([]*Alert, error) {
-	log.Println("getting alert records from db...")
-	row, err := c.db.Query("SELECT * FROM alerts")
+	log.Printf("opening %s database...", driver)
+	sqlDB, err := sql.Open(driver, dsn)
 	if err != nil {
 		return nil, err
 	}
-	defer row.Close()
-
-	alerts := make([]*Alert, 0)
-	for row.Next() {
-		alert := &Alert{}
-		err = row.Scan(&alert.ID, &alert.Symbol, &alert.Price, &alert.Name, &alert.Email, &alert.Text, &alert.DirectionDown)
-		if err != nil {
-			return nil, err
-		}
-		alerts = append(alerts, alert)
-	}
-	return alerts, nil
-}
-
-func (c *client) AddAuthRequest(ip string) error {
-	log.Println("inserting auth request into db...")
-	insertSQL := fmt.Sprintf(`
-			INSERT INTO auth_requests ('ip' ,'attempts', 'alertSent')
-			VALUES('%s', '%d', '%t');
-	`, ip, 0, false)
-
-	statement, err := c.db.Prepare(insertSQL)
-	if err != nil {
-		return err
+	if driver == DriverSQLite {
+		// sqlite allows a single writer, sharing one connection avoids "database is locked" errors
+		// and keeps in-memory databases alive between queries
+		sqlDB.SetMaxOpenConns(1)
 	}
-	_, err = statement.Exec()
-	return err
-}
-
-func (c *client) UpdateAuthRequest(ip string, attempts int, alertSent bool) error {
-	updateSQL := fmt.Sprintf(`
-			UPDATE auth_requests
-			SET attempts = '%d', 'alertSent' = %t
-			WHERE ip = '%s';
-	`, attempts, alertSent, ip)
-
-	statement, err := c.db.Prepare(updateSQL)
-	if err != nil {
-		return err
+	if err = sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, err
 	}
-	_, err = statement.Exec()
-	return err
-}
-
-func (c *client) GetAuthRequest(ip string) (*AuthRequest, error) {
-	getSQL := fmt.Sprintf(`
-		SELECT * FROM auth_requests
-		WHERE ip = '%s';`,
-		ip)
-
-	row := c.db.QueryRow(getSQL)
-	req := &AuthRequest{}
-	err := row.Scan(&req.IP, &req.Attempts, &req.AlertSent)
-	if err != nil {
-		if strings.Contains(err.Error(), "no rows") {
-			return nil, nil
-		}
+	if err = migrate(sqlDB, d); err != nil {
+		sqlDB.Close()
 		return nil, err
 	}
-	return req, nil
+	return &client{db: sqlDB, dialect: d}, nil
 }
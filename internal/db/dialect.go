@@ -0,0 +1,58 @@
+package db
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// migrationsLockID is an arbitrary key for the postgres advisory lock taken while migrating,
+// it keeps several watcher instances from applying the same migration at once.
+const migrationsLockID = 7283641
+
+type dialect struct {
+	name           string
+	bigint         string
+	positional     bool
+	lockMigrations func(tx *sql.Tx) error
+}
+
+var dialects = map[string]*dialect{
+	DriverSQLite: {
+		name:           DriverSQLite,
+		bigint:         "INTEGER",
+		lockMigrations: func(*sql.Tx) error { return nil },
+	},
+	DriverPostgres: {
+		name:       DriverPostgres,
+		bigint:     "BIGINT",
+		positional: true,
+		lockMigrations: func(tx *sql.Tx) error {
+			_, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", migrationsLockID)
+			return err
+		},
+	},
+}
+
+// rebind converts "?" placeholders into the dialect placeholder format.
+func (d *dialect) rebind(query string) string {
+	if !d.positional {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, ch := range query {
+		if ch == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
+
+// types replaces dialect specific type markers in the migration statement.
+func (d *dialect) types(statement string) string {
+	return strings.ReplaceAll(statement, "{{BIGINT}}", d.bigint)
+}
@@ -0,0 +1,732 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// memoryClient keeps all records in process memory, it is used in tests and for runs without a disk.
+// Records are copied on the way in and out so callers can't mutate the stored state.
+type memoryClient struct {
+	mu           sync.RWMutex
+	orders       []Order
+	balances     []Balance
+	prices       []Price
+	alerts       []Alert
+	events       []Event
+	klines       map[klineKey]Kline
+	auditLog     []AuditEntry
+	leveraged    []LeveragedOrder
+	positions    []Position
+	fundingRates []FundingRate
+	marginStatus []MarginStatus
+	orderLists   []OrderList
+	users        []User
+	sessions     map[string]Session
+	apiTokens    []APIToken
+	ipBans       []IPBan
+}
+
+type klineKey struct {
+	symbol   string
+	interval string
+	openTime int64
+}
+
+func NewMemoryClient() Client {
+	return &memoryClient{klines: make(map[klineKey]Kline), sessions: make(map[string]Session)}
+}
+
+func (c *memoryClient) SetOrders(accountID string, orders []*Order) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := make([]Order, 0, len(c.orders)+len(orders))
+	for _, o := range c.orders {
+		if o.AccountID != accountID {
+			kept = append(kept, o)
+		}
+	}
+	for _, o := range orders {
+		order := *o
+		order.AccountID = accountID
+		kept = append(kept, order)
+	}
+	c.orders = kept
+	return nil
+}
+
+func (c *memoryClient) GetOrders() ([]*Order, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	orders := make([]*Order, 0, len(c.orders))
+	for _, o := range c.orders {
+		order := o
+		orders = append(orders, &order)
+	}
+	return orders, nil
+}
+
+func (c *memoryClient) SetBalances(accountID string, balances []*Balance) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := make([]Balance, 0, len(c.balances)+len(balances))
+	for _, b := range c.balances {
+		if b.AccountID != accountID {
+			kept = append(kept, b)
+		}
+	}
+	for _, b := range balances {
+		balance := *b
+		balance.AccountID = accountID
+		kept = append(kept, balance)
+	}
+	c.balances = kept
+	return nil
+}
+
+func (c *memoryClient) GetBalances() ([]*Balance, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	balances := make([]*Balance, 0, len(c.balances))
+	for _, b := range c.balances {
+		balance := b
+		balances = append(balances, &balance)
+	}
+	sort.SliceStable(balances, func(i, j int) bool {
+		if balances[i].AccountID != balances[j].AccountID {
+			return balances[i].AccountID < balances[j].AccountID
+		}
+		return balances[i].Asset < balances[j].Asset
+	})
+	return balances, nil
+}
+
+func (c *memoryClient) SetPrices(prices []*Price) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prices = make([]Price, 0, len(prices))
+	for _, p := range prices {
+		c.prices = append(c.prices, *p)
+	}
+	return nil
+}
+
+func (c *memoryClient) GetPrices() ([]*Price, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	prices := make([]*Price, 0, len(c.prices))
+	for _, p := range c.prices {
+		price := p
+		prices = append(prices, &price)
+	}
+	return prices, nil
+}
+
+func (c *memoryClient) AddAlert(alert *Alert) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.alerts = append(c.alerts, *alert)
+	return nil
+}
+
+func (c *memoryClient) UpdateAlert(alert *Alert) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, a := range c.alerts {
+		if a.ID == alert.ID {
+			updated := *alert
+			updated.CreatedAt, updated.CreatedBy, updated.UserID = a.CreatedAt, a.CreatedBy, a.UserID
+			c.alerts[i] = updated
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (c *memoryClient) DeleteAlert(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	alerts := c.alerts[:0]
+	for _, a := range c.alerts {
+		if a.ID != id {
+			alerts = append(alerts, a)
+		}
+	}
+	c.alerts = alerts
+	return nil
+}
+
+func (c *memoryClient) GetAlerts() ([]*Alert, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	alerts := make([]*Alert, 0, len(c.alerts))
+	for _, a := range c.alerts {
+		alert := a
+		alerts = append(alerts, &alert)
+	}
+	return alerts, nil
+}
+
+func (c *memoryClient) AddEvent(event *Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, *event)
+	return nil
+}
+
+func (c *memoryClient) GetEvents(query *EventQuery) ([]*Event, int, error) {
+	for _, key := range query.Sort {
+		if !isEventSortField(key.Field) {
+			return nil, 0, fmt.Errorf("sorting events by %q is not supported", key.Field)
+		}
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	events := make([]*Event, 0)
+	for _, e := range c.events {
+		if (len(query.Types) > 0 && !containsString(query.Types, e.Type)) || (len(query.Symbols) > 0 && !containsString(query.Symbols, e.Symbol)) {
+			continue
+		}
+		if query.UserID != "" && e.UserID != query.UserID && (e.UserID != "" || e.Type == EventTypeAlertTriggered) {
+			continue
+		}
+		event := e
+		events = append(events, &event)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		for _, key := range query.Sort {
+			var cmp int
+			switch key.Field {
+			case "type":
+				cmp = strings.Compare(a.Type, b.Type)
+			case "symbol":
+				cmp = strings.Compare(a.Symbol, b.Symbol)
+			default:
+				cmp = compareInt64(a.CreatedAt, b.CreatedAt)
+			}
+			if cmp != 0 {
+				return (cmp < 0) != key.Desc
+			}
+		}
+		if a.CreatedAt != b.CreatedAt {
+			return a.CreatedAt > b.CreatedAt
+		}
+		return a.ID < b.ID
+	})
+
+	total := len(events)
+	if query.Limit > 0 {
+		start, end := query.Offset, query.Offset+query.Limit
+		if start > total {
+			start = total
+		}
+		if end > total {
+			end = total
+		}
+		events = events[start:end]
+	}
+	return events, total, nil
+}
+
+func (c *memoryClient) DeleteEventsBefore(createdAt int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := c.events[:0]
+	for _, e := range c.events {
+		if e.CreatedAt >= createdAt {
+			kept = append(kept, e)
+		}
+	}
+	c.events = kept
+	return nil
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func (c *memoryClient) AddAuditEntry(entry *AuditEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.auditLog = append(c.auditLog, *entry)
+	return nil
+}
+
+func (c *memoryClient) GetAuditEntries() ([]*AuditEntry, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entries := make([]*AuditEntry, 0, len(c.auditLog))
+	for _, e := range c.auditLog {
+		entry := e
+		entries = append(entries, &entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].CreatedAt < entries[j].CreatedAt })
+	return entries, nil
+}
+
+func (c *memoryClient) SaveKlines(klines []*Kline) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range klines {
+		c.klines[klineKey{symbol: k.Symbol, interval: k.Interval, openTime: k.OpenTime}] = *k
+	}
+	return nil
+}
+
+func (c *memoryClient) GetKlines(symbol, interval string, from, to int64) ([]*Kline, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	klines := make([]*Kline, 0)
+	for key, k := range c.klines {
+		if key.symbol == symbol && key.interval == interval && key.openTime >= from && key.openTime <= to {
+			kline := k
+			klines = append(klines, &kline)
+		}
+	}
+	sort.Slice(klines, func(i, j int) bool { return klines[i].OpenTime < klines[j].OpenTime })
+	return klines, nil
+}
+
+func (c *memoryClient) SetLeveragedOrders(accountID, market string, orders []*LeveragedOrder) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := make([]LeveragedOrder, 0, len(c.leveraged)+len(orders))
+	for _, o := range c.leveraged {
+		if o.AccountID != accountID || o.Market != market {
+			kept = append(kept, o)
+		}
+	}
+	for _, o := range orders {
+		order := *o
+		order.AccountID, order.Market = accountID, market
+		kept = append(kept, order)
+	}
+	c.leveraged = kept
+	return nil
+}
+
+func (c *memoryClient) GetLeveragedOrders() ([]*LeveragedOrder, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	orders := make([]*LeveragedOrder, 0, len(c.leveraged))
+	for _, o := range c.leveraged {
+		order := o
+		orders = append(orders, &order)
+	}
+	sort.SliceStable(orders, func(i, j int) bool {
+		if orders[i].AccountID != orders[j].AccountID {
+			return orders[i].AccountID < orders[j].AccountID
+		}
+		if orders[i].Market != orders[j].Market {
+			return orders[i].Market < orders[j].Market
+		}
+		return orders[i].Time < orders[j].Time
+	})
+	return orders, nil
+}
+
+func (c *memoryClient) SetPositions(accountID string, positions []*Position) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := make([]Position, 0, len(c.positions)+len(positions))
+	for _, p := range c.positions {
+		if p.AccountID != accountID {
+			kept = append(kept, p)
+		}
+	}
+	for _, p := range positions {
+		position := *p
+		position.AccountID = accountID
+		kept = append(kept, position)
+	}
+	c.positions = kept
+	return nil
+}
+
+func (c *memoryClient) GetPositions() ([]*Position, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	positions := make([]*Position, 0, len(c.positions))
+	for _, p := range c.positions {
+		position := p
+		positions = append(positions, &position)
+	}
+	sort.SliceStable(positions, func(i, j int) bool {
+		a, b := positions[i], positions[j]
+		if a.AccountID != b.AccountID {
+			return a.AccountID < b.AccountID
+		}
+		if a.Symbol != b.Symbol {
+			return a.Symbol < b.Symbol
+		}
+		return a.PositionSide < b.PositionSide
+	})
+	return positions, nil
+}
+
+func (c *memoryClient) SetFundingRates(rates []*FundingRate) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fundingRates = make([]FundingRate, 0, len(rates))
+	for _, r := range rates {
+		c.fundingRates = append(c.fundingRates, *r)
+	}
+	return nil
+}
+
+func (c *memoryClient) GetFundingRates() ([]*FundingRate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	rates := make([]*FundingRate, 0, len(c.fundingRates))
+	for _, r := range c.fundingRates {
+		rate := r
+		rates = append(rates, &rate)
+	}
+	sort.SliceStable(rates, func(i, j int) bool { return rates[i].Symbol < rates[j].Symbol })
+	return rates, nil
+}
+
+func (c *memoryClient) SetMarginStatus(status *MarginStatus) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, s := range c.marginStatus {
+		if s.AccountID == status.AccountID && s.Market == status.Market {
+			c.marginStatus[i] = *status
+			return nil
+		}
+	}
+	c.marginStatus = append(c.marginStatus, *status)
+	return nil
+}
+
+func (c *memoryClient) GetMarginStatuses() ([]*MarginStatus, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	statuses := make([]*MarginStatus, 0, len(c.marginStatus))
+	for _, s := range c.marginStatus {
+		status := s
+		statuses = append(statuses, &status)
+	}
+	sort.SliceStable(statuses, func(i, j int) bool {
+		if statuses[i].AccountID != statuses[j].AccountID {
+			return statuses[i].AccountID < statuses[j].AccountID
+		}
+		return statuses[i].Market < statuses[j].Market
+	})
+	return statuses, nil
+}
+
+func (c *memoryClient) SetOrderLists(accountID string, lists []*OrderList) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := make([]OrderList, 0, len(c.orderLists)+len(lists))
+	for _, l := range c.orderLists {
+		if l.AccountID != accountID {
+			kept = append(kept, l)
+		}
+	}
+	for _, l := range lists {
+		list := *l
+		list.AccountID = accountID
+		kept = append(kept, list)
+	}
+	c.orderLists = kept
+	return nil
+}
+
+func (c *memoryClient) GetOrderLists() ([]*OrderList, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	lists := make([]*OrderList, 0, len(c.orderLists))
+	for _, l := range c.orderLists {
+		list := l
+		lists = append(lists, &list)
+	}
+	sort.SliceStable(lists, func(i, j int) bool {
+		if lists[i].AccountID != lists[j].AccountID {
+			return lists[i].AccountID < lists[j].AccountID
+		}
+		return lists[i].OrderListID < lists[j].OrderListID
+	})
+	return lists, nil
+}
+
+func (c *memoryClient) AddUser(user *User) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, u := range c.users {
+		if u.ID == user.ID || u.Username == user.Username {
+			return fmt.Errorf("user %s already exists", user.Username)
+		}
+	}
+	c.users = append(c.users, copyUser(user))
+	return nil
+}
+
+func (c *memoryClient) UpdateUser(user *User) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, u := range c.users {
+		if u.ID == user.ID {
+			updated := copyUser(user)
+			updated.CreatedAt = u.CreatedAt
+			c.users[i] = updated
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (c *memoryClient) DeleteUser(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	users := c.users[:0]
+	for _, u := range c.users {
+		if u.ID != id {
+			users = append(users, u)
+		}
+	}
+	c.users = users
+	alerts := c.alerts[:0]
+	for _, a := range c.alerts {
+		if a.UserID != id {
+			alerts = append(alerts, a)
+		}
+	}
+	c.alerts = alerts
+	for sessionID, session := range c.sessions {
+		if session.UserID == id {
+			delete(c.sessions, sessionID)
+		}
+	}
+	tokens := c.apiTokens[:0]
+	for _, t := range c.apiTokens {
+		if t.UserID != id {
+			tokens = append(tokens, t)
+		}
+	}
+	c.apiTokens = tokens
+	return nil
+}
+
+func (c *memoryClient) GetUser(id string) (*User, error) {
+	return c.findUser(func(u *User) bool { return u.ID == id })
+}
+
+func (c *memoryClient) GetUserByUsername(username string) (*User, error) {
+	return c.findUser(func(u *User) bool { return u.Username == username })
+}
+
+func (c *memoryClient) findUser(match func(u *User) bool) (*User, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, u := range c.users {
+		if match(&u) {
+			user := copyUser(&u)
+			return &user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *memoryClient) GetUsers() ([]*User, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	users := make([]*User, 0, len(c.users))
+	for _, u := range c.users {
+		user := copyUser(&u)
+		users = append(users, &user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+// copyUser copies the user with its recovery codes, stored users are not shared with callers.
+func copyUser(user *User) User {
+	copied := *user
+	copied.RecoveryCodes = append([]string(nil), user.RecoveryCodes...)
+	return copied
+}
+
+func (c *memoryClient) AddSession(session *Session) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.sessions[session.ID]; ok {
+		return fmt.Errorf("session %s already exists", session.ID)
+	}
+	c.sessions[session.ID] = *session
+	return nil
+}
+
+func (c *memoryClient) GetSession(id string) (*Session, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	session, ok := c.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+func (c *memoryClient) GetUserSessions(userID string) ([]*Session, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	sessions := make([]*Session, 0)
+	for _, s := range c.sessions {
+		if s.UserID == userID {
+			session := s
+			sessions = append(sessions, &session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastSeenAt > sessions[j].LastSeenAt })
+	return sessions, nil
+}
+
+func (c *memoryClient) TouchSession(id string, lastSeenAt int64, ip string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if session, ok := c.sessions[id]; ok {
+		session.LastSeenAt, session.IP = lastSeenAt, ip
+		c.sessions[id] = session
+	}
+	return nil
+}
+
+func (c *memoryClient) DeleteSession(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.sessions, id)
+	return nil
+}
+
+func (c *memoryClient) DeleteUserSessions(userID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, s := range c.sessions {
+		if s.UserID == userID {
+			delete(c.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (c *memoryClient) DeleteExpiredSessions(now, lastSeenBefore int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, s := range c.sessions {
+		if s.ExpiresAt <= now || s.LastSeenAt < lastSeenBefore {
+			delete(c.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (c *memoryClient) AddAPIToken(token *APIToken) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range c.apiTokens {
+		if t.ID == token.ID || t.TokenHash == token.TokenHash {
+			return fmt.Errorf("API token %s already exists", token.ID)
+		}
+	}
+	c.apiTokens = append(c.apiTokens, copyAPIToken(token))
+	return nil
+}
+
+func (c *memoryClient) GetAPITokens() ([]*APIToken, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	tokens := make([]*APIToken, 0, len(c.apiTokens))
+	for i := range c.apiTokens {
+		token := copyAPIToken(&c.apiTokens[i])
+		tokens = append(tokens, &token)
+	}
+	sort.SliceStable(tokens, func(i, j int) bool { return tokens[i].CreatedAt > tokens[j].CreatedAt })
+	return tokens, nil
+}
+
+func (c *memoryClient) GetAPITokenByHash(hash string) (*APIToken, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for i := range c.apiTokens {
+		if c.apiTokens[i].TokenHash == hash {
+			token := copyAPIToken(&c.apiTokens[i])
+			return &token, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *memoryClient) TouchAPIToken(id string, lastUsedAt int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.apiTokens {
+		if c.apiTokens[i].ID == id {
+			c.apiTokens[i].LastUsedAt = lastUsedAt
+		}
+	}
+	return nil
+}
+
+func (c *memoryClient) DeleteAPIToken(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, t := range c.apiTokens {
+		if t.ID == id {
+			c.apiTokens = append(c.apiTokens[:i], c.apiTokens[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func copyAPIToken(token *APIToken) APIToken {
+	copied := *token
+	copied.Scopes = append([]string(nil), token.Scopes...)
+	return copied
+}
+
+func (c *memoryClient) AddIPBan(ban *IPBan) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, b := range c.ipBans {
+		if b.ID == ban.ID || b.Network == ban.Network {
+			return fmt.Errorf("ban of %s already exists", ban.Network)
+		}
+	}
+	c.ipBans = append(c.ipBans, *ban)
+	return nil
+}
+
+func (c *memoryClient) GetIPBans() ([]*IPBan, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	bans := make([]*IPBan, 0, len(c.ipBans))
+	for _, b := range c.ipBans {
+		ban := b
+		bans = append(bans, &ban)
+	}
+	sort.SliceStable(bans, func(i, j int) bool { return bans[i].CreatedAt > bans[j].CreatedAt })
+	return bans, nil
+}
+
+func (c *memoryClient) DeleteIPBan(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, b := range c.ipBans {
+		if b.ID == id {
+			c.ipBans = append(c.ipBans[:i], c.ipBans[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,347 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+)
+
+type migration struct {
+	version    int
+	name       string
+	statements []string
+}
+
+// migrations are applied in order and must never be edited once released, add a new one instead.
+// Type markers like {{BIGINT}} are resolved by the dialect.
+var migrations = []*migration{
+	{
+		version: 1,
+		name:    "create initial tables",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS orders (
+				"symbol" TEXT,
+				"orderId" {{BIGINT}},
+				"orderListId" {{BIGINT}},
+				"clientOrderId" TEXT,
+				"price" TEXT,
+				"origQty" TEXT,
+				"executedQty" TEXT,
+				"cummulativeQuoteQty" TEXT,
+				"status" TEXT,
+				"timeInForce" TEXT,
+				"type" TEXT,
+				"side" TEXT,
+				"stopPrice" TEXT,
+				"icebergQty" TEXT,
+				"time" {{BIGINT}},
+				"updateTime" {{BIGINT}},
+				"isWorking" BOOLEAN,
+				"lastOrderPrice" TEXT,
+				"marketPrice" TEXT,
+				"percentCompleted" TEXT,
+				"orderMarketPriceSpread" TEXT
+			)`,
+			`CREATE TABLE IF NOT EXISTS prices (
+				"symbol" TEXT,
+				"price" TEXT
+			)`,
+			`CREATE TABLE IF NOT EXISTS alerts (
+				"id" TEXT,
+				"symbol" TEXT,
+				"price" TEXT,
+				"name" TEXT,
+				"email" TEXT,
+				"text" TEXT,
+				"directionDown" BOOLEAN
+			)`,
+			`CREATE TABLE IF NOT EXISTS auth_requests (
+				"ip" TEXT,
+				"attempts" INTEGER,
+				"alertSent" BOOLEAN
+			)`,
+		},
+	},
+	{
+		version: 2,
+		name:    "create events table",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS events (
+				"id" TEXT PRIMARY KEY,
+				"type" TEXT,
+				"symbol" TEXT,
+				"message" TEXT,
+				"createdAt" {{BIGINT}}
+			)`,
+			`CREATE INDEX IF NOT EXISTS events_created_at ON events ("createdAt")`,
+		},
+	},
+	{
+		version: 3,
+		name:    "add alert audit columns",
+		statements: []string{
+			`ALTER TABLE alerts ADD COLUMN "createdAt" {{BIGINT}} NOT NULL DEFAULT 0`,
+			`ALTER TABLE alerts ADD COLUMN "createdBy" TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE alerts ADD COLUMN "updatedAt" {{BIGINT}} NOT NULL DEFAULT 0`,
+			`ALTER TABLE alerts ADD COLUMN "updatedBy" TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version: 4,
+		name:    "create klines table",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS klines (
+				"symbol" TEXT NOT NULL,
+				"interval" TEXT NOT NULL,
+				"openTime" {{BIGINT}} NOT NULL,
+				"open" TEXT,
+				"high" TEXT,
+				"low" TEXT,
+				"close" TEXT,
+				"volume" TEXT,
+				"closeTime" {{BIGINT}},
+				PRIMARY KEY ("symbol", "interval", "openTime")
+			)`,
+		},
+	},
+	{
+		version: 5,
+		name:    "create audit log table",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS audit_log (
+				"id" TEXT PRIMARY KEY,
+				"action" TEXT NOT NULL,
+				"username" TEXT NOT NULL,
+				"symbol" TEXT NOT NULL,
+				"test" BOOLEAN NOT NULL,
+				"request" TEXT NOT NULL,
+				"response" TEXT NOT NULL,
+				"error" TEXT NOT NULL,
+				"createdAt" {{BIGINT}} NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS audit_log_created_at ON audit_log ("createdAt")`,
+		},
+	},
+	{
+		version: 6,
+		name:    "add accounts",
+		statements: []string{
+			`ALTER TABLE orders ADD COLUMN "accountId" TEXT NOT NULL DEFAULT 'default'`,
+			`ALTER TABLE alerts ADD COLUMN "accountId" TEXT NOT NULL DEFAULT 'default'`,
+			`ALTER TABLE audit_log ADD COLUMN "accountId" TEXT NOT NULL DEFAULT 'default'`,
+			`CREATE TABLE IF NOT EXISTS balances (
+				"accountId" TEXT NOT NULL,
+				"asset" TEXT NOT NULL,
+				"free" TEXT NOT NULL,
+				"locked" TEXT NOT NULL,
+				"updatedAt" {{BIGINT}} NOT NULL,
+				PRIMARY KEY ("accountId", "asset")
+			)`,
+		},
+	},
+	{
+		version: 7,
+		name:    "add futures and margin",
+		statements: []string{
+			`ALTER TABLE alerts ADD COLUMN "type" TEXT NOT NULL DEFAULT 'price'`,
+			`CREATE TABLE IF NOT EXISTS leveraged_orders (
+				"accountId" TEXT NOT NULL,
+				"market" TEXT NOT NULL,
+				"symbol" TEXT NOT NULL,
+				"orderId" {{BIGINT}} NOT NULL,
+				"clientOrderId" TEXT NOT NULL,
+				"price" TEXT NOT NULL,
+				"origQty" TEXT NOT NULL,
+				"executedQty" TEXT NOT NULL,
+				"status" TEXT NOT NULL,
+				"timeInForce" TEXT NOT NULL,
+				"type" TEXT NOT NULL,
+				"side" TEXT NOT NULL,
+				"positionSide" TEXT NOT NULL,
+				"stopPrice" TEXT NOT NULL,
+				"reduceOnly" BOOLEAN NOT NULL,
+				"time" {{BIGINT}} NOT NULL,
+				"updateTime" {{BIGINT}} NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS leveraged_orders_account ON leveraged_orders ("accountId", "market")`,
+			`CREATE TABLE IF NOT EXISTS futures_positions (
+				"accountId" TEXT NOT NULL,
+				"symbol" TEXT NOT NULL,
+				"positionSide" TEXT NOT NULL,
+				"positionAmt" TEXT NOT NULL,
+				"entryPrice" TEXT NOT NULL,
+				"markPrice" TEXT NOT NULL,
+				"unrealizedProfit" TEXT NOT NULL,
+				"liquidationPrice" TEXT NOT NULL,
+				"leverage" INTEGER NOT NULL,
+				"marginType" TEXT NOT NULL,
+				"updatedAt" {{BIGINT}} NOT NULL,
+				PRIMARY KEY ("accountId", "symbol", "positionSide")
+			)`,
+			`CREATE TABLE IF NOT EXISTS funding_rates (
+				"symbol" TEXT PRIMARY KEY,
+				"markPrice" TEXT NOT NULL,
+				"fundingRate" TEXT NOT NULL,
+				"nextFundingTime" {{BIGINT}} NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS margin_status (
+				"accountId" TEXT NOT NULL,
+				"market" TEXT NOT NULL,
+				"marginRatio" TEXT NOT NULL,
+				"marginLevel" TEXT NOT NULL,
+				"updatedAt" {{BIGINT}} NOT NULL,
+				PRIMARY KEY ("accountId", "market")
+			)`,
+		},
+	},
+	{
+		version: 8,
+		name:    "add order lists",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS order_lists (
+				"accountId" TEXT NOT NULL,
+				"symbol" TEXT NOT NULL,
+				"orderListId" {{BIGINT}} NOT NULL,
+				"contingencyType" TEXT NOT NULL,
+				"listStatusType" TEXT NOT NULL,
+				"listOrderStatus" TEXT NOT NULL,
+				"listClientOrderId" TEXT NOT NULL,
+				"transactionTime" {{BIGINT}} NOT NULL,
+				"triggerDistance" TEXT NOT NULL,
+				PRIMARY KEY ("accountId", "orderListId")
+			)`,
+		},
+	},
+	{
+		version: 9,
+		name:    "add users and alert owners",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS users (
+				"id" TEXT NOT NULL PRIMARY KEY,
+				"username" TEXT NOT NULL UNIQUE,
+				"passwordHash" TEXT NOT NULL,
+				"role" TEXT NOT NULL,
+				"name" TEXT NOT NULL,
+				"email" TEXT NOT NULL,
+				"createdAt" {{BIGINT}} NOT NULL,
+				"updatedAt" {{BIGINT}} NOT NULL
+			)`,
+			`ALTER TABLE alerts ADD COLUMN "userId" TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version: 10,
+		name:    "add user two-factor authentication",
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN "totpSecret" TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE users ADD COLUMN "totpEnabled" BOOLEAN NOT NULL DEFAULT FALSE`,
+			`ALTER TABLE users ADD COLUMN "totpLastStep" {{BIGINT}} NOT NULL DEFAULT 0`,
+			`ALTER TABLE users ADD COLUMN "recoveryCodes" TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version: 11,
+		name:    "add sessions",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS sessions (
+				"id" TEXT NOT NULL PRIMARY KEY,
+				"userId" TEXT NOT NULL,
+				"userAgent" TEXT NOT NULL,
+				"ip" TEXT NOT NULL,
+				"createdAt" {{BIGINT}} NOT NULL,
+				"lastSeenAt" {{BIGINT}} NOT NULL,
+				"expiresAt" {{BIGINT}} NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS sessions_user_id ON sessions ("userId")`,
+		},
+	},
+	{
+		version: 12,
+		name:    "add api tokens",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS api_tokens (
+				"id" TEXT NOT NULL PRIMARY KEY,
+				"userId" TEXT NOT NULL,
+				"name" TEXT NOT NULL,
+				"prefix" TEXT NOT NULL,
+				"tokenHash" TEXT NOT NULL UNIQUE,
+				"scopes" TEXT NOT NULL,
+				"createdAt" {{BIGINT}} NOT NULL,
+				"expiresAt" {{BIGINT}} NOT NULL,
+				"lastUsedAt" {{BIGINT}} NOT NULL DEFAULT 0
+			)`,
+		},
+	},
+	{
+		version: 13,
+		name:    "add ip bans",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS ip_bans (
+				"id" TEXT NOT NULL PRIMARY KEY,
+				"network" TEXT NOT NULL UNIQUE,
+				"reason" TEXT NOT NULL,
+				"createdBy" TEXT NOT NULL,
+				"createdAt" {{BIGINT}} NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 14,
+		name:    "drop auth requests",
+		statements: []string{
+			`DROP TABLE IF EXISTS auth_requests`,
+		},
+	},
+	{
+		version: 15,
+		name:    "add event users",
+		statements: []string{
+			`ALTER TABLE events ADD COLUMN "userId" TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+}
+
+func migrate(sqlDB *sql.DB, d *dialect) error {
+	if _, err := sqlDB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		"version" INTEGER PRIMARY KEY,
+		"name" TEXT
+	)`); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if err := applyMigration(sqlDB, d, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyMigration(sqlDB *sql.DB, d *dialect, m *migration) error {
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = d.lockMigrations(tx); err != nil {
+		return err
+	}
+	var applied int
+	if err = tx.QueryRow(d.rebind(`SELECT COUNT(*) FROM schema_migrations WHERE "version" = ?`), m.version).Scan(&applied); err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	log.Printf("applying migration %d: %s...", m.version, m.name)
+	for _, statement := range m.statements {
+		if _, err = tx.Exec(d.types(statement)); err != nil {
+			return err
+		}
+	}
+	if _, err = tx.Exec(d.rebind(`INSERT INTO schema_migrations ("version", "name") VALUES (?, ?)`), m.version, m.name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
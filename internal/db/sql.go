@@ -0,0 +1,824 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const (
+	orderColumns = `"accountId", "symbol", "orderId", "orderListId", "clientOrderId", "price", "origQty", "executedQty", "cummulativeQuoteQty", "status", "timeInForce", "type", "side", "stopPrice", "icebergQty", "time", "updateTime", "isWorking", "lastOrderPrice", "marketPrice", "percentCompleted", "orderMarketPriceSpread"`
+	alertColumns = `"id", "userId", "accountId", "type", "symbol", "price", "name", "email", "text", "directionDown", "createdAt", "createdBy", "updatedAt", "updatedBy"`
+
+	leveragedOrderColumns = `"accountId", "market", "symbol", "orderId", "clientOrderId", "price", "origQty", "executedQty", "status", "timeInForce", "type", "side", "positionSide", "stopPrice", "reduceOnly", "time", "updateTime"`
+	orderListColumns      = `"accountId", "symbol", "orderListId", "contingencyType", "listStatusType", "listOrderStatus", "listClientOrderId", "transactionTime", "triggerDistance"`
+	positionColumns       = `"accountId", "symbol", "positionSide", "positionAmt", "entryPrice", "markPrice", "unrealizedProfit", "liquidationPrice", "leverage", "marginType", "updatedAt"`
+)
+
+type client struct {
+	db      *sql.DB
+	dialect *dialect
+}
+
+func (c *client) exec(query string, args ...interface{}) error {
+	statement, err := c.db.Prepare(c.dialect.rebind(query))
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+	_, err = statement.Exec(args...)
+	return err
+}
+
+func (c *client) query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.db.Query(c.dialect.rebind(query), args...)
+}
+
+// SetOrders replaces the orders of the account, orders of other accounts are kept.
+func (c *client) SetOrders(accountID string, orders []*Order) error {
+	log.Printf("inserting order records of account %s into db...", accountID)
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(c.dialect.rebind(`DELETE FROM orders WHERE "accountId" = ?`), accountID); err != nil {
+		return err
+	}
+	insertSQL := c.dialect.rebind(`INSERT INTO orders (` + orderColumns + `)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	for _, o := range orders {
+		_, err = tx.Exec(insertSQL, accountID, o.Symbol, o.OrderID, o.OrderListID, o.ClientOrderID, o.Price, o.OrigQty, o.ExecutedQty, o.CummulativeQuoteQty, o.Status, o.TimeInForce, o.Type, o.Side, o.StopPrice, o.IcebergQty, o.Time, o.UpdateTime, o.IsWorking, o.LastOrderPrice, o.MarketPrice, o.PercentCompleted, o.OrderMarketPriceSpread)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (c *client) GetOrders() ([]*Order, error) {
+	log.Println("getting order records from db...")
+	row, err := c.query(`SELECT ` + orderColumns + ` FROM orders`)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+
+	orders := make([]*Order, 0)
+	for row.Next() {
+		order := &Order{}
+		err = row.Scan(&order.AccountID, &order.Symbol, &order.OrderID, &order.OrderListID, &order.ClientOrderID, &order.Price, &order.OrigQty, &order.ExecutedQty, &order.CummulativeQuoteQty, &order.Status, &order.TimeInForce, &order.Type, &order.Side, &order.StopPrice, &order.IcebergQty, &order.Time, &order.UpdateTime, &order.IsWorking, &order.LastOrderPrice, &order.MarketPrice, &order.PercentCompleted, &order.OrderMarketPriceSpread)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, row.Err()
+}
+
+func (c *client) SetPrices(prices []*Price) error {
+	log.Println("inserting price records into db...")
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("DELETE FROM prices"); err != nil {
+		return err
+	}
+	insertSQL := c.dialect.rebind(`INSERT INTO prices ("symbol", "price") VALUES(?, ?)`)
+	for _, p := range prices {
+		if _, err = tx.Exec(insertSQL, p.Symbol, p.Price); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (c *client) GetPrices() ([]*Price, error) {
+	log.Println("getting price records from db...")
+	row, err := c.query(`SELECT "symbol", "price" FROM prices`)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+
+	prices := make([]*Price, 0)
+	for row.Next() {
+		price := &Price{}
+		if err = row.Scan(&price.Symbol, &price.Price); err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+	return prices, row.Err()
+}
+
+// SetBalances replaces the balances of the account, balances of other accounts are kept.
+func (c *client) SetBalances(accountID string, balances []*Balance) error {
+	log.Printf("inserting balance records of account %s into db...", accountID)
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(c.dialect.rebind(`DELETE FROM balances WHERE "accountId" = ?`), accountID); err != nil {
+		return err
+	}
+	insertSQL := c.dialect.rebind(`INSERT INTO balances ("accountId", "asset", "free", "locked", "updatedAt") VALUES(?, ?, ?, ?, ?)`)
+	for _, b := range balances {
+		if _, err = tx.Exec(insertSQL, accountID, b.Asset, b.Free, b.Locked, b.UpdatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (c *client) GetBalances() ([]*Balance, error) {
+	log.Println("getting balance records from db...")
+	row, err := c.query(`SELECT "accountId", "asset", "free", "locked", "updatedAt" FROM balances ORDER BY "accountId", "asset"`)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+
+	balances := make([]*Balance, 0)
+	for row.Next() {
+		b := &Balance{}
+		if err = row.Scan(&b.AccountID, &b.Asset, &b.Free, &b.Locked, &b.UpdatedAt); err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+	return balances, row.Err()
+}
+
+func (c *client) AddAlert(alert *Alert) error {
+	log.Println("inserting alert into db...")
+	return c.exec(`INSERT INTO alerts (`+alertColumns+`) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		alert.ID, alert.UserID, alert.AccountID, alert.Type, alert.Symbol, alert.Price, alert.Name, alert.Email, alert.Text, alert.DirectionDown, alert.CreatedAt, alert.CreatedBy, alert.UpdatedAt, alert.UpdatedBy)
+}
+
+func (c *client) UpdateAlert(alert *Alert) error {
+	log.Printf("updating alert with id %s...", alert.ID)
+	res, err := c.db.Exec(c.dialect.rebind(`UPDATE alerts
+		SET "accountId" = ?, "type" = ?, "symbol" = ?, "price" = ?, "name" = ?, "email" = ?, "text" = ?, "directionDown" = ?, "updatedAt" = ?, "updatedBy" = ?
+		WHERE "id" = ?`),
+		alert.AccountID, alert.Type, alert.Symbol, alert.Price, alert.Name, alert.Email, alert.Text, alert.DirectionDown, alert.UpdatedAt, alert.UpdatedBy, alert.ID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (c *client) DeleteAlert(id string) error {
+	log.Printf("deleting alert with id %s...", id)
+	return c.exec(`DELETE FROM alerts WHERE "id" = ?`, id)
+}
+
+func (c *client) GetAlerts() ([]*Alert, error) {
+	log.Println("getting alert records from db...")
+	row, err := c.query(`SELECT ` + alertColumns + ` FROM alerts`)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+
+	alerts := make([]*Alert, 0)
+	for row.Next() {
+		alert := &Alert{}
+		err = row.Scan(&alert.ID, &alert.UserID, &alert.AccountID, &alert.Type, &alert.Symbol, &alert.Price, &alert.Name, &alert.Email, &alert.Text, &alert.DirectionDown, &alert.CreatedAt, &alert.CreatedBy, &alert.UpdatedAt, &alert.UpdatedBy)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, row.Err()
+}
+
+func (c *client) AddEvent(event *Event) error {
+	return c.exec(`INSERT INTO events ("id", "type", "symbol", "message", "userId", "createdAt") VALUES(?, ?, ?, ?, ?, ?)`,
+		event.ID, event.Type, event.Symbol, event.Message, event.UserID, event.CreatedAt)
+}
+
+// GetEvents filters, sorts and pages events in the database and returns the page with the number of matching events.
+func (c *client) GetEvents(query *EventQuery) ([]*Event, int, error) {
+	log.Println("getting event records from db...")
+	var where []string
+	var args []interface{}
+	for column, values := range map[string][]string{`"type"`: query.Types, `"symbol"`: query.Symbols} {
+		if len(values) == 0 {
+			continue
+		}
+		where = append(where, column+` IN (?`+strings.Repeat(`, ?`, len(values)-1)+`)`)
+		for _, v := range values {
+			args = append(args, v)
+		}
+	}
+	if query.UserID != "" {
+		where = append(where, `("userId" = ? OR ("userId" = '' AND "type" <> ?))`)
+		args = append(args, query.UserID, EventTypeAlertTriggered)
+	}
+	filter := ""
+	if len(where) > 0 {
+		filter = ` WHERE ` + strings.Join(where, ` AND `)
+	}
+
+	var total int
+	if err := c.db.QueryRow(c.dialect.rebind(`SELECT COUNT(*) FROM events`+filter), args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	orderBy, err := eventOrderBy(query.Sort)
+	if err != nil {
+		return nil, 0, err
+	}
+	selectSQL := `SELECT "id", "type", "symbol", "message", "userId", "createdAt" FROM events` + filter + ` ORDER BY ` + orderBy
+	if query.Limit > 0 {
+		selectSQL += ` LIMIT ? OFFSET ?`
+		args = append(args, query.Limit, query.Offset)
+	}
+	row, err := c.query(selectSQL, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer row.Close()
+
+	events := make([]*Event, 0)
+	for row.Next() {
+		event := &Event{}
+		if err = row.Scan(&event.ID, &event.Type, &event.Symbol, &event.Message, &event.UserID, &event.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		events = append(events, event)
+	}
+	return events, total, row.Err()
+}
+
+// eventOrderBy converts the sort keys to an ORDER BY clause, the IDs keep events created at once in a stable order.
+func eventOrderBy(keys []EventSort) (string, error) {
+	var columns []string
+	for _, key := range keys {
+		if !isEventSortField(key.Field) {
+			return "", fmt.Errorf("sorting events by %q is not supported", key.Field)
+		}
+		column := `"` + key.Field + `"`
+		if key.Desc {
+			column += ` DESC`
+		}
+		columns = append(columns, column)
+	}
+	return strings.Join(append(columns, `"createdAt" DESC`, `"id"`), `, `), nil
+}
+
+func (c *client) DeleteEventsBefore(createdAt int64) error {
+	return c.exec(`DELETE FROM events WHERE "createdAt" < ?`, createdAt)
+}
+
+func (c *client) AddAuditEntry(entry *AuditEntry) error {
+	return c.exec(`INSERT INTO audit_log ("id", "action", "username", "accountId", "symbol", "test", "request", "response", "error", "createdAt")
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.ID, entry.Action, entry.Username, entry.AccountID, entry.Symbol, entry.Test, entry.Request, entry.Response, entry.Error, entry.CreatedAt)
+}
+
+func (c *client) GetAuditEntries() ([]*AuditEntry, error) {
+	row, err := c.query(`SELECT "id", "action", "username", "accountId", "symbol", "test", "request", "response", "error", "createdAt"
+		FROM audit_log ORDER BY "createdAt"`)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+
+	entries := make([]*AuditEntry, 0)
+	for row.Next() {
+		entry := &AuditEntry{}
+		if err = row.Scan(&entry.ID, &entry.Action, &entry.Username, &entry.AccountID, &entry.Symbol, &entry.Test, &entry.Request, &entry.Response, &entry.Error, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, row.Err()
+}
+
+// SaveKlines inserts klines replacing the cached ones with the same open time.
+func (c *client) SaveKlines(klines []*Kline) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	upsertSQL := c.dialect.rebind(`INSERT INTO klines ("symbol", "interval", "openTime", "open", "high", "low", "close", "volume", "closeTime")
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT ("symbol", "interval", "openTime") DO UPDATE SET
+			"open" = excluded."open", "high" = excluded."high", "low" = excluded."low", "close" = excluded."close",
+			"volume" = excluded."volume", "closeTime" = excluded."closeTime"`)
+	for _, k := range klines {
+		if _, err = tx.Exec(upsertSQL, k.Symbol, k.Interval, k.OpenTime, k.Open, k.High, k.Low, k.Close, k.Volume, k.CloseTime); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetKlines returns cached klines with open time in [from, to] ordered by open time.
+func (c *client) GetKlines(symbol, interval string, from, to int64) ([]*Kline, error) {
+	row, err := c.query(`SELECT "symbol", "interval", "openTime", "open", "high", "low", "close", "volume", "closeTime"
+		FROM klines WHERE "symbol" = ? AND "interval" = ? AND "openTime" >= ? AND "openTime" <= ?
+		ORDER BY "openTime"`, symbol, interval, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+
+	klines := make([]*Kline, 0)
+	for row.Next() {
+		k := &Kline{}
+		if err = row.Scan(&k.Symbol, &k.Interval, &k.OpenTime, &k.Open, &k.High, &k.Low, &k.Close, &k.Volume, &k.CloseTime); err != nil {
+			return nil, err
+		}
+		klines = append(klines, k)
+	}
+	return klines, row.Err()
+}
+
+// SetLeveragedOrders replaces the orders of the account on the market, other orders are kept.
+func (c *client) SetLeveragedOrders(accountID, market string, orders []*LeveragedOrder) error {
+	log.Printf("inserting %s order records of account %s into db...", market, accountID)
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(c.dialect.rebind(`DELETE FROM leveraged_orders WHERE "accountId" = ? AND "market" = ?`), accountID, market); err != nil {
+		return err
+	}
+	insertSQL := c.dialect.rebind(`INSERT INTO leveraged_orders (` + leveragedOrderColumns + `)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	for _, o := range orders {
+		_, err = tx.Exec(insertSQL, accountID, market, o.Symbol, o.OrderID, o.ClientOrderID, o.Price, o.OrigQty, o.ExecutedQty, o.Status, o.TimeInForce, o.Type, o.Side, o.PositionSide, o.StopPrice, o.ReduceOnly, o.Time, o.UpdateTime)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (c *client) GetLeveragedOrders() ([]*LeveragedOrder, error) {
+	log.Println("getting futures and margin order records from db...")
+	row, err := c.query(`SELECT ` + leveragedOrderColumns + ` FROM leveraged_orders ORDER BY "accountId", "market", "time"`)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+
+	orders := make([]*LeveragedOrder, 0)
+	for row.Next() {
+		o := &LeveragedOrder{}
+		err = row.Scan(&o.AccountID, &o.Market, &o.Symbol, &o.OrderID, &o.ClientOrderID, &o.Price, &o.OrigQty, &o.ExecutedQty, &o.Status, &o.TimeInForce, &o.Type, &o.Side, &o.PositionSide, &o.StopPrice, &o.ReduceOnly, &o.Time, &o.UpdateTime)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, row.Err()
+}
+
+// SetPositions replaces the futures positions of the account, positions of other accounts are kept.
+func (c *client) SetPositions(accountID string, positions []*Position) error {
+	log.Printf("inserting position records of account %s into db...", accountID)
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(c.dialect.rebind(`DELETE FROM futures_positions WHERE "accountId" = ?`), accountID); err != nil {
+		return err
+	}
+	insertSQL := c.dialect.rebind(`INSERT INTO futures_positions (` + positionColumns + `) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	for _, p := range positions {
+		_, err = tx.Exec(insertSQL, accountID, p.Symbol, p.PositionSide, p.PositionAmt, p.EntryPrice, p.MarkPrice, p.UnrealizedProfit, p.LiquidationPrice, p.Leverage, p.MarginType, p.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (c *client) GetPositions() ([]*Position, error) {
+	log.Println("getting position records from db...")
+	row, err := c.query(`SELECT ` + positionColumns + ` FROM futures_positions ORDER BY "accountId", "symbol", "positionSide"`)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+
+	positions := make([]*Position, 0)
+	for row.Next() {
+		p := &Position{}
+		err = row.Scan(&p.AccountID, &p.Symbol, &p.PositionSide, &p.PositionAmt, &p.EntryPrice, &p.MarkPrice, &p.UnrealizedProfit, &p.LiquidationPrice, &p.Leverage, &p.MarginType, &p.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, p)
+	}
+	return positions, row.Err()
+}
+
+func (c *client) SetFundingRates(rates []*FundingRate) error {
+	log.Println("inserting funding rate records into db...")
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("DELETE FROM funding_rates"); err != nil {
+		return err
+	}
+	insertSQL := c.dialect.rebind(`INSERT INTO funding_rates ("symbol", "markPrice", "fundingRate", "nextFundingTime") VALUES(?, ?, ?, ?)`)
+	for _, r := range rates {
+		if _, err = tx.Exec(insertSQL, r.Symbol, r.MarkPrice, r.FundingRate, r.NextFundingTime); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (c *client) GetFundingRates() ([]*FundingRate, error) {
+	log.Println("getting funding rate records from db...")
+	row, err := c.query(`SELECT "symbol", "markPrice", "fundingRate", "nextFundingTime" FROM funding_rates ORDER BY "symbol"`)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+
+	rates := make([]*FundingRate, 0)
+	for row.Next() {
+		r := &FundingRate{}
+		if err = row.Scan(&r.Symbol, &r.MarkPrice, &r.FundingRate, &r.NextFundingTime); err != nil {
+			return nil, err
+		}
+		rates = append(rates, r)
+	}
+	return rates, row.Err()
+}
+
+// SetMarginStatus replaces the margin status of the account on the status market.
+func (c *client) SetMarginStatus(status *MarginStatus) error {
+	log.Printf("inserting %s margin status of account %s into db...", status.Market, status.AccountID)
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(c.dialect.rebind(`DELETE FROM margin_status WHERE "accountId" = ? AND "market" = ?`), status.AccountID, status.Market); err != nil {
+		return err
+	}
+	_, err = tx.Exec(c.dialect.rebind(`INSERT INTO margin_status ("accountId", "market", "marginRatio", "marginLevel", "updatedAt") VALUES(?, ?, ?, ?, ?)`),
+		status.AccountID, status.Market, status.MarginRatio, status.MarginLevel, status.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (c *client) GetMarginStatuses() ([]*MarginStatus, error) {
+	log.Println("getting margin status records from db...")
+	row, err := c.query(`SELECT "accountId", "market", "marginRatio", "marginLevel", "updatedAt" FROM margin_status ORDER BY "accountId", "market"`)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+
+	statuses := make([]*MarginStatus, 0)
+	for row.Next() {
+		s := &MarginStatus{}
+		if err = row.Scan(&s.AccountID, &s.Market, &s.MarginRatio, &s.MarginLevel, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, row.Err()
+}
+
+// SetOrderLists replaces the open order lists of the account, lists of other accounts are kept.
+func (c *client) SetOrderLists(accountID string, lists []*OrderList) error {
+	log.Printf("inserting order list records of account %s into db...", accountID)
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(c.dialect.rebind(`DELETE FROM order_lists WHERE "accountId" = ?`), accountID); err != nil {
+		return err
+	}
+	insertSQL := c.dialect.rebind(`INSERT INTO order_lists (` + orderListColumns + `) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	for _, l := range lists {
+		_, err = tx.Exec(insertSQL, accountID, l.Symbol, l.OrderListID, l.ContingencyType, l.ListStatusType, l.ListOrderStatus, l.ListClientOrderID, l.TransactionTime, l.TriggerDistance)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (c *client) GetOrderLists() ([]*OrderList, error) {
+	log.Println("getting order list records from db...")
+	row, err := c.query(`SELECT ` + orderListColumns + ` FROM order_lists ORDER BY "accountId", "orderListId"`)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+
+	lists := make([]*OrderList, 0)
+	for row.Next() {
+		l := &OrderList{}
+		err = row.Scan(&l.AccountID, &l.Symbol, &l.OrderListID, &l.ContingencyType, &l.ListStatusType, &l.ListOrderStatus, &l.ListClientOrderID, &l.TransactionTime, &l.TriggerDistance)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, l)
+	}
+	return lists, row.Err()
+}
+
+const userColumns = `"id", "username", "passwordHash", "role", "name", "email", "totpSecret", "totpEnabled", "totpLastStep", "recoveryCodes", "createdAt", "updatedAt"`
+
+func (c *client) AddUser(user *User) error {
+	log.Printf("inserting user %s into db...", user.Username)
+	return c.exec(`INSERT INTO users (`+userColumns+`) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		user.ID, user.Username, user.PasswordHash, user.Role, user.Name, user.Email,
+		user.TOTPSecret, user.TOTPEnabled, user.TOTPLastStep, strings.Join(user.RecoveryCodes, ","), user.CreatedAt, user.UpdatedAt)
+}
+
+func (c *client) UpdateUser(user *User) error {
+	log.Printf("updating user with id %s...", user.ID)
+	res, err := c.db.Exec(c.dialect.rebind(`UPDATE users
+		SET "username" = ?, "passwordHash" = ?, "role" = ?, "name" = ?, "email" = ?,
+			"totpSecret" = ?, "totpEnabled" = ?, "totpLastStep" = ?, "recoveryCodes" = ?, "updatedAt" = ?
+		WHERE "id" = ?`),
+		user.Username, user.PasswordHash, user.Role, user.Name, user.Email,
+		user.TOTPSecret, user.TOTPEnabled, user.TOTPLastStep, strings.Join(user.RecoveryCodes, ","), user.UpdatedAt, user.ID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteUser deletes the user with the alerts, the sessions and the API tokens of the user.
+func (c *client) DeleteUser(id string) error {
+	log.Printf("deleting user with id %s...", id)
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(c.dialect.rebind(`DELETE FROM alerts WHERE "userId" = ?`), id); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(c.dialect.rebind(`DELETE FROM sessions WHERE "userId" = ?`), id); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(c.dialect.rebind(`DELETE FROM api_tokens WHERE "userId" = ?`), id); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(c.dialect.rebind(`DELETE FROM users WHERE "id" = ?`), id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetUser returns nil when the user does not exist.
+func (c *client) GetUser(id string) (*User, error) {
+	return c.getUser(`"id"`, id)
+}
+
+// GetUserByUsername returns nil when the user does not exist.
+func (c *client) GetUserByUsername(username string) (*User, error) {
+	return c.getUser(`"username"`, username)
+}
+
+func (c *client) getUser(column, value string) (*User, error) {
+	user, err := scanUser(c.db.QueryRow(c.dialect.rebind(`SELECT `+userColumns+` FROM users WHERE `+column+` = ?`), value))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (c *client) GetUsers() ([]*User, error) {
+	row, err := c.query(`SELECT ` + userColumns + ` FROM users ORDER BY "username"`)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+
+	users := make([]*User, 0)
+	for row.Next() {
+		user, err := scanUser(row)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, row.Err()
+}
+
+// rowScanner is *sql.Row or *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*User, error) {
+	user := &User{}
+	var recoveryCodes string
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.Name, &user.Email,
+		&user.TOTPSecret, &user.TOTPEnabled, &user.TOTPLastStep, &recoveryCodes, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if recoveryCodes != "" {
+		user.RecoveryCodes = strings.Split(recoveryCodes, ",")
+	}
+	return user, nil
+}
+
+const sessionColumns = `"id", "userId", "userAgent", "ip", "createdAt", "lastSeenAt", "expiresAt"`
+
+func (c *client) AddSession(session *Session) error {
+	log.Printf("inserting session of user %s into db...", session.UserID)
+	return c.exec(`INSERT INTO sessions (`+sessionColumns+`) VALUES(?, ?, ?, ?, ?, ?, ?)`,
+		session.ID, session.UserID, session.UserAgent, session.IP, session.CreatedAt, session.LastSeenAt, session.ExpiresAt)
+}
+
+// GetSession returns nil when the session does not exist.
+func (c *client) GetSession(id string) (*Session, error) {
+	row := c.db.QueryRow(c.dialect.rebind(`SELECT `+sessionColumns+` FROM sessions WHERE "id" = ?`), id)
+	session := &Session{}
+	err := row.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return session, nil
+}
+
+// GetUserSessions returns the sessions of the user, the last used first.
+func (c *client) GetUserSessions(userID string) ([]*Session, error) {
+	row, err := c.query(`SELECT `+sessionColumns+` FROM sessions WHERE "userId" = ? ORDER BY "lastSeenAt" DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+
+	sessions := make([]*Session, 0)
+	for row.Next() {
+		session := &Session{}
+		if err = row.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, row.Err()
+}
+
+func (c *client) TouchSession(id string, lastSeenAt int64, ip string) error {
+	return c.exec(`UPDATE sessions SET "lastSeenAt" = ?, "ip" = ? WHERE "id" = ?`, lastSeenAt, ip, id)
+}
+
+func (c *client) DeleteSession(id string) error {
+	log.Printf("deleting session with id %s...", id)
+	return c.exec(`DELETE FROM sessions WHERE "id" = ?`, id)
+}
+
+func (c *client) DeleteUserSessions(userID string) error {
+	log.Printf("deleting sessions of user %s...", userID)
+	return c.exec(`DELETE FROM sessions WHERE "userId" = ?`, userID)
+}
+
+// DeleteExpiredSessions deletes sessions which expired at now or were last seen before lastSeenBefore.
+func (c *client) DeleteExpiredSessions(now, lastSeenBefore int64) error {
+	return c.exec(`DELETE FROM sessions WHERE "expiresAt" <= ? OR "lastSeenAt" < ?`, now, lastSeenBefore)
+}
+
+const apiTokenColumns = `"id", "userId", "name", "prefix", "tokenHash", "scopes", "createdAt", "expiresAt", "lastUsedAt"`
+
+func (c *client) AddAPIToken(token *APIToken) error {
+	log.Printf("inserting API token %s of user %s into db...", token.Prefix, token.UserID)
+	return c.exec(`INSERT INTO api_tokens (`+apiTokenColumns+`) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		token.ID, token.UserID, token.Name, token.Prefix, token.TokenHash, strings.Join(token.Scopes, ","), token.CreatedAt, token.ExpiresAt, token.LastUsedAt)
+}
+
+// GetAPITokens returns the tokens of every user, the latest created first.
+func (c *client) GetAPITokens() ([]*APIToken, error) {
+	row, err := c.query(`SELECT ` + apiTokenColumns + ` FROM api_tokens ORDER BY "createdAt" DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+
+	tokens := make([]*APIToken, 0)
+	for row.Next() {
+		token, err := scanAPIToken(row)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, row.Err()
+}
+
+// GetAPITokenByHash returns nil when there is no token with the hash.
+func (c *client) GetAPITokenByHash(hash string) (*APIToken, error) {
+	row := c.db.QueryRow(c.dialect.rebind(`SELECT `+apiTokenColumns+` FROM api_tokens WHERE "tokenHash" = ?`), hash)
+	token, err := scanAPIToken(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+func (c *client) TouchAPIToken(id string, lastUsedAt int64) error {
+	return c.exec(`UPDATE api_tokens SET "lastUsedAt" = ? WHERE "id" = ?`, lastUsedAt, id)
+}
+
+func (c *client) DeleteAPIToken(id string) error {
+	log.Printf("deleting API token with id %s...", id)
+	return c.exec(`DELETE FROM api_tokens WHERE "id" = ?`, id)
+}
+
+func scanAPIToken(row rowScanner) (*APIToken, error) {
+	token := &APIToken{}
+	var scopes string
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.Prefix, &token.TokenHash, &scopes,
+		&token.CreatedAt, &token.ExpiresAt, &token.LastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+	if scopes != "" {
+		token.Scopes = strings.Split(scopes, ",")
+	}
+	return token, nil
+}
+
+func (c *client) AddIPBan(ban *IPBan) error {
+	log.Printf("inserting ban of %s into db...", ban.Network)
+	return c.exec(`INSERT INTO ip_bans ("id", "network", "reason", "createdBy", "createdAt") VALUES(?, ?, ?, ?, ?)`,
+		ban.ID, ban.Network, ban.Reason, ban.CreatedBy, ban.CreatedAt)
+}
+
+// GetIPBans returns the bans, the latest created first.
+func (c *client) GetIPBans() ([]*IPBan, error) {
+	row, err := c.query(`SELECT "id", "network", "reason", "createdBy", "createdAt" FROM ip_bans ORDER BY "createdAt" DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer row.Close()
+
+	bans := make([]*IPBan, 0)
+	for row.Next() {
+		ban := &IPBan{}
+		if err = row.Scan(&ban.ID, &ban.Network, &ban.Reason, &ban.CreatedBy, &ban.CreatedAt); err != nil {
+			return nil, err
+		}
+		bans = append(bans, ban)
+	}
+	return bans, row.Err()
+}
+
+func (c *client) DeleteIPBan(id string) error {
+	log.Printf("deleting ban with id %s...", id)
+	return c.exec(`DELETE FROM ip_bans WHERE "id" = ?`, id)
+}
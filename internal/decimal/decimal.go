@@ -0,0 +1,165 @@
+package decimal
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// DivisionPrecision is the number of decimals kept when a division result has no finite decimal representation.
+const DivisionPrecision = 16
+
+var (
+	ErrDivisionByZero = errors.New("decimal division by zero")
+
+	decimalRegexp = regexp.MustCompile(`^[+-]?([0-9]+\.?[0-9]*|\.[0-9]+)$`)
+)
+
+// Decimal is an exact decimal number. Binance sends prices and quantities as decimal strings,
+// they are parsed into Decimal for arithmetic and comparisons instead of float64, which can't
+// represent most of them exactly. The zero value is 0 and values are immutable.
+type Decimal struct {
+	rat *big.Rat
+}
+
+var Zero = Decimal{}
+
+// Parse parses a decimal string like "0.00001230" or "-12". Exponents, fractions and base prefixes are rejected.
+func Parse(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	if !decimalRegexp.MatchString(s) {
+		return Zero, fmt.Errorf("invalid decimal %q", s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Zero, fmt.Errorf("invalid decimal %q", s)
+	}
+	return Decimal{rat: r}, nil
+}
+
+// MustParse is Parse for constants, it panics on invalid input.
+func MustParse(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func NewFromInt(i int64) Decimal {
+	return Decimal{rat: new(big.Rat).SetInt64(i)}
+}
+
+func (d Decimal) r() *big.Rat {
+	if d.rat == nil {
+		return new(big.Rat)
+	}
+	return d.rat
+}
+
+func (d Decimal) Add(o Decimal) Decimal {
+	return Decimal{rat: new(big.Rat).Add(d.r(), o.r())}
+}
+
+func (d Decimal) Sub(o Decimal) Decimal {
+	return Decimal{rat: new(big.Rat).Sub(d.r(), o.r())}
+}
+
+func (d Decimal) Mul(o Decimal) Decimal {
+	return Decimal{rat: new(big.Rat).Mul(d.r(), o.r())}
+}
+
+func (d Decimal) Div(o Decimal) (Decimal, error) {
+	if o.IsZero() {
+		return Zero, ErrDivisionByZero
+	}
+	return Decimal{rat: new(big.Rat).Quo(d.r(), o.r())}, nil
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{rat: new(big.Rat).Neg(d.r())}
+}
+
+func (d Decimal) Abs() Decimal {
+	return Decimal{rat: new(big.Rat).Abs(d.r())}
+}
+
+// Cmp returns -1, 0 or 1 when d is less than, equal to or greater than o.
+func (d Decimal) Cmp(o Decimal) int {
+	return d.r().Cmp(o.r())
+}
+
+func (d Decimal) Equal(o Decimal) bool {
+	return d.Cmp(o) == 0
+}
+
+func (d Decimal) LessThan(o Decimal) bool {
+	return d.Cmp(o) < 0
+}
+
+func (d Decimal) GreaterThan(o Decimal) bool {
+	return d.Cmp(o) > 0
+}
+
+func (d Decimal) Sign() int {
+	return d.r().Sign()
+}
+
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+func (d Decimal) IsInteger() bool {
+	return d.r().IsInt()
+}
+
+// Truncate drops the fraction, rounding towards zero.
+func (d Decimal) Truncate() *big.Int {
+	r := d.r()
+	return new(big.Int).Quo(r.Num(), r.Denom())
+}
+
+// StringFixed rounds d half away from zero to the number of decimals.
+func (d Decimal) StringFixed(decimals int) string {
+	return d.r().FloatString(decimals)
+}
+
+// String returns the shortest exact representation without trailing zeros, results of divisions
+// which have no finite representation are rounded to DivisionPrecision decimals.
+func (d Decimal) String() string {
+	s := d.r().FloatString(d.decimals())
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// decimals returns the number of decimals of the exact representation, capped with DivisionPrecision.
+// A fraction has a finite decimal representation only when its denominator is 2^a * 5^b.
+func (d Decimal) decimals() int {
+	denom := new(big.Int).Set(d.r().Denom())
+	twos := int(denom.TrailingZeroBits())
+	denom.Rsh(denom, uint(twos))
+
+	five, rem, fives := big.NewInt(5), new(big.Int), 0
+	for {
+		q, m := new(big.Int).QuoRem(denom, five, rem)
+		if m.Sign() != 0 {
+			break
+		}
+		denom = q
+		fives++
+	}
+	if denom.Cmp(big.NewInt(1)) != 0 {
+		return DivisionPrecision
+	}
+	if twos > fives {
+		return twos
+	}
+	return fives
+}
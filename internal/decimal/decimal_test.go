@@ -0,0 +1,106 @@
+package decimal
+
+import (
+	"math/big"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// testDecimal generates decimals with up to 18 fraction digits like Binance prices and quantities.
+type testDecimal struct {
+	d Decimal
+}
+
+func (testDecimal) Generate(r *rand.Rand, _ int) reflect.Value {
+	unscaled := big.NewInt(r.Int63n(1<<62) - 1<<61)
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(r.Intn(19))), nil)
+	return reflect.ValueOf(testDecimal{d: Decimal{rat: new(big.Rat).SetFrac(unscaled, scale)}})
+}
+
+func TestParseString(t *testing.T) {
+	for s, expected := range map[string]string{
+		"0.00001230":   "0.0000123",
+		"-12":          "-12",
+		"12.":          "12",
+		".5":           "0.5",
+		"-0.000":       "0",
+		"000100.00100": "100.001",
+	} {
+		d, err := Parse(s)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if d.String() != expected {
+			t.Fatalf("%s: expected %s, got %s", s, expected, d.String())
+		}
+	}
+	for _, s := range []string{"", "N/A", "1e5", "1/3", "0x10", "1_000", "--1", "."} {
+		if _, err := Parse(s); err == nil {
+			t.Fatalf("%q should not be parsed", s)
+		}
+	}
+}
+
+func TestDiv(t *testing.T) {
+	if _, err := NewFromInt(1).Div(Zero); err != ErrDivisionByZero {
+		t.Fatalf("expected division by zero error, got %v", err)
+	}
+	third, _ := NewFromInt(1).Div(NewFromInt(3))
+	if third.String() != "0."+strings.Repeat("3", DivisionPrecision) {
+		t.Fatalf("unexpected 1/3 %s", third)
+	}
+	if d, _ := MustParse("0.3").Div(MustParse("0.1")); d.String() != "3" || !d.IsInteger() {
+		t.Fatalf("0.3/0.1 should be exactly 3, got %s", d)
+	}
+}
+
+func TestZeroValue(t *testing.T) {
+	var d Decimal
+	if !d.IsZero() || d.String() != "0" || !d.Add(NewFromInt(2)).Equal(NewFromInt(2)) {
+		t.Fatal("zero value should behave as 0")
+	}
+}
+
+func TestStringRoundTripProperty(t *testing.T) {
+	f := func(a testDecimal) bool {
+		parsed, err := Parse(a.d.String())
+		return err == nil && parsed.Equal(a.d)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArithmeticProperties(t *testing.T) {
+	f := func(a, b testDecimal) bool {
+		sum := a.d.Add(b.d)
+		if !sum.Sub(b.d).Equal(a.d) || !sum.Equal(b.d.Add(a.d)) {
+			return false
+		}
+		if a.d.Cmp(b.d) != -b.d.Cmp(a.d) || a.d.Sub(b.d).Sign() != a.d.Cmp(b.d) {
+			return false
+		}
+		if b.d.IsZero() {
+			return true
+		}
+		q, err := a.d.Mul(b.d).Div(b.d)
+		return err == nil && q.Equal(a.d)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTruncateProperty(t *testing.T) {
+	f := func(a testDecimal) bool {
+		i := Decimal{rat: new(big.Rat).SetInt(a.d.Truncate())}
+		// truncation goes towards zero and drops less than 1
+		return i.Abs().Cmp(a.d.Abs()) <= 0 && a.d.Sub(i).Abs().LessThan(NewFromInt(1)) && (i.IsZero() || i.Sign() == a.d.Sign())
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,89 @@
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	TypeOrders         = "orders"
+	TypePrices         = "prices"
+	TypeBalances       = "balances"
+	TypePositions      = "positions"
+	TypeAlerts         = "alerts"
+	TypeAlertTriggered = "alert_triggered"
+	TypeOrderListDone  = "order_list_done"
+	TypeFetchStatus    = "fetch_status"
+
+	FetchStatusStarted   = "started"
+	FetchStatusCompleted = "completed"
+	FetchStatusFailed    = "failed"
+
+	subscriberBufferSize = 16
+)
+
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+type FetchStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Time   int64  `json:"time"`
+}
+
+// Bus delivers events from fetcher and checker to the connected dashboards.
+// Publish never blocks, events are dropped for subscribers which don't keep up.
+type Bus interface {
+	Publish(eventType string, data interface{})
+	Subscribe() (events <-chan *Event, unsubscribe func())
+}
+
+type bus struct {
+	mu          sync.RWMutex
+	subscribers map[chan *Event]struct{}
+}
+
+func NewBus() Bus {
+	return &bus{subscribers: make(map[chan *Event]struct{})}
+}
+
+func NewFetchStatus(status string, err error) *FetchStatus {
+	s := &FetchStatus{Status: status, Time: time.Now().UnixMilli()}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	return s
+}
+
+func (b *bus) Publish(eventType string, data interface{}) {
+	e := &Event{Type: eventType, Data: data}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			log.Printf("dropping %s event for slow subscriber", eventType)
+		}
+	}
+}
+
+func (b *bus) Subscribe() (<-chan *Event, func()) {
+	ch := make(chan *Event, subscriberBufferSize)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package events
+
+import "testing"
+
+func TestBus(t *testing.T) {
+	b := NewBus()
+	first, unsubscribeFirst := b.Subscribe()
+	second, unsubscribeSecond := b.Subscribe()
+	defer unsubscribeSecond()
+
+	b.Publish(TypePrices, "data")
+	for _, ch := range []<-chan *Event{first, second} {
+		e := <-ch
+		if e.Type != TypePrices || e.Data != "data" {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	}
+
+	unsubscribeFirst()
+	unsubscribeFirst()
+	if _, ok := <-first; ok {
+		t.Fatal("channel should be closed after unsubscribe")
+	}
+	b.Publish(TypeOrders, nil)
+	if e := <-second; e.Type != TypeOrders {
+		t.Fatalf("unexpected event %+v", e)
+	}
+}
+
+func TestBusDropsEventsForSlowSubscribers(t *testing.T) {
+	b := NewBus()
+	ch, unsubscribe := b.Subscribe()
+	defer unsubscribe()
+
+	for i := 0; i < subscriberBufferSize*2; i++ {
+		b.Publish(TypePrices, i)
+	}
+	if len(ch) != subscriberBufferSize {
+		t.Fatalf("expected %d buffered events, got %d", subscriberBufferSize, len(ch))
+	}
+}
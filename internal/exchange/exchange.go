@@ -0,0 +1,66 @@
+package exchange
+
+import "github.com/morzhanov/binance-orders-watcher/internal/db"
+
+const (
+	Binance = "binance"
+	Bybit   = "bybit"
+)
+
+// Order statuses, sides and types use Binance names, drivers of other exchanges convert their values to them.
+const (
+	OrderStatusNew             = "NEW"
+	OrderStatusPartiallyFilled = "PARTIALLY_FILLED"
+	OrderStatusFilled          = "FILLED"
+	OrderStatusCanceled        = "CANCELED"
+	OrderStatusRejected        = "REJECTED"
+	OrderStatusExpired         = "EXPIRED"
+
+	SideBuy  = "BUY"
+	SideSell = "SELL"
+
+	OrderTypeLimit         = "LIMIT"
+	OrderTypeLimitMaker    = "LIMIT_MAKER"
+	OrderTypeMarket        = "MARKET"
+	OrderTypeStopLoss      = "STOP_LOSS"
+	OrderTypeStopLossLimit = "STOP_LOSS_LIMIT"
+)
+
+// Driver loads orders, prices and balances of an exchange account.
+type Driver interface {
+	// Exchange returns the exchange name, e.g. Binance.
+	Exchange() string
+	GetOpenOrders() ([]*Order, error)
+	// GetOrderHistory returns recent orders of the symbol, oldest first.
+	GetOrderHistory(symbol string) ([]*Order, error)
+	GetPrices() ([]*db.Price, error)
+	// GetBalances returns non zero balances, AccountID is set by the caller.
+	GetBalances() ([]*db.Balance, error)
+}
+
+// Order is an exchange order, prices and quantities are decimal strings and times are unix timestamps in milliseconds.
+type Order struct {
+	Symbol              string
+	OrderID             int
+	OrderListID         int
+	ClientOrderID       string
+	Price               string
+	OrigQty             string
+	ExecutedQty         string
+	CummulativeQuoteQty string
+	Status              string
+	TimeInForce         string
+	Type                string
+	Side                string
+	StopPrice           string
+	IcebergQty          string
+	Time                int
+	UpdateTime          int
+	IsWorking           bool
+}
+
+// Account is an exchange driver bound to the credentials of a named account.
+type Account struct {
+	ID     string
+	Driver Driver
+}
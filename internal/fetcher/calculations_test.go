@@ -0,0 +1,98 @@
+package fetcher
+
+import (
+	"math/big"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+	"testing/quick"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/decimal"
+)
+
+// price generates positive prices from 0.00000001 to about 10^10 with 8 decimals like Binance sends them.
+type price struct {
+	d decimal.Decimal
+}
+
+func (price) Generate(r *rand.Rand, _ int) reflect.Value {
+	var satoshis int64
+	switch r.Intn(3) {
+	case 0:
+		// low priced tokens
+		satoshis = 1 + r.Int63n(1000)
+	case 1:
+		satoshis = 1 + r.Int63n(1e10)
+	default:
+		satoshis = 1 + r.Int63n(1e18)
+	}
+	d := decimal.MustParse(new(big.Rat).SetFrac(big.NewInt(satoshis), big.NewInt(1e8)).FloatString(8))
+	return reflect.ValueOf(price{d: d})
+}
+
+func TestCalculateOrderPercentCompleted(t *testing.T) {
+	for _, tc := range []struct {
+		original, market, price, expected string
+	}{
+		{"40000", "45000", "50000", "50"},
+		{"50000", "45000", "40000", "50"},
+		{"0.00000100", "0.00000101", "0.00000103", "33"},
+		{"0.00000100", "0.00000099", "0.00000103", "-33"},
+		{"0.1", "0.3", "0.3", "100"},
+		{"1", "1", "2", "0"},
+		{"1", "3", "2", "200"},
+		{"5", "4", "5", notAvailableText},
+	} {
+		res := calculateOrderPercentCompleted(decimal.MustParse(tc.original), decimal.MustParse(tc.market), decimal.MustParse(tc.price))
+		if res != tc.expected {
+			t.Fatalf("%+v: got %s", tc, res)
+		}
+	}
+}
+
+func TestOrderPercentCompletedProperties(t *testing.T) {
+	f := func(original, market, orderPrice price) bool {
+		o, m, p := original.d, market.d, orderPrice.d
+		res := calculateOrderPercentCompleted(o, m, p)
+		if o.Equal(p) {
+			return res == notAvailableText
+		}
+		percent, err := strconv.ParseInt(res, 10, 64)
+		if err != nil && len(res) < 18 {
+			return false
+		}
+
+		// the market at the last filled price is 0% and at the order price it is 100%
+		if calculateOrderPercentCompleted(o, o, p) != "0" || calculateOrderPercentCompleted(o, p, p) != "100" {
+			return false
+		}
+		// mirroring all prices around zero does not change the result
+		if calculateOrderPercentCompleted(o.Neg(), m.Neg(), p.Neg()) != res {
+			return false
+		}
+		// the market between the last filled and the order price gives 0-100%
+		between := (m.Cmp(o) >= 0 && m.Cmp(p) <= 0) || (m.Cmp(o) <= 0 && m.Cmp(p) >= 0)
+		return err != nil || !between || (percent >= 0 && percent <= 100)
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 1000}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpreadProperties(t *testing.T) {
+	f := func(orderPrice, market price) bool {
+		spread, err := decimal.Parse(calculateSpread(orderPrice.d, market.d))
+		if err != nil {
+			return false
+		}
+		// the spread is exact: market + spread gives back the order price
+		return market.d.Add(spread).Equal(orderPrice.d) && spread.Sign() == orderPrice.d.Cmp(market.d)
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	if spread := calculateSpread(decimal.MustParse("0.00000123"), decimal.MustParse("0.00000120")); spread != "0.00000003" {
+		t.Fatalf("unexpected low price spread %s", spread)
+	}
+}
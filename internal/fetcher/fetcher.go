@@ -3,50 +3,195 @@ package fetcher
 import (
 	"fmt"
 	"log"
-	"strconv"
+	"time"
 
 	"github.com/morzhanov/binance-orders-watcher/internal/binance"
 	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/decimal"
+	"github.com/morzhanov/binance-orders-watcher/internal/events"
+	"github.com/morzhanov/binance-orders-watcher/internal/exchange"
 )
 
 const (
-	orderStatusFilled = "FILLED"
-	notAvailableText  = "N/A"
+	notAvailableText = "N/A"
+
+	// DefaultEventRetention is how long fetch, alert and order list events are kept
+	DefaultEventRetention = time.Hour * 24 * 30
 )
 
+// Fetcher loads open orders, balances and prices from exchanges. Fetch loads all accounts one by one,
+// FetchAccount loads a single account and keeps stored orders and balances of other accounts.
+// Alerts, symbols and charts use Binance market data, so only prices of Binance accounts are stored,
+// other exchange prices are used for their own orders only and the stored prices are returned for them.
+// Futures and margin markets are loaded for Binance accounts which have them enabled, OCO order lists
+// are loaded for all Binance accounts.
 type Fetcher interface {
 	Fetch() (orders []*db.Order, prices []*db.Price, err error)
+	FetchAccount(accountID string) (orders []*db.Order, prices []*db.Price, err error)
 }
 
 type fetcherImp struct {
-	binClient binance.Client
-	db        db.Client
+	accounts        []*exchange.Account
+	binanceAccounts []*binance.Account
+	db              db.Client
+	bus             events.Bus
+	eventRetention  time.Duration
 }
 
-func New(binClient binance.Client, dbClient db.Client) Fetcher {
-	return &fetcherImp{binClient: binClient, db: dbClient}
+// New creates a fetcher deleting events older than eventRetention on every fetch, eventRetention <= 0 keeps them.
+func New(accounts []*exchange.Account, binanceAccounts []*binance.Account, dbClient db.Client, bus events.Bus, eventRetention time.Duration) Fetcher {
+	return &fetcherImp{accounts: accounts, binanceAccounts: binanceAccounts, db: dbClient, bus: bus, eventRetention: eventRetention}
 }
 
+// Fetch fetches every account even when some of them fail, the first error is returned.
 func (f *fetcherImp) Fetch() ([]*db.Order, []*db.Price, error) {
-	binanceOrders, err := f.binClient.GetOrders()
+	var orders []*db.Order
+	var prices []*db.Price
+	var firstErr error
+	for _, account := range f.accounts {
+		accountOrders, accountPrices, err := f.FetchAccount(account.ID)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		orders = append(orders, accountOrders...)
+		prices = accountPrices
+	}
+	if firstErr != nil {
+		return nil, nil, firstErr
+	}
+	return orders, prices, nil
+}
+
+func (f *fetcherImp) FetchAccount(accountID string) ([]*db.Order, []*db.Price, error) {
+	account := f.account(accountID)
+	if account == nil {
+		return nil, nil, fmt.Errorf("unknown account %s", accountID)
+	}
+	f.pruneEvents()
+
+	f.bus.Publish(events.TypeFetchStatus, events.NewFetchStatus(events.FetchStatusStarted, nil))
+	orders, prices, err := f.fetch(account)
+	if err == nil {
+		err = f.fetchOrderLists(accountID, orders)
+	}
+	var leveraged bool
+	if err == nil {
+		leveraged, err = f.fetchLeveraged(accountID)
+	}
+	if err != nil {
+		err = fmt.Errorf("account %s: %w", accountID, err)
+		f.addEvent(db.NewEvent(db.EventTypeFetchFailed, "", err.Error()))
+		f.bus.Publish(events.TypeFetchStatus, events.NewFetchStatus(events.FetchStatusFailed, err))
+		return nil, nil, err
+	}
+	f.addEvent(db.NewEvent(db.EventTypeFetchCompleted, "", fmt.Sprintf("account %s: fetched %d orders and %d prices", accountID, len(orders), len(prices))))
+	f.publishAll()
+	if leveraged {
+		f.publishPositions()
+	}
+	f.bus.Publish(events.TypePrices, prices)
+	f.bus.Publish(events.TypeFetchStatus, events.NewFetchStatus(events.FetchStatusCompleted, nil))
+	return orders, prices, nil
+}
+
+func (f *fetcherImp) account(id string) *exchange.Account {
+	for _, account := range f.accounts {
+		if account.ID == id {
+			return account
+		}
+	}
+	return nil
+}
+
+func (f *fetcherImp) binanceAccount(id string) *binance.Account {
+	for _, account := range f.binanceAccounts {
+		if account.ID == id {
+			return account
+		}
+	}
+	return nil
+}
+
+// publishAll sends orders and balances of all accounts, dashboards replace their lists with them.
+func (f *fetcherImp) publishAll() {
+	orders, err := f.db.GetOrders()
+	if err != nil {
+		log.Println("failed to get orders for publishing: ", err)
+		return
+	}
+	f.bus.Publish(events.TypeOrders, orders)
+	balances, err := f.db.GetBalances()
+	if err != nil {
+		log.Println("failed to get balances for publishing: ", err)
+		return
+	}
+	f.bus.Publish(events.TypeBalances, balances)
+}
+
+func (f *fetcherImp) publishPositions() {
+	positions, err := f.db.GetPositions()
+	if err != nil {
+		log.Println("failed to get positions for publishing: ", err)
+		return
+	}
+	f.bus.Publish(events.TypePositions, positions)
+}
+
+func (f *fetcherImp) addEvent(event *db.Event) {
+	if err := f.db.AddEvent(event); err != nil {
+		log.Println("failed to store fetch event: ", err)
+	}
+}
+
+func (f *fetcherImp) pruneEvents() {
+	if f.eventRetention <= 0 {
+		return
+	}
+	if err := f.db.DeleteEventsBefore(time.Now().Add(-f.eventRetention).UnixMilli()); err != nil {
+		log.Println("failed to delete old events: ", err)
+	}
+}
+
+func (f *fetcherImp) fetch(account *exchange.Account) ([]*db.Order, []*db.Price, error) {
+	exchangeName := account.Driver.Exchange()
+	exchangeOrders, err := account.Driver.GetOpenOrders()
 	if err != nil {
-		log.Println("failed to get binanceOrders from binance: ", err)
+		log.Printf("failed to get orders from %s: %s", exchangeName, err)
 		return nil, nil, err
 	}
-	prices, err := f.binClient.GetPrices()
+	balances, err := account.Driver.GetBalances()
 	if err != nil {
-		log.Println("failed to get prices from binance: ", err)
+		log.Printf("failed to get balances from %s: %s", exchangeName, err)
+		return nil, nil, err
+	}
+	prices, err := account.Driver.GetPrices()
+	if err != nil {
+		log.Printf("failed to get prices from %s: %s", exchangeName, err)
 		return nil, nil, err
 	}
 
-	orders, err := f.binanceOrdersToDBOrders(binanceOrders, prices)
+	orders, err := f.exchangeOrdersToDBOrders(account, exchangeOrders, prices)
 	if err != nil {
 		return nil, nil, err
 	}
-	if err = f.db.SetOrders(orders); err != nil {
-		log.Println("failed to set binanceOrders from binance to db: ", err)
+	if err = f.db.SetOrders(account.ID, orders); err != nil {
+		log.Printf("failed to set orders from %s to db: %s", exchangeName, err)
+		return nil, nil, err
+	}
+	if err = f.db.SetBalances(account.ID, balances); err != nil {
+		log.Printf("failed to set balances from %s to db: %s", exchangeName, err)
 		return nil, nil, err
 	}
+	if exchangeName != exchange.Binance {
+		if prices, err = f.db.GetPrices(); err != nil {
+			log.Println("failed to get prices from db: ", err)
+			return nil, nil, err
+		}
+		return orders, prices, nil
+	}
 	if err = f.db.SetPrices(prices); err != nil {
 		log.Println("failed to set prices from binance to db: ", err)
 		return nil, nil, err
@@ -54,44 +199,107 @@ func (f *fetcherImp) Fetch() ([]*db.Order, []*db.Price, error) {
 	return orders, prices, nil
 }
 
-func (f *fetcherImp) binanceOrdersToDBOrders(binOrders []*binance.BinanceOrder, prices []*db.Price) ([]*db.Order, error) {
-	allOrders := make(map[string][]*binance.BinanceOrder, 0)
+// fetchLeveraged loads futures and margin markets of the account, it reports whether any of them is enabled.
+func (f *fetcherImp) fetchLeveraged(accountID string) (bool, error) {
+	account := f.binanceAccount(accountID)
+	if account == nil || (account.Futures == nil && account.Margin == nil) {
+		return false, nil
+	}
+
+	if account.Futures != nil {
+		orders, err := account.Futures.GetOpenOrders()
+		if err != nil {
+			log.Println("failed to get futures orders from binance: ", err)
+			return false, err
+		}
+		positions, err := account.Futures.GetPositions()
+		if err != nil {
+			log.Println("failed to get futures positions from binance: ", err)
+			return false, err
+		}
+		rates, err := account.Futures.GetFundingRates()
+		if err != nil {
+			log.Println("failed to get funding rates from binance: ", err)
+			return false, err
+		}
+		status, err := account.Futures.GetMarginStatus()
+		if err != nil {
+			log.Println("failed to get futures margin ratio from binance: ", err)
+			return false, err
+		}
+		if err = f.db.SetLeveragedOrders(accountID, db.MarketFutures, orders); err != nil {
+			return false, err
+		}
+		if err = f.db.SetPositions(accountID, positions); err != nil {
+			return false, err
+		}
+		if err = f.db.SetFundingRates(rates); err != nil {
+			return false, err
+		}
+		status.AccountID = accountID
+		if err = f.db.SetMarginStatus(status); err != nil {
+			return false, err
+		}
+	}
+	if account.Margin != nil {
+		orders, err := account.Margin.GetOpenOrders()
+		if err != nil {
+			log.Println("failed to get margin orders from binance: ", err)
+			return false, err
+		}
+		status, err := account.Margin.GetMarginStatus()
+		if err != nil {
+			log.Println("failed to get margin level from binance: ", err)
+			return false, err
+		}
+		if err = f.db.SetLeveragedOrders(accountID, db.MarketMargin, orders); err != nil {
+			return false, err
+		}
+		status.AccountID = accountID
+		if err = f.db.SetMarginStatus(status); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (f *fetcherImp) exchangeOrdersToDBOrders(account *exchange.Account, exchangeOrders []*exchange.Order, prices []*db.Price) ([]*db.Order, error) {
+	allOrders := make(map[string][]*exchange.Order, 0)
 	var orders []*db.Order
-	var err error
 
-	for _, binOrder := range binOrders {
-		var parsedMarketPrice, parsedOrderPrice float64
+	for _, exchangeOrder := range exchangeOrders {
+		orderPrice, err := decimal.Parse(exchangeOrder.Price)
+		if err != nil {
+			return nil, err
+		}
+		var parsedMarketPrice *decimal.Decimal
 		var marketPrice, spread string
 		for _, price := range prices {
-			if price.Symbol == binOrder.Symbol {
-				parsedMarketPrice, err = strconv.ParseFloat(price.Price, 64)
-				if err != nil {
-					return nil, err
-				}
-				parsedOrderPrice, err = strconv.ParseFloat(binOrder.Price, 64)
+			if price.Symbol == exchangeOrder.Symbol {
+				parsed, err := decimal.Parse(price.Price)
 				if err != nil {
 					return nil, err
 				}
+				parsedMarketPrice = &parsed
 				marketPrice = price.Price
-				spreadVal := parsedOrderPrice - parsedMarketPrice
-				spread = fmt.Sprintf("%f", spreadVal)
+				spread = calculateSpread(orderPrice, parsed)
 				break
 			}
 		}
 
-		allOrdersForSymbol, ok := allOrders[binOrder.Symbol]
+		allOrdersForSymbol, ok := allOrders[exchangeOrder.Symbol]
 		if !ok {
-			res, err := f.binClient.GetAllOrdersForSymbol(binOrder.Symbol)
+			res, err := account.Driver.GetOrderHistory(exchangeOrder.Symbol)
 			if err != nil {
 				return nil, err
 			}
 			allOrdersForSymbol = res
 		}
-		allOrders[binOrder.Symbol] = allOrdersForSymbol
+		allOrders[exchangeOrder.Symbol] = allOrdersForSymbol
 
 		var lastOrderPrice string
 		for _, order := range allOrdersForSymbol {
-			if order.Status == orderStatusFilled {
+			if order.Status == exchange.OrderStatusFilled {
 				lastOrderPrice = order.Price
 				break
 			}
@@ -100,35 +308,34 @@ func (f *fetcherImp) binanceOrdersToDBOrders(binOrders []*binance.BinanceOrder,
 			lastOrderPrice = notAvailableText
 		}
 
-		var percentCompleted string
-		if lastOrderPrice == notAvailableText {
-			percentCompleted = notAvailableText
-		} else {
-			originalPrice, err := strconv.ParseFloat(lastOrderPrice, 64)
+		percentCompleted := notAvailableText
+		if lastOrderPrice != notAvailableText && parsedMarketPrice != nil {
+			originalPrice, err := decimal.Parse(lastOrderPrice)
 			if err != nil {
 				return nil, err
 			}
-			percentCompleted = calculateOrderPercentCompleted(originalPrice, parsedMarketPrice, parsedOrderPrice)
+			percentCompleted = calculateOrderPercentCompleted(originalPrice, *parsedMarketPrice, orderPrice)
 		}
 
 		order := &db.Order{
-			Symbol:                 binOrder.Symbol,
-			OrderID:                binOrder.OrderId,
-			OrderListID:            binOrder.OrderListId,
-			ClientOrderID:          binOrder.ClientOrderId,
-			Price:                  binOrder.Price,
-			OrigQty:                binOrder.OrigQty,
-			ExecutedQty:            binOrder.ExecutedQty,
-			CummulativeQuoteQty:    binOrder.CummulativeQuoteQty,
-			Status:                 binOrder.Status,
-			TimeInForce:            binOrder.TimeInForce,
-			Type:                   binOrder.Type,
-			Side:                   binOrder.Side,
-			StopPrice:              binOrder.StopPrice,
-			IcebergQty:             binOrder.IcebergQty,
-			Time:                   binOrder.Time,
-			UpdateTime:             binOrder.UpdateTime,
-			IsWorking:              binOrder.IsWorking,
+			AccountID:              account.ID,
+			Symbol:                 exchangeOrder.Symbol,
+			OrderID:                exchangeOrder.OrderID,
+			OrderListID:            exchangeOrder.OrderListID,
+			ClientOrderID:          exchangeOrder.ClientOrderID,
+			Price:                  exchangeOrder.Price,
+			OrigQty:                exchangeOrder.OrigQty,
+			ExecutedQty:            exchangeOrder.ExecutedQty,
+			CummulativeQuoteQty:    exchangeOrder.CummulativeQuoteQty,
+			Status:                 exchangeOrder.Status,
+			TimeInForce:            exchangeOrder.TimeInForce,
+			Type:                   exchangeOrder.Type,
+			Side:                   exchangeOrder.Side,
+			StopPrice:              exchangeOrder.StopPrice,
+			IcebergQty:             exchangeOrder.IcebergQty,
+			Time:                   exchangeOrder.Time,
+			UpdateTime:             exchangeOrder.UpdateTime,
+			IsWorking:              exchangeOrder.IsWorking,
 			LastOrderPrice:         lastOrderPrice,
 			MarketPrice:            marketPrice,
 			PercentCompleted:       percentCompleted,
@@ -139,12 +346,23 @@ func (f *fetcherImp) binanceOrdersToDBOrders(binOrders []*binance.BinanceOrder,
 	return orders, nil
 }
 
-func calculateOrderPercentCompleted(original, market, price float64) string {
-	var res float64
-	if price > original {
-		res = ((market - original) * 100) / (price - original)
-	} else {
-		res = ((original - market) * 100) / (original - price)
+// calculateOrderPercentCompleted returns how far the market moved from the last filled price towards
+// the order price, in whole percents truncated towards zero. It is N/A when the order price equals
+// the last filled price.
+func calculateOrderPercentCompleted(original, market, price decimal.Decimal) string {
+	distance := price.Sub(original).Abs()
+	moved := market.Sub(original)
+	if price.LessThan(original) {
+		moved = moved.Neg()
 	}
-	return fmt.Sprintf("%d", int(res))
+	res, err := moved.Mul(decimal.NewFromInt(100)).Div(distance)
+	if err != nil {
+		return notAvailableText
+	}
+	return res.Truncate().String()
+}
+
+// calculateSpread returns the exact difference between the order and the market price.
+func calculateSpread(price, market decimal.Decimal) string {
+	return price.Sub(market).String()
 }
@@ -0,0 +1,430 @@
+package fetcher
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+	"github.com/morzhanov/binance-orders-watcher/internal/cassette"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/events"
+	"github.com/morzhanov/binance-orders-watcher/internal/exchange"
+)
+
+type fakeBinanceClient struct {
+	binance.Client
+	open     []*binance.BinanceOrder
+	all      map[string][]*binance.BinanceOrder
+	prices   []*db.Price
+	balances []*db.Balance
+	lists    []*binance.OrderList
+	err      error
+	allErr   error
+}
+
+func (c *fakeBinanceClient) GetOrders() ([]*binance.BinanceOrder, error) {
+	return c.open, c.err
+}
+
+func (c *fakeBinanceClient) GetBalances() ([]*db.Balance, error) {
+	return c.balances, nil
+}
+
+func (c *fakeBinanceClient) GetAllOrdersForSymbol(symbol string) ([]*binance.BinanceOrder, error) {
+	return c.all[symbol], c.allErr
+}
+
+func (c *fakeBinanceClient) GetPrices() ([]*db.Price, error) {
+	return c.prices, nil
+}
+
+func (c *fakeBinanceClient) GetOpenOrderLists() ([]*binance.OrderList, error) {
+	return c.lists, nil
+}
+
+type fakeDriver struct {
+	exchange.Driver
+	open   []*exchange.Order
+	prices []*db.Price
+}
+
+func (d *fakeDriver) Exchange() string {
+	return exchange.Bybit
+}
+
+func (d *fakeDriver) GetOpenOrders() ([]*exchange.Order, error) {
+	return d.open, nil
+}
+
+func (d *fakeDriver) GetOrderHistory(_ string) ([]*exchange.Order, error) {
+	return nil, nil
+}
+
+func (d *fakeDriver) GetPrices() ([]*db.Price, error) {
+	return d.prices, nil
+}
+
+func (d *fakeDriver) GetBalances() ([]*db.Balance, error) {
+	return nil, nil
+}
+
+func TestFetch(t *testing.T) {
+	binClient := &fakeBinanceClient{
+		open: []*binance.BinanceOrder{
+			{Symbol: "BTCUSDT", OrderId: 2, Price: "50000", Status: "NEW", Side: "SELL"},
+			{Symbol: "ETHUSDT", OrderId: 3, Price: "2000", Status: "NEW", Side: "BUY"},
+		},
+		all: map[string][]*binance.BinanceOrder{
+			"BTCUSDT": {{Symbol: "BTCUSDT", OrderId: 1, Price: "40000", Status: "FILLED"}},
+		},
+		prices:   []*db.Price{{Symbol: "BTCUSDT", Price: "45000"}, {Symbol: "ETHUSDT", Price: "2500"}},
+		balances: []*db.Balance{{Asset: "BTC", Free: "0.1", Locked: "0"}},
+	}
+	dbClient := db.NewMemoryClient()
+	bus := events.NewBus()
+	published, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	orders, prices, err := New([]*exchange.Account{{ID: db.DefaultAccountID, Driver: binance.NewDriver(binClient)}}, nil, dbClient, bus, DefaultEventRetention).Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 || len(prices) != 2 {
+		t.Fatalf("unexpected result: %d orders, %d prices", len(orders), len(prices))
+	}
+
+	btc := orders[0]
+	if btc.AccountID != db.DefaultAccountID || btc.LastOrderPrice != "40000" || btc.MarketPrice != "45000" || btc.PercentCompleted != "50" || btc.OrderMarketPriceSpread != "5000" {
+		t.Fatalf("unexpected BTCUSDT order: %+v", btc)
+	}
+	eth := orders[1]
+	if eth.LastOrderPrice != notAvailableText || eth.PercentCompleted != notAvailableText {
+		t.Fatalf("order without filled history should have N/A values: %+v", eth)
+	}
+
+	stored, err := dbClient.GetOrders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("orders were not stored, got %d", len(stored))
+	}
+	storedPrices, err := dbClient.GetPrices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(storedPrices) != 2 {
+		t.Fatalf("prices were not stored, got %d", len(storedPrices))
+	}
+
+	balances, err := dbClient.GetBalances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(balances) != 1 || balances[0].AccountID != db.DefaultAccountID {
+		t.Fatalf("balances were not stored, got %+v", balances)
+	}
+
+	for _, want := range []string{events.TypeFetchStatus, events.TypeOrders, events.TypeBalances, events.TypePrices, events.TypeFetchStatus} {
+		if e := <-published; e.Type != want {
+			t.Fatalf("expected %s event, got %s", want, e.Type)
+		}
+	}
+}
+
+func TestFetchDeletesOldEvents(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	old := &db.Event{ID: "old", Type: db.EventTypeFetchCompleted, CreatedAt: time.Now().Add(-2 * time.Hour).UnixMilli()}
+	recent := &db.Event{ID: "recent", Type: db.EventTypeAlertTriggered, CreatedAt: time.Now().Add(-30 * time.Minute).UnixMilli()}
+	for _, e := range []*db.Event{old, recent} {
+		if err := dbClient.AddEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f := New([]*exchange.Account{{ID: "main", Driver: binance.NewDriver(&fakeBinanceClient{})}}, nil, dbClient, events.NewBus(), time.Hour)
+	if _, _, err := f.FetchAccount("main"); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, total, err := dbClient.GetEvents(&db.EventQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || stored[0].Type != db.EventTypeFetchCompleted || stored[1].ID != "recent" {
+		t.Fatalf("expected events older than the retention to be deleted, got %+v", stored)
+	}
+}
+
+func TestFetchAccounts(t *testing.T) {
+	mainClient := &fakeBinanceClient{open: []*binance.BinanceOrder{{Symbol: "BTCUSDT", OrderId: 1, Price: "50000", Status: "NEW"}}}
+	sub := &fakeBinanceClient{open: []*binance.BinanceOrder{{Symbol: "ETHUSDT", OrderId: 2, Price: "2000", Status: "NEW"}}}
+	dbClient := db.NewMemoryClient()
+	f := New([]*exchange.Account{{ID: "main", Driver: binance.NewDriver(mainClient)}, {ID: "sub", Driver: binance.NewDriver(sub)}}, nil, dbClient, events.NewBus(), DefaultEventRetention)
+
+	if _, _, err := f.Fetch(); err != nil {
+		t.Fatal(err)
+	}
+	sub.open = nil
+	orders, _, err := f.FetchAccount("sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 0 {
+		t.Fatalf("expected no sub account orders, got %+v", orders)
+	}
+	stored, err := dbClient.GetOrders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || stored[0].AccountID != "main" {
+		t.Fatalf("orders of other accounts should be kept, got %+v", stored)
+	}
+
+	// a failing account does not stop fetching of the others
+	mainClient.err = errors.New("invalid api key")
+	sub.open = []*binance.BinanceOrder{{Symbol: "ETHUSDT", OrderId: 3, Price: "2000", Status: "NEW"}}
+	if _, _, err = f.Fetch(); err == nil {
+		t.Fatal("expected main account error")
+	}
+	if stored, _ = dbClient.GetOrders(); len(stored) != 2 {
+		t.Fatalf("sub account orders should be fetched, got %+v", stored)
+	}
+	if _, _, err = f.FetchAccount("unknown"); err == nil {
+		t.Fatal("expected unknown account error")
+	}
+}
+
+func TestFetchOtherExchangeKeepsBinancePrices(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	if err := dbClient.SetPrices([]*db.Price{{Symbol: "BTCUSDT", Price: "45000"}}); err != nil {
+		t.Fatal(err)
+	}
+	bybit := &fakeDriver{
+		open:   []*exchange.Order{{Symbol: "BTCUSDT", OrderID: 1, Price: "50000", Status: exchange.OrderStatusNew}},
+		prices: []*db.Price{{Symbol: "BTCUSDT", Price: "45100"}},
+	}
+
+	orders, prices, err := New([]*exchange.Account{{ID: "bybit", Driver: bybit}}, nil, dbClient, events.NewBus(), DefaultEventRetention).FetchAccount("bybit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].MarketPrice != "45100" || orders[0].OrderMarketPriceSpread != "4900" {
+		t.Fatalf("orders should use the exchange prices, got %+v", orders)
+	}
+	if len(prices) != 1 || prices[0].Price != "45000" {
+		t.Fatalf("stored Binance prices should be returned, got %+v", prices)
+	}
+	if stored, _ := dbClient.GetPrices(); len(stored) != 1 || stored[0].Price != "45000" {
+		t.Fatalf("stored prices should not be replaced, got %+v", stored)
+	}
+}
+
+type fakeFuturesClient struct {
+	binance.FuturesClient
+}
+
+func (c *fakeFuturesClient) GetOpenOrders() ([]*db.LeveragedOrder, error) {
+	return []*db.LeveragedOrder{{Market: db.MarketFutures, Symbol: "BTCUSDT", OrderID: 7}}, nil
+}
+
+func (c *fakeFuturesClient) GetPositions() ([]*db.Position, error) {
+	return []*db.Position{{Symbol: "BTCUSDT", PositionAmt: "0.01", MarkPrice: "40000", LiquidationPrice: "30000"}}, nil
+}
+
+func (c *fakeFuturesClient) GetFundingRates() ([]*db.FundingRate, error) {
+	return []*db.FundingRate{{Symbol: "BTCUSDT", FundingRate: "0.0001"}}, nil
+}
+
+func (c *fakeFuturesClient) GetMarginStatus() (*db.MarginStatus, error) {
+	return &db.MarginStatus{Market: db.MarketFutures, MarginRatio: "1.5"}, nil
+}
+
+func TestFetchFutures(t *testing.T) {
+	binClient := &fakeBinanceClient{}
+	dbClient := db.NewMemoryClient()
+	bus := events.NewBus()
+	published, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+	f := New(
+		[]*exchange.Account{{ID: "main", Driver: binance.NewDriver(binClient)}},
+		[]*binance.Account{{ID: "main", Client: binClient, Futures: &fakeFuturesClient{}}},
+		dbClient, bus, DefaultEventRetention,
+	)
+
+	if _, _, err := f.FetchAccount("main"); err != nil {
+		t.Fatal(err)
+	}
+	if orders, _ := dbClient.GetLeveragedOrders(); len(orders) != 1 || orders[0].AccountID != "main" {
+		t.Fatalf("futures orders were not stored, got %+v", orders)
+	}
+	if positions, _ := dbClient.GetPositions(); len(positions) != 1 || positions[0].AccountID != "main" {
+		t.Fatalf("positions were not stored, got %+v", positions)
+	}
+	if statuses, _ := dbClient.GetMarginStatuses(); len(statuses) != 1 || statuses[0].AccountID != "main" || statuses[0].MarginRatio != "1.5" {
+		t.Fatalf("margin status was not stored, got %+v", statuses)
+	}
+	for _, want := range []string{events.TypeFetchStatus, events.TypeOrders, events.TypeBalances, events.TypePositions} {
+		if e := <-published; e.Type != want {
+			t.Fatalf("expected %s event, got %s", want, e.Type)
+		}
+	}
+}
+
+func TestFetchOrderLists(t *testing.T) {
+	binClient := &fakeBinanceClient{
+		open: []*binance.BinanceOrder{
+			{Symbol: "BTCUSDT", OrderId: 1, OrderListId: 5, Price: "50000", StopPrice: "0.00000000", Type: "LIMIT_MAKER", Side: "SELL", Status: "NEW"},
+			{Symbol: "BTCUSDT", OrderId: 2, OrderListId: 5, Price: "39900", StopPrice: "40000", Type: "STOP_LOSS_LIMIT", Side: "SELL", Status: "NEW"},
+		},
+		prices: []*db.Price{{Symbol: "BTCUSDT", Price: "48000"}},
+		lists:  []*binance.OrderList{{OrderListID: 5, Symbol: "BTCUSDT", ContingencyType: "OCO", ListStatusType: "EXEC_STARTED", ListOrderStatus: "EXECUTING"}},
+	}
+	dbClient := db.NewMemoryClient()
+	bus := events.NewBus()
+	published, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+	f := New(
+		[]*exchange.Account{{ID: "main", Driver: binance.NewDriver(binClient)}},
+		[]*binance.Account{{ID: "main", Client: binClient}},
+		dbClient, bus, DefaultEventRetention,
+	)
+
+	if _, _, err := f.FetchAccount("main"); err != nil {
+		t.Fatal(err)
+	}
+	lists, err := dbClient.GetOrderLists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the take profit at 50000 is 4.17% away from 48000, the stop at 40000 is 16.67% away
+	if len(lists) != 1 || lists[0].AccountID != "main" || lists[0].TriggerDistance != "4.17" {
+		t.Fatalf("unexpected order lists %+v", lists)
+	}
+
+	binClient.open, binClient.lists = nil, nil
+	binClient.all = map[string][]*binance.BinanceOrder{"BTCUSDT": {
+		{Symbol: "BTCUSDT", OrderId: 1, OrderListId: 5, Price: "50000", StopPrice: "0.00000000", Type: "LIMIT_MAKER", Side: "SELL", Status: "FILLED"},
+		{Symbol: "BTCUSDT", OrderId: 2, OrderListId: 5, Price: "39900", StopPrice: "40000", Type: "STOP_LOSS_LIMIT", Side: "SELL", Status: "CANCELED"},
+		{Symbol: "BTCUSDT", OrderId: 3, OrderListId: -1, Price: "45000", Status: "FILLED"},
+	}}
+	if _, _, err = f.FetchAccount("main"); err != nil {
+		t.Fatal(err)
+	}
+	if lists, _ = dbClient.GetOrderLists(); len(lists) != 0 {
+		t.Fatalf("done order lists should be removed, got %+v", lists)
+	}
+
+	var done []*OrderListDone
+	for len(published) > 0 {
+		if e := <-published; e.Type == events.TypeOrderListDone {
+			done = append(done, e.Data.(*OrderListDone))
+		}
+	}
+	if len(done) != 1 || len(done[0].Orders) != 2 || done[0].Orders[0].Status != "FILLED" || done[0].Orders[1].Status != "CANCELED" {
+		t.Fatalf("expected a single event for both legs, got %+v", done)
+	}
+	stored, _, err := dbClient.GetEvents(&db.EventQuery{Types: []string{db.EventTypeOrderListDone}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for _, e := range stored {
+		messages = append(messages, e.Message)
+	}
+	want := "OCO 5 of account main is done: LIMIT_MAKER SELL at 50000 FILLED, STOP_LOSS_LIMIT SELL at 40000 CANCELED"
+	if len(messages) != 1 || messages[0] != want {
+		t.Fatalf("unexpected events %v", messages)
+	}
+}
+
+func TestFetchOrderListsKeepsListsWhenHistoryFails(t *testing.T) {
+	binClient := &fakeBinanceClient{
+		prices: []*db.Price{{Symbol: "BTCUSDT", Price: "48000"}},
+		lists:  []*binance.OrderList{{OrderListID: 5, Symbol: "BTCUSDT", ContingencyType: "OCO"}},
+	}
+	dbClient := db.NewMemoryClient()
+	bus := events.NewBus()
+	published, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+	f := New(
+		[]*exchange.Account{{ID: "main", Driver: binance.NewDriver(binClient)}},
+		[]*binance.Account{{ID: "main", Client: binClient}},
+		dbClient, bus, DefaultEventRetention,
+	)
+	if _, _, err := f.FetchAccount("main"); err != nil {
+		t.Fatal(err)
+	}
+
+	binClient.lists = nil
+	binClient.allErr = errors.New("history is not available")
+	if _, _, err := f.FetchAccount("main"); err == nil {
+		t.Fatal("expected the history error")
+	}
+	if lists, _ := dbClient.GetOrderLists(); len(lists) != 1 {
+		t.Fatalf("the done list should be kept until it is reported, got %+v", lists)
+	}
+
+	binClient.allErr = nil
+	binClient.all = map[string][]*binance.BinanceOrder{"BTCUSDT": {
+		{Symbol: "BTCUSDT", OrderId: 1, OrderListId: 5, Price: "50000", Type: "LIMIT_MAKER", Side: "SELL", Status: "FILLED"},
+	}}
+	if _, _, err := f.FetchAccount("main"); err != nil {
+		t.Fatal(err)
+	}
+	if lists, _ := dbClient.GetOrderLists(); len(lists) != 0 {
+		t.Fatalf("done order lists should be removed, got %+v", lists)
+	}
+	var done []*OrderListDone
+	for len(published) > 0 {
+		if e := <-published; e.Type == events.TypeOrderListDone {
+			done = append(done, e.Data.(*OrderListDone))
+		}
+	}
+	if len(done) != 1 || len(done[0].Orders) != 1 {
+		t.Fatalf("expected the done list to be reported once, got %+v", done)
+	}
+}
+
+// TestFetchReplaysCassette fetches an account from a recorded cassette, cassettes reproducing
+// production bugs are added to testdata the same way.
+func TestFetchReplaysCassette(t *testing.T) {
+	c, err := cassette.Load("testdata/spot_account.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	binClient := binance.NewWithHTTPClient("", "", "https://api.binance.com", &http.Client{Transport: cassette.NewReplayer(c)})
+	dbClient := db.NewMemoryClient()
+	f := New(
+		[]*exchange.Account{{ID: "main", Driver: binance.NewDriver(binClient)}},
+		[]*binance.Account{{ID: "main", Client: binClient}},
+		dbClient, events.NewBus(), DefaultEventRetention,
+	)
+
+	orders, prices, err := f.FetchAccount("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 3 || len(prices) != 2 {
+		t.Fatalf("unexpected result: %d orders, %d prices", len(orders), len(prices))
+	}
+	btc := orders[0]
+	if btc.OrderID != 21474836471 || btc.LastOrderPrice != "64000.00000000" || btc.PercentCompleted != "50" || btc.OrderMarketPriceSpread != "3999.99" {
+		t.Fatalf("unexpected BTCUSDT order: %+v", btc)
+	}
+	if orders[1].PercentCompleted != notAvailableText || orders[2].OrderListID != 90001 {
+		t.Fatalf("unexpected ETHUSDT orders: %+v %+v", orders[1], orders[2])
+	}
+	lists, err := dbClient.GetOrderLists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the stop at 3000 is 14.29% away from 3500, the take profit at 4200 is 20% away
+	if len(lists) != 1 || lists[0].TriggerDistance != "14.29" {
+		t.Fatalf("unexpected order lists %+v", lists)
+	}
+	if balances, _ := dbClient.GetBalances(); len(balances) != 3 {
+		t.Fatalf("unexpected balances %+v", balances)
+	}
+}
@@ -0,0 +1,158 @@
+package fetcher
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/decimal"
+	"github.com/morzhanov/binance-orders-watcher/internal/events"
+	"github.com/morzhanov/binance-orders-watcher/internal/exchange"
+)
+
+// OrderListDone is published when an order list is no longer open, Orders hold its legs with their
+// final statuses, e.g. the filled take profit and the canceled stop loss.
+type OrderListDone struct {
+	List   *db.OrderList `json:"list"`
+	Orders []*db.Order   `json:"orders"`
+}
+
+// fetchOrderLists stores the open order lists of a Binance account with the distance to their closest trigger,
+// lists which were open on the previous fetch and are gone now are reported with a single event.
+func (f *fetcherImp) fetchOrderLists(accountID string, orders []*db.Order) error {
+	account := f.binanceAccount(accountID)
+	if account == nil {
+		return nil
+	}
+	binanceLists, err := account.Client.GetOpenOrderLists()
+	if err != nil {
+		log.Println("failed to get order lists from binance: ", err)
+		return err
+	}
+	stored, err := f.db.GetOrderLists()
+	if err != nil {
+		return err
+	}
+
+	lists := make([]*db.OrderList, 0, len(binanceLists))
+	open := make(map[int]bool, len(binanceLists))
+	for _, l := range binanceLists {
+		open[l.OrderListID] = true
+		lists = append(lists, &db.OrderList{
+			AccountID:         accountID,
+			Symbol:            l.Symbol,
+			OrderListID:       l.OrderListID,
+			ContingencyType:   l.ContingencyType,
+			ListStatusType:    l.ListStatusType,
+			ListOrderStatus:   l.ListOrderStatus,
+			ListClientOrderID: l.ListClientOrderID,
+			TransactionTime:   l.TransactionTime,
+			TriggerDistance:   calculateTriggerDistance(listOrders(orders, l.OrderListID)),
+		})
+	}
+	// histories of done lists are fetched before the lists are stored, so a failed fetch keeps them for the next one
+	driver := f.account(accountID).Driver
+	var done []*db.OrderList
+	histories := make(map[string][]*exchange.Order)
+	for _, l := range stored {
+		if l.AccountID != accountID || open[l.OrderListID] {
+			continue
+		}
+		if _, ok := histories[l.Symbol]; !ok {
+			history, err := driver.GetOrderHistory(l.Symbol)
+			if err != nil {
+				log.Println("failed to get order history of done order lists: ", err)
+				return err
+			}
+			histories[l.Symbol] = history
+		}
+		done = append(done, l)
+	}
+	if err = f.db.SetOrderLists(accountID, lists); err != nil {
+		log.Println("failed to set order lists from binance to db: ", err)
+		return err
+	}
+	for _, l := range done {
+		f.reportOrderListDone(l, histories[l.Symbol])
+	}
+	return nil
+}
+
+func (f *fetcherImp) reportOrderListDone(list *db.OrderList, history []*exchange.Order) {
+	var legs []*db.Order
+	var texts []string
+	for _, o := range history {
+		if o.OrderListID != list.OrderListID {
+			continue
+		}
+		leg := &db.Order{
+			AccountID:   list.AccountID,
+			Symbol:      o.Symbol,
+			OrderID:     o.OrderID,
+			OrderListID: o.OrderListID,
+			Price:       o.Price,
+			OrigQty:     o.OrigQty,
+			ExecutedQty: o.ExecutedQty,
+			Status:      o.Status,
+			Type:        o.Type,
+			Side:        o.Side,
+			StopPrice:   o.StopPrice,
+			Time:        o.Time,
+			UpdateTime:  o.UpdateTime,
+		}
+		legs = append(legs, leg)
+		texts = append(texts, fmt.Sprintf("%s %s at %s %s", leg.Type, leg.Side, triggerPrice(leg), leg.Status))
+	}
+	message := fmt.Sprintf("%s %d of account %s is done", list.ContingencyType, list.OrderListID, list.AccountID)
+	if len(texts) > 0 {
+		message += ": " + strings.Join(texts, ", ")
+	}
+	f.addEvent(db.NewEvent(db.EventTypeOrderListDone, list.Symbol, message))
+	f.bus.Publish(events.TypeOrderListDone, &OrderListDone{List: list, Orders: legs})
+}
+
+func listOrders(orders []*db.Order, orderListID int) []*db.Order {
+	var res []*db.Order
+	for _, o := range orders {
+		if o.OrderListID == orderListID {
+			res = append(res, o)
+		}
+	}
+	return res
+}
+
+// triggerPrice returns the stop price of stop legs and the price of limit legs.
+func triggerPrice(o *db.Order) string {
+	if stop, err := decimal.Parse(o.StopPrice); err == nil && !stop.IsZero() {
+		return o.StopPrice
+	}
+	return o.Price
+}
+
+// calculateTriggerDistance returns the distance between the market price and the closest leg trigger price
+// in percent of the market price, rounded to two decimals.
+func calculateTriggerDistance(legs []*db.Order) string {
+	var closest *decimal.Decimal
+	for _, leg := range legs {
+		market, err := decimal.Parse(leg.MarketPrice)
+		if err != nil {
+			continue
+		}
+		trigger, err := decimal.Parse(triggerPrice(leg))
+		if err != nil {
+			continue
+		}
+		distance, err := trigger.Sub(market).Abs().Mul(decimal.NewFromInt(100)).Div(market)
+		if err != nil {
+			continue
+		}
+		if closest == nil || distance.LessThan(*closest) {
+			closest = &distance
+		}
+	}
+	if closest == nil {
+		return notAvailableText
+	}
+	return closest.StringFixed(2)
+}
@@ -0,0 +1,110 @@
+package klines
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+// binanceLimit is the max number of klines returned by a single Binance request.
+const binanceLimit = 1000
+
+// Intervals supported by Binance klines.
+var Intervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"3m":  3 * time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"2h":  2 * time.Hour,
+	"4h":  4 * time.Hour,
+	"6h":  6 * time.Hour,
+	"8h":  8 * time.Hour,
+	"12h": 12 * time.Hour,
+	"1d":  24 * time.Hour,
+	"3d":  3 * 24 * time.Hour,
+	"1w":  7 * 24 * time.Hour,
+}
+
+// Cache returns klines from the db, only the missing head, gaps and tail of the range are downloaded from Binance.
+type Cache interface {
+	Get(symbol, interval string, from, to time.Time) ([]*db.Kline, error)
+}
+
+type cache struct {
+	binClient binance.Client
+	db        db.Client
+	now       func() time.Time
+}
+
+func New(binClient binance.Client, dbClient db.Client) Cache {
+	return &cache{binClient: binClient, db: dbClient, now: time.Now}
+}
+
+func (c *cache) Get(symbol, interval string, from, to time.Time) ([]*db.Kline, error) {
+	duration, ok := Intervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("unsupported interval %q", interval)
+	}
+	if now := c.now(); to.After(now) {
+		to = now
+	}
+	fromMs, toMs := from.UnixMilli(), to.UnixMilli()
+
+	cached, err := c.db.GetKlines(symbol, interval, fromMs, toMs)
+	if err != nil {
+		return nil, err
+	}
+	if len(cached) == 0 {
+		if err = c.download(symbol, interval, fromMs, toMs); err != nil {
+			return nil, err
+		}
+		return c.db.GetKlines(symbol, interval, fromMs, toMs)
+	}
+
+	first, last := cached[0], cached[len(cached)-1]
+	if first.OpenTime-fromMs >= duration.Milliseconds() {
+		if err = c.download(symbol, interval, fromMs, first.OpenTime-1); err != nil {
+			return nil, err
+		}
+	}
+	// candles of a range requested before could be missing in the middle of the cached ones
+	for i := 1; i < len(cached); i++ {
+		prev, next := cached[i-1], cached[i]
+		if next.OpenTime-prev.OpenTime > duration.Milliseconds() {
+			if err = c.download(symbol, interval, prev.OpenTime+duration.Milliseconds(), next.OpenTime-1); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// the last cached candle could have been stored before it was closed, so it is downloaded again
+	if last.CloseTime < toMs {
+		if err = c.download(symbol, interval, last.OpenTime, toMs); err != nil {
+			return nil, err
+		}
+	}
+	return c.db.GetKlines(symbol, interval, fromMs, toMs)
+}
+
+func (c *cache) download(symbol, interval string, from, to int64) error {
+	for from <= to {
+		klines, err := c.binClient.GetKlines(symbol, interval, from, to, binanceLimit)
+		if err != nil {
+			return err
+		}
+		if len(klines) == 0 {
+			return nil
+		}
+		if err = c.db.SaveKlines(klines); err != nil {
+			return err
+		}
+		if len(klines) < binanceLimit {
+			return nil
+		}
+		from = klines[len(klines)-1].CloseTime + 1
+	}
+	return nil
+}
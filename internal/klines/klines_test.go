@@ -0,0 +1,100 @@
+package klines
+
+import (
+	"testing"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+// fakeBinanceClient serves one minute candles and records requested ranges.
+type fakeBinanceClient struct {
+	binance.Client
+	requests [][2]int64
+}
+
+func (c *fakeBinanceClient) GetKlines(symbol, interval string, startTime, endTime int64, limit int) ([]*db.Kline, error) {
+	c.requests = append(c.requests, [2]int64{startTime, endTime})
+	step := time.Minute.Milliseconds()
+	klines := make([]*db.Kline, 0)
+	for t := (startTime + step - 1) / step * step; t <= endTime && len(klines) < limit; t += step {
+		klines = append(klines, &db.Kline{Symbol: symbol, Interval: interval, OpenTime: t, Close: "1", CloseTime: t + step - 1})
+	}
+	return klines, nil
+}
+
+func TestCacheDownloadsMissingRanges(t *testing.T) {
+	binClient := &fakeBinanceClient{}
+	now := time.UnixMilli(0).Add(3000 * time.Minute)
+	c := &cache{binClient: binClient, db: db.NewMemoryClient(), now: func() time.Time { return now }}
+
+	from := now.Add(-1500 * time.Minute)
+	klines, err := c.Get("BTCUSDT", "1m", from, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(klines) != 1501 || len(binClient.requests) != 2 {
+		t.Fatalf("expected 1501 klines in 2 pages, got %d klines in %d requests", len(klines), len(binClient.requests))
+	}
+
+	binClient.requests = nil
+	if klines, err = c.Get("BTCUSDT", "1m", from.Add(10*time.Minute), now); err != nil {
+		t.Fatal(err)
+	}
+	if len(klines) != 1491 {
+		t.Fatalf("expected 1491 cached klines, got %d", len(klines))
+	}
+	if len(binClient.requests) != 0 {
+		t.Fatalf("expected cached range to be served from db, got requests %v", binClient.requests)
+	}
+
+	if klines, err = c.Get("BTCUSDT", "1m", from.Add(-5*time.Minute), now); err != nil {
+		t.Fatal(err)
+	}
+	if len(klines) != 1506 || len(binClient.requests) != 1 {
+		t.Fatalf("expected only the missing head to be downloaded, got %d klines and requests %v", len(klines), binClient.requests)
+	}
+	if binClient.requests[0] != [2]int64{from.Add(-5 * time.Minute).UnixMilli(), from.UnixMilli() - 1} {
+		t.Fatalf("unexpected head request %v", binClient.requests[0])
+	}
+}
+
+func TestCacheDownloadsGaps(t *testing.T) {
+	binClient := &fakeBinanceClient{}
+	now := time.UnixMilli(0).Add(3000 * time.Minute)
+	dbClient := db.NewMemoryClient()
+	c := &cache{binClient: binClient, db: dbClient, now: func() time.Time { return now }}
+
+	// two ranges were requested before, the candles between them were never downloaded
+	if _, err := c.Get("BTCUSDT", "1m", now.Add(-100*time.Minute), now.Add(-80*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("BTCUSDT", "1m", now.Add(-40*time.Minute), now); err != nil {
+		t.Fatal(err)
+	}
+
+	binClient.requests = nil
+	klines, err := c.Get("BTCUSDT", "1m", now.Add(-100*time.Minute), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(klines) != 101 {
+		t.Fatalf("expected 101 klines, got %d", len(klines))
+	}
+	for i := 1; i < len(klines); i++ {
+		if klines[i].OpenTime-klines[i-1].OpenTime != time.Minute.Milliseconds() {
+			t.Fatalf("klines have a gap after %d", klines[i-1].OpenTime)
+		}
+	}
+	if len(binClient.requests) != 1 || binClient.requests[0] != [2]int64{now.Add(-79 * time.Minute).UnixMilli(), now.Add(-40*time.Minute).UnixMilli() - 1} {
+		t.Fatalf("expected only the gap to be downloaded, got requests %v", binClient.requests)
+	}
+}
+
+func TestCacheUnsupportedInterval(t *testing.T) {
+	c := New(&fakeBinanceClient{}, db.NewMemoryClient())
+	if _, err := c.Get("BTCUSDT", "7m", time.Now().Add(-time.Hour), time.Now()); err == nil {
+		t.Fatal("expected an error for unsupported interval")
+	}
+}
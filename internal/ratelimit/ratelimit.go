@@ -0,0 +1,160 @@
+// Package ratelimit locks out clients with too many failed attempts.
+//
+// Failures are counted in a sliding window. A key with MaxFailures failures within the window is locked out,
+// every following lockout is twice as long as the previous one up to the maximum lockout. Keys without failures
+// for the maximum lockout are forgotten, so clients which stopped failing start over.
+package ratelimit
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxFailures = 5
+	DefaultWindow      = 15 * time.Minute
+	DefaultLockout     = time.Minute
+	DefaultMaxLockout  = 24 * time.Hour
+)
+
+// Entry is the state of a key with recent failures or lockouts.
+type Entry struct {
+	Key string
+	// Failures is the number of failures in the current window
+	Failures    int
+	Lockouts    int
+	LockedUntil time.Time
+	LastFailure time.Time
+}
+
+type Limiter interface {
+	// Locked returns how long the key is still locked out, 0 when it is not.
+	Locked(key string) time.Duration
+	// Fail records a failed attempt of the key and returns the lockout when this failure started one.
+	Fail(key string) time.Duration
+	// Reset forgets the failures and lockouts of the key, after a successful attempt or to unblock it.
+	Reset(key string)
+	// Entries lists keys with failures in the window or lockouts, the last failed first.
+	Entries() []Entry
+}
+
+type entry struct {
+	failures    []time.Time
+	lockouts    int
+	lockedUntil time.Time
+	lastFailure time.Time
+}
+
+type limiter struct {
+	maxFailures int
+	window      time.Duration
+	lockout     time.Duration
+	maxLockout  time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+func New(maxFailures int, window, lockout, maxLockout time.Duration) Limiter {
+	return &limiter{
+		maxFailures: maxFailures,
+		window:      window,
+		lockout:     lockout,
+		maxLockout:  maxLockout,
+		now:         time.Now,
+		entries:     make(map[string]*entry),
+	}
+}
+
+func (l *limiter) Locked(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.entries[key]
+	if !ok {
+		return 0
+	}
+	if wait := e.lockedUntil.Sub(l.now()); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+func (l *limiter) Fail(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.prune(now)
+
+	e, ok := l.entries[key]
+	if !ok {
+		e = &entry{}
+		l.entries[key] = e
+	}
+	e.lastFailure = now
+	if now.Before(e.lockedUntil) {
+		return 0
+	}
+	e.failures = append(e.failures, now)
+	if len(e.failures) < l.maxFailures {
+		return 0
+	}
+
+	lockout := l.lockout
+	for i := 0; i < e.lockouts && lockout < l.maxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > l.maxLockout {
+		lockout = l.maxLockout
+	}
+	e.lockouts++
+	e.lockedUntil = now.Add(lockout)
+	e.failures = nil
+	return lockout
+}
+
+func (l *limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, key)
+}
+
+func (l *limiter) Entries() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(l.now())
+
+	entries := make([]Entry, 0, len(l.entries))
+	for key, e := range l.entries {
+		entries = append(entries, Entry{
+			Key:         key,
+			Failures:    len(e.failures),
+			Lockouts:    e.lockouts,
+			LockedUntil: e.lockedUntil,
+			LastFailure: e.lastFailure,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].LastFailure.After(entries[j].LastFailure) })
+	return entries
+}
+
+// prune drops failures which left the window and forgets keys which didn't fail for the maximum lockout.
+func (l *limiter) prune(now time.Time) {
+	for key, e := range l.entries {
+		failures := e.failures[:0]
+		for _, t := range e.failures {
+			if now.Sub(t) < l.window {
+				failures = append(failures, t)
+			}
+		}
+		e.failures = failures
+		if len(e.failures) == 0 && e.lockouts == 0 {
+			delete(l.entries, key)
+			continue
+		}
+		if now.After(e.lockedUntil) && now.Sub(e.lastFailure) >= l.maxLockout {
+			delete(l.entries, key)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestLimiter(clock *fakeClock) *limiter {
+	l := New(3, 10*time.Minute, time.Minute, 8*time.Minute).(*limiter)
+	l.now = func() time.Time { return clock.now }
+	return l
+}
+
+func failTimes(l *limiter, key string, n int) time.Duration {
+	var lockout time.Duration
+	for i := 0; i < n; i++ {
+		lockout = l.Fail(key)
+	}
+	return lockout
+}
+
+func TestLockoutDoubles(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	l := newTestLimiter(clock)
+
+	for i, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 8 * time.Minute} {
+		if lockout := failTimes(l, "10.0.0.1", 3); lockout != want {
+			t.Fatalf("lockout %d: got %s, want %s", i, lockout, want)
+		}
+		if locked := l.Locked("10.0.0.1"); locked != want {
+			t.Fatalf("lockout %d: locked for %s, want %s", i, locked, want)
+		}
+		if l.Locked("10.0.0.2") != 0 {
+			t.Fatal("other keys should not be locked")
+		}
+		clock.advance(want)
+		if l.Locked("10.0.0.1") != 0 {
+			t.Fatalf("lockout %d should have expired", i)
+		}
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	l := newTestLimiter(clock)
+
+	failTimes(l, "10.0.0.1", 2)
+	clock.advance(6 * time.Minute)
+	if lockout := l.Fail("10.0.0.1"); lockout != time.Minute {
+		t.Fatalf("expected a lockout with 3 failures in the window, got %s", lockout)
+	}
+
+	l.Reset("10.0.0.1")
+	failTimes(l, "10.0.0.1", 2)
+	clock.advance(11 * time.Minute)
+	if lockout := l.Fail("10.0.0.1"); lockout != 0 {
+		t.Fatalf("failures out of the window should not count, got lockout %s", lockout)
+	}
+	entries := l.Entries()
+	if len(entries) != 1 || entries[0].Failures != 1 || entries[0].Lockouts != 0 {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+}
+
+func TestResetAndExpiry(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	l := newTestLimiter(clock)
+
+	failTimes(l, "10.0.0.1", 3)
+	l.Reset("10.0.0.1")
+	if l.Locked("10.0.0.1") != 0 || len(l.Entries()) != 0 {
+		t.Fatal("reset should unblock the key")
+	}
+
+	failTimes(l, "10.0.0.1", 3)
+	clock.advance(time.Second)
+	l.Fail("10.0.0.2")
+	entries := l.Entries()
+	if len(entries) != 2 || entries[0].Key != "10.0.0.2" || entries[1].Lockouts != 1 {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+
+	// keys are forgotten after the maximum lockout without failures, so the next lockout is short again
+	clock.advance(8 * time.Minute)
+	if entries = l.Entries(); len(entries) != 0 {
+		t.Fatalf("expected old entries to be forgotten, got %+v", entries)
+	}
+	if lockout := failTimes(l, "10.0.0.1", 3); lockout != time.Minute {
+		t.Fatalf("got lockout %s, want %s", lockout, time.Minute)
+	}
+}
@@ -0,0 +1,418 @@
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/decimal"
+)
+
+const (
+	orderTypeStopLoss        = "STOP_LOSS"
+	orderTypeTakeProfit      = "TAKE_PROFIT"
+	orderTypeTakeProfitLimit = "TAKE_PROFIT_LIMIT"
+	defaultValuationAsset    = "USDT"
+	noOrderList              = -1
+)
+
+// Simulator paper trades the stored open orders and balances against a hypothetical price path.
+// Nothing is sent to Binance and the db is not changed.
+type Simulator interface {
+	Simulate(scenario *Scenario) (*Result, error)
+}
+
+type simulatorImp struct {
+	db           db.Client
+	exchangeInfo binance.ExchangeInfoCache
+}
+
+// Scenario moves the prices of symbols to the path points in order, symbols which are not in the path
+// keep their current price. AccountID selects an account, all accounts are simulated when it is empty.
+type Scenario struct {
+	AccountID      string        `json:"accountId"`
+	ValuationAsset string        `json:"valuationAsset"`
+	Path           []*PricePoint `json:"path"`
+}
+
+type PricePoint struct {
+	Symbol string `json:"symbol"`
+	Price  string `json:"price"`
+}
+
+// Fill is an order filled at Step, the index of the path point the price was moving to.
+type Fill struct {
+	AccountID string `json:"accountId"`
+	Symbol    string `json:"symbol"`
+	OrderID   int    `json:"orderId"`
+	Type      string `json:"type"`
+	Side      string `json:"side"`
+	Qty       string `json:"qty"`
+	Price     string `json:"price"`
+	Step      int    `json:"step"`
+}
+
+// BalanceChange holds the free and locked amount of an asset before and after the scenario.
+type BalanceChange struct {
+	AccountID string `json:"accountId"`
+	Asset     string `json:"asset"`
+	Before    string `json:"before"`
+	After     string `json:"after"`
+	Change    string `json:"change"`
+}
+
+// Result values balances in ValuationAsset: StartValue at the current prices, EndValue and HoldValue
+// at the prices at the end of the path. HoldValue is the value of the balances if no order filled, so
+// TradingPnL is the part of PnL made by the fills. Assets without a price are listed in Unvalued.
+type Result struct {
+	Fills          []*Fill          `json:"fills"`
+	Canceled       []*db.Order      `json:"canceled"`
+	OpenOrders     []*db.Order      `json:"openOrders"`
+	Balances       []*BalanceChange `json:"balances"`
+	Prices         []*db.Price      `json:"prices"`
+	ValuationAsset string           `json:"valuationAsset"`
+	StartValue     string           `json:"startValue"`
+	EndValue       string           `json:"endValue"`
+	HoldValue      string           `json:"holdValue"`
+	PnL            string           `json:"pnl"`
+	TradingPnL     string           `json:"tradingPnl"`
+	Unvalued       []string         `json:"unvalued"`
+}
+
+var ErrUnsupportedOrderType = errors.New("unsupported order type")
+
+// simOrder is an open order during the simulation, stop orders become limit or market orders when triggered.
+type simOrder struct {
+	order     *db.Order
+	base      string
+	quote     string
+	remaining decimal.Decimal
+	limit     decimal.Decimal
+	stop      decimal.Decimal
+	triggered bool
+	done      bool
+}
+
+type holdings map[string]map[string]decimal.Decimal
+
+func New(dbClient db.Client, exchangeInfo binance.ExchangeInfoCache) Simulator {
+	return &simulatorImp{db: dbClient, exchangeInfo: exchangeInfo}
+}
+
+func (s *simulatorImp) Simulate(scenario *Scenario) (*Result, error) {
+	valuationAsset := scenario.ValuationAsset
+	if valuationAsset == "" {
+		valuationAsset = defaultValuationAsset
+	}
+	orders, err := s.openOrders(scenario.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	before, err := s.balances(scenario.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	after := make(holdings, len(before))
+	for accountID, assets := range before {
+		after[accountID] = make(map[string]decimal.Decimal, len(assets))
+		for asset, amount := range assets {
+			after[accountID][asset] = amount
+		}
+	}
+	stored, err := s.db.GetPrices()
+	if err != nil {
+		return nil, err
+	}
+	startPrices := make(map[string]decimal.Decimal, len(stored))
+	for _, p := range stored {
+		if price, err := decimal.Parse(p.Price); err == nil {
+			startPrices[p.Symbol] = price
+		}
+	}
+	prices := make(map[string]decimal.Decimal, len(startPrices))
+	for symbol, price := range startPrices {
+		prices[symbol] = price
+	}
+
+	result := &Result{ValuationAsset: valuationAsset, Fills: []*Fill{}, Canceled: []*db.Order{}, OpenOrders: []*db.Order{}}
+	for step, point := range scenario.Path {
+		target, err := decimal.Parse(point.Price)
+		if err != nil {
+			return nil, fmt.Errorf("invalid price %q of path point %d", point.Price, step)
+		}
+		current, ok := prices[point.Symbol]
+		if !ok {
+			current = target
+		}
+		s.move(orders, point.Symbol, current, target, step, after, result)
+		prices[point.Symbol] = target
+	}
+
+	for _, o := range orders {
+		if !o.done {
+			result.OpenOrders = append(result.OpenOrders, o.order)
+		}
+	}
+	result.Balances = balanceChanges(before, after)
+	result.Prices = priceList(prices)
+	s.value(result, before, after, startPrices, prices)
+	return result, nil
+}
+
+func (s *simulatorImp) openOrders(accountID string) ([]*simOrder, error) {
+	stored, err := s.db.GetOrders()
+	if err != nil {
+		return nil, err
+	}
+	var orders []*simOrder
+	for _, o := range stored {
+		if accountID != "" && o.AccountID != accountID {
+			continue
+		}
+		info := s.exchangeInfo.Lookup(o.Symbol)
+		if info == nil {
+			return nil, fmt.Errorf("unknown symbol %s", o.Symbol)
+		}
+		order, err := newSimOrder(o, info)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	// orders reached at the same price fill in the order they were placed
+	sort.SliceStable(orders, func(i, j int) bool { return orders[i].order.Time < orders[j].order.Time })
+	return orders, nil
+}
+
+func newSimOrder(o *db.Order, info *binance.SymbolInfo) (*simOrder, error) {
+	orig, err := decimal.Parse(o.OrigQty)
+	if err != nil {
+		return nil, err
+	}
+	executed, err := decimal.Parse(o.ExecutedQty)
+	if err != nil {
+		return nil, err
+	}
+	order := &simOrder{order: o, base: info.BaseAsset, quote: info.QuoteAsset, remaining: orig.Sub(executed)}
+	switch o.Type {
+	case binance.OrderTypeLimit, binance.OrderTypeLimitMaker:
+		order.triggered = true
+	case binance.OrderTypeStopLossLimit, orderTypeTakeProfitLimit, orderTypeStopLoss, orderTypeTakeProfit:
+	default:
+		return nil, fmt.Errorf("%w %s of order %d", ErrUnsupportedOrderType, o.Type, o.OrderID)
+	}
+	if order.hasLimit() {
+		if order.limit, err = decimal.Parse(o.Price); err != nil {
+			return nil, err
+		}
+	}
+	if !order.triggered {
+		if order.stop, err = decimal.Parse(o.StopPrice); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+func (o *simOrder) hasLimit() bool {
+	return o.order.Type != orderTypeStopLoss && o.order.Type != orderTypeTakeProfit
+}
+
+// level returns the price reaching the order and whether it is reached from above. Untriggered orders
+// are reached at the stop price: stop loss sells and take profit buys when the price falls to it.
+// Limit orders are reached when the price falls to a buy or rises to a sell.
+func (o *simOrder) level() (decimal.Decimal, bool) {
+	buy := o.order.Side == binance.SideBuy
+	if o.triggered {
+		return o.limit, buy
+	}
+	stopLoss := o.order.Type == binance.OrderTypeStopLossLimit || o.order.Type == orderTypeStopLoss
+	return o.stop, stopLoss != buy
+}
+
+// reachedAt returns the price at which the order is reached while the price moves from current to target.
+func (o *simOrder) reachedAt(current, target decimal.Decimal) (decimal.Decimal, bool) {
+	level, falling := o.level()
+	if falling {
+		if current.Cmp(level) <= 0 {
+			return current, true
+		}
+		return level, target.Cmp(level) <= 0
+	}
+	if current.Cmp(level) >= 0 {
+		return current, true
+	}
+	return level, target.Cmp(level) >= 0
+}
+
+// move moves the symbol price continuously from current to target, orders are processed in the order the
+// price reaches them. Marketable orders fill at the current price, so resting limit orders fill at
+// their limit and market stop orders at their stop price. When an OCO leg is triggered or filled the
+// other legs are canceled. Commissions are not simulated.
+func (s *simulatorImp) move(orders []*simOrder, symbol string, current, target decimal.Decimal, step int, after holdings, result *Result) {
+	for {
+		var next *simOrder
+		var nextAt decimal.Decimal
+		for _, o := range orders {
+			if o.done || o.order.Symbol != symbol {
+				continue
+			}
+			at, ok := o.reachedAt(current, target)
+			if !ok {
+				continue
+			}
+			if next == nil || at.Sub(current).Abs().LessThan(nextAt.Sub(current).Abs()) {
+				next, nextAt = o, at
+			}
+		}
+		if next == nil {
+			return
+		}
+		current = nextAt
+		if !next.triggered {
+			next.triggered = true
+			cancelOrderList(orders, next, result)
+			if next.hasLimit() {
+				continue
+			}
+		}
+		fill(next, current, step, after, result)
+		cancelOrderList(orders, next, result)
+	}
+}
+
+func fill(o *simOrder, price decimal.Decimal, step int, after holdings, result *Result) {
+	o.done = true
+	quoteQty := o.remaining.Mul(price)
+	assets, ok := after[o.order.AccountID]
+	if !ok {
+		assets = make(map[string]decimal.Decimal)
+		after[o.order.AccountID] = assets
+	}
+	if o.order.Side == binance.SideBuy {
+		assets[o.base] = assets[o.base].Add(o.remaining)
+		assets[o.quote] = assets[o.quote].Sub(quoteQty)
+	} else {
+		assets[o.base] = assets[o.base].Sub(o.remaining)
+		assets[o.quote] = assets[o.quote].Add(quoteQty)
+	}
+	result.Fills = append(result.Fills, &Fill{
+		AccountID: o.order.AccountID,
+		Symbol:    o.order.Symbol,
+		OrderID:   o.order.OrderID,
+		Type:      o.order.Type,
+		Side:      o.order.Side,
+		Qty:       o.remaining.String(),
+		Price:     price.String(),
+		Step:      step,
+	})
+}
+
+func cancelOrderList(orders []*simOrder, leg *simOrder, result *Result) {
+	if leg.order.OrderListID == noOrderList {
+		return
+	}
+	for _, o := range orders {
+		if o == leg || o.done || o.order.AccountID != leg.order.AccountID || o.order.OrderListID != leg.order.OrderListID {
+			continue
+		}
+		o.done = true
+		result.Canceled = append(result.Canceled, o.order)
+	}
+}
+
+func (s *simulatorImp) balances(accountID string) (holdings, error) {
+	stored, err := s.db.GetBalances()
+	if err != nil {
+		return nil, err
+	}
+	h := make(holdings)
+	for _, b := range stored {
+		if accountID != "" && b.AccountID != accountID {
+			continue
+		}
+		free, err := decimal.Parse(b.Free)
+		if err != nil {
+			return nil, err
+		}
+		locked, err := decimal.Parse(b.Locked)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := h[b.AccountID]; !ok {
+			h[b.AccountID] = make(map[string]decimal.Decimal)
+		}
+		h[b.AccountID][b.Asset] = free.Add(locked)
+	}
+	return h, nil
+}
+
+func balanceChanges(before, after holdings) []*BalanceChange {
+	changes := []*BalanceChange{}
+	for accountID, assets := range after {
+		for asset, amount := range assets {
+			start := before[accountID][asset]
+			changes = append(changes, &BalanceChange{
+				AccountID: accountID,
+				Asset:     asset,
+				Before:    start.String(),
+				After:     amount.String(),
+				Change:    amount.Sub(start).String(),
+			})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].AccountID != changes[j].AccountID {
+			return changes[i].AccountID < changes[j].AccountID
+		}
+		return changes[i].Asset < changes[j].Asset
+	})
+	return changes
+}
+
+func priceList(prices map[string]decimal.Decimal) []*db.Price {
+	list := make([]*db.Price, 0, len(prices))
+	for symbol, price := range prices {
+		list = append(list, &db.Price{Symbol: symbol, Price: price.String()})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Symbol < list[j].Symbol })
+	return list
+}
+
+func (s *simulatorImp) value(result *Result, before, after holdings, startPrices, endPrices map[string]decimal.Decimal) {
+	unvalued := make(map[string]bool)
+	total := func(h holdings, prices map[string]decimal.Decimal) decimal.Decimal {
+		var sum decimal.Decimal
+		for _, assets := range h {
+			for asset, amount := range assets {
+				if amount.IsZero() {
+					continue
+				}
+				if asset == result.ValuationAsset {
+					sum = sum.Add(amount)
+					continue
+				}
+				price, ok := prices[asset+result.ValuationAsset]
+				if !ok {
+					unvalued[asset] = true
+					continue
+				}
+				sum = sum.Add(amount.Mul(price))
+			}
+		}
+		return sum
+	}
+	start, end, hold := total(before, startPrices), total(after, endPrices), total(before, endPrices)
+	result.StartValue = start.String()
+	result.EndValue = end.String()
+	result.HoldValue = hold.String()
+	result.PnL = end.Sub(start).String()
+	result.TradingPnL = end.Sub(hold).String()
+	result.Unvalued = []string{}
+	for asset := range unvalued {
+		result.Unvalued = append(result.Unvalued, asset)
+	}
+	sort.Strings(result.Unvalued)
+}
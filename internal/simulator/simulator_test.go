@@ -0,0 +1,125 @@
+package simulator
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+type fakeExchangeInfo struct{}
+
+func (fakeExchangeInfo) Get(symbol string) (*binance.SymbolInfo, error) {
+	return fakeExchangeInfo{}.Lookup(symbol), nil
+}
+
+func (fakeExchangeInfo) Lookup(symbol string) *binance.SymbolInfo {
+	if symbol != "BTCUSDT" {
+		return nil
+	}
+	return &binance.SymbolInfo{Symbol: symbol, BaseAsset: "BTC", QuoteAsset: "USDT"}
+}
+
+func (fakeExchangeInfo) Symbols() []*binance.SymbolInfo { return nil }
+func (fakeExchangeInfo) Refresh() error                 { return nil }
+func (fakeExchangeInfo) Run(time.Duration)              {}
+
+func order(id, listID int, orderType, side, price, stopPrice string) *db.Order {
+	return &db.Order{AccountID: db.DefaultAccountID, Symbol: "BTCUSDT", OrderID: id, OrderListID: listID, Type: orderType, Side: side, Price: price, StopPrice: stopPrice, OrigQty: "0.1", ExecutedQty: "0", Time: id}
+}
+
+func TestSimulate(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	err := dbClient.SetOrders(db.DefaultAccountID, []*db.Order{
+		order(1, -1, binance.OrderTypeLimit, binance.SideBuy, "45000", "0"),
+		order(2, 7, binance.OrderTypeLimitMaker, binance.SideSell, "60000", "0"),
+		order(3, 7, binance.OrderTypeStopLossLimit, binance.SideSell, "41900", "42000"),
+		order(4, -1, binance.OrderTypeLimit, binance.SideBuy, "30000", "0"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = dbClient.SetBalances(db.DefaultAccountID, []*db.Balance{
+		{Asset: "USDT", Free: "5500", Locked: "4500"},
+		{Asset: "BTC", Free: "0", Locked: "0.1"},
+		{Asset: "ETH", Free: "1", Locked: "0"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dbClient.SetPrices([]*db.Price{{Symbol: "BTCUSDT", Price: "50000"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := New(dbClient, fakeExchangeInfo{}).Simulate(&Scenario{Path: []*PricePoint{
+		{Symbol: "BTCUSDT", Price: "40000"},
+		{Symbol: "BTCUSDT", Price: "55000"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the buy fills at its limit, then the stop triggers at 42000, cancels the take profit leg
+	// and its marketable limit fills at the stop price
+	if len(result.Fills) != 2 || result.Fills[0].OrderID != 1 || result.Fills[0].Price != "45000" ||
+		result.Fills[1].OrderID != 3 || result.Fills[1].Price != "42000" || result.Fills[1].Step != 0 {
+		t.Fatalf("unexpected fills %+v", result.Fills)
+	}
+	if len(result.Canceled) != 1 || result.Canceled[0].OrderID != 2 {
+		t.Fatalf("unexpected canceled orders %+v", result.Canceled)
+	}
+	if len(result.OpenOrders) != 1 || result.OpenOrders[0].OrderID != 4 {
+		t.Fatalf("unexpected open orders %+v", result.OpenOrders)
+	}
+	balances := make(map[string]*BalanceChange)
+	for _, b := range result.Balances {
+		balances[b.Asset] = b
+	}
+	if balances["BTC"].After != "0.1" || balances["USDT"].After != "9700" || balances["USDT"].Change != "-300" || balances["ETH"].Change != "0" {
+		t.Fatalf("unexpected balances %+v %+v %+v", balances["BTC"], balances["USDT"], balances["ETH"])
+	}
+	if result.StartValue != "15000" || result.EndValue != "15200" || result.HoldValue != "15500" || result.PnL != "200" || result.TradingPnL != "-300" {
+		t.Fatalf("unexpected values %+v", result)
+	}
+	if len(result.Unvalued) != 1 || result.Unvalued[0] != "ETH" {
+		t.Fatalf("ETH has no price and should not be valued, got %v", result.Unvalued)
+	}
+
+	// the simulation does not change the db
+	if orders, _ := dbClient.GetOrders(); len(orders) != 4 {
+		t.Fatalf("orders should not be changed, got %+v", orders)
+	}
+}
+
+func TestSimulateTakeProfitAndStopMarket(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	err := dbClient.SetOrders(db.DefaultAccountID, []*db.Order{
+		// triggers at 52000 and rests until the price rises to 52100
+		order(1, -1, orderTypeTakeProfitLimit, binance.SideSell, "52100", "52000"),
+		order(2, -1, orderTypeStopLoss, binance.SideBuy, "0", "51000"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dbClient.SetPrices([]*db.Price{{Symbol: "BTCUSDT", Price: "50000"}}); err != nil {
+		t.Fatal(err)
+	}
+	s := New(dbClient, fakeExchangeInfo{})
+
+	result, err := s.Simulate(&Scenario{Path: []*PricePoint{{Symbol: "BTCUSDT", Price: "52050"}, {Symbol: "BTCUSDT", Price: "52200"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Fills) != 2 || result.Fills[0].OrderID != 2 || result.Fills[0].Price != "51000" || result.Fills[1].OrderID != 1 || result.Fills[1].Step != 1 || result.Fills[1].Price != "52100" {
+		t.Fatalf("unexpected fills %+v", result.Fills)
+	}
+
+	if err = dbClient.SetOrders(db.DefaultAccountID, []*db.Order{order(3, -1, binance.OrderTypeMarket, binance.SideBuy, "0", "0")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Simulate(&Scenario{}); !errors.Is(err, ErrUnsupportedOrderType) {
+		t.Fatalf("expected unsupported order type error, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	Digits = 6
+	Period = 30 * time.Second
+	// Skew is the number of periods before and after the current one which are accepted for clock drift.
+	Skew       = 1
+	secretSize = 20
+	// RecoveryCodes is the number of one time recovery codes generated on enrolment.
+	RecoveryCodes = 10
+)
+
+var (
+	ErrInvalidCode = errors.New("invalid two-factor code")
+	ErrReusedCode  = errors.New("two-factor code was already used")
+	encoding       = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// Authenticator verifies RFC 6238 codes: HMAC-SHA1, 6 digits and 30 seconds period like authenticator apps use.
+type Authenticator interface {
+	Code(secret string) (string, error)
+	// Verify returns the time step of the code, a code of lastStep or an earlier step is rejected
+	// so an intercepted code can't be used again.
+	Verify(secret, code string, lastStep int64) (int64, error)
+}
+
+type authenticator struct {
+	now func() time.Time
+}
+
+func New() Authenticator {
+	return &authenticator{now: time.Now}
+}
+
+func (a *authenticator) Code(secret string) (string, error) {
+	return CodeAt(secret, a.now())
+}
+
+func (a *authenticator) Verify(secret, code string, lastStep int64) (int64, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, err
+	}
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != Digits {
+		return 0, ErrInvalidCode
+	}
+	current := step(a.now())
+	for s := current - Skew; s <= current+Skew; s++ {
+		if hmac.Equal([]byte(hotp(key, s)), []byte(code)) {
+			if s <= lastStep {
+				return 0, ErrReusedCode
+			}
+			return s, nil
+		}
+	}
+	return 0, ErrInvalidCode
+}
+
+// CodeAt returns the code of the secret at t.
+func CodeAt(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, step(t)), nil
+}
+
+// GenerateSecret returns a random base32 encoded secret.
+func GenerateSecret() (string, error) {
+	key := make([]byte, secretSize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(key), nil
+}
+
+// GenerateRecoveryCodes returns one time codes to log in without the authenticator app,
+// like "k3x9a-q2m7p", and their hashes to store.
+func GenerateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, RecoveryCodes)
+	hashes := make([]string, 0, RecoveryCodes)
+	for i := 0; i < RecoveryCodes; i++ {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(raw))[:10]
+		code = code[:5] + "-" + code[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode returns the stored form of a recovery code, codes are random so a fast hash is enough.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// KeyURI returns the otpauth URI encoded in enrolment QR codes.
+func KeyURI(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(Digits))
+	query.Set("period", fmt.Sprint(int(Period.Seconds())))
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid two-factor secret: %s", err)
+	}
+	return key, nil
+}
+
+func step(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+// hotp is the RFC 4226 code of the counter.
+func hotp(key []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, value%1000000)
+}
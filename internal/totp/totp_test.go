@@ -0,0 +1,109 @@
+package totp
+
+import (
+	"encoding/base32"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func newTestAuthenticator(now time.Time) *authenticator {
+	return &authenticator{now: func() time.Time { return now }}
+}
+
+func TestCodeAtRFCVectors(t *testing.T) {
+	// the RFC lists 8 digit codes, the last 6 digits are the 6 digit codes
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range vectors {
+		code, err := CodeAt(rfcSecret, time.Unix(unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != want {
+			t.Fatalf("code at %d: got %s, want %s", unix, code, want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	a := newTestAuthenticator(now)
+
+	step, err := a.Verify(rfcSecret, "050471", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step != 1111111111/30 {
+		t.Fatalf("unexpected step %d", step)
+	}
+	if _, err = a.Verify(rfcSecret, "050471", step); !errors.Is(err, ErrReusedCode) {
+		t.Fatalf("expected reused code error, got %v", err)
+	}
+
+	// the previous period is accepted for clock drift, two periods ago is not
+	previous, _ := CodeAt(rfcSecret, now.Add(-Period))
+	if _, err = a.Verify(rfcSecret, previous, 0); err != nil {
+		t.Fatalf("expected previous period code to be accepted, got %v", err)
+	}
+	old, _ := CodeAt(rfcSecret, now.Add(-2*Period))
+	if _, err = a.Verify(rfcSecret, old, 0); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("expected old code to be rejected, got %v", err)
+	}
+	for _, code := range []string{"", "12345", "1234567", "000000"} {
+		if _, err = a.Verify(rfcSecret, code, 0); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("expected %q to be rejected, got %v", code, err)
+		}
+	}
+	if _, err = a.Verify("not base32!", "050471", 0); err == nil {
+		t.Fatal("expected invalid secret error")
+	}
+}
+
+func TestVerifyGeneratedSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newTestAuthenticator(time.Unix(1700000000, 0))
+	code, err := a.Code(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a.Verify(secret, code[:3]+" "+code[3:], 0); err != nil {
+		t.Fatalf("expected generated code to be accepted, got %v", err)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodes || len(hashes) != RecoveryCodes {
+		t.Fatalf("unexpected codes %v", codes)
+	}
+	if len(codes[0]) != 11 || codes[0][5] != '-' || codes[0] == codes[1] {
+		t.Fatalf("unexpected codes %v", codes)
+	}
+	if HashRecoveryCode(" "+strings.ToUpper(codes[0])+" ") != hashes[0] {
+		t.Fatal("recovery code hash should ignore case and spaces")
+	}
+}
+
+func TestKeyURI(t *testing.T) {
+	uri := KeyURI("Binance Orders Watcher", "admin", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/Binance%20Orders%20Watcher:admin?") || !strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") {
+		t.Fatalf("unexpected uri %s", uri)
+	}
+}
@@ -0,0 +1,182 @@
+package trader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+// Trader places and cancels orders on Binance. Orders are checked against the symbol filters before
+// submission and every action, including rejected ones, is written to the audit log.
+// In test mode orders are only validated with the Binance test endpoint. Actions are sent to the
+// account with accountID.
+type Trader interface {
+	PlaceOrder(username, accountID string, req *binance.OrderRequest, test bool) (*binance.BinanceOrder, error)
+	PlaceOCO(username, accountID string, req *binance.OCORequest, test bool) (*binance.OrderList, error)
+	CancelOrder(username, accountID, symbol string, orderID int) (*binance.BinanceOrder, error)
+	CancelReplaceOrder(username, accountID string, orderID int, req *binance.OrderRequest, test bool) (*binance.CancelReplaceResult, error)
+}
+
+var ErrUnknownAccount = errors.New("unknown account")
+
+type traderImp struct {
+	accounts     []*binance.Account
+	exchangeInfo binance.ExchangeInfoCache
+	db           db.Client
+}
+
+type cancelRequest struct {
+	Symbol  string `json:"symbol"`
+	OrderID int    `json:"orderId"`
+}
+
+type cancelReplaceRequest struct {
+	CancelOrderID int                   `json:"cancelOrderId"`
+	Order         *binance.OrderRequest `json:"order"`
+}
+
+func New(accounts []*binance.Account, exchangeInfo binance.ExchangeInfoCache, dbClient db.Client) Trader {
+	return &traderImp{accounts: accounts, exchangeInfo: exchangeInfo, db: dbClient}
+}
+
+func (t *traderImp) PlaceOrder(username, accountID string, req *binance.OrderRequest, test bool) (order *binance.BinanceOrder, err error) {
+	entry := db.NewAuditEntry(db.AuditActionPlaceOrder, username, accountID, req.Symbol, test)
+	defer func() { t.audit(entry, req, order, err) }()
+
+	binClient, err := t.client(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if err = t.validate(req); err != nil {
+		return nil, err
+	}
+	if test {
+		return nil, binClient.TestOrder(req)
+	}
+	return binClient.PlaceOrder(req)
+}
+
+func (t *traderImp) PlaceOCO(username, accountID string, req *binance.OCORequest, test bool) (list *binance.OrderList, err error) {
+	entry := db.NewAuditEntry(db.AuditActionPlaceOCO, username, accountID, req.Symbol, test)
+	defer func() { t.audit(entry, req, list, err) }()
+
+	binClient, err := t.client(accountID)
+	if err != nil {
+		return nil, err
+	}
+	legs := req.Legs()
+	if err = t.validate(legs...); err != nil {
+		return nil, err
+	}
+	if test {
+		// there is no test endpoint for OCO orders, so both legs are tested as separate orders
+		for _, leg := range legs {
+			if err = binClient.TestOrder(leg); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+	return binClient.PlaceOCO(req)
+}
+
+func (t *traderImp) CancelOrder(username, accountID, symbol string, orderID int) (order *binance.BinanceOrder, err error) {
+	entry := db.NewAuditEntry(db.AuditActionCancelOrder, username, accountID, symbol, false)
+	defer func() { t.audit(entry, &cancelRequest{Symbol: symbol, OrderID: orderID}, order, err) }()
+
+	binClient, err := t.client(accountID)
+	if err != nil {
+		return nil, err
+	}
+	return binClient.CancelOrder(symbol, orderID)
+}
+
+func (t *traderImp) CancelReplaceOrder(username, accountID string, orderID int, req *binance.OrderRequest, test bool) (result *binance.CancelReplaceResult, err error) {
+	entry := db.NewAuditEntry(db.AuditActionCancelReplaceOrder, username, accountID, req.Symbol, test)
+	defer func() { t.audit(entry, &cancelReplaceRequest{CancelOrderID: orderID, Order: req}, result, err) }()
+
+	binClient, err := t.client(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if err = t.validate(req); err != nil {
+		return nil, err
+	}
+	if test {
+		return nil, binClient.TestOrder(req)
+	}
+	return binClient.CancelReplaceOrder(orderID, req)
+}
+
+func (t *traderImp) client(accountID string) (binance.Client, error) {
+	for _, account := range t.accounts {
+		if account.ID == accountID {
+			return account.Client, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %s", ErrUnknownAccount, accountID)
+}
+
+// validate checks orders of the same symbol against the exchange filters.
+func (t *traderImp) validate(orders ...*binance.OrderRequest) error {
+	symbol := orders[0].Symbol
+	symbolInfo, err := t.exchangeInfo.Get(symbol)
+	if err != nil {
+		return err
+	}
+	if symbolInfo == nil {
+		return binance.FilterErrors{{Field: "symbol", Filter: "SYMBOL", Message: fmt.Sprintf("symbol %s is not listed on Binance", symbol)}}
+	}
+	marketPrice, err := t.marketPrice(symbol)
+	if err != nil {
+		return err
+	}
+
+	var errs binance.FilterErrors
+	for _, order := range orders {
+		if err = symbolInfo.ValidateOrder(order, marketPrice); err != nil {
+			errs = append(errs, err.(binance.FilterErrors)...)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (t *traderImp) marketPrice(symbol string) (string, error) {
+	prices, err := t.db.GetPrices()
+	if err != nil {
+		return "", err
+	}
+	for _, p := range prices {
+		if p.Symbol == symbol {
+			return p.Price, nil
+		}
+	}
+	return "", nil
+}
+
+func (t *traderImp) audit(entry *db.AuditEntry, req, res interface{}, err error) {
+	entry.Request = marshalAudit(req)
+	entry.Response = marshalAudit(res)
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	log.Printf("trading action %s on %s by %s in account %s, test = %t, error = %q", entry.Action, entry.Symbol, entry.Username, entry.AccountID, entry.Test, entry.Error)
+	if err = t.db.AddAuditEntry(entry); err != nil {
+		log.Println("failed to write audit log entry: ", err)
+	}
+}
+
+func marshalAudit(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%q", err.Error())
+	}
+	return string(b)
+}
@@ -0,0 +1,124 @@
+package trader
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+)
+
+type fakeBinanceClient struct {
+	binance.Client
+	tested []*binance.OrderRequest
+	placed []*binance.OrderRequest
+}
+
+func (c *fakeBinanceClient) GetExchangeInfo(_ ...string) (*binance.ExchangeInfo, error) {
+	return &binance.ExchangeInfo{Symbols: []*binance.SymbolInfo{{
+		Symbol: "BTCUSDT",
+		Status: binance.SymbolStatusTrading,
+		Filters: []*binance.SymbolFilter{
+			{FilterType: binance.FilterTypePrice, TickSize: "0.01"},
+			{FilterType: binance.FilterTypeLotSize, MinQty: "0.00001", StepSize: "0.00001"},
+			{FilterType: binance.FilterTypeMinNotional, MinNotional: "10"},
+		},
+	}}}, nil
+}
+
+func (c *fakeBinanceClient) TestOrder(req *binance.OrderRequest) error {
+	c.tested = append(c.tested, req)
+	return nil
+}
+
+func (c *fakeBinanceClient) PlaceOrder(req *binance.OrderRequest) (*binance.BinanceOrder, error) {
+	c.placed = append(c.placed, req)
+	return &binance.BinanceOrder{Symbol: req.Symbol, OrderId: 1, Status: "NEW"}, nil
+}
+
+func (c *fakeBinanceClient) CancelOrder(_ string, _ int) (*binance.BinanceOrder, error) {
+	return nil, &binance.APIError{Code: -2011, Message: "Unknown order sent."}
+}
+
+func accounts(binClient binance.Client) []*binance.Account {
+	return []*binance.Account{{ID: "main", Client: binClient}}
+}
+
+func TestPlaceOrder(t *testing.T) {
+	binClient := &fakeBinanceClient{}
+	dbClient := db.NewMemoryClient()
+	tr := New(accounts(binClient), binance.NewExchangeInfoCache(binClient), dbClient)
+	req := &binance.OrderRequest{Symbol: "BTCUSDT", Side: binance.SideBuy, Type: binance.OrderTypeLimit, Quantity: "0.001", Price: "50000"}
+
+	order, err := tr.PlaceOrder("admin", "main", req, true)
+	if err != nil || order != nil || len(binClient.tested) != 1 || len(binClient.placed) != 0 {
+		t.Fatalf("test mode should only test the order: %v, %v, %d tested, %d placed", order, err, len(binClient.tested), len(binClient.placed))
+	}
+	if order, err = tr.PlaceOrder("admin", "main", req, false); err != nil || order.OrderId != 1 || len(binClient.placed) != 1 {
+		t.Fatalf("expected order to be placed: %v, %v", order, err)
+	}
+
+	entries, err := dbClient.GetAuditEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || !entries[0].Test || entries[1].Test || entries[1].Username != "admin" || entries[1].AccountID != "main" || entries[1].Response == "null" {
+		t.Fatalf("unexpected audit log %+v", entries)
+	}
+}
+
+func TestPlaceOrderRejectedByFilters(t *testing.T) {
+	binClient := &fakeBinanceClient{}
+	dbClient := db.NewMemoryClient()
+	_, err := New(accounts(binClient), binance.NewExchangeInfoCache(binClient), dbClient).PlaceOrder("admin", "main", &binance.OrderRequest{Symbol: "BTCUSDT", Side: binance.SideBuy, Type: binance.OrderTypeLimit, Quantity: "0.0001", Price: "50000.001"}, false)
+	errs, ok := err.(binance.FilterErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected tick size and notional errors, got %v", err)
+	}
+	if len(binClient.placed) != 0 {
+		t.Fatal("rejected order should not be sent to Binance")
+	}
+	entries, _ := dbClient.GetAuditEntries()
+	if len(entries) != 1 || entries[0].Error == "" {
+		t.Fatalf("rejected order should be audited, got %+v", entries)
+	}
+}
+
+func TestPlaceOCOTestModeTestsBothLegs(t *testing.T) {
+	binClient := &fakeBinanceClient{}
+	req := &binance.OCORequest{Symbol: "BTCUSDT", Side: binance.SideSell, Quantity: "0.001", Price: "60000", StopPrice: "45000", StopLimitPrice: "44900"}
+	if _, err := New(accounts(binClient), binance.NewExchangeInfoCache(binClient), db.NewMemoryClient()).PlaceOCO("admin", "main", req, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(binClient.tested) != 2 {
+		t.Fatalf("expected both legs to be tested, got %d", len(binClient.tested))
+	}
+}
+
+func TestCancelOrderAuditsFailure(t *testing.T) {
+	dbClient := db.NewMemoryClient()
+	binClient := &fakeBinanceClient{}
+	if _, err := New(accounts(binClient), binance.NewExchangeInfoCache(binClient), dbClient).CancelOrder("admin", "main", "BTCUSDT", 7); err == nil {
+		t.Fatal("expected Binance error")
+	}
+	entries, _ := dbClient.GetAuditEntries()
+	if len(entries) != 1 || entries[0].Action != db.AuditActionCancelOrder || entries[0].Request != `{"symbol":"BTCUSDT","orderId":7}` {
+		t.Fatalf("unexpected audit log %+v", entries)
+	}
+}
+
+func TestPlaceOrderUnknownAccount(t *testing.T) {
+	binClient := &fakeBinanceClient{}
+	dbClient := db.NewMemoryClient()
+	req := &binance.OrderRequest{Symbol: "BTCUSDT", Side: binance.SideBuy, Type: binance.OrderTypeLimit, Quantity: "0.001", Price: "50000"}
+	if _, err := New(accounts(binClient), binance.NewExchangeInfoCache(binClient), dbClient).PlaceOrder("admin", "sub", req, false); !errors.Is(err, ErrUnknownAccount) {
+		t.Fatalf("expected unknown account error, got %v", err)
+	}
+	if len(binClient.placed) != 0 {
+		t.Fatal("order should not be placed")
+	}
+	entries, _ := dbClient.GetAuditEntries()
+	if len(entries) != 1 || entries[0].AccountID != "sub" || entries[0].Error == "" {
+		t.Fatalf("rejected order should be audited, got %+v", entries)
+	}
+}
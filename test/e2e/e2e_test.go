@@ -0,0 +1,281 @@
+// Package e2e runs fetcher, checker and cron against the fake Binance server.
+package e2e
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/morzhanov/binance-orders-watcher/internal/binance"
+	"github.com/morzhanov/binance-orders-watcher/internal/binancetest"
+	"github.com/morzhanov/binance-orders-watcher/internal/checker"
+	"github.com/morzhanov/binance-orders-watcher/internal/cron"
+	"github.com/morzhanov/binance-orders-watcher/internal/db"
+	"github.com/morzhanov/binance-orders-watcher/internal/events"
+	"github.com/morzhanov/binance-orders-watcher/internal/exchange"
+	"github.com/morzhanov/binance-orders-watcher/internal/fetcher"
+)
+
+const accountID = "main"
+
+type sentAlert struct {
+	email string
+	text  string
+}
+
+type fakeAlertManager struct {
+	mu   sync.Mutex
+	sent []sentAlert
+	err  error
+}
+
+func (m *fakeAlertManager) SendAlert(toEmail, _, text string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, sentAlert{email: toEmail, text: text})
+	return m.err
+}
+
+func (m *fakeAlertManager) setErr(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.err = err
+}
+
+func (m *fakeAlertManager) alerts() []sentAlert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]sentAlert(nil), m.sent...)
+}
+
+type watcher struct {
+	server       *binancetest.Server
+	db           db.Client
+	fetcher      fetcher.Fetcher
+	checker      checker.Checker
+	alertManager *fakeAlertManager
+}
+
+func newWatcher(t *testing.T) *watcher {
+	s := binancetest.NewServer("key", "secret")
+	t.Cleanup(s.Close)
+	s.SetPrice("BTCUSDT", "45000")
+	s.SetPrice("ETHUSDT", "2500")
+	s.SetBalance("BTC", "0.01", "0.01")
+	s.SetBalance("USDT", "1000", "0")
+	s.AddOrder(&binance.BinanceOrder{Symbol: "BTCUSDT", Price: "40000", OrigQty: "0.01", Status: binancetest.OrderStatusFilled, Type: binance.OrderTypeLimit, Side: binance.SideBuy})
+
+	c := binance.New("key", "secret", s.URL)
+	dbClient := db.NewMemoryClient()
+	bus := events.NewBus()
+	alertManager := &fakeAlertManager{}
+	return &watcher{
+		server:       s,
+		db:           dbClient,
+		fetcher:      fetcher.New([]*exchange.Account{{ID: accountID, Driver: binance.NewDriver(c)}}, []*binance.Account{{ID: accountID, Client: c}}, dbClient, bus, fetcher.DefaultEventRetention),
+		checker:      checker.New(dbClient, alertManager, bus),
+		alertManager: alertManager,
+	}
+}
+
+func (w *watcher) addAlert(t *testing.T, symbol, price string) {
+	alert := &db.Alert{ID: symbol + price, AccountID: accountID, Type: db.AlertTypePrice, Symbol: symbol, Price: price, Email: "trader@example.com"}
+	if err := w.db.AddAlert(alert); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (w *watcher) fetchAndCheck(t *testing.T) {
+	_, prices, err := w.fetcher.FetchAccount(accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.checker.Check(prices); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (w *watcher) events(t *testing.T, eventType string) []*db.Event {
+	stored, _, err := w.db.GetEvents(&db.EventQuery{Types: []string{eventType}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stored
+}
+
+func TestFillsPriceMovesAndAlerts(t *testing.T) {
+	w := newWatcher(t)
+	sell := w.server.AddOrder(&binance.BinanceOrder{Symbol: "BTCUSDT", Price: "50000", OrigQty: "0.01", Status: binancetest.OrderStatusNew, Type: binance.OrderTypeLimit, Side: binance.SideSell})
+	oco := w.server.AddOCO(&binance.OCORequest{Symbol: "ETHUSDT", Side: binance.SideSell, Quantity: "1", Price: "3000", StopPrice: "2000", StopLimitPrice: "1990"})
+	w.addAlert(t, "BTCUSDT", "48000")
+
+	w.fetchAndCheck(t)
+	orders, err := w.db.GetOrders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 3 {
+		t.Fatalf("expected the sell order and both OCO legs, got %+v", orders)
+	}
+	for _, o := range orders {
+		if o.OrderID == sell.OrderId && (o.LastOrderPrice != "40000" || o.PercentCompleted != "50") {
+			t.Fatalf("unexpected sell order progress %+v", o)
+		}
+	}
+	if lists, _ := w.db.GetOrderLists(); len(lists) != 1 || lists[0].TriggerDistance != "20.00" {
+		t.Fatalf("unexpected order lists %+v", lists)
+	}
+	if sent := w.alertManager.alerts(); len(sent) != 0 {
+		t.Fatalf("no alert should be sent yet, got %+v", sent)
+	}
+
+	w.server.Run(
+		binancetest.MovePrice("BTCUSDT", "48000"),
+		binancetest.MovePrice("ETHUSDT", "3000"),
+		binancetest.FillOrder(oco.Orders[0].OrderID),
+	)
+	w.fetchAndCheck(t)
+	sent := w.alertManager.alerts()
+	if len(sent) != 1 || !strings.Contains(sent[0].text, "BTCUSDT") {
+		t.Fatalf("expected one BTCUSDT alert, got %+v", sent)
+	}
+	if alerts, _ := w.db.GetAlerts(); len(alerts) != 0 {
+		t.Fatalf("triggered alert should be deleted, got %+v", alerts)
+	}
+	if orders, _ = w.db.GetOrders(); len(orders) != 1 || orders[0].OrderID != sell.OrderId {
+		t.Fatalf("only the sell order should be open, got %+v", orders)
+	}
+	done := w.events(t, db.EventTypeOrderListDone)
+	if len(done) != 1 || !strings.Contains(done[0].Message, "LIMIT_MAKER SELL at 3000 FILLED, STOP_LOSS_LIMIT SELL at 2000 CANCELED") {
+		t.Fatalf("expected a single order list event, got %+v", done)
+	}
+
+	w.fetchAndCheck(t)
+	if sent = w.alertManager.alerts(); len(sent) != 1 {
+		t.Fatalf("alert should be sent once, got %+v", sent)
+	}
+}
+
+func TestFetchErrorsKeepStoredData(t *testing.T) {
+	w := newWatcher(t)
+	w.server.AddOrder(&binance.BinanceOrder{Symbol: "BTCUSDT", Price: "50000", OrigQty: "0.01", Status: binancetest.OrderStatusNew, Type: binance.OrderTypeLimit, Side: binance.SideSell})
+	w.fetchAndCheck(t)
+
+	w.server.Run(binancetest.RateLimit("/api/v3/openOrders"))
+	_, _, err := w.fetcher.FetchAccount(accountID)
+	var apiErr *binance.APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusTooManyRequests {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+	w.server.Run(binancetest.Fail("/api/v3/account", http.StatusBadRequest, -2015, "Invalid API-key, IP, or permissions for action."))
+	if _, _, err = w.fetcher.FetchAccount(accountID); !errors.As(err, &apiErr) || apiErr.Code != -2015 {
+		t.Fatalf("expected account error, got %v", err)
+	}
+	if orders, _ := w.db.GetOrders(); len(orders) != 1 {
+		t.Fatalf("stored orders should be kept after failed fetches, got %+v", orders)
+	}
+	if failed := w.events(t, db.EventTypeFetchFailed); len(failed) != 2 {
+		t.Fatalf("expected two fetch_failed events, got %+v", failed)
+	}
+
+	w.fetchAndCheck(t)
+	if completed := w.events(t, db.EventTypeFetchCompleted); len(completed) != 2 {
+		t.Fatalf("fetching should recover, got %+v", completed)
+	}
+}
+
+func TestCronFetchesUntilCheckFails(t *testing.T) {
+	w := newWatcher(t)
+	w.addAlert(t, "BTCUSDT", "46000")
+	errStop := errors.New("stop")
+	w.alertManager.err = errStop
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cron.New(w.fetcher, w.checker, map[string]time.Duration{accountID: 10 * time.Millisecond}).Run()
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for w.server.Requests("/api/v3/openOrders") < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("cron did not fetch the account")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if sent := w.alertManager.alerts(); len(sent) != 0 {
+		t.Fatalf("no alert should be sent before the price move, got %+v", sent)
+	}
+	w.server.Run(binancetest.MovePrice("BTCUSDT", "46500"))
+
+	select {
+	case err := <-done:
+		if err != errStop {
+			t.Fatalf("expected alert manager error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cron did not check the alert")
+	}
+	if sent := w.alertManager.alerts(); len(sent) != 1 || sent[0].email != "trader@example.com" {
+		t.Fatalf("expected one alert, got %+v", sent)
+	}
+}
+
+func TestCronKeepsRunningAfterFailedFetches(t *testing.T) {
+	w := newWatcher(t)
+	w.addAlert(t, "BTCUSDT", "46000")
+	// every fetch fails at another request until the fourth one, the price request failure leaves cron without prices
+	w.server.Run(
+		binancetest.RateLimit("/api/v3/openOrders"),
+		binancetest.Fail("/api/v3/account", http.StatusBadRequest, binancetest.CodeInvalidAPIKey, "Invalid API-key, IP, or permissions for action."),
+		binancetest.RateLimit("/api/v3/ticker/price"),
+	)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cron.New(w.fetcher, w.checker, map[string]time.Duration{accountID: 10 * time.Millisecond}).Run()
+	}()
+	wait := func(what string, ok func() bool) {
+		deadline := time.Now().Add(5 * time.Second)
+		for !ok() {
+			select {
+			case err := <-done:
+				t.Fatalf("cron stopped while waiting for %s: %v", what, err)
+			default:
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	wait("the fetches to recover", func() bool {
+		return len(w.events(t, db.EventTypeFetchFailed)) == 3 && len(w.events(t, db.EventTypeFetchCompleted)) > 0
+	})
+	if sent := w.alertManager.alerts(); len(sent) != 0 {
+		t.Fatalf("no alert should be sent before the price move, got %+v", sent)
+	}
+	w.server.Run(binancetest.MovePrice("BTCUSDT", "46500"))
+	wait("the alert", func() bool {
+		return len(w.alertManager.alerts()) == 1
+	})
+	if alerts, _ := w.db.GetAlerts(); len(alerts) != 0 {
+		t.Fatalf("triggered alert should be deleted, got %+v", alerts)
+	}
+
+	// cron only stops when alerts can't be sent
+	errStop := errors.New("stop")
+	w.alertManager.setErr(errStop)
+	w.addAlert(t, "ETHUSDT", "2400")
+	select {
+	case err := <-done:
+		if err != errStop {
+			t.Fatalf("expected alert manager error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cron did not check the second alert")
+	}
+}
@@ -40,8 +40,14 @@ func main() {
 
 	uri := fmt.Sprintf("%s/api/v3/openOrders?%s&signature=%s", prodURI, query, sha)
 	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
 	req.Header[apiKeyHeaderName] = []string{apiKey}
 	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal(err)
+	}
 	defer res.Body.Close()
 
 	bodyBytes, err := io.ReadAll(res.Body)